@@ -37,6 +37,8 @@
       const SESSION_EXPIRY_MS = 1800000; // 30 minutes
       const VISITOR_EXPIRY_MS = 2592000000; // 30 days
       const BATCH_DELAY = 100;
+      const MAX_QUEUE_SIZE = 200;
+      const DEFAULT_RETRY_AFTER = 10; // seconds
 
       // State variables
       let visitorId = getOrCreateId(VISITOR_ID_KEY, VISITOR_EXPIRY_MS);
@@ -51,6 +53,7 @@
       // Event batching
       const eventQueue = [];
       let flushTimeout = null;
+      let retryAt = 0; // set when the server throttles us
 
       // --- Core Functions ---
 
@@ -107,6 +110,8 @@
       // --- Event Batching ---
 
       function queueEvent(event) {
+        // Drop the oldest events if the server has been throttling for long
+        if (eventQueue.length >= MAX_QUEUE_SIZE) eventQueue.shift();
         eventQueue.push(event);
 
         if (!flushTimeout) {
@@ -114,11 +119,18 @@
         }
       }
 
-      async function flushEventQueue() {
+      async function flushEventQueue(unloading = false) {
+        flushTimeout = null;
         if (eventQueue.length === 0) return;
 
+        // Hold events back while the server asked us to wait
+        const wait = retryAt - Date.now();
+        if (wait > 0 && !unloading) {
+          flushTimeout = setTimeout(flushEventQueue, wait);
+          return;
+        }
+
         const events = eventQueue.splice(0);
-        flushTimeout = null;
 
         try {
           const batchData = {
@@ -127,22 +139,46 @@
             events
           };
 
-          if (nav.sendBeacon) {
+          // The page may be gone before a response arrives, so use a beacon
+          if ((unloading || !win.fetch) && nav.sendBeacon) {
             const blob = new Blob([JSON.stringify(batchData)], { type: 'application/json' });
             nav.sendBeacon(API_ENDPOINT, blob);
-          } else {
-            await fetch(API_ENDPOINT, {
-              method: 'POST',
-              headers: { 'Content-Type': 'application/json' },
-              body: JSON.stringify(batchData),
-              keepalive: true
-            });
+            return;
+          }
+
+          const response = await fetch(API_ENDPOINT, {
+            method: 'POST',
+            headers: { 'Content-Type': 'application/json' },
+            body: JSON.stringify(batchData),
+            keepalive: true
+          });
+          if (response.status === 201 || response.status === 429) {
+            requeueThrottled(events, await response.json());
           }
         } catch (error) {
           if (DEBUG) console.warn('Seentics: Failed to send events', error);
         }
       }
 
+      // Puts events the server throttled back in the queue and waits for the
+      // time it asked for before sending again
+      function requeueThrottled(events, result) {
+        const throttled = (result.results || [])
+          .filter(r => r.status === 'throttled' && events[r.index])
+          .map(r => events[r.index]);
+        if (throttled.length === 0) return;
+
+        const retryAfter = (result.queue && result.queue.retry_after) || DEFAULT_RETRY_AFTER;
+        retryAt = Date.now() + retryAfter * 1000;
+        if (DEBUG) console.warn(`Seentics: ${throttled.length} events throttled, retrying in ${retryAfter}s`);
+
+        const room = Math.max(0, MAX_QUEUE_SIZE - eventQueue.length);
+        eventQueue.unshift(...throttled.slice(0, room));
+        if (!flushTimeout) {
+          flushTimeout = setTimeout(flushEventQueue, retryAfter * 1000);
+        }
+      }
+
       // --- Custom Event Tracking ---
 
       function trackCustomEvent(eventName, properties = {}) {
@@ -214,6 +250,8 @@
           user_agent: nav.userAgent,
           time_on_page: timeOnPage,
           timestamp: new Date().toISOString(),
+          // Automated browsers expose navigator.webdriver; used for bot detection
+          properties: nav.webdriver ? { webdriver: true } : undefined,
           ...utmParams
         };
 
@@ -279,7 +317,7 @@
 
         win.addEventListener('beforeunload', () => {
           if (!pageviewSent) sendPageview();
-          if (eventQueue.length > 0) flushEventQueue();
+          if (eventQueue.length > 0) flushEventQueue(true);
         });
 
         ['click', 'keydown', 'scroll', 'mousemove', 'touchstart'].forEach(evt => {
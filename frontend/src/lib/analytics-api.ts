@@ -111,6 +111,10 @@ export interface CountryStat {
   views: number;
   unique: number;
   bounce_rate?: number;
+  currency?: string;
+  orders?: number;
+  revenue?: number;
+  average_order_value?: number;
 }
 
 export interface BrowserStat {
@@ -250,6 +254,7 @@ export interface TrafficSummary {
   returning_visitors: number;
   engagement_score: number;
   retention_rate: number;
+  retention_days: number;
   top_traffic_sources: Array<{
     source: string;
     visitors: number;
@@ -327,18 +332,25 @@ export interface GetHourlyStatsResponse {
 
 export interface GetActivityTrendsResponse {
   website_id: string;
+  date_range: string;
+  previous_range: string;
+  granularity: 'hour' | 'day' | 'week' | 'month';
+  timezone: string;
   trends: ActivityTrend[];
 }
 
 export interface ActivityTrend {
-  hour: number;
-  timestamp: string;
-  time: string;
-  users: number;
-  events: number;
+  period: string;
+  previous_period?: string;
+  page_views: number;
+  visitors: number;
   sessions: number;
-  pageViews: number;
-  engagement: number;
+  previous_page_views: number;
+  previous_visitors: number;
+  previous_sessions: number;
+  page_views_change: number | null;
+  visitors_change: number | null;
+  sessions_change: number | null;
 }
 
 export interface GetDailyStatsResponse {
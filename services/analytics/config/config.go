@@ -4,6 +4,7 @@ import (
 	"errors"
 	"os"
 	"strconv"
+	"time"
 )
 
 type Config struct {
@@ -12,6 +13,56 @@ type Config struct {
 	DatabaseURL string
 	LogLevel    string
 	JWTSecret   string
+
+	// Geolocation
+	GeoIPDBPath         string
+	GeoIPASNDBPath      string
+	GeoIPHTTPFallback   bool
+	GeoIPCacheSize      int
+	GeoIPReloadInterval time.Duration
+
+	// Bot detection
+	BotMaxEventsPerMinute int
+
+	// Ingestion queue
+	EventQueueMaxBacklog int
+
+	// Server-side sessions
+	SessionStitching       bool
+	SessionTimeout         time.Duration
+	SessionTimezone        string
+	SessionSplitOnCampaign bool
+
+	// Live metrics push interval
+	LiveInterval time.Duration
+
+	// Background exports
+	ExportStorage     string
+	ExportDir         string
+	ExportWorkers     int
+	ExportS3Endpoint  string
+	ExportS3Region    string
+	ExportS3Bucket    string
+	ExportS3AccessKey string
+	ExportS3SecretKey string
+	ExportS3UseSSL    bool
+
+	// Largest historical data file accepted for import, in bytes
+	ImportMaxBytes int
+
+	// Email delivery; without an SMTP host emails are written to MailDir
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+	MailDir      string
+
+	// How often scheduled reports are checked for due runs
+	ReportCheckInterval time.Duration
+
+	// How often alert rules are checked for a newly completed hour
+	AlertCheckInterval time.Duration
 }
 
 func Load() (*Config, error) {
@@ -24,6 +75,45 @@ func Load() (*Config, error) {
 		DatabaseURL: getEnvOrDefault("DATABASE_URL", ""),
 		LogLevel:    getEnvOrDefault("LOG_LEVEL", "info"),
 		JWTSecret:   getEnvOrDefault("JWT_SECRET", ""),
+
+		GeoIPDBPath:         getEnvOrDefault("GEOIP_DB_PATH", ""),
+		GeoIPASNDBPath:      getEnvOrDefault("GEOIP_ASN_DB_PATH", ""),
+		GeoIPHTTPFallback:   GetEnvAsBool("GEOIP_HTTP_FALLBACK", false),
+		GeoIPCacheSize:      GetEnvAsInt("GEOIP_CACHE_SIZE", 100000),
+		GeoIPReloadInterval: GetEnvAsDuration("GEOIP_RELOAD_INTERVAL", time.Hour),
+
+		BotMaxEventsPerMinute: GetEnvAsInt("BOT_MAX_EVENTS_PER_MINUTE", 120),
+
+		EventQueueMaxBacklog: GetEnvAsInt("EVENT_QUEUE_MAX_BACKLOG", 1000000),
+
+		SessionStitching:       GetEnvAsBool("SESSION_STITCHING", true),
+		SessionTimeout:         GetEnvAsDuration("SESSION_TIMEOUT", 30*time.Minute),
+		SessionTimezone:        getEnvOrDefault("SESSION_TIMEZONE", "UTC"),
+		SessionSplitOnCampaign: GetEnvAsBool("SESSION_SPLIT_ON_CAMPAIGN", true),
+
+		LiveInterval: GetEnvAsDuration("LIVE_INTERVAL", 5*time.Second),
+
+		ExportStorage:     getEnvOrDefault("EXPORT_STORAGE", "local"),
+		ExportDir:         getEnvOrDefault("EXPORT_DIR", "./exports"),
+		ExportWorkers:     GetEnvAsInt("EXPORT_WORKERS", 2),
+		ExportS3Endpoint:  getEnvOrDefault("EXPORT_S3_ENDPOINT", ""),
+		ExportS3Region:    getEnvOrDefault("EXPORT_S3_REGION", ""),
+		ExportS3Bucket:    getEnvOrDefault("EXPORT_S3_BUCKET", ""),
+		ExportS3AccessKey: getEnvOrDefault("EXPORT_S3_ACCESS_KEY", ""),
+		ExportS3SecretKey: getEnvOrDefault("EXPORT_S3_SECRET_KEY", ""),
+		ExportS3UseSSL:    GetEnvAsBool("EXPORT_S3_USE_SSL", true),
+
+		ImportMaxBytes: GetEnvAsInt("IMPORT_MAX_BYTES", 1<<30),
+
+		SMTPHost:     getEnvOrDefault("SMTP_HOST", ""),
+		SMTPPort:     GetEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername: getEnvOrDefault("SMTP_USERNAME", ""),
+		SMTPPassword: getEnvOrDefault("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnvOrDefault("SMTP_FROM", "Seentics <reports@localhost>"),
+		MailDir:      getEnvOrDefault("MAIL_DIR", "./mail"),
+
+		ReportCheckInterval: GetEnvAsDuration("REPORT_CHECK_INTERVAL", time.Minute),
+		AlertCheckInterval:  GetEnvAsDuration("ALERT_CHECK_INTERVAL", 5*time.Minute),
 	}
 
 	// Validate required fields for production
@@ -66,3 +156,12 @@ func GetEnvAsInt(key string, defaultValue int) int {
 	}
 	return defaultValue
 }
+
+func GetEnvAsDuration(key string, defaultValue time.Duration) time.Duration {
+	if value := os.Getenv(key); value != "" {
+		if parsed, err := time.ParseDuration(value); err == nil {
+			return parsed
+		}
+	}
+	return defaultValue
+}
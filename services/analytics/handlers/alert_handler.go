@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type AlertHandler struct {
+	service *services.AlertService
+	logger  zerolog.Logger
+}
+
+func NewAlertHandler(service *services.AlertService, logger zerolog.Logger) *AlertHandler {
+	return &AlertHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *AlertHandler) CreateRule(c *gin.Context) {
+	var req models.CreateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind alert rule data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid alert rule data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	rule, err := h.service.CreateRule(c.Request.Context(), &req)
+	if errors.Is(err, services.ErrInvalidAlertRule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to create alert rule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"rule": rule})
+}
+
+func (h *AlertHandler) GetRules(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	rules, err := h.service.GetRules(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get alert rules")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alert rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rules": rules})
+}
+
+func (h *AlertHandler) GetRule(c *gin.Context) {
+	ruleID, err := uuid.Parse(c.Param("rule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule ID"})
+		return
+	}
+
+	rule, err := h.service.GetRule(c.Request.Context(), ruleID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get alert rule")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Alert rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rule": rule})
+}
+
+func (h *AlertHandler) UpdateRule(c *gin.Context) {
+	ruleID, err := uuid.Parse(c.Param("rule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule ID"})
+		return
+	}
+
+	var req models.UpdateAlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind alert rule update data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid alert rule data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	rule, err := h.service.UpdateRule(c.Request.Context(), ruleID, &req)
+	if errors.Is(err, services.ErrInvalidAlertRule) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to update alert rule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"rule": rule})
+}
+
+func (h *AlertHandler) DeleteRule(c *gin.Context) {
+	ruleID, err := uuid.Parse(c.Param("rule_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule ID"})
+		return
+	}
+
+	err = h.service.DeleteRule(c.Request.Context(), ruleID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to delete alert rule")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete alert rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// GetAlerts returns a website's triggered alerts for hours in the date
+// range, latest first, optionally filtered by rule_id
+func (h *AlertHandler) GetAlerts(c *gin.Context) {
+	websiteID := c.Param("website_id")
+
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var ruleID *uuid.UUID
+	if value := c.Query("rule_id"); value != "" {
+		parsed, err := uuid.Parse(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid alert rule ID"})
+			return
+		}
+		ruleID = &parsed
+	}
+
+	limit := services.DefaultAlertHistoryLimit
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
+		}
+	}
+
+	alerts, err := h.service.GetAlerts(c.Request.Context(), websiteID, dateRange, ruleID, limit)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get alerts")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get alerts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"alerts": alerts})
+}
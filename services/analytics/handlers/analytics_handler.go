@@ -1,15 +1,44 @@
 package handlers
 
 import (
+	"analytics-app/models"
 	"analytics-app/services"
+	"analytics-app/utils"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 )
 
+// Window of the traffic summary's retention rate
+const (
+	defaultRetentionDays = 7
+	maxRetentionDays     = 90
+)
+
+// Number of periods after the first tracked for each cohort
+const (
+	defaultCohortPeriods = 8
+	maxCohortPeriods     = 52
+)
+
+// Page sizes of the session listing and of visitor timelines
+const (
+	defaultSessionsLimit = 50
+	maxSessionsLimit     = 500
+	defaultTimelineLimit = 500
+	maxTimelineLimit     = 5000
+)
+
+// maxSelectedMetrics caps the custom metrics one request reports
+const maxSelectedMetrics = 10
+
 type AnalyticsHandler struct {
 	service *services.AnalyticsService
 	logger  zerolog.Logger
@@ -29,14 +58,25 @@ func (h *AnalyticsHandler) GetDashboard(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	data, err := h.service.GetDashboard(c.Request.Context(), websiteID, days)
+	metrics, err := h.parseCustomMetrics(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	data, err := h.service.GetDashboard(c.Request.Context(), websiteID, dateRange, filters, metrics)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get dashboard data")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get dashboard data"})
@@ -53,11 +93,22 @@ func (h *AnalyticsHandler) GetTopPages(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goal, err := h.parseGoal(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	limit := 10
@@ -67,7 +118,13 @@ func (h *AnalyticsHandler) GetTopPages(c *gin.Context) {
 		}
 	}
 
-	pages, err := h.service.GetTopPages(c.Request.Context(), websiteID, days, limit)
+	metrics, err := h.parseCustomMetrics(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pages, err := h.service.GetTopPages(c.Request.Context(), websiteID, dateRange, filters, limit, goal, metrics)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top pages")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top pages"})
@@ -76,7 +133,7 @@ func (h *AnalyticsHandler) GetTopPages(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id": websiteID,
-		"date_range": fmt.Sprintf("%d days", days),
+		"date_range": dateRange.Label(),
 		"top_pages":  pages,
 	})
 }
@@ -94,15 +151,20 @@ func (h *AnalyticsHandler) GetPageUTMBreakdown(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Get page UTM breakdown from repository
-	breakdown, err := h.service.GetPageUTMBreakdown(c.Request.Context(), websiteID, pagePath, days)
+	breakdown, err := h.service.GetPageUTMBreakdown(c.Request.Context(), websiteID, pagePath, dateRange, filters)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get page UTM breakdown")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get page UTM breakdown"})
@@ -119,11 +181,22 @@ func (h *AnalyticsHandler) GetTopReferrers(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goal, err := h.parseGoal(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	limit := 10
@@ -133,7 +206,13 @@ func (h *AnalyticsHandler) GetTopReferrers(c *gin.Context) {
 		}
 	}
 
-	referrers, err := h.service.GetTopReferrers(c.Request.Context(), websiteID, days, limit)
+	metrics, err := h.parseCustomMetrics(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	referrers, err := h.service.GetTopReferrers(c.Request.Context(), websiteID, dateRange, filters, limit, goal, metrics)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top referrers")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top referrers"})
@@ -142,7 +221,7 @@ func (h *AnalyticsHandler) GetTopReferrers(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id":    websiteID,
-		"date_range":    fmt.Sprintf("%d days", days),
+		"date_range":    dateRange.Label(),
 		"top_referrers": referrers,
 	})
 }
@@ -154,11 +233,22 @@ func (h *AnalyticsHandler) GetTopSources(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goal, err := h.parseGoal(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	limit := 10
@@ -168,7 +258,13 @@ func (h *AnalyticsHandler) GetTopSources(c *gin.Context) {
 		}
 	}
 
-	sources, err := h.service.GetTopSources(c.Request.Context(), websiteID, days, limit)
+	metrics, err := h.parseCustomMetrics(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	sources, err := h.service.GetTopSources(c.Request.Context(), websiteID, dateRange, filters, limit, goal, metrics)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top sources")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top sources"})
@@ -176,8 +272,8 @@ func (h *AnalyticsHandler) GetTopSources(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"website_id":   websiteID,
-		"date_range":   fmt.Sprintf("%d days", days),
+		"website_id":  websiteID,
+		"date_range":  dateRange.Label(),
 		"top_sources": sources,
 	})
 }
@@ -189,11 +285,22 @@ func (h *AnalyticsHandler) GetTopCountries(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goal, err := h.parseGoal(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	limit := 10
@@ -203,7 +310,13 @@ func (h *AnalyticsHandler) GetTopCountries(c *gin.Context) {
 		}
 	}
 
-	countries, err := h.service.GetTopCountries(c.Request.Context(), websiteID, days, limit)
+	metrics, err := h.parseCustomMetrics(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	countries, err := h.service.GetTopCountries(c.Request.Context(), websiteID, dateRange, filters, limit, goal, metrics)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top countries")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top countries"})
@@ -212,7 +325,7 @@ func (h *AnalyticsHandler) GetTopCountries(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id":    websiteID,
-		"date_range":    fmt.Sprintf("%d days", days),
+		"date_range":    dateRange.Label(),
 		"top_countries": countries,
 	})
 }
@@ -224,11 +337,22 @@ func (h *AnalyticsHandler) GetTopBrowsers(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goal, err := h.parseGoal(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	limit := 10
@@ -238,7 +362,13 @@ func (h *AnalyticsHandler) GetTopBrowsers(c *gin.Context) {
 		}
 	}
 
-	browsers, err := h.service.GetTopBrowsers(c.Request.Context(), websiteID, days, limit)
+	metrics, err := h.parseCustomMetrics(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	browsers, err := h.service.GetTopBrowsers(c.Request.Context(), websiteID, dateRange, filters, limit, goal, metrics)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top browsers")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top browsers"})
@@ -247,25 +377,77 @@ func (h *AnalyticsHandler) GetTopBrowsers(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id":   websiteID,
-		"date_range":   fmt.Sprintf("%d days", days),
+		"date_range":   dateRange.Label(),
 		"top_browsers": browsers,
 	})
 }
 
-func (h *AnalyticsHandler) GetTopDevices(c *gin.Context) {
+// GetBotTraffic returns traffic flagged as bots, broken down by bot name
+func (h *AnalyticsHandler) GetBotTraffic(c *gin.Context) {
 	websiteID := c.Param("website_id")
 	if websiteID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := 20
+	if l := c.Query("limit"); l != "" {
+		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
+			limit = parsedLimit
 		}
 	}
 
+	report, err := h.service.GetBotTraffic(c.Request.Context(), websiteID, dateRange, filters, limit)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get bot traffic")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get bot traffic"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"website_id": websiteID,
+		"date_range": dateRange.Label(),
+		"bots":       report,
+	})
+}
+
+func (h *AnalyticsHandler) GetTopDevices(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goal, err := h.parseGoal(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	limit := 10
 	if l := c.Query("limit"); l != "" {
 		if parsedLimit, err := strconv.Atoi(l); err == nil && parsedLimit > 0 {
@@ -273,7 +455,13 @@ func (h *AnalyticsHandler) GetTopDevices(c *gin.Context) {
 		}
 	}
 
-	devices, err := h.service.GetTopDevices(c.Request.Context(), websiteID, days, limit)
+	metrics, err := h.parseCustomMetrics(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	devices, err := h.service.GetTopDevices(c.Request.Context(), websiteID, dateRange, filters, limit, goal, metrics)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top devices")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top devices"})
@@ -282,7 +470,7 @@ func (h *AnalyticsHandler) GetTopDevices(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id":  websiteID,
-		"date_range":  fmt.Sprintf("%d days", days),
+		"date_range":  dateRange.Label(),
 		"top_devices": devices,
 	})
 }
@@ -294,11 +482,22 @@ func (h *AnalyticsHandler) GetTopOS(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goal, err := h.parseGoal(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	limit := 10
@@ -308,7 +507,13 @@ func (h *AnalyticsHandler) GetTopOS(c *gin.Context) {
 		}
 	}
 
-	osList, err := h.service.GetTopOS(c.Request.Context(), websiteID, days, limit)
+	metrics, err := h.parseCustomMetrics(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	osList, err := h.service.GetTopOS(c.Request.Context(), websiteID, dateRange, filters, limit, goal, metrics)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get top OS")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get top OS"})
@@ -317,7 +522,7 @@ func (h *AnalyticsHandler) GetTopOS(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id": websiteID,
-		"date_range": fmt.Sprintf("%d days", days),
+		"date_range": dateRange.Label(),
 		"top_os":     osList,
 	})
 }
@@ -329,14 +534,28 @@ func (h *AnalyticsHandler) GetTrafficSummary(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	retentionDays := defaultRetentionDays
+	if d := c.Query("retention_days"); d != "" {
+		retentionDays, err = strconv.Atoi(d)
+		if err != nil || retentionDays < 1 || retentionDays > maxRetentionDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("retention_days must be between 1 and %d", maxRetentionDays)})
+			return
 		}
 	}
 
-	summary, err := h.service.GetTrafficSummary(c.Request.Context(), websiteID, days)
+	summary, err := h.service.GetTrafficSummary(c.Request.Context(), websiteID, dateRange, filters, retentionDays)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get traffic summary")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get traffic summary"})
@@ -353,14 +572,25 @@ func (h *AnalyticsHandler) GetDailyStats(c *gin.Context) {
 		return
 	}
 
-	days := 30
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 30)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	stats, err := h.service.GetDailyStats(c.Request.Context(), websiteID, days)
+	metrics, err := h.parseCustomMetrics(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	stats, err := h.service.GetDailyStats(c.Request.Context(), websiteID, dateRange, filters, metrics)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get daily stats")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get daily stats"})
@@ -369,7 +599,7 @@ func (h *AnalyticsHandler) GetDailyStats(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id":  websiteID,
-		"date_range":  fmt.Sprintf("%d days", days),
+		"date_range":  dateRange.Label(),
 		"daily_stats": stats,
 	})
 }
@@ -381,17 +611,29 @@ func (h *AnalyticsHandler) GetHourlyStats(c *gin.Context) {
 		return
 	}
 
-	// Get timezone from query parameter, default to UTC
-	timezone := c.Query("timezone")
-	if timezone == "" {
-		timezone = "UTC"
+	// Hourly stats default to the last 24 hours
+	dateRange, err := parseDateRange(c, 1)
+	if err == nil {
+		err = utils.CheckHourlyRange(dateRange)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	// Debug logging
-	fmt.Printf("DEBUG Handler: timezone=%s\n", timezone)
+	metrics, err := h.parseCustomMetrics(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	// Always fetch last 24 hours for hourly stats
-	stats, err := h.service.GetHourlyStats(c.Request.Context(), websiteID, 1, timezone)
+	stats, err := h.service.GetHourlyStats(c.Request.Context(), websiteID, dateRange, filters, metrics)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get hourly stats")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get hourly stats"})
@@ -400,8 +642,8 @@ func (h *AnalyticsHandler) GetHourlyStats(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{
 		"website_id":   websiteID,
-		"date_range":   "24 hours",
-		"timezone":     timezone,
+		"date_range":   dateRange.Label(),
+		"timezone":     dateRange.Timezone,
 		"hourly_stats": stats,
 	})
 }
@@ -413,21 +655,32 @@ func (h *AnalyticsHandler) GetCustomEvents(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goal, err := h.parseGoal(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
 	// Get custom events data from repository
-	customEvents, err := h.service.GetCustomEvents(c.Request.Context(), websiteID, days)
+	customEvents, err := h.service.GetCustomEvents(c.Request.Context(), websiteID, dateRange, filters)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get custom events")
 		// Return empty data in the format the frontend expects
 		c.JSON(http.StatusOK, gin.H{
 			"website_id":    websiteID,
-			"date_range":    fmt.Sprintf("%d days", days),
+			"date_range":    dateRange.Label(),
 			"top_events":    []interface{}{},
 			"timeseries":    []interface{}{},
 			"total_events":  0,
@@ -448,12 +701,12 @@ func (h *AnalyticsHandler) GetCustomEvents(c *gin.Context) {
 	}
 
 	// Get UTM performance data for this website
-	utmData, _ := h.service.GetUTMAnalytics(c.Request.Context(), websiteID, days)
+	utmData, _ := h.service.GetUTMAnalytics(c.Request.Context(), websiteID, dateRange, filters, goal)
 
 	// Transform the data to match frontend expectations
 	transformedEvents := gin.H{
 		"website_id":      websiteID,
-		"date_range":      fmt.Sprintf("%d days", days),
+		"date_range":      dateRange.Label(),
 		"top_events":      customEvents,
 		"timeseries":      []interface{}{}, // TODO: Add timeseries data
 		"total_events":    totalEvents,
@@ -464,6 +717,7 @@ func (h *AnalyticsHandler) GetCustomEvents(c *gin.Context) {
 	c.JSON(http.StatusOK, transformedEvents)
 }
 
+// GetActivityTrends returns activity per bucket next to the previous period
 func (h *AnalyticsHandler) GetActivityTrends(c *gin.Context) {
 	websiteID := c.Param("website_id")
 	if websiteID == "" {
@@ -471,18 +725,267 @@ func (h *AnalyticsHandler) GetActivityTrends(c *gin.Context) {
 		return
 	}
 
-	// For now, return a basic response since the service method doesn't exist yet
-	// This will be enhanced when the service method is implemented
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	granularity := c.Query("granularity")
+	if granularity == "" {
+		granularity = utils.DefaultTrendGranularity(dateRange)
+	}
+
+	trends, err := h.service.GetActivityTrends(c.Request.Context(), websiteID, dateRange, filters, granularity)
+	if errors.Is(err, services.ErrInvalidTrends) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get activity trends")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get activity trends"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"website_id":     websiteID,
+		"date_range":     dateRange.Label(),
+		"previous_range": trends.PreviousRange,
+		"granularity":    trends.Granularity,
+		"timezone":       trends.Timezone,
+		"trends":         trends.Buckets,
+	})
+}
+
+// GetCohorts returns a retention matrix of visitors grouped by the week or
+// month of their first visit, or of their first occurrence of cohort_event
+func (h *AnalyticsHandler) GetCohorts(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	dateRange, err := parseDateRange(c, 90)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	granularity := c.DefaultQuery("period", models.GranularityWeek)
+	if granularity != models.GranularityWeek && granularity != models.GranularityMonth {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "period must be week or month"})
+		return
+	}
+
+	periods := defaultCohortPeriods
+	if p := c.Query("periods"); p != "" {
+		periods, err = strconv.Atoi(p)
+		if err != nil || periods < 1 || periods > maxCohortPeriods {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("periods must be between 1 and %d", maxCohortPeriods)})
+			return
+		}
+	}
+
+	cohortEvent := c.Query("cohort_event")
+	if len(cohortEvent) > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cohort_event exceeds 100 characters"})
+		return
+	}
+
+	report, err := h.service.GetCohorts(c.Request.Context(), websiteID, dateRange, filters, granularity, cohortEvent, periods)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get cohorts")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get cohorts"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"website_id":   websiteID,
+		"date_range":   dateRange.Label(),
+		"granularity":  report.Granularity,
+		"cohort_event": report.CohortEvent,
+		"periods":      report.Periods,
+		"timezone":     report.Timezone,
+		"cohorts":      report.Cohorts,
+	})
+}
+
+// GetAttribution credits a goal's conversions to the UTM campaigns or
+// sources of the visitors' sessions before converting
+func (h *AnalyticsHandler) GetAttribution(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	dateRange, err := parseDateRange(c, 30)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	goal, err := h.parseGoal(c, websiteID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if goal == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "goal_id is required"})
+		return
+	}
+
+	dimension := c.DefaultQuery("dimension", "utm_source")
+	if !models.AttributionDimensions[dimension] {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dimension must be utm_source, utm_medium, utm_campaign, source or referrer"})
+		return
+	}
+
+	lookbackDays := utils.DefaultAttributionLookbackDays
+	if l := c.Query("lookback_days"); l != "" {
+		lookbackDays, err = strconv.Atoi(l)
+		if err != nil || lookbackDays < 1 || lookbackDays > utils.MaxAttributionLookbackDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("lookback_days must be between 1 and %d", utils.MaxAttributionLookbackDays)})
+			return
+		}
+	}
+
+	halfLife := utils.DefaultAttributionHalfLife
+	if hl := c.Query("half_life_days"); hl != "" {
+		days, err := strconv.ParseFloat(hl, 64)
+		if err != nil || days <= 0 || days > utils.MaxAttributionLookbackDays {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("half_life_days must be greater than 0 and at most %d", utils.MaxAttributionLookbackDays)})
+			return
+		}
+		halfLife = time.Duration(days * float64(24*time.Hour))
+	}
+
+	report, err := h.service.GetAttribution(c.Request.Context(), websiteID, dateRange, filters, goal, dimension, lookbackDays, halfLife)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get attribution")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get attribution"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetSessions returns the sessions of a website, latest first, with their
+// totals
+func (h *AnalyticsHandler) GetSessions(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := defaultSessionsLimit
+	if l := c.Query("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxSessionsLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxSessionsLimit)})
+			return
+		}
+	}
+
+	offset := 0
+	if o := c.Query("offset"); o != "" {
+		offset, err = strconv.Atoi(o)
+		if err != nil || offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "offset must be a non-negative integer"})
+			return
+		}
+	}
+
+	summary, sessions, err := h.service.GetSessions(c.Request.Context(), websiteID, dateRange, filters, limit, offset)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get sessions")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get sessions"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"website_id": websiteID,
-		"trends": gin.H{
-			"page_views": []gin.H{},
-			"visitors":   []gin.H{},
-			"sessions":   []gin.H{},
-		},
+		"date_range": dateRange.Label(),
+		"summary":    summary,
+		"sessions":   sessions,
+		"limit":      limit,
+		"offset":     offset,
 	})
 }
 
+// GetVisitorJourney returns what a single visitor did: their sessions and a
+// timeline of pageviews, custom events and funnel progress
+func (h *AnalyticsHandler) GetVisitorJourney(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	visitorID := c.Param("visitor_id")
+	if websiteID == "" || visitorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id and visitor_id are required"})
+		return
+	}
+
+	dateRange, err := parseDateRange(c, 30)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	limit := defaultTimelineLimit
+	if l := c.Query("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > maxTimelineLimit {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("limit must be between 1 and %d", maxTimelineLimit)})
+			return
+		}
+	}
+
+	journey, err := h.service.GetVisitorJourney(c.Request.Context(), websiteID, visitorID, dateRange, filters, maxSessionsLimit, limit)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get visitor journey")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get visitor journey"})
+		return
+	}
+
+	c.JSON(http.StatusOK, journey)
+}
+
 // GetLiveVisitors returns the number of currently active visitors
 func (h *AnalyticsHandler) GetLiveVisitors(c *gin.Context) {
 	websiteID := c.Param("website_id")
@@ -505,3 +1008,54 @@ func (h *AnalyticsHandler) GetLiveVisitors(c *gin.Context) {
 		"timestamp":     "now",
 	})
 }
+
+// parseDateRange reads from/to/timezone (or the legacy days parameter) from the query string
+func parseDateRange(c *gin.Context, defaultDays int) (models.DateRange, error) {
+	return utils.ParseDateRange(utils.DateRangeParams{
+		From:     c.Query("from"),
+		To:       c.Query("to"),
+		Timezone: c.Query("timezone"),
+		Days:     c.Query("days"),
+	}, defaultDays, time.Now())
+}
+
+// parseFilters reads the dimension filters shared by all analytics endpoints
+func parseFilters(c *gin.Context) (models.AnalyticsFilters, error) {
+	return utils.ParseAnalyticsFilters(c.Request.URL.Query())
+}
+
+// parseGoal reads the optional goal_id whose conversions the breakdowns report
+func (h *AnalyticsHandler) parseGoal(c *gin.Context, websiteID string) (*models.Goal, error) {
+	value := c.Query("goal_id")
+	if value == "" {
+		return nil, nil
+	}
+	goalID, err := uuid.Parse(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid goal_id: %s", value)
+	}
+	return h.service.GetGoal(c.Request.Context(), websiteID, goalID)
+}
+
+// parseCustomMetrics reads the optional comma-separated IDs of the custom
+// metrics to report alongside the numbers
+func (h *AnalyticsHandler) parseCustomMetrics(c *gin.Context, websiteID string) ([]models.CustomMetric, error) {
+	value := c.Query("metrics")
+	if value == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(value, ",")
+	if len(parts) > maxSelectedMetrics {
+		return nil, fmt.Errorf("at most %d metrics can be selected", maxSelectedMetrics)
+	}
+	metricIDs := make([]uuid.UUID, 0, len(parts))
+	for _, part := range parts {
+		metricID, err := uuid.Parse(strings.TrimSpace(part))
+		if err != nil {
+			return nil, fmt.Errorf("invalid metric ID: %s", part)
+		}
+		metricIDs = append(metricIDs, metricID)
+	}
+	return h.service.GetCustomMetrics(c.Request.Context(), websiteID, metricIDs)
+}
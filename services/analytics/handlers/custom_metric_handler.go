@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type CustomMetricHandler struct {
+	service *services.CustomMetricService
+	logger  zerolog.Logger
+}
+
+func NewCustomMetricHandler(service *services.CustomMetricService, logger zerolog.Logger) *CustomMetricHandler {
+	return &CustomMetricHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *CustomMetricHandler) CreateMetric(c *gin.Context) {
+	var req models.CreateCustomMetricRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind custom metric data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid custom metric data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	metric, err := h.service.CreateMetric(c.Request.Context(), &req)
+	if errors.Is(err, services.ErrInvalidCustomMetric) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to create custom metric")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create custom metric"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"metric": metric})
+}
+
+func (h *CustomMetricHandler) GetMetrics(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	metrics, err := h.service.GetMetrics(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get custom metrics")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get custom metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"metrics": metrics})
+}
+
+func (h *CustomMetricHandler) GetMetric(c *gin.Context) {
+	metricID, err := uuid.Parse(c.Param("metric_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid custom metric ID"})
+		return
+	}
+
+	metric, err := h.service.GetMetric(c.Request.Context(), metricID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get custom metric")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Custom metric not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"metric": metric})
+}
+
+func (h *CustomMetricHandler) UpdateMetric(c *gin.Context) {
+	metricID, err := uuid.Parse(c.Param("metric_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid custom metric ID"})
+		return
+	}
+
+	var req models.UpdateCustomMetricRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind custom metric update data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid custom metric data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	metric, err := h.service.UpdateMetric(c.Request.Context(), metricID, &req)
+	if errors.Is(err, services.ErrInvalidCustomMetric) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to update custom metric")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update custom metric"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"metric": metric})
+}
+
+func (h *CustomMetricHandler) DeleteMetric(c *gin.Context) {
+	metricID, err := uuid.Parse(c.Param("metric_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid custom metric ID"})
+		return
+	}
+
+	err = h.service.DeleteMetric(c.Request.Context(), metricID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to delete custom metric")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete custom metric"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
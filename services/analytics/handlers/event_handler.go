@@ -3,8 +3,9 @@ package handlers
 import (
 	"analytics-app/models"
 	"analytics-app/services"
-	"fmt"
+	"errors"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
@@ -46,6 +47,19 @@ func (h *EventHandler) TrackEvent(c *gin.Context) {
 	}
 
 	response, err := h.service.TrackEvent(c.Request.Context(), &event)
+	if errors.Is(err, services.ErrInvalidEvent) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if errors.Is(err, services.ErrQueueFull) {
+		queue := h.service.QueueStatus(true)
+		setQueueHeaders(c, queue)
+		c.JSON(http.StatusTooManyRequests, gin.H{
+			"error": err.Error(),
+			"queue": queue,
+		})
+		return
+	}
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to track event")
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -80,19 +94,6 @@ func (h *EventHandler) TrackBatchEvents(c *gin.Context) {
 		return
 	}
 
-	// Validate individual events
-	for i, event := range req.Events {
-		if event.VisitorID == "" {
-			h.logger.Error().
-				Int("event_index", i).
-				Msg("Event missing visitor_id")
-			c.JSON(http.StatusBadRequest, gin.H{
-				"error": fmt.Sprintf("Event at index %d missing visitor_id", i),
-			})
-			return
-		}
-	}
-
 	response, err := h.service.TrackBatchEvents(c.Request.Context(), &req)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to track batch events")
@@ -102,5 +103,84 @@ func (h *EventHandler) TrackBatchEvents(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusCreated, response)
+	// Events are accepted, rejected or throttled individually; the status code
+	// only signals a batch where nothing was accepted
+	setQueueHeaders(c, response.Queue)
+	switch response.Status {
+	case models.EventStatusThrottled:
+		c.JSON(http.StatusTooManyRequests, response)
+	case models.EventStatusRejected:
+		c.JSON(http.StatusBadRequest, response)
+	default:
+		c.JSON(http.StatusCreated, response)
+	}
+}
+
+// GetQueueStats returns the ingestion queue depth
+func (h *EventHandler) GetQueueStats(c *gin.Context) {
+	stats, err := h.service.GetStats(c.Request.Context())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get queue stats")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get queue stats",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// setQueueHeaders exposes the queue depth to trackers, and Retry-After when
+// events were throttled
+func setQueueHeaders(c *gin.Context, queue models.QueueStatus) {
+	c.Header("X-Queue-Depth", strconv.FormatInt(queue.Depth, 10))
+	c.Header("X-Queue-Capacity", strconv.FormatInt(queue.Capacity, 10))
+	if queue.RetryAfter > 0 {
+		c.Header("Retry-After", strconv.Itoa(queue.RetryAfter))
+	}
+}
+
+// GetDeadLetters lists events that could not be stored after all retries
+func (h *EventHandler) GetDeadLetters(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	letters, err := h.service.GetDeadLetters(c.Request.Context(), limit)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to list dead letters")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to list dead letters",
+		})
+		return
+	}
+
+	stats, err := h.service.GetStats(c.Request.Context())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get queue stats")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to get queue stats",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"dead_letters": letters,
+		"queue":        stats,
+	})
+}
+
+// RequeueDeadLetters moves dead letters back to the ingestion queue
+func (h *EventHandler) RequeueDeadLetters(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+
+	requeued, err := h.service.RequeueDeadLetters(c.Request.Context(), limit)
+	if err != nil {
+		h.logger.Error().Err(err).Int("requeued", requeued).Msg("Failed to requeue dead letters")
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":    "Failed to requeue dead letters",
+			"requeued": requeued,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"requeued": requeued})
 }
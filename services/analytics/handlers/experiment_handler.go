@@ -0,0 +1,164 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type ExperimentHandler struct {
+	service *services.ExperimentService
+	logger  zerolog.Logger
+}
+
+func NewExperimentHandler(service *services.ExperimentService, logger zerolog.Logger) *ExperimentHandler {
+	return &ExperimentHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *ExperimentHandler) CreateExperiment(c *gin.Context) {
+	var req models.CreateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind experiment data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid experiment data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	experiment, err := h.service.CreateExperiment(c.Request.Context(), &req)
+	if errors.Is(err, services.ErrInvalidExperiment) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to create experiment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create experiment"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"experiment": experiment})
+}
+
+func (h *ExperimentHandler) GetExperiments(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	experiments, err := h.service.GetExperiments(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get experiments")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get experiments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"experiments": experiments})
+}
+
+func (h *ExperimentHandler) GetExperiment(c *gin.Context) {
+	experimentID, err := uuid.Parse(c.Param("experiment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment ID"})
+		return
+	}
+
+	experiment, err := h.service.GetExperiment(c.Request.Context(), experimentID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get experiment")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Experiment not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"experiment": experiment})
+}
+
+func (h *ExperimentHandler) UpdateExperiment(c *gin.Context) {
+	experimentID, err := uuid.Parse(c.Param("experiment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment ID"})
+		return
+	}
+
+	var req models.UpdateExperimentRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind experiment update data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid experiment data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	experiment, err := h.service.UpdateExperiment(c.Request.Context(), experimentID, &req)
+	if errors.Is(err, services.ErrInvalidExperiment) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to update experiment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update experiment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"experiment": experiment})
+}
+
+func (h *ExperimentHandler) DeleteExperiment(c *gin.Context) {
+	experimentID, err := uuid.Parse(c.Param("experiment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment ID"})
+		return
+	}
+
+	err = h.service.DeleteExperiment(c.Request.Context(), experimentID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to delete experiment")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete experiment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// GetExperimentResults compares the variants over the requested range, or
+// over the experiment's run when no from, to or days is given
+func (h *ExperimentHandler) GetExperimentResults(c *gin.Context) {
+	experimentID, err := uuid.Parse(c.Param("experiment_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid experiment ID"})
+		return
+	}
+
+	var dateRange *models.DateRange
+	if c.Query("from") != "" || c.Query("to") != "" || c.Query("days") != "" {
+		parsed, err := parseDateRange(c, 30)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		dateRange = &parsed
+	}
+
+	results, err := h.service.GetExperimentResults(c.Request.Context(), experimentID, dateRange)
+	if errors.Is(err, services.ErrInvalidExperiment) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get experiment results")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get experiment results"})
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"analytics-app/utils"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+// exportWriteTimeout bounds each write of a download; the server's
+// WriteTimeout would otherwise cut off large exports
+const exportWriteTimeout = time.Minute
+
+type ExportHandler struct {
+	service *services.ExportService
+	logger  zerolog.Logger
+}
+
+func NewExportHandler(service *services.ExportService, logger zerolog.Logger) *ExportHandler {
+	return &ExportHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+// Export streams a dataset of a website in the requested format
+func (h *ExportHandler) Export(c *gin.Context) {
+	req, ok := h.parseExportRequest(c)
+	if !ok {
+		return
+	}
+
+	h.setDownloadHeaders(c, exportFilename(req), req.Format)
+	rows, err := h.service.Export(c.Request.Context(), req, newDeadlineWriter(c.Writer))
+	if err != nil {
+		// The response has started, so the client only sees it cut short
+		h.logger.Error().Err(err).Str("website_id", req.WebsiteID).Int64("rows", rows).Msg("Failed to export analytics data")
+		c.Abort()
+	}
+}
+
+// CreateExportJob queues a dataset to be exported to the object store
+func (h *ExportHandler) CreateExportJob(c *gin.Context) {
+	req, ok := h.parseExportRequest(c)
+	if !ok {
+		return
+	}
+
+	job, err := h.service.CreateJob(c.Request.Context(), req)
+	if errors.Is(err, services.ErrExportUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to create export job")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create export job"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetExportJob returns the status of a background export
+func (h *ExportHandler) GetExportJob(c *gin.Context) {
+	job, err := h.service.GetJob(c.Request.Context(), c.Param("job_id"))
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get export job")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export job not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadExportJob streams the file of a completed background export
+func (h *ExportHandler) DownloadExportJob(c *gin.Context) {
+	job, err := h.service.GetJob(c.Request.Context(), c.Param("job_id"))
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get export job")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Export job not found"})
+		return
+	}
+	if job.Status != models.ExportJobCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Export job is %s", job.Status)})
+		return
+	}
+
+	file, err := h.service.OpenJobResult(c.Request.Context(), job)
+	if err != nil {
+		h.logger.Error().Err(err).Str("job_id", job.ID).Msg("Failed to open export")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open export"})
+		return
+	}
+	defer file.Close()
+
+	h.setDownloadHeaders(c, exportFilename(job.ExportRequest), job.Format)
+	if _, err := io.Copy(newDeadlineWriter(c.Writer), file); err != nil {
+		h.logger.Error().Err(err).Str("job_id", job.ID).Msg("Failed to download export")
+		c.Abort()
+	}
+}
+
+// parseExportRequest reads the dataset, format, date range and filters of an
+// export, responding with 400 when they are invalid
+func (h *ExportHandler) parseExportRequest(c *gin.Context) (models.ExportRequest, bool) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return models.ExportRequest{}, false
+	}
+
+	dataset := c.DefaultQuery("dataset", models.ExportDatasetEvents)
+	if !slices.Contains(models.ExportDatasets, dataset) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("dataset must be one of %s", strings.Join(models.ExportDatasets, ", "))})
+		return models.ExportRequest{}, false
+	}
+
+	format := c.DefaultQuery("format", models.ExportFormatCSV)
+	if _, ok := models.ExportContentTypes[format]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, ndjson or parquet"})
+		return models.ExportRequest{}, false
+	}
+
+	dateRange, err := parseDateRange(c, 30)
+	if err == nil && dataset == models.ExportDatasetHourlyStats {
+		err = utils.CheckHourlyRange(dateRange)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.ExportRequest{}, false
+	}
+
+	filters, err := parseFilters(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return models.ExportRequest{}, false
+	}
+
+	return models.ExportRequest{
+		WebsiteID: websiteID,
+		Dataset:   dataset,
+		Format:    format,
+		DateRange: dateRange,
+		Filters:   filters,
+	}, true
+}
+
+func (h *ExportHandler) setDownloadHeaders(c *gin.Context, filename, format string) {
+	c.Header("Content-Type", models.ExportContentTypes[format])
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+}
+
+func exportFilename(req models.ExportRequest) string {
+	return fmt.Sprintf("%s-%s-%s-%s.%s", req.WebsiteID, req.Dataset,
+		req.DateRange.From.Format("20060102"), req.DateRange.To.Format("20060102"), req.Format)
+}
+
+// deadlineWriter extends the connection's write deadline before each write,
+// so long downloads are only cut off when the client stops reading
+type deadlineWriter struct {
+	writer     gin.ResponseWriter
+	controller *http.ResponseController
+}
+
+func newDeadlineWriter(w gin.ResponseWriter) *deadlineWriter {
+	return &deadlineWriter{writer: w, controller: http.NewResponseController(w)}
+}
+
+func (w *deadlineWriter) Write(p []byte) (int, error) {
+	// Writers that do not support deadlines keep the server's
+	_ = w.controller.SetWriteDeadline(time.Now().Add(exportWriteTimeout))
+	return w.writer.Write(p)
+}
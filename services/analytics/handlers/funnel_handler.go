@@ -4,7 +4,6 @@ import (
 	"analytics-app/models"
 	"analytics-app/services"
 	"net/http"
-	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
@@ -197,14 +196,13 @@ func (h *FunnelHandler) GetFunnelAnalytics(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	analytics, err := h.service.GetFunnelAnalytics(c.Request.Context(), funnelID, days)
+	analytics, err := h.service.GetFunnelAnalytics(c.Request.Context(), funnelID, dateRange)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get funnel analytics")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get funnel analytics"})
@@ -222,14 +220,13 @@ func (h *FunnelHandler) GetDetailedFunnelAnalytics(c *gin.Context) {
 		return
 	}
 
-	days := 7
-	if d := c.Query("days"); d != "" {
-		if parsedDays, err := strconv.Atoi(d); err == nil && parsedDays > 0 {
-			days = parsedDays
-		}
+	dateRange, err := parseDateRange(c, 7)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	analytics, err := h.service.GetDetailedFunnelAnalytics(c.Request.Context(), funnelID, days)
+	analytics, err := h.service.GetDetailedFunnelAnalytics(c.Request.Context(), funnelID, dateRange)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to get detailed funnel analytics")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get detailed funnel analytics"})
@@ -259,12 +256,18 @@ func (h *FunnelHandler) CompareFunnels(c *gin.Context) {
 		return
 	}
 
+	// The body's date_range (in days) is the default; query parameters win
 	days := 7
 	if req.DateRange > 0 {
 		days = req.DateRange
 	}
+	dateRange, err := parseDateRange(c, days)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	results, err := h.service.CompareFunnels(c.Request.Context(), websiteID, req.FunnelIDs, days)
+	results, err := h.service.CompareFunnels(c.Request.Context(), websiteID, req.FunnelIDs, dateRange)
 	if err != nil {
 		h.logger.Error().Err(err).Msg("Failed to compare funnels")
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to compare funnels"})
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type GoalHandler struct {
+	service *services.GoalService
+	logger  zerolog.Logger
+}
+
+func NewGoalHandler(service *services.GoalService, logger zerolog.Logger) *GoalHandler {
+	return &GoalHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *GoalHandler) CreateGoal(c *gin.Context) {
+	var req models.CreateGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind goal data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid goal data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	goal, err := h.service.CreateGoal(c.Request.Context(), &req)
+	if errors.Is(err, services.ErrInvalidGoal) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to create goal")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create goal"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"goal": goal})
+}
+
+func (h *GoalHandler) GetGoals(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	goals, err := h.service.GetGoals(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get goals")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get goals"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"goals": goals})
+}
+
+func (h *GoalHandler) GetGoal(c *gin.Context) {
+	goalID, err := uuid.Parse(c.Param("goal_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return
+	}
+
+	goal, err := h.service.GetGoal(c.Request.Context(), goalID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get goal")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Goal not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"goal": goal})
+}
+
+func (h *GoalHandler) UpdateGoal(c *gin.Context) {
+	goalID, err := uuid.Parse(c.Param("goal_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return
+	}
+
+	var req models.UpdateGoalRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind goal update data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid goal data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	goal, err := h.service.UpdateGoal(c.Request.Context(), goalID, &req)
+	if errors.Is(err, services.ErrInvalidGoal) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to update goal")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update goal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"goal": goal})
+}
+
+func (h *GoalHandler) DeleteGoal(c *gin.Context) {
+	goalID, err := uuid.Parse(c.Param("goal_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid goal ID"})
+		return
+	}
+
+	err = h.service.DeleteGoal(c.Request.Context(), goalID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to delete goal")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete goal"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
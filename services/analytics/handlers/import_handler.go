@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/services"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// importReadTimeout bounds each read of an upload; the server's ReadTimeout
+// would otherwise cut off large files
+const importReadTimeout = time.Minute
+
+type ImportHandler struct {
+	service  *services.ImportService
+	maxBytes int64
+	logger   zerolog.Logger
+}
+
+func NewImportHandler(service *services.ImportService, maxBytes int, logger zerolog.Logger) *ImportHandler {
+	return &ImportHandler{
+		service:  service,
+		maxBytes: int64(maxBytes),
+		logger:   logger,
+	}
+}
+
+// CreateImport imports an export of another analytics tool, uploaded as the
+// "file" field of a form or as the request body
+func (h *ImportHandler) CreateImport(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	source := c.Query("source")
+	if !slices.Contains(models.ImportSources, source) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("source must be one of %s", strings.Join(models.ImportSources, ", "))})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, newDeadlineReader(c.Request.Body, c.Writer), h.maxBytes)
+	file, filename, err := h.uploadedFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	imp, err := h.service.Import(c.Request.Context(), websiteID, source, filename, file)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case errors.As(err, &tooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("import file is larger than %d bytes", h.maxBytes)})
+		return
+	case errors.Is(err, services.ErrInvalidImport):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.Error().Err(err).Str("website_id", websiteID).Msg("Failed to import file")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to import file"})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, imp)
+}
+
+// GetImports lists the imports of a website
+func (h *ImportHandler) GetImports(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	imports, err := h.service.ListImports(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get imports")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get imports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"imports": imports})
+}
+
+// GetImport returns the progress of an import
+func (h *ImportHandler) GetImport(c *gin.Context) {
+	importID, err := uuid.Parse(c.Param("import_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	imp, err := h.service.GetImport(c.Request.Context(), c.Param("website_id"), importID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get import")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, imp)
+}
+
+// RollbackImport deletes everything an import stored
+func (h *ImportHandler) RollbackImport(c *gin.Context) {
+	importID, err := uuid.Parse(c.Param("import_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid import ID"})
+		return
+	}
+
+	imp, err := h.service.Rollback(c.Request.Context(), c.Param("website_id"), importID)
+	switch {
+	case errors.Is(err, repository.ErrImportNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Import not found"})
+		return
+	case errors.Is(err, services.ErrImportRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		h.logger.Error().Err(err).Str("import_id", importID.String()).Msg("Failed to roll back import")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to roll back import"})
+		return
+	}
+
+	c.JSON(http.StatusOK, imp)
+}
+
+// uploadedFile returns the uploaded file and its name. Forms are read as a
+// stream so the file is not buffered twice.
+func (h *ImportHandler) uploadedFile(c *gin.Context) (io.Reader, string, error) {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != "multipart/form-data" {
+		return c.Request.Body, c.Query("filename"), nil
+	}
+
+	form, err := c.Request.MultipartReader()
+	if err != nil {
+		return nil, "", err
+	}
+	for {
+		part, err := form.NextPart()
+		if err == io.EOF {
+			return nil, "", errors.New("file is required")
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if part.FormName() == "file" {
+			return part, part.FileName(), nil
+		}
+	}
+}
+
+// deadlineReader extends the connection's deadlines before each read, so
+// long uploads are only cut off when the client stops sending
+type deadlineReader struct {
+	reader     io.ReadCloser
+	controller *http.ResponseController
+}
+
+func newDeadlineReader(r io.ReadCloser, w gin.ResponseWriter) *deadlineReader {
+	return &deadlineReader{reader: r, controller: http.NewResponseController(w)}
+}
+
+func (r *deadlineReader) Read(p []byte) (int, error) {
+	// The write deadline runs from the start of the request too, so the
+	// response would not get through after a long upload
+	deadline := time.Now().Add(importReadTimeout)
+	_ = r.controller.SetReadDeadline(deadline)
+	_ = r.controller.SetWriteDeadline(deadline)
+	return r.reader.Read(p)
+}
+
+func (r *deadlineReader) Close() error {
+	return r.reader.Close()
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"analytics-app/services"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// liveWriteTimeout bounds each write to a live stream; the server's
+	// WriteTimeout would otherwise end every stream after a few seconds
+	liveWriteTimeout = 30 * time.Second
+	// liveHeartbeat keeps idle streams open through proxies
+	liveHeartbeat = 15 * time.Second
+)
+
+type LiveHandler struct {
+	hub    *services.LiveHub
+	logger zerolog.Logger
+}
+
+func NewLiveHandler(hub *services.LiveHub, logger zerolog.Logger) *LiveHandler {
+	return &LiveHandler{
+		hub:    hub,
+		logger: logger,
+	}
+}
+
+// GetLiveMetrics returns what is happening on a website right now
+func (h *LiveHandler) GetLiveMetrics(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	metrics, err := h.hub.Snapshot(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Str("website_id", websiteID).Msg("Failed to get live metrics")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get live metrics"})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+// StreamLiveMetrics pushes a website's live metrics as server-sent "metrics"
+// events until the client disconnects
+func (h *LiveHandler) StreamLiveMetrics(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	updates, unsubscribe := h.hub.Subscribe(websiteID)
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	controller := http.NewResponseController(c.Writer)
+	extendDeadline := func() {
+		if err := controller.SetWriteDeadline(time.Now().Add(liveWriteTimeout)); err != nil {
+			h.logger.Warn().Err(err).Msg("Failed to extend live stream write deadline")
+		}
+	}
+	heartbeat := time.NewTicker(liveHeartbeat)
+	defer heartbeat.Stop()
+
+	h.logger.Debug().Str("website_id", websiteID).Msg("Live stream opened")
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case metrics := <-updates:
+			extendDeadline()
+			c.SSEvent("metrics", metrics)
+		case <-heartbeat.C:
+			extendDeadline()
+			if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+				return false
+			}
+		}
+		return true
+	})
+	h.logger.Debug().Str("website_id", websiteID).Msg("Live stream closed")
+}
@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type ReportHandler struct {
+	service *services.ReportService
+	logger  zerolog.Logger
+}
+
+func NewReportHandler(service *services.ReportService, logger zerolog.Logger) *ReportHandler {
+	return &ReportHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *ReportHandler) CreateReport(c *gin.Context) {
+	var req models.CreateReportScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind report data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	report, err := h.service.CreateReport(c.Request.Context(), &req)
+	if errors.Is(err, services.ErrInvalidReport) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to create report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create report"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"report": report})
+}
+
+func (h *ReportHandler) GetReports(c *gin.Context) {
+	websiteID := c.Query("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	reports, err := h.service.GetReports(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get reports")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get reports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+func (h *ReportHandler) GetReport(c *gin.Context) {
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	report, err := h.service.GetReport(c.Request.Context(), reportID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get report")
+		c.JSON(http.StatusNotFound, gin.H{"error": "Report not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+func (h *ReportHandler) UpdateReport(c *gin.Context) {
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	var req models.UpdateReportScheduleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind report update data")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid report data",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	report, err := h.service.UpdateReport(c.Request.Context(), reportID, &req)
+	if errors.Is(err, services.ErrInvalidReport) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to update report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"report": report})
+}
+
+func (h *ReportHandler) DeleteReport(c *gin.Context) {
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	err = h.service.DeleteReport(c.Request.Context(), reportID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to delete report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "deleted"})
+}
+
+// PreviewReport renders the report a schedule would send now, as HTML or
+// with format=text as plain text
+func (h *ReportHandler) PreviewReport(c *gin.Context) {
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "html")
+	if format != "html" && format != "text" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be html or text"})
+		return
+	}
+
+	rendered, err := h.service.PreviewReport(c.Request.Context(), reportID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to preview report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to preview report"})
+		return
+	}
+
+	if format == "text" {
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(rendered.Text))
+		return
+	}
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(rendered.HTML))
+}
+
+// SendReport delivers a schedule's report right away
+func (h *ReportHandler) SendReport(c *gin.Context) {
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid report ID"})
+		return
+	}
+
+	if err := h.service.SendReport(c.Request.Context(), reportID); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to send report")
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to send report", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"status": "sent"})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"analytics-app/models"
+	"analytics-app/services"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/rs/zerolog"
+)
+
+type RevenueHandler struct {
+	service *services.RevenueService
+	logger  zerolog.Logger
+}
+
+func NewRevenueHandler(service *services.RevenueService, logger zerolog.Logger) *RevenueHandler {
+	return &RevenueHandler{
+		service: service,
+		logger:  logger,
+	}
+}
+
+func (h *RevenueHandler) GetSettings(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	settings, err := h.service.GetSettings(c.Request.Context(), websiteID)
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get revenue settings")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revenue settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": settings})
+}
+
+func (h *RevenueHandler) UpdateSettings(c *gin.Context) {
+	websiteID := c.Param("website_id")
+	if websiteID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "website_id is required"})
+		return
+	}
+
+	var req models.UpdateRevenueSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind revenue settings")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid revenue settings",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	settings, err := h.service.UpdateSettings(c.Request.Context(), websiteID, &req)
+	if errors.Is(err, services.ErrInvalidCurrency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to update revenue settings")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update revenue settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"settings": settings})
+}
+
+func (h *RevenueHandler) GetExchangeRates(c *gin.Context) {
+	rates, err := h.service.GetExchangeRates(c.Request.Context())
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to get exchange rates")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reference_currency": models.ReferenceCurrency,
+		"rates":              rates,
+	})
+}
+
+func (h *RevenueHandler) UpdateExchangeRates(c *gin.Context) {
+	var req models.UpdateExchangeRatesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		h.logger.Error().Err(err).Msg("Failed to bind exchange rates")
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error":   "Invalid exchange rates",
+			"details": err.Error(),
+		})
+		return
+	}
+
+	rates, err := h.service.UpdateExchangeRates(c.Request.Context(), &req)
+	if errors.Is(err, services.ErrInvalidCurrency) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		h.logger.Error().Err(err).Msg("Failed to update exchange rates")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update exchange rates"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"reference_currency": models.ReferenceCurrency,
+		"rates":              rates,
+	})
+}
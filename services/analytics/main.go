@@ -4,11 +4,13 @@ import (
 	"analytics-app/config"
 	"analytics-app/database"
 	"analytics-app/handlers"
+	"analytics-app/metrics"
 	"analytics-app/middleware"
 	"analytics-app/migrations"
 	"analytics-app/repository"
 	"analytics-app/repository/privacy"
 	"analytics-app/services"
+	"analytics-app/utils"
 	"context"
 	"log"
 	"net/http"
@@ -73,24 +75,80 @@ func main() {
 	// Initialize repositories
 	eventRepo := repository.NewEventRepository(db, logger)
 	funnelRepo := repository.NewFunnelRepository(db)
+	experimentRepo := repository.NewExperimentRepository(db)
+	goalRepo := repository.NewGoalRepository(db)
+	revenueRepo := repository.NewRevenueRepository(db)
 	analyticsRepo := repository.NewMainAnalyticsRepository(db)
 	privacyRepo := privacy.NewPrivacyRepository(db)
 
+	// Initialize geolocation
+	geoLocator, closeGeo := setupGeoLocator(cfg, logger)
+	defer closeGeo()
+
+	botClassifier := utils.NewBotClassifier(utils.BotClassifierConfig{
+		MaxEventsPerMinute: cfg.BotMaxEventsPerMinute,
+	})
+
+	// Events are buffered in a Redis stream until stored, so they survive
+	// restarts and database outages
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "analytics"
+	}
+	eventStream := repository.NewEventStream(redisClient, hostname)
+
+	sessionStitcher := setupSessionStitcher(cfg, redisClient, logger)
+
+	// Live metrics are kept in Redis as events are ingested
+	liveHub := services.NewLiveHub(repository.NewLiveStore(redisClient), cfg.LiveInterval, logger)
+
 	// Initialize services
-	eventService := services.NewEventService(eventRepo, logger)
-	funnelService := services.NewFunnelService(funnelRepo, logger, redisClient)
+	eventService, err := services.NewEventService(eventRepo, eventStream, geoLocator, botClassifier, sessionStitcher, liveHub, int64(cfg.EventQueueMaxBacklog), logger)
+	if err != nil {
+		logger.Fatal().Err(err).Msg("Failed to initialize event service")
+	}
+	funnelService := services.NewFunnelService(funnelRepo, revenueRepo, logger, redisClient)
+	experimentService := services.NewExperimentService(experimentRepo, funnelRepo, logger)
+	goalService := services.NewGoalService(goalRepo, logger)
+	customMetricService := services.NewCustomMetricService(repository.NewCustomMetricRepository(db), logger)
+	revenueService := services.NewRevenueService(revenueRepo, logger)
 	analyticsService := services.NewAnalyticsService(analyticsRepo, logger)
 	privacyService := services.NewPrivacyService(privacyRepo, logger)
+	exportJobs := repository.NewExportJobStore(redisClient, services.ExportJobTTL)
+	exportService := services.NewExportService(analyticsRepo, exportJobs, setupExportStore(cfg, logger), cfg.ExportWorkers, logger)
+	importService := services.NewImportService(repository.NewImportRepository(db), eventRepo, logger)
+	mailer := setupMailer(cfg, logger)
+	webhooks := utils.NewWebhookSender(30 * time.Second)
+	reportService := services.NewReportService(repository.NewReportRepository(db), analyticsService, funnelService, mailer, webhooks, cfg.ReportCheckInterval, logger)
+	reportService.Start()
+	alertService := services.NewAlertService(repository.NewAlertRepository(db), analyticsRepo, mailer, webhooks, cfg.AlertCheckInterval, logger)
+	alertService.Start()
+
+	// Export queue, connection pool and hypertable metrics
+	metrics.Registry.MustRegister(
+		metrics.NewQueueCollector(eventStream.Stats, logger),
+		metrics.NewPoolCollector(db),
+		metrics.NewHypertableCollector(utils.NewTimescaleDBHelper(db).HypertableSizes, time.Minute, logger),
+	)
 
 	// Initialize handlers
 	eventHandler := handlers.NewEventHandler(eventService, logger)
 	funnelHandler := handlers.NewFunnelHandler(funnelService, logger)
+	experimentHandler := handlers.NewExperimentHandler(experimentService, logger)
+	goalHandler := handlers.NewGoalHandler(goalService, logger)
+	customMetricHandler := handlers.NewCustomMetricHandler(customMetricService, logger)
+	revenueHandler := handlers.NewRevenueHandler(revenueService, logger)
 	analyticsHandler := handlers.NewAnalyticsHandler(analyticsService, logger)
 	privacyHandler := handlers.NewPrivacyHandler(privacyService, logger)
+	liveHandler := handlers.NewLiveHandler(liveHub, logger)
+	exportHandler := handlers.NewExportHandler(exportService, logger)
+	importHandler := handlers.NewImportHandler(importService, cfg.ImportMaxBytes, logger)
+	reportHandler := handlers.NewReportHandler(reportService, logger)
+	alertHandler := handlers.NewAlertHandler(alertService, logger)
 	healthHandler := handlers.NewHealthHandler(db, logger)
 
 	// Setup router
-	router := setupRouter(cfg, eventService, eventHandler, funnelHandler, analyticsHandler, privacyHandler, healthHandler, logger)
+	router := setupRouter(cfg, eventService, eventHandler, funnelHandler, experimentHandler, goalHandler, customMetricHandler, revenueHandler, analyticsHandler, privacyHandler, liveHandler, exportHandler, importHandler, reportHandler, alertHandler, healthHandler, logger)
 
 	// Start server
 	server := &http.Server{
@@ -116,16 +174,32 @@ func main() {
 
 	logger.Info().Msg("Server shutting down...")
 
-	// Graceful shutdown with event buffer flush
+	// Graceful shutdown
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer shutdownCancel()
 
-	// Shutdown event service first to flush buffered events
-	logger.Info().Msg("Flushing buffered events...")
+	// Stop the queue consumer first; unstored events stay in the stream
+	logger.Info().Msg("Stopping event consumer...")
 	if err := eventService.Shutdown(10 * time.Second); err != nil {
 		logger.Error().Err(err).Msg("Failed to shutdown event service gracefully")
 	}
 
+	if err := exportService.Shutdown(5 * time.Second); err != nil {
+		logger.Error().Err(err).Msg("Failed to stop export jobs")
+	}
+	if err := importService.Shutdown(5 * time.Second); err != nil {
+		logger.Error().Err(err).Msg("Failed to stop imports")
+	}
+	if err := reportService.Shutdown(5 * time.Second); err != nil {
+		logger.Error().Err(err).Msg("Failed to stop report scheduler")
+	}
+	if err := alertService.Shutdown(5 * time.Second); err != nil {
+		logger.Error().Err(err).Msg("Failed to stop alert evaluator")
+	}
+	if err := liveHub.Shutdown(5 * time.Second); err != nil {
+		logger.Error().Err(err).Msg("Failed to stop live metrics")
+	}
+
 	// Then shutdown HTTP server
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		logger.Error().Err(err).Msg("Server forced to shutdown")
@@ -176,13 +250,128 @@ func setupLogger(cfg *config.Config) zerolog.Logger {
 	}
 }
 
+// setupGeoLocator resolves visitor locations from local MaxMind/DB-IP
+// databases; the third-party HTTP APIs are only used when explicitly enabled
+func setupGeoLocator(cfg *config.Config, logger zerolog.Logger) (*utils.GeoLocator, func()) {
+	var providers []utils.GeoProvider
+	closeFn := func() {}
+
+	var mmdb *utils.MMDBProvider
+	if cfg.GeoIPDBPath != "" {
+		var err error
+		mmdb, err = utils.NewMMDBProvider(cfg.GeoIPDBPath, cfg.GeoIPASNDBPath, cfg.GeoIPReloadInterval, logger)
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to open geolocation database")
+		} else {
+			providers = append(providers, mmdb)
+			closeFn = func() { mmdb.Close() }
+			logger.Info().Str("path", cfg.GeoIPDBPath).Msg("Using local geolocation database")
+		}
+	}
+
+	if cfg.GeoIPHTTPFallback {
+		providers = append(providers, utils.NewFreeGeoIPService())
+		logger.Warn().Msg("HTTP geolocation fallback enabled, visitor IPs are sent to third-party APIs")
+	}
+
+	if len(providers) == 0 {
+		logger.Warn().Msg("No geolocation provider configured, set GEOIP_DB_PATH to resolve visitor locations")
+	}
+
+	locator := utils.NewGeoLocator(cfg.GeoIPCacheSize, providers...)
+	if mmdb != nil {
+		mmdb.OnReload(locator.Purge)
+	}
+	return locator, closeFn
+}
+
+// setupSessionStitcher returns the server-side session assigner, or nil when
+// the tracker's session IDs are trusted
+func setupSessionStitcher(cfg *config.Config, redisClient *redis.Client, logger zerolog.Logger) *services.SessionStitcher {
+	if !cfg.SessionStitching {
+		logger.Info().Msg("Session stitching disabled, using tracker session IDs")
+		return nil
+	}
+
+	rules := utils.DefaultSessionRules()
+	if cfg.SessionTimeout > 0 {
+		rules.Timeout = cfg.SessionTimeout
+	}
+	rules.SplitOnCampaign = cfg.SessionSplitOnCampaign
+	location, err := time.LoadLocation(cfg.SessionTimezone)
+	if err != nil {
+		logger.Error().Err(err).Str("timezone", cfg.SessionTimezone).Msg("Unknown session timezone, splitting sessions at UTC midnight")
+	} else {
+		rules.Location = location
+	}
+
+	store := repository.NewSessionStore(redisClient, 24*time.Hour)
+	return services.NewSessionStitcher(store, rules, logger)
+}
+
+// setupExportStore returns the object store of background exports, or nil
+// when it cannot be set up
+func setupExportStore(cfg *config.Config, logger zerolog.Logger) repository.ObjectStore {
+	switch cfg.ExportStorage {
+	case "s3":
+		store, err := repository.NewS3ObjectStore(repository.S3Options{
+			Endpoint:  cfg.ExportS3Endpoint,
+			Region:    cfg.ExportS3Region,
+			Bucket:    cfg.ExportS3Bucket,
+			AccessKey: cfg.ExportS3AccessKey,
+			SecretKey: cfg.ExportS3SecretKey,
+			UseSSL:    cfg.ExportS3UseSSL,
+		})
+		if err != nil {
+			logger.Error().Err(err).Msg("Failed to set up S3 export storage, background exports disabled")
+			return nil
+		}
+		return store
+	case "local":
+		store, err := repository.NewLocalObjectStore(cfg.ExportDir)
+		if err != nil {
+			logger.Error().Err(err).Str("dir", cfg.ExportDir).Msg("Failed to set up export directory, background exports disabled")
+			return nil
+		}
+		return store
+	default:
+		logger.Error().Str("storage", cfg.ExportStorage).Msg("Unknown export storage, background exports disabled")
+		return nil
+	}
+}
+
+// setupMailer returns the SMTP mailer, or without an SMTP host one writing
+// emails to the mail directory
+func setupMailer(cfg *config.Config, logger zerolog.Logger) utils.Mailer {
+	if cfg.SMTPHost != "" {
+		return utils.NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPFrom)
+	}
+
+	mailer, err := utils.NewLocalMailer(cfg.MailDir, cfg.SMTPFrom)
+	if err != nil {
+		logger.Error().Err(err).Str("dir", cfg.MailDir).Msg("Failed to set up mail directory, email reports disabled")
+		return nil
+	}
+	logger.Warn().Str("dir", cfg.MailDir).Msg("SMTP_HOST not set, emails are written to the mail directory")
+	return mailer
+}
+
 func setupRouter(
 	cfg *config.Config,
 	eventService *services.EventService,
 	eventHandler *handlers.EventHandler,
 	funnelHandler *handlers.FunnelHandler,
+	experimentHandler *handlers.ExperimentHandler,
+	goalHandler *handlers.GoalHandler,
+	customMetricHandler *handlers.CustomMetricHandler,
+	revenueHandler *handlers.RevenueHandler,
 	analyticsHandler *handlers.AnalyticsHandler,
 	privacyHandler *handlers.PrivacyHandler,
+	liveHandler *handlers.LiveHandler,
+	exportHandler *handlers.ExportHandler,
+	importHandler *handlers.ImportHandler,
+	reportHandler *handlers.ReportHandler,
+	alertHandler *handlers.AlertHandler,
 	healthHandler *handlers.HealthHandler,
 	logger zerolog.Logger,
 ) *gin.Engine {
@@ -197,10 +386,10 @@ func setupRouter(
 	router.Use(middleware.Recovery(logger))
 	router.Use(middleware.ClientIPMiddleware()) // Add client IP middleware for geolocation
 
-	// API Key validation for all routes (except health check)
+	// API Key validation for all routes (except health check and metrics)
 	router.Use(func(c *gin.Context) {
-		// Skip API key validation for health check
-		if c.Request.URL.Path == "/health" {
+		// Skip API key validation for health check and Prometheus scrapes
+		if c.Request.URL.Path == "/health" || c.Request.URL.Path == "/metrics" {
 			c.Next()
 			return
 		}
@@ -211,6 +400,9 @@ func setupRouter(
 	// Health check with buffer stats
 	router.GET("/health", healthHandler.HealthCheck)
 
+	// Prometheus metrics
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+
 	// API routes
 	v1 := router.Group("/api/v1")
 	{
@@ -219,6 +411,9 @@ func setupRouter(
 		{
 			analytics.POST("/event", eventHandler.TrackEvent)
 			analytics.POST("/event/batch", eventHandler.TrackBatchEvents)
+			analytics.GET("/queue", eventHandler.GetQueueStats)
+			analytics.GET("/dead-letters", eventHandler.GetDeadLetters)
+			analytics.POST("/dead-letters/requeue", eventHandler.RequeueDeadLetters)
 			analytics.GET("/dashboard/:website_id", analyticsHandler.GetDashboard)
 
 			analytics.GET("/top-pages/:website_id", analyticsHandler.GetTopPages)
@@ -231,10 +426,21 @@ func setupRouter(
 			analytics.GET("/top-os/:website_id", analyticsHandler.GetTopOS)
 			analytics.GET("/traffic-summary/:website_id", analyticsHandler.GetTrafficSummary)
 			analytics.GET("/activity-trends/:website_id", analyticsHandler.GetActivityTrends)
+			analytics.GET("/cohorts/:website_id", analyticsHandler.GetCohorts)
+			analytics.GET("/attribution/:website_id", analyticsHandler.GetAttribution)
+			analytics.GET("/sessions/:website_id", analyticsHandler.GetSessions)
+			analytics.GET("/visitors/:website_id/:visitor_id", analyticsHandler.GetVisitorJourney)
 			analytics.GET("/daily-stats/:website_id", analyticsHandler.GetDailyStats)
 			analytics.GET("/hourly-stats/:website_id", analyticsHandler.GetHourlyStats)
 			analytics.GET("/custom-events/:website_id", analyticsHandler.GetCustomEvents)
 			analytics.GET("/live-visitors/:website_id", analyticsHandler.GetLiveVisitors)
+			analytics.GET("/live/:website_id", liveHandler.GetLiveMetrics)
+			analytics.GET("/live/:website_id/stream", liveHandler.StreamLiveMetrics)
+			analytics.GET("/export/:website_id", exportHandler.Export)
+			analytics.POST("/export/:website_id", exportHandler.CreateExportJob)
+			analytics.GET("/export-jobs/:job_id", exportHandler.GetExportJob)
+			analytics.GET("/export-jobs/:job_id/download", exportHandler.DownloadExportJob)
+			analytics.GET("/bots/:website_id", analyticsHandler.GetBotTraffic)
 		}
 
 		// Public funnel routes (no auth required) - must be before parameterized routes
@@ -254,6 +460,78 @@ func setupRouter(
 			funnels.POST("/compare", funnelHandler.CompareFunnels)
 		}
 
+		// Experiment routes
+		experiments := v1.Group("/experiments")
+		{
+			experiments.POST("/", experimentHandler.CreateExperiment)
+			experiments.GET("/", experimentHandler.GetExperiments)
+			experiments.GET("/:experiment_id", experimentHandler.GetExperiment)
+			experiments.PUT("/:experiment_id", experimentHandler.UpdateExperiment)
+			experiments.DELETE("/:experiment_id", experimentHandler.DeleteExperiment)
+			experiments.GET("/:experiment_id/results", experimentHandler.GetExperimentResults)
+		}
+
+		// Goal routes
+		goals := v1.Group("/goals")
+		{
+			goals.POST("/", goalHandler.CreateGoal)
+			goals.GET("/", goalHandler.GetGoals)
+			goals.GET("/:goal_id", goalHandler.GetGoal)
+			goals.PUT("/:goal_id", goalHandler.UpdateGoal)
+			goals.DELETE("/:goal_id", goalHandler.DeleteGoal)
+		}
+
+		// Custom metric routes
+		customMetrics := v1.Group("/custom-metrics")
+		{
+			customMetrics.POST("/", customMetricHandler.CreateMetric)
+			customMetrics.GET("/", customMetricHandler.GetMetrics)
+			customMetrics.GET("/:metric_id", customMetricHandler.GetMetric)
+			customMetrics.PUT("/:metric_id", customMetricHandler.UpdateMetric)
+			customMetrics.DELETE("/:metric_id", customMetricHandler.DeleteMetric)
+		}
+
+		// Revenue routes
+		revenue := v1.Group("/revenue")
+		{
+			revenue.GET("/settings/:website_id", revenueHandler.GetSettings)
+			revenue.PUT("/settings/:website_id", revenueHandler.UpdateSettings)
+			revenue.GET("/exchange-rates", revenueHandler.GetExchangeRates)
+			revenue.PUT("/exchange-rates", revenueHandler.UpdateExchangeRates)
+		}
+
+		// Scheduled report routes
+		reports := v1.Group("/reports")
+		{
+			reports.POST("/", reportHandler.CreateReport)
+			reports.GET("/", reportHandler.GetReports)
+			reports.GET("/:report_id", reportHandler.GetReport)
+			reports.PUT("/:report_id", reportHandler.UpdateReport)
+			reports.DELETE("/:report_id", reportHandler.DeleteReport)
+			reports.GET("/:report_id/preview", reportHandler.PreviewReport)
+			reports.POST("/:report_id/send", reportHandler.SendReport)
+		}
+
+		// Traffic alert routes
+		alertRules := v1.Group("/alert-rules")
+		{
+			alertRules.POST("/", alertHandler.CreateRule)
+			alertRules.GET("/", alertHandler.GetRules)
+			alertRules.GET("/:rule_id", alertHandler.GetRule)
+			alertRules.PUT("/:rule_id", alertHandler.UpdateRule)
+			alertRules.DELETE("/:rule_id", alertHandler.DeleteRule)
+		}
+		v1.GET("/alerts/:website_id", alertHandler.GetAlerts)
+
+		// Historical data imports
+		imports := v1.Group("/imports")
+		{
+			imports.POST("/:website_id", importHandler.CreateImport)
+			imports.GET("/:website_id", importHandler.GetImports)
+			imports.GET("/:website_id/:import_id", importHandler.GetImport)
+			imports.DELETE("/:website_id/:import_id", importHandler.RollbackImport)
+		}
+
 		// Privacy routes
 		privacy := v1.Group("/privacy")
 		{
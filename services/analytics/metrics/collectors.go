@@ -0,0 +1,175 @@
+package metrics
+
+import (
+	"analytics-app/utils"
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog"
+)
+
+// QueueStats reports the ingestion queue backlog, entries awaiting
+// acknowledgement and dead letters
+type QueueStats func(ctx context.Context) (backlog, pending, dead int64, err error)
+
+type queueCollector struct {
+	stats   QueueStats
+	backlog *prometheus.Desc
+	pending *prometheus.Desc
+	dead    *prometheus.Desc
+	logger  zerolog.Logger
+}
+
+// NewQueueCollector exports the ingestion queue depths at scrape time
+func NewQueueCollector(stats QueueStats, logger zerolog.Logger) prometheus.Collector {
+	return &queueCollector{
+		stats:   stats,
+		backlog: prometheus.NewDesc(namespace+"_queue_backlog", "Events in the ingestion stream not yet stored.", nil, nil),
+		pending: prometheus.NewDesc(namespace+"_queue_pending", "Events read from the ingestion stream but not yet acknowledged.", nil, nil),
+		dead:    prometheus.NewDesc(namespace+"_queue_dead_letters", "Events in the dead-letter stream.", nil, nil),
+		logger:  logger,
+	}
+}
+
+func (c *queueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.backlog
+	ch <- c.pending
+	ch <- c.dead
+}
+
+func (c *queueCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	backlog, pending, dead, err := c.stats(ctx)
+	if err != nil {
+		c.logger.Error().Err(err).Msg("Failed to collect queue metrics")
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.backlog, prometheus.GaugeValue, float64(backlog))
+	ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(pending))
+	ch <- prometheus.MustNewConstMetric(c.dead, prometheus.GaugeValue, float64(dead))
+}
+
+type poolCollector struct {
+	pool          *pgxpool.Pool
+	total         *prometheus.Desc
+	idle          *prometheus.Desc
+	acquired      *prometheus.Desc
+	max           *prometheus.Desc
+	acquireCount  *prometheus.Desc
+	acquireWait   *prometheus.Desc
+	emptyAcquires *prometheus.Desc
+}
+
+// NewPoolCollector exports pgxpool connection statistics
+func NewPoolCollector(pool *pgxpool.Pool) prometheus.Collector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(namespace+"_db_pool_"+name, help, nil, nil)
+	}
+	return &poolCollector{
+		pool:          pool,
+		total:         desc("connections", "Open database connections."),
+		idle:          desc("idle_connections", "Idle database connections."),
+		acquired:      desc("acquired_connections", "Database connections in use."),
+		max:           desc("max_connections", "Maximum database connections."),
+		acquireCount:  desc("acquires_total", "Connections acquired from the pool."),
+		acquireWait:   desc("acquire_wait_seconds_total", "Time spent waiting for a connection."),
+		emptyAcquires: desc("empty_acquires_total", "Acquires that had to wait because the pool was empty."),
+	}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.total, c.idle, c.acquired, c.max, c.acquireCount, c.acquireWait, c.emptyAcquires} {
+		ch <- d
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stat := c.pool.Stat()
+	ch <- prometheus.MustNewConstMetric(c.total, prometheus.GaugeValue, float64(stat.TotalConns()))
+	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stat.IdleConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquired, prometheus.GaugeValue, float64(stat.AcquiredConns()))
+	ch <- prometheus.MustNewConstMetric(c.max, prometheus.GaugeValue, float64(stat.MaxConns()))
+	ch <- prometheus.MustNewConstMetric(c.acquireCount, prometheus.CounterValue, float64(stat.AcquireCount()))
+	ch <- prometheus.MustNewConstMetric(c.acquireWait, prometheus.CounterValue, stat.AcquireDuration().Seconds())
+	ch <- prometheus.MustNewConstMetric(c.emptyAcquires, prometheus.CounterValue, float64(stat.EmptyAcquireCount()))
+}
+
+// HypertableSizes returns the size of every hypertable
+type HypertableSizes func(ctx context.Context) ([]utils.HypertableSize, error)
+
+type hypertableCollector struct {
+	sizes    HypertableSizes
+	interval time.Duration
+	logger   zerolog.Logger
+
+	mu        sync.Mutex
+	cached    []utils.HypertableSize
+	fetchedAt time.Time
+
+	chunks           *prometheus.Desc
+	compressedChunks *prometheus.Desc
+	totalBytes       *prometheus.Desc
+	beforeBytes      *prometheus.Desc
+	afterBytes       *prometheus.Desc
+}
+
+// NewHypertableCollector exports hypertable chunk counts and sizes. The size
+// queries scan catalog tables, so results are reused for interval.
+func NewHypertableCollector(sizes HypertableSizes, interval time.Duration, logger zerolog.Logger) prometheus.Collector {
+	labels := []string{"hypertable"}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(namespace+"_hypertable_"+name, help, labels, nil)
+	}
+	return &hypertableCollector{
+		sizes:            sizes,
+		interval:         interval,
+		logger:           logger,
+		chunks:           desc("chunks", "Chunks in the hypertable."),
+		compressedChunks: desc("compressed_chunks", "Compressed chunks in the hypertable."),
+		totalBytes:       desc("size_bytes", "Total size of the hypertable including indexes."),
+		beforeBytes:      desc("compression_before_bytes", "Size of compressed chunks before compression."),
+		afterBytes:       desc("compression_after_bytes", "Size of compressed chunks after compression."),
+	}
+}
+
+func (c *hypertableCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{c.chunks, c.compressedChunks, c.totalBytes, c.beforeBytes, c.afterBytes} {
+		ch <- d
+	}
+}
+
+func (c *hypertableCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, size := range c.load() {
+		ch <- prometheus.MustNewConstMetric(c.chunks, prometheus.GaugeValue, float64(size.Chunks), size.Name)
+		ch <- prometheus.MustNewConstMetric(c.compressedChunks, prometheus.GaugeValue, float64(size.CompressedChunks), size.Name)
+		ch <- prometheus.MustNewConstMetric(c.totalBytes, prometheus.GaugeValue, float64(size.TotalBytes), size.Name)
+		ch <- prometheus.MustNewConstMetric(c.beforeBytes, prometheus.GaugeValue, float64(size.BeforeCompressionBytes), size.Name)
+		ch <- prometheus.MustNewConstMetric(c.afterBytes, prometheus.GaugeValue, float64(size.AfterCompressionBytes), size.Name)
+	}
+}
+
+func (c *hypertableCollector) load() []utils.HypertableSize {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.cached != nil && time.Since(c.fetchedAt) < c.interval {
+		return c.cached
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	sizes, err := c.sizes(ctx)
+	if err != nil {
+		// Keep exporting the last known sizes
+		c.logger.Error().Err(err).Msg("Failed to collect hypertable metrics")
+		return c.cached
+	}
+	c.cached, c.fetchedAt = sizes, time.Now()
+	return sizes
+}
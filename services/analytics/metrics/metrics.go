@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "analytics"
+
+// Event outcomes counted by EventsTotal
+const (
+	EventAccepted     = "accepted"
+	EventRejected     = "rejected"
+	EventThrottled    = "throttled"
+	EventFailed       = "failed"
+	EventStored       = "stored"
+	EventDeadLettered = "dead_lettered"
+)
+
+// InvalidWebsite labels rejected events, whose website_id has not been
+// validated and would otherwise let clients create arbitrary series
+const InvalidWebsite = "invalid"
+
+// Registry holds the service metrics, separate from the global default
+// registry so only what is registered here is exported
+var Registry = prometheus.NewRegistry()
+
+var (
+	// EventsTotal counts ingested events per website and outcome
+	EventsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "events_total",
+		Help:      "Events by website and outcome (accepted, rejected, throttled, failed, stored, dead_lettered).",
+	}, []string{"website_id", "status"})
+
+	// BatchDuration observes how long writing a batch to the database takes
+	BatchDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "event_batch_duration_seconds",
+		Help:      "Time taken to write an event batch to the database.",
+		Buckets:   prometheus.ExponentialBuckets(0.005, 2, 12),
+	}, []string{"result"})
+
+	// BatchSize observes the number of events per database write
+	BatchSize = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "event_batch_size",
+		Help:      "Events per batch written to the database.",
+		Buckets:   []float64{1, 5, 10, 25, 50, 100, 250, 500, 1000},
+	})
+
+	// HTTPRequestDuration observes request latency per route
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by method, route and status code.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		EventsTotal,
+		BatchDuration,
+		BatchSize,
+		HTTPRequestDuration,
+	)
+}
+
+// Handler serves the registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{})
+}
+
+// CountEvents adds n events with the given outcome for a website
+func CountEvents(websiteID, status string, n int) {
+	if n > 0 {
+		EventsTotal.WithLabelValues(websiteID, status).Add(float64(n))
+	}
+}
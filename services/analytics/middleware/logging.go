@@ -1,25 +1,41 @@
 package middleware
 
 import (
+	"analytics-app/metrics"
 	"analytics-app/utils"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/rs/zerolog"
 )
 
+// Logger logs every request and records its latency per route
 func Logger(logger zerolog.Logger) gin.HandlerFunc {
-	return gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+		latency := time.Since(start)
+
+		// Unmatched paths share one label to keep cardinality bounded
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		metrics.HTTPRequestDuration.
+			WithLabelValues(c.Request.Method, route, strconv.Itoa(c.Writer.Status())).
+			Observe(latency.Seconds())
+
 		logger.Info().
-			Str("client_ip", param.ClientIP).
-			Str("method", param.Method).
-			Str("path", param.Path).
-			Int("status", param.StatusCode).
-			Dur("latency", param.Latency).
-			Str("user_agent", param.Request.UserAgent()).
-			Time("timestamp", param.TimeStamp).
+			Str("client_ip", c.ClientIP()).
+			Str("method", c.Request.Method).
+			Str("path", c.Request.URL.Path).
+			Int("status", c.Writer.Status()).
+			Dur("latency", latency).
+			Str("user_agent", c.Request.UserAgent()).
+			Time("timestamp", start).
 			Msg("HTTP Request")
-		return ""
-	})
+	}
 }
 
 // ClientIPMiddleware sets the client IP in the context for geolocation
@@ -0,0 +1,8 @@
+-- Rollback raw custom events storage
+
+DROP VIEW IF EXISTS custom_events_aggregated;
+DROP MATERIALIZED VIEW IF EXISTS custom_events_hourly;
+
+ALTER TABLE IF EXISTS custom_events_aggregated_legacy RENAME TO custom_events_aggregated;
+
+DROP TABLE IF EXISTS custom_events CASCADE;
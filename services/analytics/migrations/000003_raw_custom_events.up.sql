@@ -0,0 +1,82 @@
+-- Store custom events row by row instead of only per-signature aggregates.
+-- The aggregate is kept as a continuous aggregate over the raw table; rows
+-- written by the old upsert path are preserved in custom_events_aggregated_legacy.
+
+CREATE TABLE custom_events (
+    id UUID DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    visitor_id VARCHAR(255) NOT NULL,
+    session_id VARCHAR(255),
+    event_type VARCHAR(255) NOT NULL,
+    event_signature VARCHAR(64) NOT NULL,
+    page TEXT,
+    referrer TEXT,
+    user_agent TEXT,
+    ip_address INET,
+    country VARCHAR(2),
+    city VARCHAR(100),
+    browser VARCHAR(100),
+    device VARCHAR(50),
+    os VARCHAR(100),
+    utm_source VARCHAR(255),
+    utm_medium VARCHAR(255),
+    utm_campaign VARCHAR(255),
+    utm_term VARCHAR(255),
+    utm_content VARCHAR(255),
+    time_on_page INTEGER,
+    properties JSONB,
+    timestamp TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (id, timestamp)
+);
+
+SELECT create_hypertable('custom_events', 'timestamp', if_not_exists => TRUE);
+
+CREATE INDEX idx_custom_events_website_timestamp ON custom_events(website_id, timestamp DESC);
+CREATE INDEX idx_custom_events_website_type ON custom_events(website_id, event_type, timestamp DESC);
+CREATE INDEX idx_custom_events_session_id ON custom_events(session_id);
+CREATE INDEX idx_custom_events_visitor_id ON custom_events(visitor_id);
+
+ALTER TABLE custom_events SET (
+    timescaledb.compress,
+    timescaledb.compress_segmentby = 'website_id',
+    timescaledb.compress_orderby = 'timestamp DESC, id'
+);
+
+SELECT add_compression_policy('custom_events', INTERVAL '7 days', if_not_exists => TRUE);
+SELECT add_retention_policy('custom_events', INTERVAL '2 years', if_not_exists => TRUE);
+
+-- Keep the historical aggregates around under a new name
+ALTER TABLE custom_events_aggregated RENAME TO custom_events_aggregated_legacy;
+
+-- Hourly signature counts derived from the raw table. The policy refreshes
+-- from the beginning of time so that deleted, imported and late events reach
+-- every bucket; after the first run only invalidated buckets are recomputed.
+CREATE MATERIALIZED VIEW custom_events_hourly
+WITH (timescaledb.continuous) AS
+SELECT
+    time_bucket('1 hour', timestamp) AS bucket,
+    website_id,
+    event_type,
+    event_signature,
+    COUNT(*) AS count,
+    last(properties, timestamp) AS sample_properties,
+    MIN(timestamp) AS first_seen,
+    MAX(timestamp) AS last_seen
+FROM custom_events
+GROUP BY bucket, website_id, event_type, event_signature
+WITH NO DATA;
+
+SELECT add_continuous_aggregate_policy('custom_events_hourly',
+    start_offset => NULL,
+    end_offset => INTERVAL '1 hour',
+    schedule_interval => INTERVAL '30 minutes',
+    if_not_exists => TRUE);
+
+-- Same shape as the old table so existing consumers keep working
+CREATE VIEW custom_events_aggregated AS
+SELECT website_id, event_type, event_signature, count, sample_properties, first_seen, last_seen
+FROM custom_events_hourly
+UNION ALL
+SELECT website_id, event_type, event_signature, count, sample_properties, first_seen, last_seen
+FROM custom_events_aggregated_legacy;
@@ -0,0 +1,10 @@
+-- Rollback funnel step order and conversion window
+
+DROP INDEX IF EXISTS idx_custom_events_website_visitor_timestamp;
+DROP INDEX IF EXISTS idx_events_website_visitor_timestamp;
+
+ALTER TABLE funnels DROP CONSTRAINT IF EXISTS funnels_conversion_window_check;
+ALTER TABLE funnels DROP CONSTRAINT IF EXISTS funnels_step_order_check;
+
+ALTER TABLE funnels DROP COLUMN IF EXISTS conversion_window_hours;
+ALTER TABLE funnels DROP COLUMN IF EXISTS step_order;
@@ -0,0 +1,11 @@
+-- Funnels are evaluated server-side from events; store how steps are matched
+
+ALTER TABLE funnels ADD COLUMN IF NOT EXISTS step_order VARCHAR(10) NOT NULL DEFAULT 'strict';
+ALTER TABLE funnels ADD COLUMN IF NOT EXISTS conversion_window_hours INTEGER NOT NULL DEFAULT 24;
+
+ALTER TABLE funnels ADD CONSTRAINT funnels_step_order_check CHECK (step_order IN ('strict', 'any'));
+ALTER TABLE funnels ADD CONSTRAINT funnels_conversion_window_check CHECK (conversion_window_hours > 0);
+
+-- Funnel evaluation scans a website's events per visitor in time order
+CREATE INDEX IF NOT EXISTS idx_events_website_visitor_timestamp ON events(website_id, visitor_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_custom_events_website_visitor_timestamp ON custom_events(website_id, visitor_id, timestamp);
@@ -0,0 +1,10 @@
+-- Rollback bot detection
+
+DROP INDEX IF EXISTS idx_custom_events_bots;
+DROP INDEX IF EXISTS idx_events_bots;
+
+ALTER TABLE custom_events DROP COLUMN IF EXISTS bot_name;
+ALTER TABLE custom_events DROP COLUMN IF EXISTS is_bot;
+
+ALTER TABLE events DROP COLUMN IF EXISTS bot_name;
+ALTER TABLE events DROP COLUMN IF EXISTS is_bot;
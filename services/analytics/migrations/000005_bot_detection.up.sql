@@ -0,0 +1,10 @@
+-- Events are classified as bot or human at ingestion time
+ALTER TABLE events ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE events ADD COLUMN IF NOT EXISTS bot_name VARCHAR(100);
+
+ALTER TABLE custom_events ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE custom_events ADD COLUMN IF NOT EXISTS bot_name VARCHAR(100);
+
+-- Bot traffic is a small share of events; keep the report index small
+CREATE INDEX IF NOT EXISTS idx_events_bots ON events(website_id, timestamp DESC) WHERE is_bot;
+CREATE INDEX IF NOT EXISTS idx_custom_events_bots ON custom_events(website_id, timestamp DESC) WHERE is_bot;
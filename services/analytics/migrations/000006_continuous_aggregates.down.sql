@@ -0,0 +1,5 @@
+-- Rollback continuous aggregates
+
+DROP MATERIALIZED VIEW IF EXISTS sessions_hourly;
+DROP MATERIALIZED VIEW IF EXISTS events_daily;
+DROP MATERIALIZED VIEW IF EXISTS events_hourly;
@@ -0,0 +1,3 @@
+-- Rollback visitors
+
+DROP TABLE IF EXISTS visitors;
@@ -0,0 +1,20 @@
+-- First and last pageview of every visitor, so new and returning visitors
+-- can be told apart without scanning their whole history. Rows are upserted
+-- as pageviews are stored.
+CREATE TABLE IF NOT EXISTS visitors (
+    website_id VARCHAR(24) NOT NULL,
+    visitor_id VARCHAR(255) NOT NULL,
+    first_seen TIMESTAMPTZ NOT NULL,
+    last_seen TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (website_id, visitor_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_visitors_first_seen ON visitors(website_id, first_seen);
+
+-- Backfill from stored pageviews, leaving out bot traffic
+INSERT INTO visitors (website_id, visitor_id, first_seen, last_seen)
+SELECT website_id, visitor_id, MIN(timestamp), MAX(timestamp)
+FROM events
+WHERE event_type = 'pageview' AND NOT is_bot
+GROUP BY website_id, visitor_id
+ON CONFLICT (website_id, visitor_id) DO NOTHING;
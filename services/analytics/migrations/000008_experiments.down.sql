@@ -0,0 +1,4 @@
+-- Rollback experiments
+
+DROP INDEX IF EXISTS idx_experiments_website_id;
+DROP TABLE IF EXISTS experiments;
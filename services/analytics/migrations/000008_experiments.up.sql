@@ -0,0 +1,27 @@
+-- A/B experiments: the site records the visitor's variant in an event
+-- property and the goal is a custom event or a funnel completion
+
+CREATE TABLE IF NOT EXISTS experiments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    user_id VARCHAR(24),
+    property VARCHAR(100) NOT NULL,
+    variants JSONB NOT NULL,
+    control_variant VARCHAR(100) NOT NULL,
+    goal_type VARCHAR(10) NOT NULL,
+    goal_event TEXT,
+    goal_funnel_id UUID REFERENCES funnels(id) ON DELETE SET NULL,
+    minimum_detectable_effect DOUBLE PRECISION NOT NULL DEFAULT 0.1,
+    status VARCHAR(10) NOT NULL DEFAULT 'draft',
+    started_at TIMESTAMPTZ,
+    ended_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT experiments_goal_type_check CHECK (goal_type IN ('event', 'funnel')),
+    CONSTRAINT experiments_status_check CHECK (status IN ('draft', 'running', 'stopped')),
+    CONSTRAINT experiments_mde_check CHECK (minimum_detectable_effect > 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_experiments_website_id ON experiments(website_id);
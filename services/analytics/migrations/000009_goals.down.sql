@@ -0,0 +1,4 @@
+-- Rollback goals
+
+DROP INDEX IF EXISTS idx_goals_website_id;
+DROP TABLE IF EXISTS goals;
@@ -0,0 +1,25 @@
+-- Goals: conversions defined by a page pattern or a custom event, used to
+-- report conversions in the breakdown endpoints
+
+CREATE TABLE IF NOT EXISTS goals (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    user_id VARCHAR(24),
+    type VARCHAR(10) NOT NULL,
+    page_pattern TEXT,
+    event_name VARCHAR(255),
+    property_key VARCHAR(100),
+    property_value TEXT,
+    value DOUBLE PRECISION,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT goals_type_check CHECK (type IN ('page', 'event')),
+    CONSTRAINT goals_condition_check CHECK (
+        (type = 'page' AND page_pattern IS NOT NULL) OR
+        (type = 'event' AND event_name IS NOT NULL)
+    )
+);
+
+CREATE INDEX IF NOT EXISTS idx_goals_website_id ON goals(website_id);
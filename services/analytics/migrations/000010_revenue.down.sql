@@ -0,0 +1,7 @@
+-- Rollback revenue
+
+DROP TABLE IF EXISTS exchange_rates;
+DROP TABLE IF EXISTS revenue_settings;
+DROP INDEX IF EXISTS idx_orders_session_id;
+DROP INDEX IF EXISTS idx_orders_website_timestamp;
+DROP TABLE IF EXISTS orders;
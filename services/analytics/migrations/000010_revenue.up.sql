@@ -0,0 +1,56 @@
+-- Revenue: orders recorded from purchase events, deduplicated by order ID,
+-- and the currencies they are reported in
+
+CREATE TABLE IF NOT EXISTS orders (
+    website_id VARCHAR(24) NOT NULL,
+    order_id VARCHAR(255) NOT NULL,
+    event_id UUID NOT NULL,
+    visitor_id VARCHAR(255) NOT NULL,
+    session_id VARCHAR(255),
+    amount NUMERIC(18, 4) NOT NULL,
+    currency CHAR(3) NOT NULL,
+    items JSONB,
+    timestamp TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (website_id, order_id),
+    CONSTRAINT orders_amount_check CHECK (amount >= 0)
+);
+
+CREATE INDEX IF NOT EXISTS idx_orders_website_timestamp ON orders(website_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_session_id ON orders(session_id);
+
+-- Reporting currency per website; websites without a row report in USD
+CREATE TABLE IF NOT EXISTS revenue_settings (
+    website_id VARCHAR(24) PRIMARY KEY,
+    base_currency CHAR(3) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Units of each currency per US dollar
+CREATE TABLE IF NOT EXISTS exchange_rates (
+    currency CHAR(3) PRIMARY KEY,
+    rate NUMERIC(18, 8) NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT exchange_rates_rate_check CHECK (rate > 0)
+);
+
+-- Orders placed before this migration, from stored purchase events; the
+-- first event of an order wins. Order IDs and amounts that don't fit the
+-- columns are skipped rather than aborting the migration.
+INSERT INTO orders (website_id, order_id, event_id, visitor_id, session_id, amount, currency, items, timestamp)
+SELECT DISTINCT ON (website_id, properties ->> 'order_id')
+    website_id, properties ->> 'order_id', id, visitor_id, session_id,
+    (properties ->> 'amount')::NUMERIC, upper(properties ->> 'currency'),
+    CASE WHEN jsonb_typeof(properties -> 'items') = 'array' THEN properties -> 'items' END,
+    timestamp
+FROM custom_events
+WHERE event_type = 'purchase'
+AND COALESCE(properties ->> 'order_id', '') <> ''
+AND length(properties ->> 'order_id') <= 255
+AND CASE WHEN properties ->> 'amount' ~ '^[0-9]{1,14}(\.[0-9]+)?$'
+    THEN round((properties ->> 'amount')::NUMERIC, 4) < 1e14
+    ELSE false
+END
+AND properties ->> 'currency' ~* '^[a-z]{3}$'
+ORDER BY website_id, properties ->> 'order_id', timestamp
+ON CONFLICT (website_id, order_id) DO NOTHING;
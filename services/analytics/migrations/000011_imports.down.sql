@@ -0,0 +1,12 @@
+-- Rollback imports; imported events are removed with their import marker
+
+DELETE FROM events WHERE import_id IS NOT NULL;
+DELETE FROM custom_events WHERE import_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_custom_events_import;
+DROP INDEX IF EXISTS idx_events_import;
+ALTER TABLE custom_events DROP COLUMN IF EXISTS import_id;
+ALTER TABLE events DROP COLUMN IF EXISTS import_id;
+
+DROP TABLE IF EXISTS imported_daily_stats;
+DROP TABLE IF EXISTS imports;
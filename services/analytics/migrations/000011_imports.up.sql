@@ -0,0 +1,47 @@
+-- Historical data imported from other analytics tools. Imported events and
+-- aggregates carry the import they came from, so an import can be rolled
+-- back without touching tracked data.
+
+CREATE TABLE IF NOT EXISTS imports (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    source VARCHAR(50) NOT NULL,
+    filename TEXT,
+    status VARCHAR(20) NOT NULL,
+    rows_read BIGINT NOT NULL DEFAULT 0,
+    rows_skipped BIGINT NOT NULL DEFAULT 0,
+    events_imported BIGINT NOT NULL DEFAULT 0,
+    aggregates_imported BIGINT NOT NULL DEFAULT 0,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    completed_at TIMESTAMPTZ,
+    rolled_back_at TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS idx_imports_website ON imports(website_id, created_at DESC);
+
+-- Tracked events have no import
+ALTER TABLE events ADD COLUMN IF NOT EXISTS import_id UUID;
+ALTER TABLE custom_events ADD COLUMN IF NOT EXISTS import_id UUID;
+
+CREATE INDEX IF NOT EXISTS idx_events_import ON events(import_id) WHERE import_id IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_custom_events_import ON custom_events(import_id) WHERE import_id IS NOT NULL;
+
+-- Daily aggregates of tools that only export aggregates. Rows without a page
+-- and a source are the website's totals for the day; visit_duration is the
+-- total of the day's visits in seconds.
+CREATE TABLE IF NOT EXISTS imported_daily_stats (
+    import_id UUID NOT NULL REFERENCES imports(id) ON DELETE CASCADE,
+    website_id VARCHAR(24) NOT NULL,
+    date DATE NOT NULL,
+    page TEXT NOT NULL DEFAULT '',
+    source TEXT NOT NULL DEFAULT '',
+    pageviews BIGINT NOT NULL DEFAULT 0,
+    visitors BIGINT NOT NULL DEFAULT 0,
+    visits BIGINT NOT NULL DEFAULT 0,
+    bounces BIGINT NOT NULL DEFAULT 0,
+    visit_duration BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE INDEX IF NOT EXISTS idx_imported_daily_stats_website_date ON imported_daily_stats(website_id, date);
+CREATE INDEX IF NOT EXISTS idx_imported_daily_stats_import ON imported_daily_stats(import_id);
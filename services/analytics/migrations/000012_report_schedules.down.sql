@@ -0,0 +1,5 @@
+-- Rollback report schedules
+
+DROP INDEX IF EXISTS idx_report_schedules_due;
+DROP INDEX IF EXISTS idx_report_schedules_website;
+DROP TABLE IF EXISTS report_schedules;
@@ -0,0 +1,25 @@
+-- Scheduled digests of a website's analytics, delivered by email or to a
+-- signed webhook
+
+CREATE TABLE IF NOT EXISTS report_schedules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    cron VARCHAR(100) NOT NULL,
+    timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    range_days INTEGER NOT NULL DEFAULT 7,
+    channel VARCHAR(20) NOT NULL,
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    webhook_url TEXT,
+    webhook_secret TEXT,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    next_run_at TIMESTAMPTZ NOT NULL,
+    last_run_at TIMESTAMPTZ,
+    last_error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT report_schedules_range_days_check CHECK (range_days BETWEEN 1 AND 90)
+);
+
+CREATE INDEX IF NOT EXISTS idx_report_schedules_website ON report_schedules(website_id);
+CREATE INDEX IF NOT EXISTS idx_report_schedules_due ON report_schedules(next_run_at) WHERE enabled;
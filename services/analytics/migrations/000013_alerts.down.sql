@@ -0,0 +1,6 @@
+-- Rollback alerts
+
+DROP INDEX IF EXISTS idx_alerts_website_hour;
+DROP TABLE IF EXISTS alerts;
+DROP INDEX IF EXISTS idx_alert_rules_website;
+DROP TABLE IF EXISTS alert_rules;
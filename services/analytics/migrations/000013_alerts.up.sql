@@ -0,0 +1,46 @@
+-- Alert rules over the hourly pageview and visitor series, and the alerts
+-- they triggered
+
+CREATE TABLE IF NOT EXISTS alert_rules (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    metric VARCHAR(20) NOT NULL,
+    type VARCHAR(20) NOT NULL,
+    direction VARCHAR(20) NOT NULL,
+    baseline VARCHAR(30),
+    threshold DOUBLE PRECISION NOT NULL DEFAULT 0,
+    sensitivity DOUBLE PRECISION NOT NULL DEFAULT 0,
+    baseline_weeks INTEGER NOT NULL DEFAULT 0,
+    channel VARCHAR(20) NOT NULL,
+    recipients TEXT[] NOT NULL DEFAULT '{}',
+    webhook_url TEXT,
+    webhook_secret TEXT,
+    cooldown_minutes INTEGER NOT NULL DEFAULT 360,
+    enabled BOOLEAN NOT NULL DEFAULT TRUE,
+    last_evaluated_hour TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX IF NOT EXISTS idx_alert_rules_website ON alert_rules(website_id);
+
+-- One alert per rule and hour, however many instances evaluate the rule
+CREATE TABLE IF NOT EXISTS alerts (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    rule_id UUID NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    rule_name VARCHAR(255) NOT NULL,
+    website_id VARCHAR(24) NOT NULL,
+    hour TIMESTAMPTZ NOT NULL,
+    metric VARCHAR(20) NOT NULL,
+    value DOUBLE PRECISION NOT NULL,
+    expected DOUBLE PRECISION NOT NULL,
+    z_score DOUBLE PRECISION,
+    message TEXT NOT NULL,
+    status VARCHAR(20) NOT NULL,
+    error TEXT,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (rule_id, hour)
+);
+
+CREATE INDEX IF NOT EXISTS idx_alerts_website_hour ON alerts(website_id, hour DESC);
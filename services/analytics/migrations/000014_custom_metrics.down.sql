@@ -0,0 +1,4 @@
+-- Rollback custom metrics
+
+DROP INDEX IF EXISTS idx_custom_metrics_website_id;
+DROP TABLE IF EXISTS custom_metrics;
@@ -0,0 +1,16 @@
+-- Custom metrics: per-website KPIs defined as expressions over event
+-- aggregates, reported alongside the dashboard, time series and breakdowns
+
+CREATE TABLE IF NOT EXISTS custom_metrics (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    website_id VARCHAR(24) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    description TEXT,
+    expression TEXT NOT NULL,
+    format VARCHAR(20) NOT NULL DEFAULT 'number',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    CONSTRAINT custom_metrics_format_check CHECK (format IN ('number', 'percentage', 'currency'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_custom_metrics_website_id ON custom_metrics(website_id);
@@ -0,0 +1,140 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Metrics of the hourly series alert rules watch
+const (
+	AlertMetricPageviews = "pageviews"
+	AlertMetricVisitors  = "visitors"
+)
+
+// Alert rule types
+const (
+	// AlertTypeThreshold compares an hour with a fixed value or with a
+	// percentage of an earlier hour
+	AlertTypeThreshold = "threshold"
+	// AlertTypeAnomaly compares an hour with the same hour of the previous
+	// weeks, alerting on z-scores beyond the rule's sensitivity
+	AlertTypeAnomaly = "anomaly"
+)
+
+// Directions an hour has to move in to trigger a rule
+const (
+	AlertDirectionBelow = "below"
+	AlertDirectionAbove = "above"
+	// AlertDirectionOutside triggers anomaly rules both ways
+	AlertDirectionOutside = "outside"
+)
+
+// What threshold rules compare an hour with
+const (
+	AlertBaselineAbsolute         = "absolute"
+	AlertBaselinePreviousHour     = "previous_hour"
+	AlertBaselineSameHourLastWeek = "same_hour_last_week"
+)
+
+// Alert delivery channels
+const (
+	AlertChannelEmail   = "email"
+	AlertChannelWebhook = "webhook"
+	// AlertChannelSlack posts Slack-compatible {"text": ...} payloads to an
+	// incoming webhook URL
+	AlertChannelSlack = "slack"
+)
+
+// Alert statuses
+const (
+	AlertPending   = "pending"
+	AlertSent      = "sent"
+	AlertThrottled = "throttled"
+	AlertFailed    = "failed"
+)
+
+// AlertRule watches a website's hourly pageviews or visitors. Threshold
+// rules trigger when an hour is below or above Threshold, an absolute count
+// or, against an earlier hour, a percentage of it: "pageviews below 50% of
+// the same hour last week". Anomaly rules trigger when an hour's z-score
+// against the same hour of the last BaselineWeeks weeks reaches Sensitivity.
+type AlertRule struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	WebsiteID     string    `json:"website_id" db:"website_id"`
+	Name          string    `json:"name" db:"name"`
+	Metric        string    `json:"metric" db:"metric"`       // pageviews, visitors
+	Type          string    `json:"type" db:"type"`           // threshold, anomaly
+	Direction     string    `json:"direction" db:"direction"` // below, above, outside
+	Baseline      string    `json:"baseline,omitempty" db:"baseline"`
+	Threshold     float64   `json:"threshold,omitempty" db:"threshold"`
+	Sensitivity   float64   `json:"sensitivity,omitempty" db:"sensitivity"`
+	BaselineWeeks int       `json:"baseline_weeks,omitempty" db:"baseline_weeks"`
+	Channel       string    `json:"channel" db:"channel"` // email, webhook, slack
+	Recipients    []string  `json:"recipients,omitempty" db:"recipients"`
+	WebhookURL    *string   `json:"webhook_url,omitempty" db:"webhook_url"`
+	// WebhookSecret signs webhook deliveries; it is never returned
+	WebhookSecret *string `json:"-" db:"webhook_secret"`
+	// CooldownMinutes is how long after an alert is sent further alerts of
+	// the rule are throttled
+	CooldownMinutes   int        `json:"cooldown_minutes" db:"cooldown_minutes"`
+	Enabled           bool       `json:"enabled" db:"enabled"`
+	LastEvaluatedHour *time.Time `json:"last_evaluated_hour,omitempty" db:"last_evaluated_hour"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type CreateAlertRuleRequest struct {
+	WebsiteID       string   `json:"website_id" binding:"required"`
+	Name            string   `json:"name" binding:"required"`
+	Metric          string   `json:"metric"`
+	Type            string   `json:"type" binding:"required"`
+	Direction       string   `json:"direction"`
+	Baseline        string   `json:"baseline"`
+	Threshold       float64  `json:"threshold"`
+	Sensitivity     float64  `json:"sensitivity"`
+	BaselineWeeks   int      `json:"baseline_weeks"`
+	Channel         string   `json:"channel" binding:"required"`
+	Recipients      []string `json:"recipients"`
+	WebhookURL      *string  `json:"webhook_url"`
+	WebhookSecret   *string  `json:"webhook_secret"`
+	CooldownMinutes *int     `json:"cooldown_minutes"`
+	Enabled         *bool    `json:"enabled"`
+}
+
+type UpdateAlertRuleRequest struct {
+	Name            *string  `json:"name"`
+	Metric          *string  `json:"metric"`
+	Type            *string  `json:"type"`
+	Direction       *string  `json:"direction"`
+	Baseline        *string  `json:"baseline"`
+	Threshold       *float64 `json:"threshold"`
+	Sensitivity     *float64 `json:"sensitivity"`
+	BaselineWeeks   *int     `json:"baseline_weeks"`
+	Channel         *string  `json:"channel"`
+	Recipients      []string `json:"recipients"`
+	WebhookURL      *string  `json:"webhook_url"`
+	WebhookSecret   *string  `json:"webhook_secret"`
+	CooldownMinutes *int     `json:"cooldown_minutes"`
+	Enabled         *bool    `json:"enabled"`
+}
+
+// Alert is a triggered alert rule for an hour
+type Alert struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	RuleID    uuid.UUID `json:"rule_id" db:"rule_id"`
+	RuleName  string    `json:"rule_name" db:"rule_name"`
+	WebsiteID string    `json:"website_id" db:"website_id"`
+	Hour      time.Time `json:"hour" db:"hour"`
+	Metric    string    `json:"metric" db:"metric"`
+	Value     float64   `json:"value" db:"value"`
+	// Expected is the baseline the value was compared with: the threshold,
+	// the earlier hour's value or the mean of the previous weeks
+	Expected float64  `json:"expected" db:"expected"`
+	ZScore   *float64 `json:"z_score,omitempty" db:"z_score"`
+	Message  string   `json:"message" db:"message"`
+	Status   string   `json:"status" db:"status"`
+	Error    *string  `json:"error,omitempty" db:"error"`
+	// CreatedAt is when the alert was triggered
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
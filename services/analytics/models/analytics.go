@@ -33,6 +33,7 @@ type DashboardData struct {
 	BounceRate      float64 `json:"bounce_rate"`
 	// Comparison metrics for growth indicators
 	Comparison *ComparisonMetrics `json:"comparison,omitempty"`
+	CustomMetricValues
 }
 
 type PageStat struct {
@@ -43,6 +44,8 @@ type PageStat struct {
 	AvgTime    *int     `json:"avg_time,omitempty" db:"avg_time"`
 	ExitRate   *float64 `json:"exit_rate,omitempty" db:"exit_rate"`
 	EntryRate  *float64 `json:"entry_rate,omitempty" db:"entry_rate"`
+	GoalConversions
+	CustomMetricValues
 }
 
 type ReferrerStat struct {
@@ -50,6 +53,8 @@ type ReferrerStat struct {
 	Views      int      `json:"views" db:"views"`
 	Unique     int      `json:"unique" db:"unique"`
 	BounceRate *float64 `json:"bounce_rate,omitempty" db:"bounce_rate"`
+	GoalConversions
+	CustomMetricValues
 }
 
 type SourceStat struct {
@@ -57,6 +62,9 @@ type SourceStat struct {
 	Views          int     `json:"views" db:"views"`
 	UniqueVisitors int     `json:"unique_visitors" db:"unique_visitors"`
 	BounceRate     float64 `json:"bounce_rate" db:"bounce_rate"`
+	GoalConversions
+	RevenueStats
+	CustomMetricValues
 }
 
 type CountryStat struct {
@@ -64,6 +72,9 @@ type CountryStat struct {
 	Views      int      `json:"views" db:"views"`
 	Unique     int      `json:"unique" db:"unique"`
 	BounceRate *float64 `json:"bounce_rate,omitempty" db:"bounce_rate"`
+	GoalConversions
+	RevenueStats
+	CustomMetricValues
 }
 
 type BrowserStat struct {
@@ -71,6 +82,8 @@ type BrowserStat struct {
 	Views      int      `json:"views" db:"views"`
 	Unique     int      `json:"unique" db:"unique"`
 	BounceRate *float64 `json:"bounce_rate,omitempty" db:"bounce_rate"`
+	GoalConversions
+	CustomMetricValues
 }
 
 type DeviceStat struct {
@@ -78,6 +91,8 @@ type DeviceStat struct {
 	Views      int      `json:"views" db:"views"`
 	Unique     int      `json:"unique" db:"unique"`
 	BounceRate *float64 `json:"bounce_rate,omitempty" db:"bounce_rate"`
+	GoalConversions
+	CustomMetricValues
 }
 
 type OSStat struct {
@@ -85,12 +100,15 @@ type OSStat struct {
 	Views      int      `json:"views" db:"views"`
 	Unique     int      `json:"unique" db:"unique"`
 	BounceRate *float64 `json:"bounce_rate,omitempty" db:"bounce_rate"`
+	GoalConversions
+	CustomMetricValues
 }
 
 type DailyStat struct {
 	Date   time.Time `json:"date" db:"date"`
 	Views  int       `json:"views" db:"views"`
 	Unique int       `json:"unique" db:"unique"`
+	CustomMetricValues
 }
 
 type HourlyStat struct {
@@ -99,15 +117,26 @@ type HourlyStat struct {
 	Views     int       `json:"views" db:"views"`
 	Unique    int       `json:"unique" db:"unique"`
 	HourLabel string    `json:"hour_label" db:"hour_label"`
+	CustomMetricValues
 }
 
 type CustomEventStat struct {
 	EventType        string     `json:"event_type" db:"event_type"`
 	Count            int        `json:"count" db:"count"`
+	UniqueVisitors   int        `json:"unique_visitors" db:"unique_visitors"`
+	Sessions         int        `json:"sessions" db:"sessions"`
 	Description      *string    `json:"description,omitempty" db:"description"`
 	CommonProperties Properties `json:"common_properties,omitempty" db:"common_properties"`
 	SampleProperties Properties `json:"sample_properties,omitempty" db:"sample_properties"`
 	SampleEvent      Properties `json:"sample_event,omitempty" db:"sample_event"`
+	// Top pages the event was fired on
+	Pages []CustomEventPageStat `json:"pages,omitempty"`
+}
+
+type CustomEventPageStat struct {
+	Page           string `json:"page" db:"page"`
+	Count          int    `json:"count" db:"count"`
+	UniqueVisitors int    `json:"unique_visitors" db:"unique_visitors"`
 }
 
 type TrafficSummary struct {
@@ -125,4 +154,97 @@ type TrafficSummary struct {
 	ReturningVisitors  int     `json:"returning_visitors" db:"returning_visitors"`
 	EngagementScore    float64 `json:"engagement_score" db:"engagement_score"`
 	RetentionRate      float64 `json:"retention_rate" db:"retention_rate"`
+	RetentionDays      int     `json:"retention_days" db:"retention_days"`
+}
+
+// BotTrafficReport summarizes traffic flagged as automated
+type BotTrafficReport struct {
+	TotalEvents int         `json:"total_events" db:"total_events"`
+	BotEvents   int         `json:"bot_events" db:"bot_events"`
+	BotShare    float64     `json:"bot_share" db:"bot_share"`
+	Bots        []BotStat   `json:"bots"`
+	Daily       []DailyStat `json:"daily"`
+}
+
+type BotStat struct {
+	BotName   string    `json:"bot_name" db:"bot_name"`
+	Events    int       `json:"events" db:"events"`
+	PageViews int       `json:"page_views" db:"page_views"`
+	Visitors  int       `json:"visitors" db:"visitors"`
+	LastSeen  time.Time `json:"last_seen" db:"last_seen"`
+}
+
+// Granularities of the activity trends report
+const (
+	GranularityHour  = "hour"
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// ActivityCount is the pageview activity in one trend bucket
+type ActivityCount struct {
+	Period    time.Time `json:"period" db:"period"`
+	PageViews int       `json:"page_views" db:"page_views"`
+	Visitors  int       `json:"visitors" db:"visitors"`
+	Sessions  int       `json:"sessions" db:"sessions"`
+}
+
+// TrendBucket pairs a bucket of the requested range with the bucket at the
+// same position in the previous range. Changes are percentages and are nil
+// when the previous value is zero.
+type TrendBucket struct {
+	Period            time.Time  `json:"period"`
+	PreviousPeriod    *time.Time `json:"previous_period,omitempty"`
+	PageViews         int        `json:"page_views"`
+	Visitors          int        `json:"visitors"`
+	Sessions          int        `json:"sessions"`
+	PreviousPageViews int        `json:"previous_page_views"`
+	PreviousVisitors  int        `json:"previous_visitors"`
+	PreviousSessions  int        `json:"previous_sessions"`
+	PageViewsChange   *float64   `json:"page_views_change"`
+	VisitorsChange    *float64   `json:"visitors_change"`
+	SessionsChange    *float64   `json:"sessions_change"`
+}
+
+// ActivityTrends compares bucketed activity in a range with the range of
+// equal length immediately before it
+type ActivityTrends struct {
+	Granularity   string        `json:"granularity"`
+	Timezone      string        `json:"timezone"`
+	PreviousRange string        `json:"previous_range"`
+	Buckets       []TrendBucket `json:"buckets"`
+}
+
+// CohortCell counts the visitors of a cohort active in one period after the
+// cohort's start; period 0 holds the cohort size
+type CohortCell struct {
+	Start    time.Time `json:"start" db:"start"`
+	Period   int       `json:"period" db:"period"`
+	Visitors int       `json:"visitors" db:"visitors"`
+}
+
+// Cohort is one row of a retention matrix
+type Cohort struct {
+	Start     time.Time         `json:"start"`
+	Size      int               `json:"size"`
+	Retention []CohortRetention `json:"retention"`
+}
+
+// CohortRetention is the share of a cohort that came back in a period. The
+// most recent period of a cohort may still be in progress.
+type CohortRetention struct {
+	Period   int     `json:"period"`
+	Visitors int     `json:"visitors"`
+	Rate     float64 `json:"rate"`
+}
+
+// CohortReport groups visitors by the period of their first visit, or of
+// their first occurrence of CohortEvent, and tracks their return
+type CohortReport struct {
+	Granularity string   `json:"granularity"`
+	CohortEvent string   `json:"cohort_event,omitempty"`
+	Periods     int      `json:"periods"`
+	Timezone    string   `json:"timezone"`
+	Cohorts     []Cohort `json:"cohorts"`
 }
@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Attribution models, each splitting one conversion between the channels of
+// the visitor's sessions in the lookback window
+const (
+	AttributionFirstTouch    = "first_touch"
+	AttributionLastTouch     = "last_touch"
+	AttributionLastNonDirect = "last_non_direct"
+	AttributionLinear        = "linear"
+	AttributionTimeDecay     = "time_decay"
+	AttributionPositionBased = "position_based"
+)
+
+// AttributionModels lists the models in report order
+var AttributionModels = []string{
+	AttributionFirstTouch,
+	AttributionLastTouch,
+	AttributionLastNonDirect,
+	AttributionLinear,
+	AttributionTimeDecay,
+	AttributionPositionBased,
+}
+
+// AttributionDimensions are the breakdowns a conversion can be attributed to
+var AttributionDimensions = map[string]bool{
+	"utm_source":   true,
+	"utm_medium":   true,
+	"utm_campaign": true,
+	"source":       true,
+	"referrer":     true,
+}
+
+// Touchpoint is a session on the way to a conversion, identified by the
+// channel of its first pageview
+type Touchpoint struct {
+	Channel   string
+	Direct    bool
+	Timestamp time.Time
+}
+
+// AttributionCredits are credited conversions (or value) per model
+type AttributionCredits map[string]float64
+
+type AttributionChannel struct {
+	Channel string `json:"channel"`
+	// Touchpoints is the number of converting journeys' sessions from the channel
+	Touchpoints int                `json:"touchpoints"`
+	Credits     AttributionCredits `json:"credits"`
+	// Value is the credits times the goal's value, when it has one
+	Value AttributionCredits `json:"value,omitempty"`
+}
+
+type AttributionReport struct {
+	WebsiteID    string    `json:"website_id"`
+	GoalID       uuid.UUID `json:"goal_id"`
+	DateRange    string    `json:"date_range"`
+	Dimension    string    `json:"dimension"`
+	LookbackDays int       `json:"lookback_days"`
+	HalfLifeDays float64   `json:"half_life_days"`
+	Conversions  int       `json:"conversions"`
+	// Unattributed conversions had no session in the lookback window
+	Unattributed int                  `json:"unattributed"`
+	Models       []string             `json:"models"`
+	Channels     []AttributionChannel `json:"channels"`
+}
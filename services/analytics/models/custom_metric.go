@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Display formats of custom metrics
+const (
+	CustomMetricFormatNumber     = "number"
+	CustomMetricFormatPercentage = "percentage"
+	CustomMetricFormatCurrency   = "currency"
+)
+
+// CustomMetric is a KPI a website defines as an expression over event
+// counts, unique visitors and sessions and numeric property aggregates, such
+// as `count("signup") / visitors * 100` for signups per 100 visitors
+type CustomMetric struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	WebsiteID   string    `json:"website_id" db:"website_id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	Expression  string    `json:"expression" db:"expression"`
+	Format      string    `json:"format" db:"format"` // number, percentage, currency
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type CreateCustomMetricRequest struct {
+	WebsiteID   string  `json:"website_id" binding:"required"`
+	Name        string  `json:"name" binding:"required"`
+	Description *string `json:"description"`
+	Expression  string  `json:"expression" binding:"required"`
+	Format      string  `json:"format"`
+}
+
+type UpdateCustomMetricRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	Expression  *string `json:"expression"`
+	Format      *string `json:"format"`
+}
+
+// CustomMetricValues are the selected custom metrics of a dashboard, time
+// series or breakdown row by metric ID. A metric is null where it is
+// undefined, such as a ratio over no visitors. The field is only set when
+// metrics are selected.
+type CustomMetricValues struct {
+	CustomMetrics map[string]*float64 `json:"custom_metrics,omitempty"`
+}
@@ -0,0 +1,59 @@
+package models
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// DateRange is a half-open [From, To) time window plus the IANA timezone
+// used to compute calendar buckets (days, hours) for that window.
+type DateRange struct {
+	From     time.Time `json:"from"`
+	To       time.Time `json:"to"`
+	Timezone string    `json:"timezone"`
+	// RelativeDays is set when the range was derived from a "last N days"
+	// request so that labels stay readable.
+	RelativeDays int `json:"-"`
+}
+
+// Duration returns the length of the range
+func (r DateRange) Duration() time.Duration {
+	return r.To.Sub(r.From)
+}
+
+// Days returns the number of (possibly partial) days covered by the range
+func (r DateRange) Days() int {
+	return int(math.Ceil(r.Duration().Hours() / 24))
+}
+
+// Previous returns the range of equal length immediately preceding this one
+func (r DateRange) Previous() DateRange {
+	return DateRange{
+		From:         r.From.Add(-r.Duration()),
+		To:           r.From,
+		Timezone:     r.Timezone,
+		RelativeDays: r.RelativeDays,
+	}
+}
+
+// Location returns the range's timezone, falling back to UTC
+func (r DateRange) Location() *time.Location {
+	if r.Timezone == "" {
+		return time.UTC
+	}
+	loc, err := time.LoadLocation(r.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// Label returns a human readable description used in API responses
+func (r DateRange) Label() string {
+	if r.RelativeDays > 0 {
+		return fmt.Sprintf("%d days", r.RelativeDays)
+	}
+	loc := r.Location()
+	return fmt.Sprintf("%s to %s", r.From.In(loc).Format(time.RFC3339), r.To.In(loc).Format(time.RFC3339))
+}
@@ -31,8 +31,13 @@ type Event struct {
 	UTMContent  *string    `json:"utm_content,omitempty" db:"utm_content"`
 	TimeOnPage  *int       `json:"time_on_page,omitempty" db:"time_on_page"`
 	Properties  Properties `json:"properties,omitempty" db:"properties"`
-	Timestamp   time.Time  `json:"timestamp" db:"timestamp"`
-	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	// IsBot and BotName are set by the bot classifier at ingestion
+	IsBot   bool    `json:"is_bot,omitempty" db:"is_bot"`
+	BotName *string `json:"bot_name,omitempty" db:"bot_name"`
+	// ImportID is set on events imported from other tools; trackers cannot set it
+	ImportID  *uuid.UUID `json:"-" db:"import_id"`
+	Timestamp time.Time  `json:"timestamp" db:"timestamp"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
 }
 
 // Properties is a custom type for JSONB handling
@@ -72,8 +77,37 @@ type EventResponse struct {
 	SessionID string `json:"session_id"`
 }
 
+// Per-event ingestion outcomes
+const (
+	EventStatusAccepted  = "accepted"
+	EventStatusRejected  = "rejected"
+	EventStatusThrottled = "throttled"
+)
+
 type BatchEventResponse struct {
-	Status      string `json:"status"`
-	EventsCount int    `json:"events_count"`
-	ProcessedAt int64  `json:"processed_at"`
+	Status      string        `json:"status"`
+	EventsCount int           `json:"events_count"`
+	Accepted    int           `json:"accepted"`
+	Rejected    int           `json:"rejected"`
+	Throttled   int           `json:"throttled"`
+	Results     []EventResult `json:"results"`
+	Queue       QueueStatus   `json:"queue"`
+	ProcessedAt int64         `json:"processed_at"`
+}
+
+// EventResult is the outcome for the event at Index in a batch
+type EventResult struct {
+	Index   int    `json:"index"`
+	Status  string `json:"status"`
+	EventID string `json:"event_id,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// QueueStatus reports the ingestion queue depth so trackers can back off.
+// RetryAfter is set, in seconds, when events were throttled.
+type QueueStatus struct {
+	Depth       int64   `json:"depth"`
+	Capacity    int64   `json:"capacity"`
+	Utilization float64 `json:"utilization"`
+	RetryAfter  int     `json:"retry_after,omitempty"`
 }
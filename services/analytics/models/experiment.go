@@ -0,0 +1,124 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Experiment is an A/B test. Visitors are assigned to a variant by the site,
+// which records the variant key in the Property event property; the first
+// assignment in the analyzed range wins. A visitor converts when they reach
+// the goal after being assigned.
+type Experiment struct {
+	ID          uuid.UUID          `json:"id" db:"id"`
+	Name        string             `json:"name" db:"name"`
+	Description *string            `json:"description,omitempty" db:"description"`
+	WebsiteID   string             `json:"website_id" db:"website_id"`
+	UserID      *string            `json:"user_id,omitempty" db:"user_id"`
+	Property    string             `json:"property" db:"property"`
+	Variants    ExperimentVariants `json:"variants" db:"variants"`
+	Control     string             `json:"control" db:"control"`
+	// GoalType is "event" (GoalEvent, using the custom funnel step syntax,
+	// e.g. "purchase?plan=pro") or "funnel" (completing GoalFunnelID)
+	GoalType     string     `json:"goal_type" db:"goal_type"`
+	GoalEvent    *string    `json:"goal_event,omitempty" db:"goal_event"`
+	GoalFunnelID *uuid.UUID `json:"goal_funnel_id,omitempty" db:"goal_funnel_id"`
+	// MinimumDetectableEffect is the relative lift used for sample size
+	// guidance, e.g. 0.1 for +10%
+	MinimumDetectableEffect float64    `json:"minimum_detectable_effect" db:"minimum_detectable_effect"`
+	Status                  string     `json:"status" db:"status"`
+	StartedAt               *time.Time `json:"started_at,omitempty" db:"started_at"`
+	EndedAt                 *time.Time `json:"ended_at,omitempty" db:"ended_at"`
+	CreatedAt               time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt               time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+const (
+	ExperimentGoalEvent  = "event"
+	ExperimentGoalFunnel = "funnel"
+
+	ExperimentStatusDraft   = "draft"
+	ExperimentStatusRunning = "running"
+	ExperimentStatusStopped = "stopped"
+
+	DefaultMinimumDetectableEffect = 0.1
+)
+
+type ExperimentVariant struct {
+	Key  string `json:"key"`
+	Name string `json:"name"`
+}
+
+type ExperimentVariants []ExperimentVariant
+
+type CreateExperimentRequest struct {
+	Name                    string             `json:"name" binding:"required"`
+	Description             *string            `json:"description"`
+	WebsiteID               string             `json:"website_id" binding:"required"`
+	UserID                  *string            `json:"user_id,omitempty"`
+	Property                string             `json:"property" binding:"required"`
+	Variants                ExperimentVariants `json:"variants" binding:"required"`
+	Control                 string             `json:"control"`
+	GoalType                string             `json:"goal_type" binding:"required"`
+	GoalEvent               *string            `json:"goal_event"`
+	GoalFunnelID            *uuid.UUID         `json:"goal_funnel_id"`
+	MinimumDetectableEffect float64            `json:"minimum_detectable_effect"`
+	Status                  string             `json:"status"`
+}
+
+type UpdateExperimentRequest struct {
+	Name                    *string             `json:"name"`
+	Description             *string             `json:"description"`
+	Property                *string             `json:"property"`
+	Variants                *ExperimentVariants `json:"variants"`
+	Control                 *string             `json:"control"`
+	GoalType                *string             `json:"goal_type"`
+	GoalEvent               *string             `json:"goal_event"`
+	GoalFunnelID            *uuid.UUID          `json:"goal_funnel_id"`
+	MinimumDetectableEffect *float64            `json:"minimum_detectable_effect"`
+	Status                  *string             `json:"status"`
+}
+
+// ExperimentResults compares the variants of an experiment over a range
+type ExperimentResults struct {
+	ExperimentID uuid.UUID       `json:"experiment_id"`
+	DateRange    string          `json:"date_range"`
+	Confidence   float64         `json:"confidence"`
+	Variants     []VariantResult `json:"variants"`
+	// Test is "z-test" for two variants and "chi-squared" for more; PValue
+	// is nil until every variant has visitors and some, but not all, converted
+	Test        string     `json:"test"`
+	PValue      *float64   `json:"p_value"`
+	Significant bool       `json:"significant"`
+	SampleSize  SampleSize `json:"sample_size"`
+}
+
+// VariantResult holds a variant's conversions and its comparison with the
+// control. Rates, bounds and lift are percentages.
+type VariantResult struct {
+	Key              string   `json:"key"`
+	Name             string   `json:"name"`
+	Control          bool     `json:"control"`
+	Visitors         int      `json:"visitors"`
+	Conversions      int      `json:"conversions"`
+	ConversionRate   float64  `json:"conversion_rate"`
+	ConfidenceLow    float64  `json:"confidence_low"`
+	ConfidenceHigh   float64  `json:"confidence_high"`
+	Lift             *float64 `json:"lift,omitempty"`
+	ZScore           *float64 `json:"z_score,omitempty"`
+	PValue           *float64 `json:"p_value,omitempty"`
+	Significant      bool     `json:"significant"`
+	ProbabilityToWin *float64 `json:"probability_to_beat_control,omitempty"`
+}
+
+// SampleSize is the number of visitors each variant needs to detect the
+// experiment's minimum effect at the control's observed conversion rate
+type SampleSize struct {
+	BaselineRate            float64 `json:"baseline_rate"`
+	MinimumDetectableEffect float64 `json:"minimum_detectable_effect"`
+	Power                   float64 `json:"power"`
+	PerVariant              int     `json:"per_variant"`
+	// Remaining is the number of visitors still missing in the smallest variant
+	Remaining int `json:"remaining"`
+}
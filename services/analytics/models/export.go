@@ -0,0 +1,86 @@
+package models
+
+import "time"
+
+// Export formats
+const (
+	ExportFormatCSV     = "csv"
+	ExportFormatNDJSON  = "ndjson"
+	ExportFormatParquet = "parquet"
+)
+
+// ExportContentTypes maps export formats to their content type
+var ExportContentTypes = map[string]string{
+	ExportFormatCSV:     "text/csv; charset=utf-8",
+	ExportFormatNDJSON:  "application/x-ndjson",
+	ExportFormatParquet: "application/vnd.apache.parquet",
+}
+
+// Export datasets: raw events or one of the reports
+const (
+	ExportDatasetEvents       = "events"
+	ExportDatasetCustomEvents = "custom_events"
+	ExportDatasetTopPages     = "top_pages"
+	ExportDatasetTopReferrers = "top_referrers"
+	ExportDatasetTopSources   = "top_sources"
+	ExportDatasetTopCountries = "top_countries"
+	ExportDatasetDailyStats   = "daily_stats"
+	ExportDatasetHourlyStats  = "hourly_stats"
+)
+
+// ExportDatasets lists the datasets that can be exported
+var ExportDatasets = []string{
+	ExportDatasetEvents,
+	ExportDatasetCustomEvents,
+	ExportDatasetTopPages,
+	ExportDatasetTopReferrers,
+	ExportDatasetTopSources,
+	ExportDatasetTopCountries,
+	ExportDatasetDailyStats,
+	ExportDatasetHourlyStats,
+}
+
+// Export column types
+const (
+	ExportString = "string"
+	ExportInt    = "int"
+	ExportFloat  = "float"
+	ExportBool   = "bool"
+	ExportTime   = "time"
+)
+
+// ExportColumn is a column of an export and the type of its values
+type ExportColumn struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ExportRequest selects what an export contains
+type ExportRequest struct {
+	WebsiteID string           `json:"website_id"`
+	Dataset   string           `json:"dataset"`
+	Format    string           `json:"format"`
+	DateRange DateRange        `json:"date_range"`
+	Filters   AnalyticsFilters `json:"filters"`
+}
+
+// Export job statuses
+const (
+	ExportJobQueued    = "queued"
+	ExportJobRunning   = "running"
+	ExportJobCompleted = "completed"
+	ExportJobFailed    = "failed"
+)
+
+// ExportJob is an export written to the object store in the background
+type ExportJob struct {
+	ID string `json:"id"`
+	ExportRequest
+	Status      string     `json:"status"`
+	ObjectKey   string     `json:"object_key,omitempty"`
+	Rows        int64      `json:"rows"`
+	Error       string     `json:"error,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"started_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
@@ -0,0 +1,38 @@
+package models
+
+// AnalyticsFilters segments analytics queries by visitor dimensions.
+//
+// Multi-valued dimensions match any of the given values (case-insensitive).
+// Page and Referrer accept `*` as a wildcard. EventType and the property
+// filters select sessions that contain a matching event, so they can be
+// combined with pageview based widgets. Bot traffic is excluded unless
+// IncludeBots is set.
+type AnalyticsFilters struct {
+	Country     []string `json:"country,omitempty"`
+	City        []string `json:"city,omitempty"`
+	Browser     []string `json:"browser,omitempty"`
+	Device      []string `json:"device,omitempty"`
+	OS          []string `json:"os,omitempty"`
+	Page        string   `json:"page,omitempty"`
+	Referrer    string   `json:"referrer,omitempty"`
+	UTMSource   []string `json:"utm_source,omitempty"`
+	UTMMedium   []string `json:"utm_medium,omitempty"`
+	UTMCampaign []string `json:"utm_campaign,omitempty"`
+	UTMTerm     []string `json:"utm_term,omitempty"`
+	UTMContent  []string `json:"utm_content,omitempty"`
+
+	EventType     string `json:"event_type,omitempty"`
+	PropertyKey   string `json:"property_key,omitempty"`
+	PropertyValue string `json:"property_value,omitempty"`
+
+	IncludeBots bool `json:"include_bots,omitempty"`
+}
+
+// IsEmpty reports whether no dimension filter is set
+func (f AnalyticsFilters) IsEmpty() bool {
+	return len(f.Country) == 0 && len(f.City) == 0 && len(f.Browser) == 0 &&
+		len(f.Device) == 0 && len(f.OS) == 0 && f.Page == "" && f.Referrer == "" &&
+		len(f.UTMSource) == 0 && len(f.UTMMedium) == 0 && len(f.UTMCampaign) == 0 &&
+		len(f.UTMTerm) == 0 && len(f.UTMContent) == 0 &&
+		f.EventType == "" && f.PropertyKey == ""
+}
@@ -13,11 +13,22 @@ type Funnel struct {
 	WebsiteID   string      `json:"website_id" db:"website_id"`
 	UserID      *string     `json:"user_id,omitempty" db:"user_id"`
 	Steps       FunnelSteps `json:"steps" db:"steps"`
-	IsActive    bool        `json:"is_active" db:"is_active"`
-	CreatedAt   time.Time   `json:"created_at" db:"created_at"`
-	UpdatedAt   time.Time   `json:"updated_at" db:"updated_at"`
+	// StepOrder is "strict" (steps must happen in order) or "any"
+	StepOrder string `json:"step_order" db:"step_order"`
+	// ConversionWindowHours is the time allowed between the first and last step
+	ConversionWindowHours int       `json:"conversion_window_hours" db:"conversion_window_hours"`
+	IsActive              bool      `json:"is_active" db:"is_active"`
+	CreatedAt             time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at" db:"updated_at"`
 }
 
+const (
+	FunnelStepOrderStrict = "strict"
+	FunnelStepOrderAny    = "any"
+
+	DefaultConversionWindowHours = 24
+)
+
 type FunnelStep struct {
 	ID        string          `json:"id"`
 	Name      string          `json:"name"`
@@ -27,8 +38,9 @@ type FunnelStep struct {
 }
 
 type FunnelCondition struct {
-	Page   *string `json:"page,omitempty"`
-	Event  *string `json:"event,omitempty"`
+	Page  *string `json:"page,omitempty"`
+	Event *string `json:"event,omitempty"`
+	// Custom matches an event with properties, e.g. "purchase?plan=pro&currency=*"
 	Custom *string `json:"custom,omitempty"`
 }
 
@@ -58,6 +70,7 @@ type FunnelAnalytics struct {
 	ConversionRate   float64   `json:"conversion_rate" db:"conversion_rate"`
 	AvgValue         *float64  `json:"avg_value,omitempty" db:"avg_value"`
 	TotalValue       *float64  `json:"total_value,omitempty" db:"total_value"`
+	Currency         string    `json:"currency,omitempty" db:"currency"` // of AvgValue and TotalValue
 	AvgTimeToConvert *int      `json:"avg_time_to_convert,omitempty" db:"avg_time_to_convert"`
 	AvgTimeToAbandon *int      `json:"avg_time_to_abandon,omitempty" db:"avg_time_to_abandon"`
 	DropOffRate      float64   `json:"drop_off_rate" db:"drop_off_rate"`
@@ -70,7 +83,10 @@ type CreateFunnelRequest struct {
 	WebsiteID   string      `json:"website_id" binding:"required"`
 	UserID      *string     `json:"user_id,omitempty"`
 	Steps       FunnelSteps `json:"steps" binding:"required"`
-	IsActive    bool        `json:"is_active"`
+	StepOrder   string      `json:"step_order"`
+	// ConversionWindowHours defaults to DefaultConversionWindowHours when zero
+	ConversionWindowHours int  `json:"conversion_window_hours"`
+	IsActive              bool `json:"is_active"`
 }
 
 type UpdateFunnelRequest struct {
@@ -79,7 +95,10 @@ type UpdateFunnelRequest struct {
 	WebsiteID   *string      `json:"website_id"`
 	UserID      *string      `json:"user_id"`
 	Steps       *FunnelSteps `json:"steps"`
-	IsActive    *bool        `json:"is_active"`
+	StepOrder   *string      `json:"step_order"`
+	// ConversionWindowHours must be positive when set
+	ConversionWindowHours *int  `json:"conversion_window_hours"`
+	IsActive              *bool `json:"is_active"`
 }
 
 // Advanced Analytics Models
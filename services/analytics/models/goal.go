@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Goal is a conversion a website cares about: viewing a page matching
+// PagePattern, or firing the custom event EventName, optionally with
+// PropertyKey matching PropertyValue. Patterns and values may contain `*`
+// wildcards. Value is the monetary worth of one conversion.
+type Goal struct {
+	ID            uuid.UUID `json:"id" db:"id"`
+	Name          string    `json:"name" db:"name"`
+	Description   *string   `json:"description,omitempty" db:"description"`
+	WebsiteID     string    `json:"website_id" db:"website_id"`
+	UserID        *string   `json:"user_id,omitempty" db:"user_id"`
+	Type          string    `json:"type" db:"type"` // page, event
+	PagePattern   *string   `json:"page_pattern,omitempty" db:"page_pattern"`
+	EventName     *string   `json:"event_name,omitempty" db:"event_name"`
+	PropertyKey   *string   `json:"property_key,omitempty" db:"property_key"`
+	PropertyValue *string   `json:"property_value,omitempty" db:"property_value"`
+	Value         *float64  `json:"value,omitempty" db:"value"`
+	CreatedAt     time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time `json:"updated_at" db:"updated_at"`
+}
+
+const (
+	GoalTypePage  = "page"
+	GoalTypeEvent = "event"
+)
+
+type CreateGoalRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Description   *string  `json:"description"`
+	WebsiteID     string   `json:"website_id" binding:"required"`
+	UserID        *string  `json:"user_id,omitempty"`
+	Type          string   `json:"type" binding:"required"`
+	PagePattern   *string  `json:"page_pattern"`
+	EventName     *string  `json:"event_name"`
+	PropertyKey   *string  `json:"property_key"`
+	PropertyValue *string  `json:"property_value"`
+	Value         *float64 `json:"value"`
+}
+
+type UpdateGoalRequest struct {
+	Name          *string  `json:"name"`
+	Description   *string  `json:"description"`
+	Type          *string  `json:"type"`
+	PagePattern   *string  `json:"page_pattern"`
+	EventName     *string  `json:"event_name"`
+	PropertyKey   *string  `json:"property_key"`
+	PropertyValue *string  `json:"property_value"`
+	Value         *float64 `json:"value"`
+}
+
+// GoalCounts are the sessions of a breakdown row and how many of them
+// reached the goal
+type GoalCounts struct {
+	Sessions    int
+	Conversions int
+}
+
+// GoalConversions are the selected goal's results for a breakdown row. The
+// fields are only set when a goal is selected.
+type GoalConversions struct {
+	Conversions *int `json:"conversions,omitempty"`
+	// ConversionRate is the percentage of the row's sessions that converted
+	ConversionRate *float64 `json:"conversion_rate,omitempty"`
+	// GoalValue is conversions times the goal's value, when it has one
+	GoalValue *float64 `json:"goal_value,omitempty"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Tools whose exports can be imported
+const (
+	// ImportSourceGA4 is the NDJSON of the GA4 BigQuery export
+	ImportSourceGA4 = "ga4"
+	// ImportSourceUniversalAnalytics is a Universal Analytics CSV report
+	ImportSourceUniversalAnalytics = "universal_analytics"
+	// ImportSourcePlausible is a Plausible CSV export
+	ImportSourcePlausible = "plausible"
+	// ImportSourceUmami is a Umami CSV data export
+	ImportSourceUmami = "umami"
+)
+
+// ImportSources lists the tools whose exports can be imported
+var ImportSources = []string{
+	ImportSourceGA4,
+	ImportSourceUniversalAnalytics,
+	ImportSourcePlausible,
+	ImportSourceUmami,
+}
+
+// Import statuses
+const (
+	ImportRunning    = "running"
+	ImportCompleted  = "completed"
+	ImportFailed     = "failed"
+	ImportRolledBack = "rolled_back"
+)
+
+// Import is a file of historical data imported for a website
+type Import struct {
+	ID                 uuid.UUID  `json:"id" db:"id"`
+	WebsiteID          string     `json:"website_id" db:"website_id"`
+	Source             string     `json:"source" db:"source"`
+	Filename           string     `json:"filename,omitempty" db:"filename"`
+	Status             string     `json:"status" db:"status"`
+	RowsRead           int64      `json:"rows_read" db:"rows_read"`
+	RowsSkipped        int64      `json:"rows_skipped" db:"rows_skipped"`
+	EventsImported     int64      `json:"events_imported" db:"events_imported"`
+	AggregatesImported int64      `json:"aggregates_imported" db:"aggregates_imported"`
+	Error              string     `json:"error,omitempty" db:"error"`
+	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
+	CompletedAt        *time.Time `json:"completed_at,omitempty" db:"completed_at"`
+	RolledBackAt       *time.Time `json:"rolled_back_at,omitempty" db:"rolled_back_at"`
+}
+
+// ImportedDailyStat is a day of aggregates imported from a tool that does
+// not export events. Page and Source are empty for the day's totals.
+type ImportedDailyStat struct {
+	Date      time.Time `json:"date" db:"date"`
+	Page      string    `json:"page,omitempty" db:"page"`
+	Source    string    `json:"source,omitempty" db:"source"`
+	Pageviews int64     `json:"pageviews" db:"pageviews"`
+	Visitors  int64     `json:"visitors" db:"visitors"`
+	Visits    int64     `json:"visits" db:"visits"`
+	Bounces   int64     `json:"bounces" db:"bounces"`
+	// VisitDuration is the total duration of the day's visits in seconds
+	VisitDuration int64 `json:"visit_duration" db:"visit_duration"`
+}
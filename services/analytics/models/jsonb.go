@@ -50,4 +50,47 @@ func (fs *FunnelSteps) Scan(value interface{}) error {
 	}
 
 	return json.Unmarshal(bytes, fs)
-}
\ No newline at end of file
+}
+// ExperimentVariants for handling experiment variants as JSONB
+func (v ExperimentVariants) Value() (driver.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	return json.Marshal(v)
+}
+
+func (v *ExperimentVariants) Scan(value interface{}) error {
+	if value == nil {
+		*v = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to unmarshal ExperimentVariants value")
+	}
+
+	return json.Unmarshal(bytes, v)
+}
+
+// OrderItems for handling order line items as JSONB
+func (items OrderItems) Value() (driver.Value, error) {
+	if items == nil {
+		return nil, nil
+	}
+	return json.Marshal(items)
+}
+
+func (items *OrderItems) Scan(value interface{}) error {
+	if value == nil {
+		*items = nil
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("failed to unmarshal OrderItems value")
+	}
+
+	return json.Unmarshal(bytes, items)
+}
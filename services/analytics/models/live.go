@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// LiveWindow is how long a visitor counts as active after their last pageview
+const LiveWindow = 5 * time.Minute
+
+// LiveCount is the number of active visitors on a page, from a referrer or
+// in a country
+type LiveCount struct {
+	Name     string `json:"name"`
+	Visitors int    `json:"visitors"`
+}
+
+type MinuteCount struct {
+	Minute    time.Time `json:"minute"`
+	Pageviews int       `json:"pageviews"`
+}
+
+// LiveMetrics is what is happening on a website right now. The embedded
+// RealtimeMetric counts pageviews in the last complete minute and averages
+// the time on page reported within LiveWindow.
+type LiveMetrics struct {
+	RealtimeMetric
+	CurrentPages []LiveCount `json:"current_pages"`
+	Referrers    []LiveCount `json:"referrers"`
+	Countries    []LiveCount `json:"countries"`
+	// PageviewSeries is the pageviews of each of the last minutes, oldest first
+	PageviewSeries []MinuteCount `json:"pageview_series"`
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Report delivery channels
+const (
+	ReportChannelEmail   = "email"
+	ReportChannelWebhook = "webhook"
+)
+
+// ReportSchedule sends a digest of a website's last RangeDays days whenever
+// Cron, a standard five-field expression evaluated in Timezone, fires
+type ReportSchedule struct {
+	ID         uuid.UUID `json:"id" db:"id"`
+	WebsiteID  string    `json:"website_id" db:"website_id"`
+	Name       string    `json:"name" db:"name"`
+	Cron       string    `json:"cron" db:"cron"`
+	Timezone   string    `json:"timezone" db:"timezone"`
+	RangeDays  int       `json:"range_days" db:"range_days"`
+	Channel    string    `json:"channel" db:"channel"` // email, webhook
+	Recipients []string  `json:"recipients,omitempty" db:"recipients"`
+	WebhookURL *string   `json:"webhook_url,omitempty" db:"webhook_url"`
+	// WebhookSecret signs webhook deliveries; it is never returned
+	WebhookSecret *string    `json:"-" db:"webhook_secret"`
+	Enabled       bool       `json:"enabled" db:"enabled"`
+	NextRunAt     time.Time  `json:"next_run_at" db:"next_run_at"`
+	LastRunAt     *time.Time `json:"last_run_at,omitempty" db:"last_run_at"`
+	LastError     *string    `json:"last_error,omitempty" db:"last_error"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+type CreateReportScheduleRequest struct {
+	WebsiteID     string   `json:"website_id" binding:"required"`
+	Name          string   `json:"name" binding:"required"`
+	Cron          string   `json:"cron" binding:"required"`
+	Timezone      string   `json:"timezone"`
+	RangeDays     int      `json:"range_days"`
+	Channel       string   `json:"channel" binding:"required"`
+	Recipients    []string `json:"recipients"`
+	WebhookURL    *string  `json:"webhook_url"`
+	WebhookSecret *string  `json:"webhook_secret"`
+	Enabled       *bool    `json:"enabled"`
+}
+
+type UpdateReportScheduleRequest struct {
+	Name          *string  `json:"name"`
+	Cron          *string  `json:"cron"`
+	Timezone      *string  `json:"timezone"`
+	RangeDays     *int     `json:"range_days"`
+	Channel       *string  `json:"channel"`
+	Recipients    []string `json:"recipients"`
+	WebhookURL    *string  `json:"webhook_url"`
+	WebhookSecret *string  `json:"webhook_secret"`
+	Enabled       *bool    `json:"enabled"`
+}
+
+// ReportDigest is the content of a scheduled report
+type ReportDigest struct {
+	WebsiteID   string         `json:"website_id"`
+	Name        string         `json:"name"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	Timezone    string         `json:"timezone"`
+	Dashboard   *DashboardData `json:"dashboard"`
+	TopPages    []PageStat     `json:"top_pages"`
+	TopSources  []SourceStat   `json:"top_sources"`
+	Funnels     []ReportFunnel `json:"funnels"`
+	GeneratedAt time.Time      `json:"generated_at"`
+}
+
+// ReportFunnel is the conversion of an active funnel over a report's range
+type ReportFunnel struct {
+	ID          uuid.UUID `json:"id"`
+	Name        string    `json:"name"`
+	Starts      int       `json:"starts"`
+	Conversions int       `json:"conversions"`
+	// ConversionRate is the percentage of starts that converted
+	ConversionRate float64 `json:"conversion_rate"`
+}
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PurchaseEventType is the custom event trackers send for completed orders.
+// Its properties are order_id, amount, currency and optionally items.
+const PurchaseEventType = "purchase"
+
+const (
+	// ReferenceCurrency is the currency exchange rates are quoted against
+	ReferenceCurrency = "USD"
+	// DefaultBaseCurrency is the reporting currency of websites without
+	// revenue settings
+	DefaultBaseCurrency = "USD"
+)
+
+// Order is a purchase recorded once per website and order ID, in the
+// currency it was made in
+type Order struct {
+	WebsiteID string     `json:"website_id" db:"website_id"`
+	OrderID   string     `json:"order_id" db:"order_id"`
+	EventID   uuid.UUID  `json:"event_id" db:"event_id"`
+	VisitorID string     `json:"visitor_id" db:"visitor_id"`
+	SessionID string     `json:"session_id" db:"session_id"`
+	Amount    float64    `json:"amount" db:"amount"`
+	Currency  string     `json:"currency" db:"currency"`
+	Items     OrderItems `json:"items,omitempty" db:"items"`
+	Timestamp time.Time  `json:"timestamp" db:"timestamp"`
+}
+
+type OrderItem struct {
+	ID       string  `json:"id"`
+	Name     string  `json:"name,omitempty"`
+	Category string  `json:"category,omitempty"`
+	Price    float64 `json:"price"`
+	Quantity int     `json:"quantity"`
+}
+
+type OrderItems []OrderItem
+
+// RevenueSettings holds the currency a website's revenue is reported in
+type RevenueSettings struct {
+	WebsiteID    string    `json:"website_id" db:"website_id"`
+	BaseCurrency string    `json:"base_currency" db:"base_currency"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type UpdateRevenueSettingsRequest struct {
+	BaseCurrency string `json:"base_currency" binding:"required"`
+}
+
+// ExchangeRate is how many units of Currency one unit of ReferenceCurrency buys
+type ExchangeRate struct {
+	Currency  string    `json:"currency" db:"currency"`
+	Rate      float64   `json:"rate" db:"rate"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+type UpdateExchangeRatesRequest struct {
+	Rates map[string]float64 `json:"rates" binding:"required"`
+}
+
+// CurrencyTotals are the orders of a breakdown row in one currency
+type CurrencyTotals struct {
+	Orders int
+	Amount float64
+}
+
+// RevenueStats is the revenue of a breakdown row in the website's base
+// currency. Orders in currencies without an exchange rate are left out.
+type RevenueStats struct {
+	Currency          string  `json:"currency"`
+	Orders            int     `json:"orders"`
+	Revenue           float64 `json:"revenue"`
+	AverageOrderValue float64 `json:"average_order_value"`
+}
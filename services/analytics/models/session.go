@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ExitIntentEventType is the custom event trackers send when the pointer
+// leaves the page towards the browser chrome
+const ExitIntentEventType = "exit_intent"
+
+// Synthetic events the analytics service emits when it opens and closes a
+// visitor's session
+const (
+	SessionStartEventType = "session_start"
+	SessionEndEventType   = "session_end"
+)
+
+// Why a session was closed, reported in the reason property of session_end
+const (
+	SessionEndTimeout  = "timeout"
+	SessionEndMidnight = "midnight"
+	SessionEndCampaign = "campaign"
+)
+
+// SessionState is the open session of a visitor, assigned server-side
+type SessionState struct {
+	SessionID string    `json:"session_id"`
+	StartedAt time.Time `json:"started_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	// Campaign is the UTM source, medium and campaign the session came from
+	Campaign  string `json:"campaign,omitempty"`
+	Pageviews int    `json:"pageviews"`
+	IsBot     bool   `json:"is_bot,omitempty"`
+}
+
+// Session is a visit reconstructed from its pageviews. Its source, country
+// and device are those of the entry pageview.
+type Session struct {
+	SessionID   string    `json:"session_id" db:"session_id"`
+	VisitorID   string    `json:"visitor_id" db:"visitor_id"`
+	StartedAt   time.Time `json:"started_at" db:"started_at"`
+	EndedAt     time.Time `json:"ended_at" db:"ended_at"`
+	Duration    int       `json:"duration" db:"duration"`
+	Pageviews   int       `json:"pageviews" db:"pageviews"`
+	UniquePages int       `json:"unique_pages" db:"unique_pages"`
+	EntryPage   string    `json:"entry_page" db:"entry_page"`
+	ExitPage    string    `json:"exit_page" db:"exit_page"`
+	Source      string    `json:"source" db:"source"`
+	Country     string    `json:"country" db:"country"`
+	Device      string    `json:"device" db:"device"`
+	Browser     string    `json:"browser" db:"browser"`
+	OS          string    `json:"os" db:"os"`
+	ExitIntent  bool      `json:"exit_intent" db:"exit_intent"`
+	// ScrollDepth is the deepest scroll_depth property reported in the session
+	ScrollDepth *int `json:"scroll_depth,omitempty" db:"scroll_depth"`
+}
+
+// Kinds of visitor timeline items
+const (
+	TimelinePageview = "pageview"
+	TimelineEvent    = "event"
+	TimelineFunnel   = "funnel"
+)
+
+// TimelineItem is a pageview, custom event or funnel progress update of a
+// visitor
+type TimelineItem struct {
+	Type       string          `json:"type"`
+	Timestamp  time.Time       `json:"timestamp"`
+	SessionID  string          `json:"session_id,omitempty"`
+	EventType  string          `json:"event_type,omitempty"`
+	Page       string          `json:"page,omitempty"`
+	Properties Properties      `json:"properties,omitempty"`
+	Funnel     *FunnelProgress `json:"funnel,omitempty"`
+}
+
+// FunnelProgress is the progress a funnel tracker reported for a visitor
+type FunnelProgress struct {
+	FunnelID   uuid.UUID `json:"funnel_id"`
+	FunnelName string    `json:"funnel_name"`
+	Step       int       `json:"step"`
+	StepName   string    `json:"step_name,omitempty"`
+	Converted  bool      `json:"converted"`
+}
+
+// VisitorJourney is everything a visitor did in a date range
+type VisitorJourney struct {
+	WebsiteID string         `json:"website_id"`
+	VisitorID string         `json:"visitor_id"`
+	DateRange string         `json:"date_range"`
+	FirstSeen *time.Time     `json:"first_seen,omitempty"`
+	LastSeen  *time.Time     `json:"last_seen,omitempty"`
+	Sessions  []Session      `json:"sessions"`
+	Timeline  []TimelineItem `json:"timeline"`
+}
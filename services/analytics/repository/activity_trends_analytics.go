@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ActivityTrendsAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewActivityTrendsAnalytics(db *pgxpool.Pool) *ActivityTrendsAnalytics {
+	return &ActivityTrendsAnalytics{db: db}
+}
+
+// GetActivityCounts returns pageviews, unique visitors and sessions per
+// bucket of the given granularity. Buckets are truncated in the range's
+// timezone and only buckets with activity are returned.
+func (ta *ActivityTrendsAnalytics) GetActivityCounts(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, granularity string) ([]models.ActivityCount, error) {
+	filterSQL, args := BuildFilterClause(filters, "events", []interface{}{websiteID, dateRange.From, dateRange.To, dateRange.Location().String(), granularity})
+
+	query := `
+		SELECT
+			date_trunc($5, timestamp AT TIME ZONE $4) AT TIME ZONE $4 as period,
+			COUNT(*) as page_views,
+			COUNT(DISTINCT visitor_id) as visitors,
+			COUNT(DISTINCT session_id) as sessions
+		FROM events
+		WHERE website_id = $1
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'` + filterSQL + `
+		GROUP BY 1
+		ORDER BY 1`
+
+	rows, err := ta.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var counts []models.ActivityCount
+	for rows.Next() {
+		var count models.ActivityCount
+		if err := rows.Scan(&count.Period, &count.PageViews, &count.Visitors, &count.Sessions); err != nil {
+			return nil, err
+		}
+		counts = append(counts, count)
+	}
+
+	return counts, rows.Err()
+}
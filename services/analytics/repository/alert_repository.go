@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AlertRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewAlertRepository(db *pgxpool.Pool) *AlertRepository {
+	return &AlertRepository{db: db}
+}
+
+const alertRuleColumns = `id, website_id, name, metric, type, direction, baseline, threshold, sensitivity,
+		baseline_weeks, channel, recipients, webhook_url, webhook_secret, cooldown_minutes, enabled,
+		last_evaluated_hour, created_at, updated_at`
+
+const alertColumns = `id, rule_id, rule_name, website_id, hour, metric, value, expected, z_score, message,
+		status, error, created_at`
+
+func (r *AlertRepository) CreateRule(ctx context.Context, rule *models.AlertRule) error {
+	rule.ID = uuid.New()
+	rule.CreatedAt = time.Now()
+	rule.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO alert_rules (` + alertRuleColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, NULLIF($7, ''), $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19)`
+
+	_, err := r.db.Exec(ctx, query,
+		rule.ID, rule.WebsiteID, rule.Name, rule.Metric, rule.Type, rule.Direction, rule.Baseline, rule.Threshold,
+		rule.Sensitivity, rule.BaselineWeeks, rule.Channel, rule.Recipients, rule.WebhookURL, rule.WebhookSecret,
+		rule.CooldownMinutes, rule.Enabled, rule.LastEvaluatedHour, rule.CreatedAt, rule.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *AlertRepository) GetRulesByWebsiteID(ctx context.Context, websiteID string) ([]models.AlertRule, error) {
+	query := `
+		SELECT ` + alertRuleColumns + `
+		FROM alert_rules
+		WHERE website_id = $1
+		ORDER BY created_at DESC`
+
+	return r.queryRules(ctx, query, websiteID)
+}
+
+func (r *AlertRepository) GetRuleByID(ctx context.Context, ruleID uuid.UUID) (*models.AlertRule, error) {
+	query := `
+		SELECT ` + alertRuleColumns + `
+		FROM alert_rules
+		WHERE id = $1`
+
+	return scanAlertRule(r.db.QueryRow(ctx, query, ruleID))
+}
+
+// GetEnabledRules returns every enabled rule
+func (r *AlertRepository) GetEnabledRules(ctx context.Context) ([]models.AlertRule, error) {
+	query := `
+		SELECT ` + alertRuleColumns + `
+		FROM alert_rules
+		WHERE enabled
+		ORDER BY created_at`
+
+	return r.queryRules(ctx, query)
+}
+
+func (r *AlertRepository) UpdateRule(ctx context.Context, rule *models.AlertRule) error {
+	rule.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE alert_rules
+		SET name = $2, metric = $3, type = $4, direction = $5, baseline = NULLIF($6, ''), threshold = $7,
+			sensitivity = $8, baseline_weeks = $9, channel = $10, recipients = $11, webhook_url = $12,
+			webhook_secret = $13, cooldown_minutes = $14, enabled = $15, updated_at = $16
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query,
+		rule.ID, rule.Name, rule.Metric, rule.Type, rule.Direction, rule.Baseline, rule.Threshold, rule.Sensitivity,
+		rule.BaselineWeeks, rule.Channel, rule.Recipients, rule.WebhookURL, rule.WebhookSecret, rule.CooldownMinutes,
+		rule.Enabled, rule.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *AlertRepository) DeleteRule(ctx context.Context, ruleID uuid.UUID) error {
+	query := `DELETE FROM alert_rules WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, ruleID)
+	return err
+}
+
+// ClaimHour marks hour as evaluated for a rule. Only one instance succeeds
+// for each hour; the others, and later claims of earlier hours, get false.
+func (r *AlertRepository) ClaimHour(ctx context.Context, ruleID uuid.UUID, hour time.Time) (bool, error) {
+	query := `
+		UPDATE alert_rules
+		SET last_evaluated_hour = $2
+		WHERE id = $1 AND enabled AND (last_evaluated_hour IS NULL OR last_evaluated_hour < $2)`
+
+	tag, err := r.db.Exec(ctx, query, ruleID, hour)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// LastSentHour returns the hour of the rule's latest sent alert, or nil when
+// it has none
+func (r *AlertRepository) LastSentHour(ctx context.Context, ruleID uuid.UUID) (*time.Time, error) {
+	query := `
+		SELECT MAX(hour)
+		FROM alerts
+		WHERE rule_id = $1 AND status = $2`
+
+	var hour *time.Time
+	err := r.db.QueryRow(ctx, query, ruleID, models.AlertSent).Scan(&hour)
+	return hour, err
+}
+
+// InsertAlert stores a triggered alert. It returns false when the rule
+// already has an alert for the hour.
+func (r *AlertRepository) InsertAlert(ctx context.Context, alert *models.Alert) (bool, error) {
+	alert.ID = uuid.New()
+	alert.CreatedAt = time.Now()
+
+	query := `
+		INSERT INTO alerts (` + alertColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (rule_id, hour) DO NOTHING`
+
+	tag, err := r.db.Exec(ctx, query,
+		alert.ID, alert.RuleID, alert.RuleName, alert.WebsiteID, alert.Hour, alert.Metric, alert.Value,
+		alert.Expected, alert.ZScore, alert.Message, alert.Status, alert.Error, alert.CreatedAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// UpdateAlertStatus records the delivery outcome of an alert
+func (r *AlertRepository) UpdateAlertStatus(ctx context.Context, alertID uuid.UUID, status string, deliveryError *string) error {
+	query := `UPDATE alerts SET status = $2, error = $3 WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, alertID, status, deliveryError)
+	return err
+}
+
+// GetAlerts returns a website's alerts for hours in the date range, latest
+// first, optionally of one rule only
+func (r *AlertRepository) GetAlerts(ctx context.Context, websiteID string, dateRange models.DateRange, ruleID *uuid.UUID, limit int) ([]models.Alert, error) {
+	args := []interface{}{websiteID, dateRange.From, dateRange.To}
+	query := `
+		SELECT ` + alertColumns + `
+		FROM alerts
+		WHERE website_id = $1 AND hour >= $2 AND hour < $3`
+	if ruleID != nil {
+		args = append(args, *ruleID)
+		query += fmt.Sprintf(" AND rule_id = $%d", len(args))
+	}
+	args = append(args, limit)
+	query += fmt.Sprintf(" ORDER BY hour DESC, created_at DESC LIMIT $%d", len(args))
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var alerts []models.Alert
+	for rows.Next() {
+		var alert models.Alert
+		err := rows.Scan(
+			&alert.ID, &alert.RuleID, &alert.RuleName, &alert.WebsiteID, &alert.Hour, &alert.Metric, &alert.Value,
+			&alert.Expected, &alert.ZScore, &alert.Message, &alert.Status, &alert.Error, &alert.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+
+	return alerts, rows.Err()
+}
+
+func (r *AlertRepository) queryRules(ctx context.Context, query string, args ...interface{}) ([]models.AlertRule, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.AlertRule
+	for rows.Next() {
+		rule, err := scanAlertRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+
+	return rules, rows.Err()
+}
+
+func scanAlertRule(row pgx.Row) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	var baseline *string
+	err := row.Scan(
+		&rule.ID, &rule.WebsiteID, &rule.Name, &rule.Metric, &rule.Type, &rule.Direction, &baseline, &rule.Threshold,
+		&rule.Sensitivity, &rule.BaselineWeeks, &rule.Channel, &rule.Recipients, &rule.WebhookURL, &rule.WebhookSecret,
+		&rule.CooldownMinutes, &rule.Enabled, &rule.LastEvaluatedHour, &rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if baseline != nil {
+		rule.Baseline = *baseline
+	}
+	return &rule, nil
+}
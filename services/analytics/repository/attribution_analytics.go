@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type AttributionAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewAttributionAnalytics(db *pgxpool.Pool) *AttributionAnalytics {
+	return &AttributionAnalytics{db: db}
+}
+
+// directTouchSQL is true for pageviews without a campaign or an external
+// referrer, the "Direct Traffic" of the sources breakdown
+const directTouchSQL = `(COALESCE(e.utm_source, '') = '' AND (e.referrer IS NULL OR e.referrer = '' OR LOWER(e.referrer) IN ('direct', 'none', 'null')))`
+
+// StreamConversionJourneys calls fn for every session that reached the goal in
+// the range, with its time of conversion and the visitor's sessions that
+// started in the lookback window before it, oldest first. Sessions are
+// identified by the dimension value of their first pageview; filters apply
+// to those pageviews.
+func (aa *AttributionAnalytics) StreamConversionJourneys(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, goal *models.Goal, dimension string, lookback time.Duration, fn func(convertedAt time.Time, touches []models.Touchpoint) error) error {
+	if !models.AttributionDimensions[dimension] {
+		return fmt.Errorf("unsupported attribution dimension %q", dimension)
+	}
+	value := breakdownDimensions[dimension]
+
+	hitsSQL, args, err := goalHitsQuery(goal, []interface{}{websiteID, dateRange.From, dateRange.To})
+	if err != nil {
+		return err
+	}
+	args = append(args, lookback.Seconds())
+	lookbackSQL := fmt.Sprintf("make_interval(secs => $%d)", len(args))
+	filterSQL, args := BuildFilterClause(filters, "e", args)
+
+	query := `
+		WITH conversions AS (
+			SELECT DISTINCT ON (session_id) session_id, visitor_id, timestamp
+			FROM (` + hitsSQL + `) hits
+			WHERE session_id IS NOT NULL AND session_id != ''
+			ORDER BY session_id, timestamp
+		),
+		touches AS (
+			SELECT DISTINCT ON (e.session_id) e.session_id, e.visitor_id, e.timestamp,
+				` + value + ` as channel,
+				` + directTouchSQL + ` as direct
+			FROM events e
+			WHERE e.website_id = $1
+			AND e.timestamp >= $2::timestamptz - ` + lookbackSQL + ` AND e.timestamp < $3
+			AND e.event_type = 'pageview'
+			AND e.visitor_id IN (SELECT visitor_id FROM conversions)` + filterSQL + `
+			ORDER BY e.session_id, e.timestamp
+		)
+		SELECT c.session_id, c.timestamp, t.channel, t.direct, t.timestamp
+		FROM conversions c
+		LEFT JOIN touches t ON t.visitor_id = c.visitor_id
+			AND t.timestamp <= c.timestamp
+			AND t.timestamp >= c.timestamp - ` + lookbackSQL + `
+		ORDER BY c.session_id, t.timestamp`
+
+	rows, err := aa.db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var current string
+	var convertedAt time.Time
+	var touches []models.Touchpoint
+	flush := func() error {
+		if current == "" {
+			return nil
+		}
+		return fn(convertedAt, touches)
+	}
+
+	for rows.Next() {
+		var sessionID string
+		var at time.Time
+		var channel *string
+		var direct *bool
+		var touchedAt *time.Time
+		if err := rows.Scan(&sessionID, &at, &channel, &direct, &touchedAt); err != nil {
+			return err
+		}
+
+		if sessionID != current {
+			if err := flush(); err != nil {
+				return err
+			}
+			current, convertedAt, touches = sessionID, at, nil
+		}
+		// Conversions without sessions in the window have a single NULL row
+		if channel != nil {
+			touches = append(touches, models.Touchpoint{Channel: *channel, Direct: *direct, Timestamp: *touchedAt})
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	return flush()
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type BotAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewBotAnalytics(db *pgxpool.Pool) *BotAnalytics {
+	return &BotAnalytics{db: db}
+}
+
+// botEventsSource selects system and custom events in the range with the
+// columns dimension filters use, aliased as e
+const botEventsSource = `(
+			SELECT website_id, visitor_id, session_id, event_type, page, referrer, country, city, browser, device, os,
+				utm_source, utm_medium, utm_campaign, utm_term, utm_content, is_bot, bot_name, timestamp
+			FROM events
+			WHERE website_id = $1 AND timestamp >= $2 AND timestamp < $3
+			UNION ALL
+			SELECT website_id, visitor_id, session_id, event_type, page, referrer, country, city, browser, device, os,
+				utm_source, utm_medium, utm_campaign, utm_term, utm_content, is_bot, bot_name, timestamp
+			FROM custom_events
+			WHERE website_id = $1 AND timestamp >= $2 AND timestamp < $3
+		) e`
+
+// GetBotTraffic returns bot traffic broken down by bot name. Dimension
+// filters apply; the bot exclusion of regular reports does not.
+func (ba *BotAnalytics) GetBotTraffic(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) (*models.BotTrafficReport, error) {
+	filters.IncludeBots = true
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To})
+
+	report := &models.BotTrafficReport{
+		Bots:  []models.BotStat{},
+		Daily: []models.DailyStat{},
+	}
+
+	totalsQuery := `
+		SELECT
+			COUNT(*) as total_events,
+			COUNT(*) FILTER (WHERE e.is_bot) as bot_events
+		FROM ` + botEventsSource + `
+		WHERE true` + filterSQL
+
+	if err := ba.db.QueryRow(ctx, totalsQuery, args...).Scan(&report.TotalEvents, &report.BotEvents); err != nil {
+		return nil, err
+	}
+	if report.TotalEvents > 0 {
+		report.BotShare = float64(report.BotEvents) * 100 / float64(report.TotalEvents)
+	}
+
+	botsArgs := append(append([]interface{}{}, args...), limit)
+	botsQuery := `
+		SELECT
+			COALESCE(e.bot_name, 'Other bot') as bot_name,
+			COUNT(*) as events,
+			COUNT(*) FILTER (WHERE e.event_type = 'pageview') as page_views,
+			COUNT(DISTINCT e.visitor_id) as visitors,
+			MAX(e.timestamp) as last_seen
+		FROM ` + botEventsSource + `
+		WHERE e.is_bot` + filterSQL + `
+		GROUP BY 1
+		ORDER BY events DESC
+		LIMIT ` + fmt.Sprintf("$%d", len(botsArgs))
+
+	rows, err := ba.db.Query(ctx, botsQuery, botsArgs...)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var bot models.BotStat
+		if err := rows.Scan(&bot.BotName, &bot.Events, &bot.PageViews, &bot.Visitors, &bot.LastSeen); err != nil {
+			continue
+		}
+		report.Bots = append(report.Bots, bot)
+	}
+	rows.Close()
+
+	dailyArgs := append(append([]interface{}{}, args...), dateRange.Timezone)
+	dailyQuery := `
+		SELECT
+			date_trunc('day', e.timestamp AT TIME ZONE ` + fmt.Sprintf("$%d", len(dailyArgs)) + `)::date as date,
+			COUNT(*) as events,
+			COUNT(DISTINCT e.visitor_id) as visitors
+		FROM ` + botEventsSource + `
+		WHERE e.is_bot` + filterSQL + `
+		GROUP BY 1
+		ORDER BY date DESC`
+
+	rows, err = ba.db.Query(ctx, dailyQuery, dailyArgs...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day models.DailyStat
+		if err := rows.Scan(&day.Date, &day.Views, &day.Unique); err != nil {
+			continue
+		}
+		report.Daily = append(report.Daily, day)
+	}
+
+	return report, nil
+}
@@ -0,0 +1,126 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CohortAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewCohortAnalytics(db *pgxpool.Pool) *CohortAnalytics {
+	return &CohortAnalytics{db: db}
+}
+
+// acquisitionColumns are the columns of the event that places a visitor in a
+// cohort; dimension filters are applied to them
+const acquisitionColumns = `a.website_id, a.visitor_id, a.session_id, a.event_type, a.page, a.referrer,
+				a.country, a.city, a.browser, a.device, a.os,
+				a.utm_source, a.utm_medium, a.utm_campaign, a.utm_term, a.utm_content, a.is_bot, a.timestamp`
+
+// GetCohortCells groups visitors by the week or month, in the range's
+// timezone, of their first pageview or, when cohortEvent is set, of their
+// first occurrence of that event. Only visitors whose first time falls in
+// the range form cohorts. For each cohort it returns the cohort size as
+// period 0 and the number of visitors with a pageview in each of the
+// following periods.
+func (ca *CohortAnalytics) GetCohortCells(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, granularity, cohortEvent string, periods int) ([]models.CohortCell, error) {
+	loc := dateRange.Location()
+
+	// Returns are followed until the last cohort's final period has passed
+	activityEnd := dateRange.To.In(loc)
+	if granularity == models.GranularityMonth {
+		activityEnd = activityEnd.AddDate(0, periods+1, 0)
+	} else {
+		activityEnd = activityEnd.AddDate(0, 0, 7*(periods+1))
+	}
+	if now := time.Now(); activityEnd.After(now) {
+		activityEnd = now
+	}
+
+	args := []interface{}{websiteID, dateRange.From, dateRange.To, loc.String(), activityEnd, periods}
+
+	var acquisitions string
+	if cohortEvent == "" || cohortEvent == "pageview" {
+		acquisitions = `
+			SELECT DISTINCT ON (a.visitor_id) ` + acquisitionColumns + `
+			FROM events a
+			JOIN visitors v ON v.website_id = a.website_id AND v.visitor_id = a.visitor_id
+			WHERE a.website_id = $1
+			AND a.timestamp >= $2 AND a.timestamp < $3
+			AND a.event_type = 'pageview'
+			AND v.first_seen >= $2
+			ORDER BY a.visitor_id, a.timestamp`
+	} else {
+		args = append(args, cohortEvent)
+		table := eventTable(cohortEvent)
+		acquisitions = `
+			SELECT DISTINCT ON (a.visitor_id) ` + acquisitionColumns + `
+			FROM ` + table + ` a
+			WHERE a.website_id = $1
+			AND a.timestamp >= $2 AND a.timestamp < $3
+			AND a.event_type = $7
+			AND NOT EXISTS (
+				SELECT 1 FROM ` + table + ` p
+				WHERE p.website_id = $1 AND p.visitor_id = a.visitor_id
+				AND p.event_type = $7 AND p.timestamp < $2
+			)
+			ORDER BY a.visitor_id, a.timestamp`
+	}
+
+	filterSQL, args := BuildFilterClause(filters, "a", args)
+
+	unit := "week"
+	activityPeriod := "(date_trunc('week', r.timestamp AT TIME ZONE $4)::date - c.start::date) / 7"
+	if granularity == models.GranularityMonth {
+		unit = "month"
+		activityPeriod = "(EXTRACT(YEAR FROM r.timestamp AT TIME ZONE $4) * 12 + EXTRACT(MONTH FROM r.timestamp AT TIME ZONE $4))::integer" +
+			" - (EXTRACT(YEAR FROM c.start) * 12 + EXTRACT(MONTH FROM c.start))::integer"
+	}
+
+	query := `
+		WITH acquisitions AS (` + acquisitions + `
+		),
+		cohort AS (
+			SELECT a.visitor_id, a.timestamp, date_trunc('` + unit + `', a.timestamp AT TIME ZONE $4) AS start
+			FROM acquisitions a
+			WHERE true` + filterSQL + `
+		),
+		activity AS (
+			SELECT DISTINCT c.start, c.visitor_id, ` + activityPeriod + ` AS period
+			FROM cohort c
+			JOIN events r ON r.website_id = $1 AND r.visitor_id = c.visitor_id
+			WHERE r.event_type = 'pageview'
+			AND r.timestamp > c.timestamp AND r.timestamp < $5
+		)
+		SELECT start, 0 AS period, COUNT(*) AS visitors
+		FROM cohort
+		GROUP BY start
+		UNION ALL
+		SELECT start, period, COUNT(*) AS visitors
+		FROM activity
+		WHERE period BETWEEN 1 AND $6
+		GROUP BY start, period
+		ORDER BY start, period`
+
+	rows, err := ca.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cells []models.CohortCell
+	for rows.Next() {
+		var cell models.CohortCell
+		if err := rows.Scan(&cell.Start, &cell.Period, &cell.Visitors); err != nil {
+			return nil, err
+		}
+		cells = append(cells, cell)
+	}
+
+	return cells, rows.Err()
+}
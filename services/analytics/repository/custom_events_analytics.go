@@ -16,22 +16,45 @@ func NewCustomEventsAnalytics(db *pgxpool.Pool) *CustomEventsAnalytics {
 	return &CustomEventsAnalytics{db: db}
 }
 
-// GetCustomEventStats returns custom event statistics for a website
-func (ce *CustomEventsAnalytics) GetCustomEventStats(ctx context.Context, websiteID string, days int) ([]models.CustomEventStat, error) {
-	// Get aggregated event counts from the custom_events_aggregated table
+// GetCustomEventStats returns custom event statistics for a website, read
+// from the raw custom_events table so visitors, sessions and pages are known
+func (ce *CustomEventsAnalytics) GetCustomEventStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) ([]models.CustomEventStat, error) {
+	filterSQL, args := BuildFilterClause(filters, "ce", []interface{}{websiteID, dateRange.From, dateRange.To})
+
 	query := `
+		WITH filtered AS (
+			SELECT ce.event_type, ce.visitor_id, ce.session_id, ce.properties, ce.timestamp
+			FROM custom_events ce
+			WHERE ce.website_id = $1
+			AND ce.timestamp >= $2 AND ce.timestamp < $3` + filterSQL + `
+		), totals AS (
+			SELECT 
+				event_type,
+				COUNT(*) as total_count,
+				COUNT(DISTINCT visitor_id) as unique_visitors,
+				COUNT(DISTINCT session_id) as sessions
+			FROM filtered
+			GROUP BY event_type
+			ORDER BY total_count DESC
+			LIMIT 50
+		), samples AS (
+			SELECT DISTINCT ON (event_type)
+				event_type,
+				properties
+			FROM filtered
+			ORDER BY event_type, timestamp DESC
+		)
 		SELECT 
-			event_type,
-			SUM(count) as total_count,
-			sample_properties
-		FROM custom_events_aggregated
-		WHERE website_id = $1 
-		AND last_seen >= NOW() - INTERVAL '1 day' * $2
-		GROUP BY event_type, sample_properties
-		ORDER BY total_count DESC
-		LIMIT 50`
-
-	rows, err := ce.db.Query(ctx, query, websiteID, days)
+			t.event_type,
+			t.total_count,
+			t.unique_visitors,
+			t.sessions,
+			s.properties
+		FROM totals t
+		LEFT JOIN samples s USING (event_type)
+		ORDER BY t.total_count DESC`
+
+	rows, err := ce.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -42,7 +65,7 @@ func (ce *CustomEventsAnalytics) GetCustomEventStats(ctx context.Context, websit
 		var event models.CustomEventStat
 		var propertiesJSON []byte
 
-		err := rows.Scan(&event.EventType, &event.Count, &propertiesJSON)
+		err := rows.Scan(&event.EventType, &event.Count, &event.UniqueVisitors, &event.Sessions, &propertiesJSON)
 		if err != nil {
 			continue
 		}
@@ -59,10 +82,67 @@ func (ce *CustomEventsAnalytics) GetCustomEventStats(ctx context.Context, websit
 
 		events = append(events, event)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(events) == 0 {
+		return events, nil
+	}
+
+	pages, err := ce.getCustomEventPages(ctx, filterSQL, args)
+	if err != nil {
+		return nil, err
+	}
+	for i := range events {
+		events[i].Pages = pages[events[i].EventType]
+	}
 
 	return events, nil
 }
 
+// getCustomEventPages returns the top pages per event type, using the same
+// filters as the totals query
+func (ce *CustomEventsAnalytics) getCustomEventPages(ctx context.Context, filterSQL string, args []interface{}) (map[string][]models.CustomEventPageStat, error) {
+	query := `
+		WITH page_counts AS (
+			SELECT 
+				ce.event_type,
+				COALESCE(NULLIF(ce.page, ''), '/') as page,
+				COUNT(*) as count,
+				COUNT(DISTINCT ce.visitor_id) as unique_visitors
+			FROM custom_events ce
+			WHERE ce.website_id = $1
+			AND ce.timestamp >= $2 AND ce.timestamp < $3` + filterSQL + `
+			GROUP BY 1, 2
+		)
+		SELECT event_type, page, count, unique_visitors
+		FROM (
+			SELECT *, ROW_NUMBER() OVER (PARTITION BY event_type ORDER BY count DESC) as rank
+			FROM page_counts
+		) ranked
+		WHERE rank <= 10
+		ORDER BY event_type, count DESC`
+
+	rows, err := ce.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	pages := make(map[string][]models.CustomEventPageStat)
+	for rows.Next() {
+		var eventType string
+		var page models.CustomEventPageStat
+		if err := rows.Scan(&eventType, &page.Page, &page.Count, &page.UniqueVisitors); err != nil {
+			continue
+		}
+		pages[eventType] = append(pages[eventType], page)
+	}
+
+	return pages, rows.Err()
+}
+
 // extractCommonProperties extracts common property keys from sample properties
 func (ce *CustomEventsAnalytics) extractCommonProperties(props models.Properties) models.Properties {
 	if props == nil {
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CustomMetricRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewCustomMetricRepository(db *pgxpool.Pool) *CustomMetricRepository {
+	return &CustomMetricRepository{db: db}
+}
+
+const customMetricColumns = `id, website_id, name, description, expression, format, created_at, updated_at`
+
+func (r *CustomMetricRepository) Create(ctx context.Context, metric *models.CustomMetric) error {
+	metric.ID = uuid.New()
+	metric.CreatedAt = time.Now()
+	metric.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO custom_metrics (` + customMetricColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`
+
+	_, err := r.db.Exec(ctx, query,
+		metric.ID, metric.WebsiteID, metric.Name, metric.Description, metric.Expression, metric.Format,
+		metric.CreatedAt, metric.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *CustomMetricRepository) GetByWebsiteID(ctx context.Context, websiteID string) ([]models.CustomMetric, error) {
+	query := `
+		SELECT ` + customMetricColumns + `
+		FROM custom_metrics
+		WHERE website_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(ctx, query, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var metrics []models.CustomMetric
+	for rows.Next() {
+		metric, err := scanCustomMetric(rows)
+		if err != nil {
+			return nil, err
+		}
+		metrics = append(metrics, *metric)
+	}
+
+	return metrics, rows.Err()
+}
+
+func (r *CustomMetricRepository) GetByID(ctx context.Context, metricID uuid.UUID) (*models.CustomMetric, error) {
+	query := `
+		SELECT ` + customMetricColumns + `
+		FROM custom_metrics
+		WHERE id = $1`
+
+	return scanCustomMetric(r.db.QueryRow(ctx, query, metricID))
+}
+
+func (r *CustomMetricRepository) Update(ctx context.Context, metric *models.CustomMetric) error {
+	metric.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE custom_metrics
+		SET name = $2, description = $3, expression = $4, format = $5, updated_at = $6
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query,
+		metric.ID, metric.Name, metric.Description, metric.Expression, metric.Format, metric.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *CustomMetricRepository) Delete(ctx context.Context, metricID uuid.UUID) error {
+	query := `DELETE FROM custom_metrics WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, metricID)
+	return err
+}
+
+func scanCustomMetric(row pgx.Row) (*models.CustomMetric, error) {
+	var metric models.CustomMetric
+	err := row.Scan(
+		&metric.ID, &metric.WebsiteID, &metric.Name, &metric.Description, &metric.Expression, &metric.Format,
+		&metric.CreatedAt, &metric.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &metric, nil
+}
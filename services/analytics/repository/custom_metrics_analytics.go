@@ -0,0 +1,202 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type CustomMetricsAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewCustomMetricsAnalytics(db *pgxpool.Pool) *CustomMetricsAnalytics {
+	return &CustomMetricsAnalytics{db: db}
+}
+
+// Custom metric groupings besides the breakdown dimensions
+const (
+	CustomMetricsTotal  = ""
+	CustomMetricsByDay  = "day"
+	CustomMetricsByHour = "hour"
+)
+
+// metricPagePathSQL normalizes the page of e like goalPagePathSQL
+var metricPagePathSQL = strings.ReplaceAll(goalPagePathSQL, "g.page", "e.page")
+
+// numericPropertySQL reads a property as a number, or NULL when it isn't
+// one; the pattern keeps the cast from failing or overflowing
+const numericPropertySQL = `CASE WHEN (m.properties ->> %[1]s) ~ '^\s*-?[0-9]{1,30}(\.[0-9]*)?([eE][-+]?[0-9]{1,2})?\s*$' THEN (m.properties ->> %[1]s)::double precision END`
+
+// GetCustomMetricValues computes custom metrics over the range, in total or
+// for each day or hour of the range's timezone ("2006-01-02" and
+// "2006-01-02 15:00") or each value of a breakdown dimension. Each event
+// counts towards the day, hour or dimension value of its own row. The values
+// are keyed by group and metric ID; groups without any event the metrics
+// aggregate are missing.
+func (cm *CustomMetricsAnalytics) GetCustomMetricValues(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, metrics []models.CustomMetric, groupBy string) (map[string]map[string]*float64, error) {
+	args := []interface{}{websiteID, dateRange.From, dateRange.To}
+	binds := make(map[string]string)
+	bind := func(value interface{}) string {
+		if s, ok := value.(string); ok && binds[s] != "" {
+			return binds[s]
+		}
+		args = append(args, value)
+		placeholder := fmt.Sprintf("$%d", len(args))
+		if s, ok := value.(string); ok {
+			binds[s] = placeholder
+		}
+		return placeholder
+	}
+
+	var group string
+	switch groupBy {
+	case CustomMetricsTotal:
+		group = "''"
+	case CustomMetricsByDay:
+		group = "to_char(e.timestamp AT TIME ZONE " + bind(dateRange.Timezone) + ", 'YYYY-MM-DD')"
+	case CustomMetricsByHour:
+		group = "to_char(e.timestamp AT TIME ZONE " + bind(dateRange.Timezone) + ", 'YYYY-MM-DD HH24:00')"
+	case "page":
+		group = metricPagePathSQL
+	default:
+		value, ok := breakdownDimensions[groupBy]
+		if !ok {
+			return nil, fmt.Errorf("unsupported custom metric grouping %q", groupBy)
+		}
+		group = value
+	}
+
+	// Aggregates shared by several metrics are computed once
+	expressions := make([]*utils.MetricExpression, len(metrics))
+	columns := make(map[utils.MetricAggregate]string)
+	var aggregates []string
+	eventTypes := make(map[string][]string)
+	for i, metric := range metrics {
+		expression, err := utils.ParseMetricExpression(metric.Expression)
+		if err != nil {
+			return nil, fmt.Errorf("invalid expression of metric %s: %w", metric.ID, err)
+		}
+		expressions[i] = expression
+
+		for _, aggregate := range expression.Aggregates() {
+			if _, ok := columns[aggregate]; ok {
+				continue
+			}
+			column := fmt.Sprintf("a%d", len(aggregates))
+			columns[aggregate] = column
+			aggregates = append(aggregates, customMetricAggregateSQL(aggregate, bind)+" AS "+column)
+
+			table := eventTable(aggregate.Event)
+			if !containsString(eventTypes[table], aggregate.Event) {
+				eventTypes[table] = append(eventTypes[table], aggregate.Event)
+			}
+		}
+	}
+	if len(aggregates) == 0 {
+		return map[string]map[string]*float64{}, nil
+	}
+
+	// Custom events live in their own table; only the tables and event
+	// types the metrics aggregate are read
+	var branches []string
+	for _, table := range []string{"events", "custom_events"} {
+		if len(eventTypes[table]) == 0 {
+			continue
+		}
+		types := bind(eventTypes[table])
+		var filterSQL string
+		filterSQL, args = BuildFilterClause(filters, "e", args)
+		branches = append(branches, `
+				SELECT `+group+` as key, e.event_type, e.visitor_id, e.session_id, e.properties
+				FROM `+table+` e
+				WHERE e.website_id = $1
+				AND e.timestamp >= $2 AND e.timestamp < $3
+				AND e.event_type = ANY(`+types+`)`+filterSQL)
+	}
+
+	values := make([]string, len(expressions))
+	for i, expression := range expressions {
+		values[i] = expression.SQL(func(aggregate utils.MetricAggregate) string {
+			return columns[aggregate]
+		})
+	}
+
+	query := `
+		SELECT key, ` + strings.Join(values, ", ") + `
+		FROM (
+			SELECT m.key, ` + strings.Join(aggregates, ", ") + `
+			FROM (` + strings.Join(branches, `
+				UNION ALL`) + `
+			) m
+			GROUP BY m.key
+		) a`
+
+	rows, err := cm.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := make(map[string]map[string]*float64)
+	for rows.Next() {
+		var key *string
+		row := make([]*float64, len(metrics))
+		dest := []interface{}{&key}
+		for i := range row {
+			dest = append(dest, &row[i])
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, err
+		}
+
+		group := ""
+		if key != nil {
+			group = *key
+		}
+		if groupBy == "page" {
+			group = normalizePagePath(group)
+		}
+		byMetric := make(map[string]*float64, len(metrics))
+		for i, metric := range metrics {
+			byMetric[metric.ID.String()] = row[i]
+		}
+		results[group] = byMetric
+	}
+
+	return results, rows.Err()
+}
+
+// customMetricAggregateSQL returns the aggregate over the rows m of the
+// metric events, as double precision
+func customMetricAggregateSQL(aggregate utils.MetricAggregate, bind func(interface{}) string) string {
+	filter := " FILTER (WHERE m.event_type = " + bind(aggregate.Event) + ")"
+
+	switch aggregate.Func {
+	case utils.MetricCount:
+		return "(COUNT(*)" + filter + ")::double precision"
+	case utils.MetricVisitors:
+		return "(COUNT(DISTINCT m.visitor_id)" + filter + ")::double precision"
+	case utils.MetricSessions:
+		return "(COUNT(DISTINCT m.session_id)" + filter + ")::double precision"
+	}
+
+	value := fmt.Sprintf(numericPropertySQL, bind(aggregate.Property))
+	if aggregate.Func == utils.MetricSum {
+		return "COALESCE(SUM(" + value + ")" + filter + ", 0)"
+	}
+	return strings.ToUpper(aggregate.Func) + "(" + value + ")" + filter
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -8,16 +8,60 @@ import (
 )
 
 type DashboardAnalytics struct {
-	db *pgxpool.Pool
+	db      *pgxpool.Pool
+	rollups *Rollups
 }
 
-func NewDashboardAnalytics(db *pgxpool.Pool) *DashboardAnalytics {
-	return &DashboardAnalytics{db: db}
+func NewDashboardAnalytics(db *pgxpool.Pool, rollups *Rollups) *DashboardAnalytics {
+	return &DashboardAnalytics{db: db, rollups: rollups}
 }
 
 // GetDashboardMetrics returns the main dashboard metrics for a website
-func (da *DashboardAnalytics) GetDashboardMetrics(ctx context.Context, websiteID string, days int) (*models.DashboardMetrics, error) {
-	query := `
+func (da *DashboardAnalytics) GetDashboardMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (*models.DashboardMetrics, error) {
+	metrics, err := da.periodMetrics(ctx, websiteID, dateRange, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	// Ensure bounce rate is reasonable (0-100%)
+	if metrics.BounceRate > 100.0 {
+		metrics.BounceRate = 100.0
+	}
+	if metrics.BounceRate < 0.0 {
+		metrics.BounceRate = 0.0
+	}
+
+	return metrics, nil
+}
+
+// periodMetrics computes the dashboard totals for one period, from the
+// session rollups when the filters allow it and from raw events otherwise
+func (da *DashboardAnalytics) periodMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (*models.DashboardMetrics, error) {
+	query, args, ok := da.rollups.sessionsQuery(ctx, websiteID, dateRange, filters, nil)
+	if ok {
+		// Session duration is weighted by pageviews, as in the raw query
+		query = `
+		WITH sessions AS (` + query + `
+		)
+		SELECT
+			COALESCE(SUM(pageviews), 0)::bigint as page_views,
+			COUNT(session_id) as total_visitors,
+			COUNT(DISTINCT visitor_id) as unique_visitors,
+			COUNT(session_id) as sessions,
+			COALESCE(
+				(COUNT(session_id) FILTER (WHERE pageviews = 1) * 100.0) /
+				NULLIF(COUNT(session_id), 0), 0
+			) as bounce_rate,
+			COALESCE(
+				SUM(duration * pageviews) FILTER (WHERE session_id IS NOT NULL) /
+				NULLIF(SUM(pageviews) FILTER (WHERE session_id IS NOT NULL), 0), 0
+			) as avg_session_time,
+			COALESCE(SUM(pageviews) * 1.0 / NULLIF(COUNT(session_id), 0), 0) as pages_per_session
+		FROM sessions`
+	} else {
+		var filterSQL string
+		filterSQL, args = BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To})
+		query = `
 		WITH session_stats AS (
 			SELECT 
 				session_id,
@@ -33,7 +77,7 @@ func (da *DashboardAnalytics) GetDashboardMetrics(ctx context.Context, websiteID
 				END as session_duration
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -58,148 +102,34 @@ func (da *DashboardAnalytics) GetDashboardMetrics(ctx context.Context, websiteID
 		FROM events e
 		INNER JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'`
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'` + filterSQL
+	}
 
 	var metrics models.DashboardMetrics
-	err := da.db.QueryRow(ctx, query, websiteID, days).Scan(
+	err := da.db.QueryRow(ctx, query, args...).Scan(
 		&metrics.PageViews, &metrics.TotalVisitors, &metrics.UniqueVisitors, &metrics.Sessions,
 		&metrics.BounceRate, &metrics.AvgSessionTime, &metrics.PagesPerSession,
 	)
-
 	if err != nil {
 		return nil, err
 	}
 
-	// Ensure bounce rate is reasonable (0-100%)
-	if metrics.BounceRate > 100.0 {
-		metrics.BounceRate = 100.0
-	}
-	if metrics.BounceRate < 0.0 {
-		metrics.BounceRate = 0.0
-	}
-
 	return &metrics, nil
 }
 
-// GetComparisonMetrics returns comparison metrics between current and previous periods
-func (da *DashboardAnalytics) GetComparisonMetrics(ctx context.Context, websiteID string, days int) (*models.ComparisonMetrics, error) {
-	// Get current period metrics
-	currentQuery := `
-		WITH current_session_stats AS (
-			SELECT 
-				session_id,
-				COUNT(*) as page_count,
-				CASE 
-					WHEN COUNT(*) > 1 THEN 
-						-- Cap session duration at 30 minutes (1800 seconds) for realistic analytics
-						LEAST(EXTRACT(EPOCH FROM (MAX(timestamp) - MIN(timestamp))), 1800)
-					ELSE 
-						-- For single-page sessions, use time_on_page if available, otherwise 30 seconds
-						COALESCE(MAX(time_on_page), 30)
-				END as session_duration
-			FROM events
-			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
-			AND event_type = 'pageview'
-			GROUP BY session_id
-		)
-		SELECT 
-			COUNT(*) as page_views,
-			COUNT(DISTINCT e.session_id) as total_visitors,
-			COUNT(DISTINCT e.visitor_id) as unique_visitors,
-			COUNT(DISTINCT e.session_id) as sessions,
-			COALESCE(
-				(COUNT(DISTINCT CASE WHEN s.page_count = 1 THEN e.session_id END) * 100.0) / 
-				NULLIF(COUNT(DISTINCT e.session_id), 0), 0
-			) as bounce_rate,
-			COALESCE(AVG(s.session_duration), 0) as avg_session_time
-		FROM events e
-		INNER JOIN current_session_stats s ON e.session_id = s.session_id
-		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'`
-
-	// Get previous period metrics
-	previousQuery := `
-		WITH previous_session_stats AS (
-			SELECT 
-				session_id,
-				COUNT(*) as page_count,
-				CASE 
-					WHEN COUNT(*) > 1 THEN 
-						-- Cap session duration at 30 minutes (1800 seconds) for realistic analytics
-						LEAST(EXTRACT(EPOCH FROM (MAX(timestamp) - MIN(timestamp))), 1800)
-					ELSE 
-						-- For single-page sessions, use time_on_page if available, otherwise 30 seconds
-						COALESCE(MAX(time_on_page), 30)
-				END as session_duration
-			FROM events
-			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
-			AND timestamp < NOW() - INTERVAL '1 day' * $3
-			AND event_type = 'pageview'
-			GROUP BY session_id
-		)
-		SELECT 
-			COUNT(*) as page_views,
-			COUNT(DISTINCT e.session_id) as total_visitors,
-			COUNT(DISTINCT e.visitor_id) as unique_visitors,
-			COUNT(DISTINCT e.session_id) as sessions,
-			COALESCE(
-				(COUNT(DISTINCT CASE WHEN s.page_count = 1 THEN e.session_id END) * 100.0) / 
-				NULLIF(COUNT(DISTINCT e.session_id), 0), 0
-			) as bounce_rate,
-			COALESCE(AVG(s.session_duration), 0) as avg_session_time
-		FROM events e
-		INNER JOIN previous_session_stats s ON e.session_id = s.session_id
-		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.timestamp < NOW() - INTERVAL '1 day' * $3
-		AND event_type = 'pageview'`
-
-	// Execute current period query
-	var current struct {
-		PageViews      int     `db:"page_views"`
-		TotalVisitors  int     `db:"total_visitors"`
-		UniqueVisitors int     `db:"unique_visitors"`
-		Sessions       int     `db:"sessions"`
-		BounceRate     float64 `db:"bounce_rate"`
-		AvgSessionTime float64 `db:"avg_session_time"`
-	}
-
-	err := da.db.QueryRow(ctx, currentQuery, websiteID, days).Scan(
-		&current.PageViews, &current.TotalVisitors, &current.UniqueVisitors, &current.Sessions,
-		&current.BounceRate, &current.AvgSessionTime,
-	)
+// GetComparisonMetrics returns comparison metrics between the given range and the
+// range of equal length immediately before it
+func (da *DashboardAnalytics) GetComparisonMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (*models.ComparisonMetrics, error) {
+	current, err := da.periodMetrics(ctx, websiteID, dateRange, filters)
 	if err != nil {
 		return nil, err
 	}
 
-	// Execute previous period query
-	var previous struct {
-		PageViews      int     `db:"page_views"`
-		TotalVisitors  int     `db:"total_visitors"`
-		UniqueVisitors int     `db:"unique_visitors"`
-		Sessions       int     `db:"sessions"`
-		BounceRate     float64 `db:"bounce_rate"`
-		AvgSessionTime float64 `db:"avg_session_time"`
-	}
-
-	err = da.db.QueryRow(ctx, previousQuery, websiteID, days*2, days).Scan(
-		&previous.PageViews, &previous.TotalVisitors, &previous.UniqueVisitors, &previous.Sessions,
-		&previous.BounceRate, &previous.AvgSessionTime,
-	)
+	previous, err := da.periodMetrics(ctx, websiteID, dateRange.Previous(), filters)
 	if err != nil {
 		// If no previous data, return zeros for safe calculation
-		previous = struct {
-			PageViews      int     `db:"page_views"`
-			TotalVisitors  int     `db:"total_visitors"`
-			UniqueVisitors int     `db:"unique_visitors"`
-			Sessions       int     `db:"sessions"`
-			BounceRate     float64 `db:"bounce_rate"`
-			AvgSessionTime float64 `db:"avg_session_time"`
-		}{}
+		previous = &models.DashboardMetrics{}
 	}
 
 	// Calculate percentage changes with clamping and N/A handling
@@ -2,9 +2,11 @@ package repository
 
 import (
 	"analytics-app/models"
+	"analytics-app/utils"
 	"context"
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -19,9 +21,8 @@ const (
 )
 
 type EventRepository struct {
-	db                     *pgxpool.Pool
-	logger                 zerolog.Logger
-	customEventsAggregated *CustomEventsAggregatedRepository
+	db     *pgxpool.Pool
+	logger zerolog.Logger
 }
 
 type BatchResult struct {
@@ -33,40 +34,26 @@ type BatchResult struct {
 
 func NewEventRepository(db *pgxpool.Pool, logger zerolog.Logger) *EventRepository {
 	return &EventRepository{
-		db:                     db,
-		logger:                 logger,
-		customEventsAggregated: NewCustomEventsAggregatedRepository(db, logger),
+		db:     db,
+		logger: logger,
 	}
 }
 
 func (r *EventRepository) Create(ctx context.Context, event *models.Event) error {
 	r.prepareEvent(event)
 
-	// Handle custom events with aggregation
-	if event.EventType != "pageview" && event.EventType != "session_start" && event.EventType != "session_end" {
-		// For custom events, aggregate them instead of storing individually
-		if err := r.customEventsAggregated.UpsertCustomEvent(ctx, event); err != nil {
-			r.logger.Error().Err(err).Str("event_id", event.ID.String()).Msg("Failed to aggregate custom event")
-			return err
-		}
-		return nil
-	}
-
-	// For system events (pageview, session_start, session_end), store normally
 	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
 	defer cancel()
 
-	query := `INSERT INTO events (
-		id, website_id, visitor_id, session_id, event_type, page, referrer, user_agent, ip_address,
-		country, city, browser, device, os, utm_source, utm_medium, utm_campaign, utm_term, utm_content,
-		time_on_page, properties, timestamp, created_at
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)`
-
-	_, err := r.db.Exec(ctx, query, r.eventArgs(event)...)
+	// System events go to events, everything else is a custom event
+	table := eventTable(event.EventType)
+	_, err := r.db.Exec(ctx, insertQuery(table), r.rowArgs(table, event)...)
 	if err != nil {
-		r.logger.Error().Err(err).Str("event_id", event.ID.String()).Msg("Failed to insert event")
+		r.logger.Error().Err(err).Str("event_id", event.ID.String()).Str("table", table).Msg("Failed to insert event")
+		return err
 	}
-	return err
+	r.recordVisitors(ctx, []models.Event{*event})
+	return r.recordOrders(ctx, []models.Event{*event})
 }
 
 func (r *EventRepository) CreateBatch(ctx context.Context, events []models.Event) (*BatchResult, error) {
@@ -86,41 +73,48 @@ func (r *EventRepository) CreateBatch(ctx context.Context, events []models.Event
 	var customEvents []models.Event
 
 	for _, event := range events {
-		if event.EventType == "pageview" || event.EventType == "session_start" || event.EventType == "session_end" {
+		if eventTable(event.EventType) == "events" {
 			systemEvents = append(systemEvents, event)
 		} else {
 			customEvents = append(customEvents, event)
 		}
 	}
 
-	// Process system events in chunks (normal storage)
-	if len(systemEvents) > 0 {
-		for i := 0; i < len(systemEvents); i += MaxBatchSize {
+	// Both kinds are stored row by row, in their own hypertables
+	for _, group := range []struct {
+		table  string
+		events []models.Event
+	}{
+		{"events", systemEvents},
+		{"custom_events", customEvents},
+	} {
+		for i := 0; i < len(group.events); i += MaxBatchSize {
 			end := i + MaxBatchSize
-			if end > len(systemEvents) {
-				end = len(systemEvents)
+			if end > len(group.events) {
+				end = len(group.events)
 			}
 
-			chunkResult, err := r.processChunk(ctx, systemEvents[i:end])
+			chunkResult, err := r.processChunk(ctx, group.table, group.events[i:end])
 			result.Processed += chunkResult.Processed
 			result.Failed += chunkResult.Failed
 
 			if err != nil {
-				result.Errors = append(result.Errors, fmt.Errorf("system events chunk %d-%d: %w", i, end-1, err))
+				result.Errors = append(result.Errors, fmt.Errorf("%s chunk %d-%d: %w", group.table, i, end-1, err))
+			} else if group.table == "events" && chunkResult.Failed == 0 {
+				// Failed chunks are retried event by event, which records visitors then
+				r.recordVisitors(ctx, group.events[i:end])
+			} else if group.table == "custom_events" && chunkResult.Failed == 0 {
+				// Orders are part of storing a purchase, so failing to record
+				// them fails the chunk; the retry stores nothing twice
+				if err := r.recordOrders(ctx, group.events[i:end]); err != nil {
+					result.Processed -= chunkResult.Processed
+					result.Failed += chunkResult.Processed
+					result.Errors = append(result.Errors, fmt.Errorf("%s chunk %d-%d: %w", group.table, i, end-1, err))
+				}
 			}
 		}
 	}
 
-	// Process custom events with aggregation
-	for _, event := range customEvents {
-		if err := r.customEventsAggregated.UpsertCustomEvent(ctx, &event); err != nil {
-			result.Failed++
-			result.Errors = append(result.Errors, fmt.Errorf("custom event aggregation failed: %w", err))
-		} else {
-			result.Processed++
-		}
-	}
-
 	r.logger.Info().
 		Int("total", result.Total).
 		Int("processed", result.Processed).
@@ -133,35 +127,29 @@ func (r *EventRepository) CreateBatch(ctx context.Context, events []models.Event
 	return result, nil
 }
 
-func (r *EventRepository) processChunk(ctx context.Context, events []models.Event) (*BatchResult, error) {
+func (r *EventRepository) processChunk(ctx context.Context, table string, events []models.Event) (*BatchResult, error) {
 	ctx, cancel := context.WithTimeout(ctx, BatchTimeout)
 	defer cancel()
 
 	// Use COPY for larger batches, regular batch for smaller ones
 	if len(events) > 50 {
-		return r.copyBatch(ctx, events)
+		return r.copyBatch(ctx, table, events)
 	}
-	return r.regularBatch(ctx, events)
+	return r.regularBatch(ctx, table, events)
 }
 
-func (r *EventRepository) copyBatch(ctx context.Context, events []models.Event) (*BatchResult, error) {
+func (r *EventRepository) copyBatch(ctx context.Context, table string, events []models.Event) (*BatchResult, error) {
 	// Prepare all events
 	for i := range events {
 		r.prepareEvent(&events[i])
 	}
 
-	columns := []string{
-		"id", "website_id", "visitor_id", "session_id", "event_type", "page", "referrer", "user_agent", "ip_address",
-		"country", "city", "browser", "device", "os", "utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content",
-		"time_on_page", "properties", "timestamp", "created_at",
-	}
-
 	rows := make([][]interface{}, len(events))
 	for i, event := range events {
-		rows[i] = r.eventArgs(&event)
+		rows[i] = r.rowArgs(table, &event)
 	}
 
-	rowsAffected, err := r.db.CopyFrom(ctx, pgx.Identifier{"events"}, columns, pgx.CopyFromRows(rows))
+	rowsAffected, err := r.db.CopyFrom(ctx, pgx.Identifier{table}, tableColumns(table), pgx.CopyFromRows(rows))
 
 	result := &BatchResult{Total: len(events)}
 	if err != nil {
@@ -174,18 +162,14 @@ func (r *EventRepository) copyBatch(ctx context.Context, events []models.Event)
 	return result, nil
 }
 
-func (r *EventRepository) regularBatch(ctx context.Context, events []models.Event) (*BatchResult, error) {
+func (r *EventRepository) regularBatch(ctx context.Context, table string, events []models.Event) (*BatchResult, error) {
 	batch := &pgx.Batch{}
-	query := `INSERT INTO events (
-		id, website_id, visitor_id, session_id, event_type, page, referrer, user_agent, ip_address,
-		country, city, browser, device, os, utm_source, utm_medium, utm_campaign, utm_term, utm_content,
-		time_on_page, properties, timestamp, created_at
-	) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23)`
+	query := insertQuery(table)
 
 	// Prepare events and queue them
 	for i := range events {
 		r.prepareEvent(&events[i])
-		batch.Queue(query, r.eventArgs(&events[i])...)
+		batch.Queue(query, r.rowArgs(table, &events[i])...)
 	}
 
 	br := r.db.SendBatch(ctx, batch)
@@ -206,6 +190,70 @@ func (r *EventRepository) regularBatch(ctx context.Context, events []models.Even
 	return result, nil
 }
 
+// recordVisitors keeps first and last seen times of the visitors of stored
+// pageviews up to date. Bot traffic doesn't count as a visit. Failures are
+// logged only: the events are stored and visitors missing from the table are
+// counted as new.
+func (r *EventRepository) recordVisitors(ctx context.Context, events []models.Event) {
+	seen := utils.SeenVisitors(events)
+	if len(seen) == 0 {
+		return
+	}
+
+	websiteIDs := make([]string, len(seen))
+	visitorIDs := make([]string, len(seen))
+	firstSeen := make([]time.Time, len(seen))
+	lastSeen := make([]time.Time, len(seen))
+	for i, visitor := range seen {
+		websiteIDs[i], visitorIDs[i] = visitor.WebsiteID, visitor.VisitorID
+		firstSeen[i], lastSeen[i] = visitor.FirstSeen, visitor.LastSeen
+	}
+
+	query := `
+		INSERT INTO visitors (website_id, visitor_id, first_seen, last_seen)
+		SELECT * FROM unnest($1::text[], $2::text[], $3::timestamptz[], $4::timestamptz[])
+		ON CONFLICT (website_id, visitor_id) DO UPDATE SET
+			first_seen = LEAST(visitors.first_seen, EXCLUDED.first_seen),
+			last_seen = GREATEST(visitors.last_seen, EXCLUDED.last_seen)`
+
+	if _, err := r.db.Exec(ctx, query, websiteIDs, visitorIDs, firstSeen, lastSeen); err != nil {
+		r.logger.Error().Err(err).Int("visitors", len(seen)).Msg("Failed to record visitors")
+	}
+}
+
+// recordOrders stores the orders of purchase events. An order ID is recorded
+// once per website; later purchases with the same ID are duplicates.
+func (r *EventRepository) recordOrders(ctx context.Context, events []models.Event) error {
+	batch := &pgx.Batch{}
+	for i := range events {
+		if !utils.IsOrder(&events[i]) {
+			continue
+		}
+		order, err := utils.ParsePurchase(&events[i])
+		if err != nil {
+			// Rejected at ingestion; only events queued before that check get here
+			r.logger.Warn().Err(err).Str("event_id", events[i].ID.String()).Msg("Skipping invalid purchase")
+			continue
+		}
+		batch.Queue(`
+			INSERT INTO orders (website_id, order_id, event_id, visitor_id, session_id, amount, currency, items, timestamp)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+			ON CONFLICT (website_id, order_id) DO NOTHING`,
+			order.WebsiteID, order.OrderID, order.EventID, order.VisitorID, r.stringPtr(&order.SessionID),
+			order.Amount, order.Currency, order.Items, order.Timestamp,
+		)
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+
+	if err := r.db.SendBatch(ctx, batch).Close(); err != nil {
+		r.logger.Error().Err(err).Int("orders", batch.Len()).Msg("Failed to record orders")
+		return fmt.Errorf("failed to record orders: %w", err)
+	}
+	return nil
+}
+
 func (r *EventRepository) GetByWebsiteID(ctx context.Context, websiteID string, limit, offset int) ([]models.Event, error) {
 	if websiteID == "" {
 		return nil, fmt.Errorf("website_id required")
@@ -290,6 +338,51 @@ func (r *EventRepository) prepareEvent(event *models.Event) {
 		r.logger.Warn().Str("event_id", event.ID.String()).Msg("Event missing visitor_id")
 	}
 }
+
+var eventColumns = []string{
+	"id", "website_id", "visitor_id", "session_id", "event_type", "page", "referrer", "user_agent", "ip_address",
+	"country", "city", "browser", "device", "os", "utm_source", "utm_medium", "utm_campaign", "utm_term", "utm_content",
+	"time_on_page", "properties", "is_bot", "bot_name", "import_id", "timestamp", "created_at",
+}
+
+// eventTable returns the hypertable an event type is stored in
+func eventTable(eventType string) string {
+	switch eventType {
+	case "pageview", "session_start", "session_end":
+		return "events"
+	default:
+		return "custom_events"
+	}
+}
+
+// tableColumns returns the insert columns for an event table; custom events
+// additionally carry their property signature
+func tableColumns(table string) []string {
+	if table == "custom_events" {
+		return append(append([]string{}, eventColumns...), "event_signature")
+	}
+	return eventColumns
+}
+
+func insertQuery(table string) string {
+	columns := tableColumns(table)
+	placeholders := make([]string, len(columns))
+	for i := range columns {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+	}
+	// Events carry their ID from ingestion, so retried inserts are no-ops
+	return fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (id, timestamp) DO NOTHING", table, strings.Join(columns, ", "), strings.Join(placeholders, ", "))
+}
+
+// rowArgs returns the values matching tableColumns(table)
+func (r *EventRepository) rowArgs(table string, event *models.Event) []interface{} {
+	args := r.eventArgs(event)
+	if table == "custom_events" {
+		args = append(args, EventSignature(event.EventType, event.Properties))
+	}
+	return args
+}
+
 func (r *EventRepository) eventArgs(event *models.Event) []interface{} {
 	// Convert properties to JSON
 	var propertiesJSON interface{}
@@ -307,7 +400,7 @@ func (r *EventRepository) eventArgs(event *models.Event) []interface{} {
 		event.Page, r.stringPtr(event.Referrer), r.stringPtr(event.UserAgent), r.stringPtr(event.IPAddress),
 		r.stringPtr(event.Country), r.stringPtr(event.City), r.stringPtr(event.Browser), r.stringPtr(event.Device), r.stringPtr(event.OS),
 		r.stringPtr(event.UTMSource), r.stringPtr(event.UTMMedium), r.stringPtr(event.UTMCampaign), r.stringPtr(event.UTMTerm), r.stringPtr(event.UTMContent),
-		event.TimeOnPage, propertiesJSON, event.Timestamp, event.CreatedAt,
+		event.TimeOnPage, propertiesJSON, event.IsBot, r.stringPtr(event.BotName), event.ImportID, event.Timestamp, event.CreatedAt,
 	}
 }
 
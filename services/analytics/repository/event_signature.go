@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// EventSignature creates a stable signature for a custom event based on its
+// type and properties, so that identical events can be grouped
+func EventSignature(eventType string, properties models.Properties) string {
+	// Keys to ignore to reduce cardinality noise
+	ignoreKeys := map[string]bool{
+		"element_class": true,
+		"class":         true,
+		"style":         true,
+		"xpath":         true,
+		"data-testid":   true,
+	}
+
+	// Create a deterministic signature from event type and key properties
+	signatureData := []string{strings.ToLower(eventType)}
+
+	if properties != nil {
+		// Sort keys for consistent hashing
+		var keys []string
+		for key := range properties {
+			if ignoreKeys[strings.ToLower(key)] {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		// Add key-value pairs to signature
+		for _, key := range keys {
+			value := properties[key]
+			if value == nil {
+				continue
+			}
+			valStr := fmt.Sprintf("%v", value)
+			valStr = strings.TrimSpace(valStr)
+			if len(valStr) > 64 {
+				valStr = valStr[:64]
+			}
+			signatureData = append(signatureData, fmt.Sprintf("%s:%s", strings.ToLower(key), valStr))
+		}
+	}
+
+	// Create hash of the signature data
+	hash := sha256.Sum256([]byte(strings.Join(signatureData, "|")))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,250 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	EventStreamKey      = "analytics:events"
+	DeadLetterStreamKey = "analytics:events:dead"
+	EventConsumerGroup  = "analytics-writers"
+)
+
+// StreamedEvent is an event read from the ingestion stream. Err is set when
+// the entry could not be decoded, Raw keeps the payload as read.
+type StreamedEvent struct {
+	StreamID string
+	Event    models.Event
+	Raw      string
+	Err      error
+}
+
+// DeadLetter is an event that could not be stored after all retries
+type DeadLetter struct {
+	ID       string       `json:"id"`
+	Event    models.Event `json:"event"`
+	Error    string       `json:"error"`
+	FailedAt time.Time    `json:"failed_at"`
+}
+
+// EventStream is the durable ingestion buffer, backed by a Redis stream read
+// through a consumer group. Entries stay pending until acknowledged, so events
+// not yet written to the database survive restarts.
+type EventStream struct {
+	client   *redis.Client
+	consumer string
+}
+
+// NewEventStream creates a stream reader identified by consumer
+func NewEventStream(client *redis.Client, consumer string) *EventStream {
+	return &EventStream{client: client, consumer: consumer}
+}
+
+// EnsureGroup creates the consumer group (and stream) if missing
+func (s *EventStream) EnsureGroup(ctx context.Context) error {
+	err := s.client.XGroupCreateMkStream(ctx, EventStreamKey, EventConsumerGroup, "0").Err()
+	if err != nil && !strings.Contains(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+	return nil
+}
+
+// Add appends events to the stream in a single round trip
+func (s *EventStream) Add(ctx context.Context, events []models.Event) error {
+	pipe := s.client.Pipeline()
+	for i := range events {
+		payload, err := json.Marshal(&events[i])
+		if err != nil {
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: EventStreamKey,
+			Values: map[string]interface{}{"event": payload},
+		})
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// Read returns up to count new events for this consumer, waiting at most block
+func (s *EventStream) Read(ctx context.Context, count int, block time.Duration) ([]StreamedEvent, error) {
+	return s.readGroup(ctx, ">", count, block)
+}
+
+// ReadOwnPending returns events delivered to this consumer but never
+// acknowledged, e.g. because the process stopped before storing them
+func (s *EventStream) ReadOwnPending(ctx context.Context, count int) ([]StreamedEvent, error) {
+	return s.readGroup(ctx, "0", count, 0)
+}
+
+func (s *EventStream) readGroup(ctx context.Context, id string, count int, block time.Duration) ([]StreamedEvent, error) {
+	args := &redis.XReadGroupArgs{
+		Group:    EventConsumerGroup,
+		Consumer: s.consumer,
+		Streams:  []string{EventStreamKey, id},
+		Count:    int64(count),
+		Block:    block,
+	}
+	if block == 0 {
+		// A zero Block would wait forever
+		args.Block = -1
+	}
+	streams, err := s.client.XReadGroup(ctx, args).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var events []StreamedEvent
+	for _, stream := range streams {
+		events = append(events, decodeMessages(stream.Messages)...)
+	}
+	return events, nil
+}
+
+// ClaimPending takes over entries delivered but never acknowledged, either by
+// this consumer before a restart or by a consumer that went away. Entries idle
+// for less than minIdle are left alone.
+func (s *EventStream) ClaimPending(ctx context.Context, minIdle time.Duration, count int) ([]StreamedEvent, error) {
+	messages, _, err := s.client.XAutoClaim(ctx, &redis.XAutoClaimArgs{
+		Stream:   EventStreamKey,
+		Group:    EventConsumerGroup,
+		Consumer: s.consumer,
+		MinIdle:  minIdle,
+		Start:    "0",
+		Count:    int64(count),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return decodeMessages(messages), nil
+}
+
+// Ack acknowledges and removes stored entries
+func (s *EventStream) Ack(ctx context.Context, ids ...string) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	pipe := s.client.TxPipeline()
+	pipe.XAck(ctx, EventStreamKey, EventConsumerGroup, ids...)
+	pipe.XDel(ctx, EventStreamKey, ids...)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// DeadLetter moves an entry to the dead-letter stream and acknowledges it
+func (s *EventStream) DeadLetter(ctx context.Context, entry StreamedEvent, cause error) error {
+	payload := []byte(entry.Raw)
+	if entry.Err == nil {
+		var err error
+		if payload, err = json.Marshal(&entry.Event); err != nil {
+			return fmt.Errorf("failed to encode event: %w", err)
+		}
+	}
+
+	pipe := s.client.TxPipeline()
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: DeadLetterStreamKey,
+		Values: map[string]interface{}{
+			"event":     payload,
+			"error":     cause.Error(),
+			"failed_at": time.Now().UTC().Format(time.RFC3339),
+		},
+	})
+	pipe.XAck(ctx, EventStreamKey, EventConsumerGroup, entry.StreamID)
+	pipe.XDel(ctx, EventStreamKey, entry.StreamID)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// ListDeadLetters returns up to limit dead letters, oldest first
+func (s *EventStream) ListDeadLetters(ctx context.Context, limit int) ([]DeadLetter, error) {
+	messages, err := s.client.XRangeN(ctx, DeadLetterStreamKey, "-", "+", int64(limit)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]DeadLetter, 0, len(messages))
+	for _, message := range messages {
+		letter := DeadLetter{ID: message.ID}
+		if raw, ok := message.Values["event"].(string); ok {
+			json.Unmarshal([]byte(raw), &letter.Event)
+		}
+		letter.Error, _ = message.Values["error"].(string)
+		if raw, ok := message.Values["failed_at"].(string); ok {
+			letter.FailedAt, _ = time.Parse(time.RFC3339, raw)
+		}
+		letters = append(letters, letter)
+	}
+	return letters, nil
+}
+
+// RequeueDeadLetters moves up to limit dead letters back to the ingestion
+// stream and returns how many were moved
+func (s *EventStream) RequeueDeadLetters(ctx context.Context, limit int) (int, error) {
+	letters, err := s.ListDeadLetters(ctx, limit)
+	if err != nil {
+		return 0, err
+	}
+
+	moved := 0
+	for _, letter := range letters {
+		payload, err := json.Marshal(&letter.Event)
+		if err != nil {
+			return moved, err
+		}
+		pipe := s.client.TxPipeline()
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: EventStreamKey,
+			Values: map[string]interface{}{"event": payload},
+		})
+		pipe.XDel(ctx, DeadLetterStreamKey, letter.ID)
+		if _, err := pipe.Exec(ctx); err != nil {
+			return moved, err
+		}
+		moved++
+	}
+	return moved, nil
+}
+
+// Stats reports the stream backlog, entries awaiting acknowledgement and
+// dead letters
+func (s *EventStream) Stats(ctx context.Context) (backlog, pending, dead int64, err error) {
+	if backlog, err = s.client.XLen(ctx, EventStreamKey).Result(); err != nil {
+		return
+	}
+	if dead, err = s.client.XLen(ctx, DeadLetterStreamKey).Result(); err != nil {
+		return
+	}
+	info, pendingErr := s.client.XPending(ctx, EventStreamKey, EventConsumerGroup).Result()
+	if pendingErr == nil {
+		pending = info.Count
+	}
+	return
+}
+
+func decodeMessages(messages []redis.XMessage) []StreamedEvent {
+	events := make([]StreamedEvent, 0, len(messages))
+	for _, message := range messages {
+		entry := StreamedEvent{StreamID: message.ID}
+		raw, ok := message.Values["event"].(string)
+		entry.Raw = raw
+		if !ok {
+			entry.Err = errors.New("stream entry has no event payload")
+		} else if err := json.Unmarshal([]byte(raw), &entry.Event); err != nil {
+			entry.Err = fmt.Errorf("failed to decode event: %w", err)
+		}
+		events = append(events, entry)
+	}
+	return events
+}
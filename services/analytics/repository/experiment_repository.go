@@ -0,0 +1,164 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ExperimentRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewExperimentRepository(db *pgxpool.Pool) *ExperimentRepository {
+	return &ExperimentRepository{db: db}
+}
+
+const experimentColumns = `id, name, description, website_id, user_id, property, variants, control_variant,
+		goal_type, goal_event, goal_funnel_id, minimum_detectable_effect, status, started_at, ended_at, created_at, updated_at`
+
+func (r *ExperimentRepository) Create(ctx context.Context, experiment *models.Experiment) error {
+	experiment.ID = uuid.New()
+	experiment.CreatedAt = time.Now()
+	experiment.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO experiments (` + experimentColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`
+
+	_, err := r.db.Exec(ctx, query,
+		experiment.ID, experiment.Name, experiment.Description, experiment.WebsiteID, experiment.UserID,
+		experiment.Property, experiment.Variants, experiment.Control,
+		experiment.GoalType, experiment.GoalEvent, experiment.GoalFunnelID, experiment.MinimumDetectableEffect,
+		experiment.Status, experiment.StartedAt, experiment.EndedAt, experiment.CreatedAt, experiment.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *ExperimentRepository) GetByWebsiteID(ctx context.Context, websiteID string) ([]models.Experiment, error) {
+	query := `
+		SELECT ` + experimentColumns + `
+		FROM experiments
+		WHERE website_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(ctx, query, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var experiments []models.Experiment
+	for rows.Next() {
+		experiment, err := scanExperiment(rows)
+		if err != nil {
+			return nil, err
+		}
+		experiments = append(experiments, *experiment)
+	}
+
+	return experiments, rows.Err()
+}
+
+func (r *ExperimentRepository) GetByID(ctx context.Context, experimentID uuid.UUID) (*models.Experiment, error) {
+	query := `
+		SELECT ` + experimentColumns + `
+		FROM experiments
+		WHERE id = $1`
+
+	return scanExperiment(r.db.QueryRow(ctx, query, experimentID))
+}
+
+func (r *ExperimentRepository) Update(ctx context.Context, experimentID uuid.UUID, experiment *models.Experiment) error {
+	experiment.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE experiments
+		SET name = $2, description = $3, property = $4, variants = $5, control_variant = $6,
+			goal_type = $7, goal_event = $8, goal_funnel_id = $9, minimum_detectable_effect = $10,
+			status = $11, started_at = $12, ended_at = $13, updated_at = $14
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query,
+		experimentID, experiment.Name, experiment.Description, experiment.Property, experiment.Variants,
+		experiment.Control, experiment.GoalType, experiment.GoalEvent, experiment.GoalFunnelID,
+		experiment.MinimumDetectableEffect, experiment.Status, experiment.StartedAt, experiment.EndedAt,
+		experiment.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *ExperimentRepository) Delete(ctx context.Context, experimentID uuid.UUID) error {
+	query := `DELETE FROM experiments WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, experimentID)
+	return err
+}
+
+// StreamAssignedVisitorEvents reads the events in [from, to) of visitors
+// who have an event carrying the experiment property in [from, assignedBefore),
+// and calls fn once per visitor with their events in time order, skipping
+// bot traffic. The events slice is reused once fn returns.
+func (r *ExperimentRepository) StreamAssignedVisitorEvents(ctx context.Context, websiteID, property string, from, assignedBefore, to time.Time, fn func(visitorID string, events []models.Event) error) error {
+	query := `
+		WITH assigned AS (
+			SELECT visitor_id
+			FROM events
+			WHERE website_id = $1 AND timestamp >= $2 AND timestamp < $4
+			AND NOT is_bot AND properties ? $5
+			UNION
+			SELECT visitor_id
+			FROM custom_events
+			WHERE website_id = $1 AND timestamp >= $2 AND timestamp < $4
+			AND NOT is_bot AND properties ? $5
+		)
+		SELECT visitor_id, COALESCE(session_id, ''), event_type, COALESCE(page, ''), properties, timestamp
+		FROM (
+			SELECT visitor_id, session_id, event_type, page, properties, timestamp
+			FROM events
+			WHERE website_id = $1 AND timestamp >= $2 AND timestamp < $3 AND NOT is_bot
+			AND visitor_id IN (SELECT visitor_id FROM assigned)
+			UNION ALL
+			SELECT visitor_id, session_id, event_type, page, properties, timestamp
+			FROM custom_events
+			WHERE website_id = $1 AND timestamp >= $2 AND timestamp < $3 AND NOT is_bot
+			AND visitor_id IN (SELECT visitor_id FROM assigned)
+		) visitor_events
+		ORDER BY visitor_id, timestamp`
+
+	rows, err := r.db.Query(ctx, query, websiteID, from, to, assignedBefore, property)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	return groupVisitorEvents(rows, fn)
+}
+
+func scanExperiment(row pgx.Row) (*models.Experiment, error) {
+	var experiment models.Experiment
+	var variantsJSON []byte
+	err := row.Scan(
+		&experiment.ID, &experiment.Name, &experiment.Description, &experiment.WebsiteID, &experiment.UserID,
+		&experiment.Property, &variantsJSON, &experiment.Control,
+		&experiment.GoalType, &experiment.GoalEvent, &experiment.GoalFunnelID, &experiment.MinimumDetectableEffect,
+		&experiment.Status, &experiment.StartedAt, &experiment.EndedAt, &experiment.CreatedAt, &experiment.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if variantsJSON != nil {
+		if err := json.Unmarshal(variantsJSON, &experiment.Variants); err != nil {
+			return nil, err
+		}
+	}
+
+	return &experiment, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ExportAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewExportAnalytics(db *pgxpool.Pool) *ExportAnalytics {
+	return &ExportAnalytics{db: db}
+}
+
+// EventExportColumns are the columns of raw event exports. IP addresses are
+// not exported.
+var EventExportColumns = []models.ExportColumn{
+	{Name: "id", Type: models.ExportString},
+	{Name: "timestamp", Type: models.ExportTime},
+	{Name: "event_type", Type: models.ExportString},
+	{Name: "visitor_id", Type: models.ExportString},
+	{Name: "session_id", Type: models.ExportString},
+	{Name: "page", Type: models.ExportString},
+	{Name: "referrer", Type: models.ExportString},
+	{Name: "country", Type: models.ExportString},
+	{Name: "city", Type: models.ExportString},
+	{Name: "browser", Type: models.ExportString},
+	{Name: "device", Type: models.ExportString},
+	{Name: "os", Type: models.ExportString},
+	{Name: "utm_source", Type: models.ExportString},
+	{Name: "utm_medium", Type: models.ExportString},
+	{Name: "utm_campaign", Type: models.ExportString},
+	{Name: "utm_term", Type: models.ExportString},
+	{Name: "utm_content", Type: models.ExportString},
+	{Name: "time_on_page", Type: models.ExportInt},
+	{Name: "properties", Type: models.ExportString},
+	{Name: "user_agent", Type: models.ExportString},
+	{Name: "is_bot", Type: models.ExportBool},
+	{Name: "bot_name", Type: models.ExportString},
+}
+
+// eventExportSQL selects EventExportColumns from the table aliased e
+const eventExportSQL = `e.id::text, e.timestamp, e.event_type, e.visitor_id, e.session_id,
+	e.page, e.referrer, e.country, e.city, e.browser, e.device, e.os,
+	e.utm_source, e.utm_medium, e.utm_campaign, e.utm_term, e.utm_content,
+	e.time_on_page, e.properties::text, e.user_agent, e.is_bot, e.bot_name`
+
+// StreamEvents calls fn with the values of EventExportColumns for every event
+// of dataset (events or custom_events) in the range, oldest first. Rows are
+// read from the connection as fn consumes them, so exports of any size run
+// in constant memory.
+func (ea *ExportAnalytics) StreamEvents(ctx context.Context, websiteID, dataset string, dateRange models.DateRange, filters models.AnalyticsFilters, fn func(values []interface{}) error) error {
+	var table string
+	switch dataset {
+	case models.ExportDatasetEvents:
+		table = "events"
+	case models.ExportDatasetCustomEvents:
+		table = "custom_events"
+	default:
+		return fmt.Errorf("unsupported event dataset %q", dataset)
+	}
+
+	args := []interface{}{websiteID, dateRange.From, dateRange.To}
+	filterSQL, args := BuildFilterClause(filters, "e", args)
+
+	query := `
+		SELECT ` + eventExportSQL + `
+		FROM ` + table + ` e
+		WHERE e.website_id = $1 AND e.timestamp >= $2 AND e.timestamp < $3` + filterSQL + `
+		ORDER BY e.timestamp`
+
+	rows, err := ea.db.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		values, err := rows.Values()
+		if err != nil {
+			return err
+		}
+		if err := fn(values); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const exportJobKeyPrefix = "analytics:export:job:"
+
+// ErrExportJobNotFound is returned for unknown or expired export jobs
+var ErrExportJobNotFound = errors.New("export job not found")
+
+// ExportJobStore keeps the state of background exports in Redis, so any
+// instance can report on a job
+type ExportJobStore struct {
+	client *redis.Client
+	// ttl is how long jobs are kept after their last update
+	ttl time.Duration
+}
+
+func NewExportJobStore(client *redis.Client, ttl time.Duration) *ExportJobStore {
+	return &ExportJobStore{client: client, ttl: ttl}
+}
+
+func (s *ExportJobStore) Save(ctx context.Context, job *models.ExportJob) error {
+	data, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+	return s.client.Set(ctx, exportJobKeyPrefix+job.ID, data, s.ttl).Err()
+}
+
+func (s *ExportJobStore) Get(ctx context.Context, id string) (*models.ExportJob, error) {
+	data, err := s.client.Get(ctx, exportJobKeyPrefix+id).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrExportJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var job models.ExportJob
+	if err := json.Unmarshal(data, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"fmt"
+	"strings"
+)
+
+// BuildFilterClause compiles filters into parameterized SQL predicates on the
+// events table referenced by alias. Bot traffic is excluded unless requested.
+// Placeholders are numbered after the arguments already in args; the returned
+// clause starts with " AND " (or is empty) so it can be appended to an
+// existing WHERE.
+func BuildFilterClause(filters models.AnalyticsFilters, alias string, args []interface{}) (string, []interface{}) {
+	var conditions []string
+
+	bind := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	column := func(name string) string {
+		return alias + "." + name
+	}
+
+	lists := []struct {
+		column string
+		values []string
+	}{
+		{"country", filters.Country},
+		{"city", filters.City},
+		{"browser", filters.Browser},
+		{"device", filters.Device},
+		{"os", filters.OS},
+		{"utm_source", filters.UTMSource},
+		{"utm_medium", filters.UTMMedium},
+		{"utm_campaign", filters.UTMCampaign},
+		{"utm_term", filters.UTMTerm},
+		{"utm_content", filters.UTMContent},
+	}
+	for _, l := range lists {
+		if len(l.values) == 0 {
+			continue
+		}
+		lowered := make([]string, len(l.values))
+		for i, v := range l.values {
+			lowered[i] = strings.ToLower(v)
+		}
+		conditions = append(conditions, fmt.Sprintf("LOWER(%s) = ANY(%s)", column(l.column), bind(lowered)))
+	}
+
+	if filters.Page != "" {
+		conditions = append(conditions, matchCondition(column("page"), filters.Page, false, bind))
+	}
+
+	if filters.Referrer != "" {
+		if strings.EqualFold(filters.Referrer, "direct") {
+			conditions = append(conditions, fmt.Sprintf("(%s IS NULL OR %s = '')", column("referrer"), column("referrer")))
+		} else {
+			conditions = append(conditions, matchCondition(column("referrer"), filters.Referrer, true, bind))
+		}
+	}
+
+	// Event and property filters select whole sessions, because most widgets
+	// only look at pageviews while the matching event is a different row
+	if filters.EventType != "" || filters.PropertyKey != "" {
+		sessionConditions := []string{
+			"fe.website_id = " + column("website_id"),
+			"fe.session_id = " + column("session_id"),
+		}
+		if filters.EventType != "" {
+			sessionConditions = append(sessionConditions, "fe.event_type = "+bind(filters.EventType))
+		}
+		if filters.PropertyKey != "" {
+			if filters.PropertyValue != "" {
+				sessionConditions = append(sessionConditions,
+					fmt.Sprintf("fe.properties ->> %s = %s", bind(filters.PropertyKey), bind(filters.PropertyValue)))
+			} else {
+				sessionConditions = append(sessionConditions, "jsonb_exists(fe.properties, "+bind(filters.PropertyKey)+")")
+			}
+		}
+		// Custom events live in their own table; without an event type both are searched
+		tables := []string{"events", "custom_events"}
+		if filters.EventType != "" {
+			tables = []string{eventTable(filters.EventType)}
+		}
+		exists := make([]string, len(tables))
+		for i, table := range tables {
+			exists[i] = fmt.Sprintf("EXISTS (SELECT 1 FROM %s fe WHERE %s)", table, strings.Join(sessionConditions, " AND "))
+		}
+		conditions = append(conditions, "("+strings.Join(exists, " OR ")+")")
+	}
+
+	if !filters.IncludeBots {
+		conditions = append(conditions, "NOT "+column("is_bot"))
+	}
+
+	if len(conditions) == 0 {
+		return "", args
+	}
+	return "\n\t\tAND " + strings.Join(conditions, "\n\t\tAND "), args
+}
+
+// matchCondition matches a column exactly, or with LIKE when the pattern
+// contains the `*` wildcard
+func matchCondition(column, pattern string, caseInsensitive bool, bind func(interface{}) string) string {
+	if caseInsensitive {
+		column = "LOWER(" + column + ")"
+		pattern = strings.ToLower(pattern)
+	}
+	if !strings.Contains(pattern, "*") {
+		return fmt.Sprintf("%s = %s", column, bind(pattern))
+	}
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(pattern)
+	return fmt.Sprintf("%s LIKE %s", column, bind(strings.ReplaceAll(escaped, "*", "%")))
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -24,12 +25,13 @@ func (r *FunnelRepository) Create(ctx context.Context, funnel *models.Funnel) er
 	funnel.UpdatedAt = time.Now()
 
 	query := `
-		INSERT INTO funnels (id, name, description, website_id, user_id, steps, is_active, created_at, updated_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+		INSERT INTO funnels (id, name, description, website_id, user_id, steps, step_order, conversion_window_hours, is_active, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`
 
 	_, err := r.db.Exec(ctx, query,
 		funnel.ID, funnel.Name, funnel.Description, funnel.WebsiteID,
-		funnel.UserID, funnel.Steps, funnel.IsActive, funnel.CreatedAt, funnel.UpdatedAt,
+		funnel.UserID, funnel.Steps, funnel.StepOrder, funnel.ConversionWindowHours,
+		funnel.IsActive, funnel.CreatedAt, funnel.UpdatedAt,
 	)
 
 	return err
@@ -37,7 +39,7 @@ func (r *FunnelRepository) Create(ctx context.Context, funnel *models.Funnel) er
 
 func (r *FunnelRepository) GetByWebsiteID(ctx context.Context, websiteID string) ([]models.Funnel, error) {
 	query := `
-		SELECT id, name, description, website_id, user_id, steps, is_active, created_at, updated_at
+		SELECT id, name, description, website_id, user_id, steps, step_order, conversion_window_hours, is_active, created_at, updated_at
 		FROM funnels
 		WHERE website_id = $1
 		ORDER BY created_at DESC`
@@ -55,7 +57,8 @@ func (r *FunnelRepository) GetByWebsiteID(ctx context.Context, websiteID string)
 
 		err := rows.Scan(
 			&funnel.ID, &funnel.Name, &funnel.Description, &funnel.WebsiteID,
-			&funnel.UserID, &stepsJSON, &funnel.IsActive, &funnel.CreatedAt, &funnel.UpdatedAt,
+			&funnel.UserID, &stepsJSON, &funnel.StepOrder, &funnel.ConversionWindowHours,
+			&funnel.IsActive, &funnel.CreatedAt, &funnel.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -77,7 +80,7 @@ func (r *FunnelRepository) GetByWebsiteID(ctx context.Context, websiteID string)
 
 func (r *FunnelRepository) GetByID(ctx context.Context, funnelID uuid.UUID) (*models.Funnel, error) {
 	query := `
-		SELECT id, name, description, website_id, user_id, steps, is_active, created_at, updated_at
+		SELECT id, name, description, website_id, user_id, steps, step_order, conversion_window_hours, is_active, created_at, updated_at
 		FROM funnels
 		WHERE id = $1`
 
@@ -86,7 +89,8 @@ func (r *FunnelRepository) GetByID(ctx context.Context, funnelID uuid.UUID) (*mo
 
 	err := r.db.QueryRow(ctx, query, funnelID).Scan(
 		&funnel.ID, &funnel.Name, &funnel.Description, &funnel.WebsiteID,
-		&funnel.UserID, &stepsJSON, &funnel.IsActive, &funnel.CreatedAt, &funnel.UpdatedAt,
+		&funnel.UserID, &stepsJSON, &funnel.StepOrder, &funnel.ConversionWindowHours,
+		&funnel.IsActive, &funnel.CreatedAt, &funnel.UpdatedAt,
 	)
 
 	if err != nil {
@@ -109,11 +113,13 @@ func (r *FunnelRepository) Update(ctx context.Context, funnelID uuid.UUID, funne
 
 	query := `
 		UPDATE funnels 
-		SET name = $2, description = $3, steps = $4, is_active = $5, updated_at = $6
+		SET name = $2, description = $3, steps = $4, step_order = $5, conversion_window_hours = $6,
+			is_active = $7, updated_at = $8
 		WHERE id = $1`
 
 	_, err := r.db.Exec(ctx, query,
-		funnelID, funnel.Name, funnel.Description, funnel.Steps, funnel.IsActive, funnel.UpdatedAt,
+		funnelID, funnel.Name, funnel.Description, funnel.Steps, funnel.StepOrder,
+		funnel.ConversionWindowHours, funnel.IsActive, funnel.UpdatedAt,
 	)
 
 	return err
@@ -153,247 +159,74 @@ func (r *FunnelRepository) CreateFunnelEvent(ctx context.Context, event *models.
 	return err
 }
 
-func (r *FunnelRepository) GetFunnelAnalytics(ctx context.Context, funnelID uuid.UUID, days int) (*models.FunnelAnalytics, error) {
-	// Calculate analytics on-the-fly from funnel_events table
-	query := `
-		SELECT 
-			COUNT(DISTINCT visitor_id) as total_starts,
-			COUNT(DISTINCT visitor_id) FILTER (WHERE converted = true) as total_conversions,
-			COUNT(DISTINCT visitor_id) FILTER (WHERE converted = true) * 100.0 / NULLIF(COUNT(DISTINCT visitor_id), 0) as conversion_rate,
-			AVG(EXTRACT(EPOCH FROM (last_activity - started_at))) FILTER (WHERE converted = true) as avg_time_to_convert,
-			AVG(EXTRACT(EPOCH FROM (last_activity - started_at))) FILTER (WHERE converted = false) as avg_time_to_abandon
-		FROM funnel_events
-		WHERE funnel_id = $1 
-		AND created_at >= NOW() - INTERVAL '1 day' * $2`
-
-	var totalStarts, totalConversions int
-	var conversionRate, avgTimeToConvert, avgTimeToAbandon *float64
-
-	err := r.db.QueryRow(ctx, query, funnelID, days).Scan(
-		&totalStarts, &totalConversions, &conversionRate,
-		&avgTimeToConvert, &avgTimeToAbandon,
-	)
-
-	if err != nil {
-		return nil, err
-	}
-
-	// Calculate drop-off and abandonment rates
-	var dropOffRate, abandonmentRate float64
-	if conversionRate != nil {
-		dropOffRate = 100.0 - *conversionRate
-		abandonmentRate = 100.0 - *conversionRate
+// StreamVisitorEvents reads system and custom events of a website in
+// [from, to) and calls fn once per visitor with that visitor's events in
+// time order, skipping bot traffic. eventTypes limits the events read; nil
+// reads every type.
+// The events slice is reused once fn returns.
+func (r *FunnelRepository) StreamVisitorEvents(ctx context.Context, websiteID string, from, to time.Time, eventTypes []string, fn func(visitorID string, events []models.Event) error) error {
+	args := []interface{}{websiteID, from, to}
+	typeFilter := ""
+	if eventTypes != nil {
+		args = append(args, eventTypes)
+		typeFilter = " AND event_type = ANY($4)"
 	}
 
-	// Get website_id from the first event (assuming all events for a funnel have the same website_id)
-	var websiteID string
-	err = r.db.QueryRow(ctx,
-		"SELECT website_id FROM funnel_events WHERE funnel_id = $1 LIMIT 1",
-		funnelID).Scan(&websiteID)
-
+	query := `
+		SELECT visitor_id, COALESCE(session_id, ''), event_type, COALESCE(page, ''), properties, timestamp
+		FROM (
+			SELECT visitor_id, session_id, event_type, page, properties, timestamp
+			FROM events
+			WHERE website_id = $1 AND timestamp >= $2 AND timestamp < $3 AND NOT is_bot` + typeFilter + `
+			UNION ALL
+			SELECT visitor_id, session_id, event_type, page, properties, timestamp
+			FROM custom_events
+			WHERE website_id = $1 AND timestamp >= $2 AND timestamp < $3 AND NOT is_bot` + typeFilter + `
+		) visitor_events
+		ORDER BY visitor_id, timestamp`
+
+	rows, err := r.db.Query(ctx, query, args...)
 	if err != nil {
-		websiteID = "" // Set empty if no events found
-	}
-
-	// Convert float64 pointers to int pointers for the model
-	var avgTimeToConvertInt, avgTimeToAbandonInt *int
-	if avgTimeToConvert != nil {
-		timeInt := int(*avgTimeToConvert)
-		avgTimeToConvertInt = &timeInt
-	}
-	if avgTimeToAbandon != nil {
-		timeInt := int(*avgTimeToAbandon)
-		avgTimeToAbandonInt = &timeInt
-	}
-
-	// Handle conversion rate safely
-	var conversionRateValue float64
-	if conversionRate != nil {
-		conversionRateValue = *conversionRate
-	}
-
-	analytics := &models.FunnelAnalytics{
-		FunnelID:         funnelID,
-		WebsiteID:        websiteID,
-		Date:             time.Now().Format("2006-01-02"),
-		TotalStarts:      totalStarts,
-		TotalConversions: totalConversions,
-		ConversionRate:   conversionRateValue,
-		AvgTimeToConvert: avgTimeToConvertInt,
-		AvgTimeToAbandon: avgTimeToAbandonInt,
-		DropOffRate:      dropOffRate,
-		AbandonmentRate:  abandonmentRate,
+		return err
 	}
+	defer rows.Close()
 
-	return analytics, nil
+	return groupVisitorEvents(rows, fn)
 }
 
-// GetDetailedFunnelAnalytics provides step-by-step funnel analysis
-func (r *FunnelRepository) GetDetailedFunnelAnalytics(ctx context.Context, funnelID uuid.UUID, days int) (*models.DetailedFunnelAnalytics, error) {
-	// Get the funnel definition first
-	funnel, err := r.GetByID(ctx, funnelID)
-	if err != nil {
-		return nil, err
-	}
-
-	// Get step-by-step analytics
-	stepAnalytics := make([]models.FunnelStepAnalytics, len(funnel.Steps))
-
-	for i, step := range funnel.Steps {
-		stepIndex := i + 1
-
-		// Count visitors who reached this EXACT step (not beyond)
-		stepQuery := `
-			SELECT 
-				COUNT(DISTINCT visitor_id) as visitors_reached,
-				AVG(EXTRACT(EPOCH FROM (last_activity - started_at))) as avg_time_on_step
-			FROM funnel_events
-			WHERE funnel_id = $1 
-			AND current_step = $2
-			AND created_at >= NOW() - INTERVAL '1 day' * $3`
-
-		var visitorsReached int
-		var avgTimeOnStep *float64
-
-		err := r.db.QueryRow(ctx, stepQuery, funnelID, stepIndex, days).Scan(
-			&visitorsReached, &avgTimeOnStep,
-		)
-
-		if err != nil {
-			visitorsReached = 0
-			avgTimeOnStep = nil
+// groupVisitorEvents scans rows of (visitor_id, session_id, event_type, page,
+// properties, timestamp) ordered by visitor and time, and calls fn once per
+// visitor. The events slice is reused once fn returns.
+func groupVisitorEvents(rows pgx.Rows, fn func(visitorID string, events []models.Event) error) error {
+	var currentVisitor string
+	var visitorEvents []models.Event
+	for rows.Next() {
+		var event models.Event
+		var propertiesJSON []byte
+		if err := rows.Scan(&event.VisitorID, &event.SessionID, &event.EventType, &event.Page, &propertiesJSON, &event.Timestamp); err != nil {
+			return err
 		}
-
-		// Calculate conversion rate to next step
-		var conversionToNext float64
-		if i < len(funnel.Steps)-1 {
-			// Count visitors who reached the NEXT step (converted from current step)
-			nextStepQuery := `
-				SELECT COUNT(DISTINCT visitor_id) as visitors_to_next
-				FROM funnel_events
-				WHERE funnel_id = $1 
-				AND current_step = $2
-				AND created_at >= NOW() - INTERVAL '1 day' * $3`
-
-			var visitorsToNext int
-			err := r.db.QueryRow(ctx, nextStepQuery, funnelID, stepIndex+1, days).Scan(&visitorsToNext)
-			if err == nil && visitorsReached > 0 {
-				conversionToNext = (float64(visitorsToNext) / float64(visitorsReached)) * 100
-			}
-		} else {
-			// Last step - check for conversions
-			conversionQuery := `
-				SELECT COUNT(DISTINCT visitor_id) as conversions
-				FROM funnel_events
-				WHERE funnel_id = $1 
-				AND converted = true
-				AND current_step = $2
-				AND created_at >= NOW() - INTERVAL '1 day' * $3`
-
-			var conversions int
-			err := r.db.QueryRow(ctx, conversionQuery, funnelID, stepIndex, days).Scan(&conversions)
-			if err == nil && visitorsReached > 0 {
-				conversionToNext = (float64(conversions) / float64(visitorsReached)) * 100
+		if len(propertiesJSON) > 0 {
+			if err := json.Unmarshal(propertiesJSON, &event.Properties); err != nil {
+				event.Properties = nil
 			}
 		}
 
-		stepAnalytics[i] = models.FunnelStepAnalytics{
-			StepID:          step.ID,
-			StepName:        step.Name,
-			StepOrder:       step.Order,
-			VisitorsReached: visitorsReached,
-			ConversionRate:  conversionToNext,
-			DropOffRate:     100 - conversionToNext,
-			AvgTimeOnStep:   avgTimeOnStep,
-		}
-	}
-
-	// Get daily performance data
-	dailyQuery := `
-		SELECT 
-			DATE(created_at) as date,
-			COUNT(DISTINCT visitor_id) as total_starts,
-			COUNT(DISTINCT visitor_id) FILTER (WHERE converted = true) as conversions,
-			COUNT(DISTINCT visitor_id) FILTER (WHERE converted = true) * 100.0 / NULLIF(COUNT(DISTINCT visitor_id), 0) as conversion_rate
-		FROM funnel_events
-		WHERE funnel_id = $1 
-		AND created_at >= NOW() - INTERVAL '1 day' * $2
-		GROUP BY DATE(created_at)
-		ORDER BY date DESC`
-
-	rows, err := r.db.Query(ctx, dailyQuery, funnelID, days)
-	if err != nil {
-		return nil, err
-	}
-	defer rows.Close()
-
-	var dailyPerformance []models.DailyFunnelPerformance
-	for rows.Next() {
-		var dp models.DailyFunnelPerformance
-		var date time.Time
-		var conversionRate *float64
-
-		err := rows.Scan(&date, &dp.TotalStarts, &dp.Conversions, &conversionRate)
-		if err != nil {
-			continue
-		}
-
-		dp.Date = date.Format("2006-01-02")
-		if conversionRate != nil {
-			dp.ConversionRate = *conversionRate
+		if event.VisitorID != currentVisitor && len(visitorEvents) > 0 {
+			if err := fn(currentVisitor, visitorEvents); err != nil {
+				return err
+			}
+			visitorEvents = visitorEvents[:0]
 		}
-
-		dailyPerformance = append(dailyPerformance, dp)
+		currentVisitor = event.VisitorID
+		visitorEvents = append(visitorEvents, event)
 	}
-
-	// Get cohort analysis data
-	cohortQuery := `
-		SELECT 
-			DATE(started_at) as cohort_date,
-			COUNT(DISTINCT visitor_id) as cohort_size,
-			COUNT(DISTINCT visitor_id) FILTER (WHERE converted = true) as conversions,
-			AVG(EXTRACT(EPOCH FROM (last_activity - started_at))) as avg_time_to_convert
-		FROM funnel_events
-		WHERE funnel_id = $1 
-		AND started_at >= NOW() - INTERVAL '1 day' * $2
-		GROUP BY DATE(started_at)
-		ORDER BY cohort_date DESC`
-
-	cohortRows, err := r.db.Query(ctx, cohortQuery, funnelID, days)
-	if err != nil {
-		return nil, err
+	if err := rows.Err(); err != nil {
+		return err
 	}
-	defer cohortRows.Close()
-
-	var cohortData []models.FunnelCohortData
-	for cohortRows.Next() {
-		var cd models.FunnelCohortData
-		var cohortDate time.Time
-		var avgTimeToConvert *float64
 
-		err := cohortRows.Scan(&cohortDate, &cd.CohortSize, &cd.Conversions, &avgTimeToConvert)
-		if err != nil {
-			continue
-		}
-
-		cd.CohortDate = cohortDate.Format("2006-01-02")
-		cd.ConversionRate = 0
-		if cd.CohortSize > 0 {
-			cd.ConversionRate = (float64(cd.Conversions) / float64(cd.CohortSize)) * 100
-		}
-		if avgTimeToConvert != nil {
-			cd.AvgTimeToConvert = int(*avgTimeToConvert)
-		}
-
-		cohortData = append(cohortData, cd)
+	if len(visitorEvents) > 0 {
+		return fn(currentVisitor, visitorEvents)
 	}
-
-	return &models.DetailedFunnelAnalytics{
-		FunnelID:         funnelID,
-		WebsiteID:        funnel.WebsiteID,
-		StepAnalytics:    stepAnalytics,
-		DailyPerformance: dailyPerformance,
-		CohortData:       cohortData,
-		DateRange:        days,
-	}, nil
+	return nil
 }
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type GoalConversionsAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewGoalConversionsAnalytics(db *pgxpool.Pool) *GoalConversionsAnalytics {
+	return &GoalConversionsAnalytics{db: db}
+}
+
+// breakdownDimensions maps each breakdown to the value its Top* query groups the
+// pageview e by
+var breakdownDimensions = map[string]string{
+	"page":         "e.page",
+	"referrer":     normalizedReferrerSQL,
+	"source":       sourceCategorySQL,
+	"country":      "COALESCE(e.country, 'unknown')",
+	"browser":      "COALESCE(e.browser, 'unknown')",
+	"device":       "COALESCE(e.device, 'unknown')",
+	"os":           "COALESCE(e.os, 'unknown')",
+	"utm_source":   "COALESCE(NULLIF(e.utm_source, ''), 'direct')",
+	"utm_medium":   "COALESCE(NULLIF(e.utm_medium, ''), 'none')",
+	"utm_campaign": "COALESCE(e.utm_campaign, '')",
+	"utm_term":     "COALESCE(e.utm_term, '')",
+	"utm_content":  "COALESCE(e.utm_content, '')",
+}
+
+// goalPagePathSQL normalizes the page of g the way utils.GoalPagePattern
+// normalizes page patterns
+const goalPagePathSQL = `COALESCE(NULLIF(rtrim(split_part(split_part(regexp_replace(g.page, '^https?://[^/]+', ''), '?', 1), '#', 1), '/'), ''), '/')`
+
+// GetGoalConversions returns, for each value of a breakdown dimension, the
+// sessions with a pageview in the range and how many of them reached the
+// goal. A session converts when it hits the goal at any point in the range.
+func (gc *GoalConversionsAnalytics) GetGoalConversions(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, goal *models.Goal, dimension string) (map[string]models.GoalCounts, error) {
+	value, ok := breakdownDimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unsupported goal dimension %q", dimension)
+	}
+
+	hitsSQL, args, err := goalHitsQuery(goal, []interface{}{websiteID, dateRange.From, dateRange.To})
+	if err != nil {
+		return nil, err
+	}
+	filterSQL, args := BuildFilterClause(filters, "e", args)
+
+	query := `
+		WITH goal_sessions AS (
+			SELECT DISTINCT session_id FROM (` + hitsSQL + `) hits
+		)
+		SELECT
+			` + value + ` as value,
+			COUNT(DISTINCT e.session_id) as sessions,
+			COUNT(DISTINCT e.session_id) FILTER (WHERE gs.session_id IS NOT NULL) as conversions
+		FROM events e
+		LEFT JOIN goal_sessions gs ON gs.session_id = e.session_id
+		WHERE e.website_id = $1
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'` + filterSQL + `
+		GROUP BY 1`
+
+	rows, err := gc.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]models.GoalCounts)
+	for rows.Next() {
+		var key string
+		var row models.GoalCounts
+		if err := rows.Scan(&key, &row.Sessions, &row.Conversions); err != nil {
+			return nil, err
+		}
+		// Top pages are merged by normalized path
+		if dimension == "page" {
+			key = normalizePagePath(key)
+		}
+		existing := counts[key]
+		existing.Sessions += row.Sessions
+		existing.Conversions += row.Conversions
+		counts[key] = existing
+	}
+
+	return counts, rows.Err()
+}
+
+// goalHitsQuery returns a query for the session_id, visitor_id and timestamp
+// of every non-bot event matching the goal. args must start with the website
+// ID and the range bounds; the goal's parameters are appended.
+func goalHitsQuery(goal *models.Goal, args []interface{}) (string, []interface{}, error) {
+	bind := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	switch goal.Type {
+	case models.GoalTypePage:
+		if goal.PagePattern == nil {
+			return "", nil, fmt.Errorf("goal has no page pattern")
+		}
+		query := `
+			SELECT g.session_id, g.visitor_id, g.timestamp
+			FROM events g
+			WHERE g.website_id = $1 AND g.timestamp >= $2 AND g.timestamp < $3
+			AND NOT g.is_bot AND g.event_type = 'pageview'
+			AND ` + goalPagePathSQL + ` LIKE ` + bind(utils.GoalPagePattern(*goal.PagePattern))
+		return query, args, nil
+
+	case models.GoalTypeEvent:
+		if goal.EventName == nil {
+			return "", nil, fmt.Errorf("goal has no event name")
+		}
+		query := `
+			SELECT g.session_id, g.visitor_id, g.timestamp
+			FROM ` + eventTable(*goal.EventName) + ` g
+			WHERE g.website_id = $1 AND g.timestamp >= $2 AND g.timestamp < $3
+			AND NOT g.is_bot AND g.event_type = ` + bind(*goal.EventName)
+
+		if goal.PropertyKey != nil && *goal.PropertyKey != "" {
+			key := bind(*goal.PropertyKey)
+			if goal.PropertyValue != nil {
+				query += ` AND g.properties ->> ` + key + ` LIKE ` + bind(utils.GlobToLike(*goal.PropertyValue))
+			} else {
+				query += ` AND g.properties ? ` + key
+			}
+		}
+		return query, args, nil
+
+	default:
+		return "", nil, fmt.Errorf("unknown goal type %q", goal.Type)
+	}
+}
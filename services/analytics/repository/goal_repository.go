@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type GoalRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewGoalRepository(db *pgxpool.Pool) *GoalRepository {
+	return &GoalRepository{db: db}
+}
+
+const goalColumns = `id, name, description, website_id, user_id, type, page_pattern, event_name,
+		property_key, property_value, value, created_at, updated_at`
+
+func (r *GoalRepository) Create(ctx context.Context, goal *models.Goal) error {
+	goal.ID = uuid.New()
+	goal.CreatedAt = time.Now()
+	goal.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO goals (` + goalColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`
+
+	_, err := r.db.Exec(ctx, query,
+		goal.ID, goal.Name, goal.Description, goal.WebsiteID, goal.UserID, goal.Type,
+		goal.PagePattern, goal.EventName, goal.PropertyKey, goal.PropertyValue, goal.Value,
+		goal.CreatedAt, goal.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *GoalRepository) GetByWebsiteID(ctx context.Context, websiteID string) ([]models.Goal, error) {
+	query := `
+		SELECT ` + goalColumns + `
+		FROM goals
+		WHERE website_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(ctx, query, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var goals []models.Goal
+	for rows.Next() {
+		goal, err := scanGoal(rows)
+		if err != nil {
+			return nil, err
+		}
+		goals = append(goals, *goal)
+	}
+
+	return goals, rows.Err()
+}
+
+func (r *GoalRepository) GetByID(ctx context.Context, goalID uuid.UUID) (*models.Goal, error) {
+	query := `
+		SELECT ` + goalColumns + `
+		FROM goals
+		WHERE id = $1`
+
+	return scanGoal(r.db.QueryRow(ctx, query, goalID))
+}
+
+func (r *GoalRepository) Update(ctx context.Context, goalID uuid.UUID, goal *models.Goal) error {
+	goal.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE goals
+		SET name = $2, description = $3, type = $4, page_pattern = $5, event_name = $6,
+			property_key = $7, property_value = $8, value = $9, updated_at = $10
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query,
+		goalID, goal.Name, goal.Description, goal.Type, goal.PagePattern, goal.EventName,
+		goal.PropertyKey, goal.PropertyValue, goal.Value, goal.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *GoalRepository) Delete(ctx context.Context, goalID uuid.UUID) error {
+	query := `DELETE FROM goals WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, goalID)
+	return err
+}
+
+func scanGoal(row pgx.Row) (*models.Goal, error) {
+	var goal models.Goal
+	err := row.Scan(
+		&goal.ID, &goal.Name, &goal.Description, &goal.WebsiteID, &goal.UserID, &goal.Type,
+		&goal.PagePattern, &goal.EventName, &goal.PropertyKey, &goal.PropertyValue, &goal.Value,
+		&goal.CreatedAt, &goal.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &goal, nil
+}
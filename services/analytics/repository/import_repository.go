@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// ErrImportNotFound is returned for unknown imports
+var ErrImportNotFound = errors.New("import not found")
+
+type ImportRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewImportRepository(db *pgxpool.Pool) *ImportRepository {
+	return &ImportRepository{db: db}
+}
+
+const importColumns = `id, website_id, source, COALESCE(filename, ''), status, rows_read, rows_skipped,
+		events_imported, aggregates_imported, COALESCE(error, ''), created_at, completed_at, rolled_back_at`
+
+func (r *ImportRepository) Create(ctx context.Context, imp *models.Import) error {
+	imp.ID = uuid.New()
+	imp.Status = models.ImportRunning
+	imp.CreatedAt = time.Now().UTC()
+
+	query := `
+		INSERT INTO imports (id, website_id, source, filename, status, created_at)
+		VALUES ($1, $2, $3, NULLIF($4, ''), $5, $6)`
+
+	_, err := r.db.Exec(ctx, query, imp.ID, imp.WebsiteID, imp.Source, imp.Filename, imp.Status, imp.CreatedAt)
+	return err
+}
+
+func (r *ImportRepository) Get(ctx context.Context, websiteID string, importID uuid.UUID) (*models.Import, error) {
+	query := `
+		SELECT ` + importColumns + `
+		FROM imports
+		WHERE id = $1 AND website_id = $2`
+
+	imp, err := scanImport(r.db.QueryRow(ctx, query, importID, websiteID))
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrImportNotFound
+	}
+	return imp, err
+}
+
+func (r *ImportRepository) List(ctx context.Context, websiteID string) ([]models.Import, error) {
+	query := `
+		SELECT ` + importColumns + `
+		FROM imports
+		WHERE website_id = $1
+		ORDER BY created_at DESC`
+
+	rows, err := r.db.Query(ctx, query, websiteID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	imports := []models.Import{}
+	for rows.Next() {
+		imp, err := scanImport(rows)
+		if err != nil {
+			return nil, err
+		}
+		imports = append(imports, *imp)
+	}
+
+	return imports, rows.Err()
+}
+
+// Finish records the counts and final status of an import
+func (r *ImportRepository) Finish(ctx context.Context, imp *models.Import) error {
+	query := `
+		UPDATE imports
+		SET status = $2, rows_read = $3, rows_skipped = $4, events_imported = $5,
+			aggregates_imported = $6, error = NULLIF($7, ''), completed_at = $8
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query,
+		imp.ID, imp.Status, imp.RowsRead, imp.RowsSkipped, imp.EventsImported,
+		imp.AggregatesImported, imp.Error, imp.CompletedAt,
+	)
+	return err
+}
+
+// InsertAggregates stores days of aggregates of an import
+func (r *ImportRepository) InsertAggregates(ctx context.Context, imp *models.Import, stats []models.ImportedDailyStat) error {
+	if len(stats) == 0 {
+		return nil
+	}
+
+	_, err := r.db.CopyFrom(ctx,
+		pgx.Identifier{"imported_daily_stats"},
+		[]string{"import_id", "website_id", "date", "page", "source", "pageviews", "visitors", "visits", "bounces", "visit_duration"},
+		pgx.CopyFromSlice(len(stats), func(i int) ([]interface{}, error) {
+			stat := stats[i]
+			return []interface{}{
+				imp.ID, imp.WebsiteID, stat.Date, stat.Page, stat.Source,
+				stat.Pageviews, stat.Visitors, stat.Visits, stat.Bounces, stat.VisitDuration,
+			}, nil
+		}),
+	)
+	return err
+}
+
+// Rollback deletes everything an import stored and marks it rolled back.
+// Visitors first seen in the import are moved to their first remaining
+// pageview, or deleted when they have none left.
+func (r *ImportRepository) Rollback(ctx context.Context, imp *models.Import) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM orders
+		WHERE website_id = $2
+		AND event_id IN (SELECT id FROM custom_events WHERE import_id = $1)`, imp.ID, imp.WebsiteID); err != nil {
+		return fmt.Errorf("failed to delete imported orders: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		CREATE TEMPORARY TABLE rolled_back_visitors (visitor_id VARCHAR(255) PRIMARY KEY) ON COMMIT DROP`); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, `
+		WITH deleted AS (
+			DELETE FROM events
+			WHERE import_id = $1
+			RETURNING visitor_id, event_type
+		)
+		INSERT INTO rolled_back_visitors
+		SELECT DISTINCT visitor_id FROM deleted WHERE event_type = 'pageview'`, imp.ID); err != nil {
+		return fmt.Errorf("failed to delete imported events: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `DELETE FROM custom_events WHERE import_id = $1`, imp.ID); err != nil {
+		return fmt.Errorf("failed to delete imported custom events: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE visitors v
+		SET first_seen = s.first_seen, last_seen = s.last_seen
+		FROM (
+			SELECT e.visitor_id, MIN(e.timestamp) AS first_seen, MAX(e.timestamp) AS last_seen
+			FROM events e
+			JOIN rolled_back_visitors r ON r.visitor_id = e.visitor_id
+			WHERE e.website_id = $1 AND e.event_type = 'pageview'
+			GROUP BY e.visitor_id
+		) s
+		WHERE v.website_id = $1 AND v.visitor_id = s.visitor_id`, imp.WebsiteID); err != nil {
+		return fmt.Errorf("failed to update visitors: %w", err)
+	}
+	if _, err := tx.Exec(ctx, `
+		DELETE FROM visitors v
+		USING rolled_back_visitors r
+		WHERE v.website_id = $1 AND v.visitor_id = r.visitor_id
+		AND NOT EXISTS (
+			SELECT 1 FROM events e
+			WHERE e.website_id = $1 AND e.visitor_id = r.visitor_id AND e.event_type = 'pageview'
+		)`, imp.WebsiteID); err != nil {
+		return fmt.Errorf("failed to delete visitors: %w", err)
+	}
+
+	if _, err := tx.Exec(ctx, `DELETE FROM imported_daily_stats WHERE import_id = $1`, imp.ID); err != nil {
+		return fmt.Errorf("failed to delete imported aggregates: %w", err)
+	}
+
+	rolledBackAt := time.Now().UTC()
+	if _, err := tx.Exec(ctx, `
+		UPDATE imports SET status = $2, rolled_back_at = $3 WHERE id = $1`,
+		imp.ID, models.ImportRolledBack, rolledBackAt); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return err
+	}
+	imp.Status = models.ImportRolledBack
+	imp.RolledBackAt = &rolledBackAt
+	return nil
+}
+
+func scanImport(row pgx.Row) (*models.Import, error) {
+	var imp models.Import
+	err := row.Scan(
+		&imp.ID, &imp.WebsiteID, &imp.Source, &imp.Filename, &imp.Status, &imp.RowsRead, &imp.RowsSkipped,
+		&imp.EventsImported, &imp.AggregatesImported, &imp.Error, &imp.CreatedAt, &imp.CompletedAt, &imp.RolledBackAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &imp, nil
+}
@@ -0,0 +1,297 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	liveKeyPrefix = "analytics:live:"
+	// liveKeyTTL drops the live state of websites without traffic
+	liveKeyTTL = 2 * time.Hour
+	// Pages viewed in the last hour are counted in buckets of this size
+	livePageBucket = 5 * time.Minute
+
+	// LiveTopLimit is the number of pages, referrers and countries reported
+	LiveTopLimit = 10
+	// LiveSeriesMinutes is the length of the pageviews per minute series
+	LiveSeriesMinutes = 30
+	// maxLiveVisitors caps the active visitors broken down by page, referrer
+	// and country; ActiveUsers is always exact
+	maxLiveVisitors = 10000
+
+	// livePruneInterval is how often recording events also forgets a
+	// website's inactive visitors and old minute counters, so websites
+	// nobody watches don't grow their live state
+	livePruneInterval = 10 * time.Second
+	// livePruneBatch bounds the inactive visitors forgotten per round trip
+	livePruneBatch = 1000
+)
+
+// Per-visitor attributes of the active visitors
+var liveVisitorHashes = []string{"page", "country", "referrer", "session"}
+
+// LiveStore keeps what is happening on each website right now in Redis,
+// updated as events are ingested, so live metrics never scan the database
+type LiveStore struct {
+	client *redis.Client
+}
+
+func NewLiveStore(client *redis.Client) *LiveStore {
+	return &LiveStore{client: client}
+}
+
+func liveKey(websiteID, name string) string {
+	return liveKeyPrefix + websiteID + ":" + name
+}
+
+func livePagesKey(websiteID string, bucket int64) string {
+	return liveKey(websiteID, "pages:"+strconv.FormatInt(bucket, 10))
+}
+
+// Record adds accepted events to the live state of their websites. Bot
+// traffic and events older than models.LiveWindow are ignored; session_start
+// events set the referrer the visitor came from.
+func (s *LiveStore) Record(ctx context.Context, events []models.Event) error {
+	now := time.Now()
+	pipe := s.client.Pipeline()
+	touched := make(map[string]bool)
+
+	for i := range events {
+		event := &events[i]
+		if event.IsBot || event.WebsiteID == "" || event.VisitorID == "" {
+			continue
+		}
+		at := event.Timestamp
+		if at.After(now) {
+			at = now
+		}
+		if now.Sub(at) > models.LiveWindow {
+			continue
+		}
+		websiteID := event.WebsiteID
+
+		switch event.EventType {
+		case models.SessionStartEventType:
+			pipe.HSet(ctx, liveKey(websiteID, "referrer"), event.VisitorID, utils.LiveReferrer(event.Referrer))
+		case "pageview":
+			country := "unknown"
+			if event.Country != nil && *event.Country != "" {
+				country = *event.Country
+			}
+			pipe.ZAdd(ctx, liveKey(websiteID, "visitors"), &redis.Z{Score: float64(at.Unix()), Member: event.VisitorID})
+			pipe.HSet(ctx, liveKey(websiteID, "page"), event.VisitorID, event.Page)
+			pipe.HSet(ctx, liveKey(websiteID, "country"), event.VisitorID, country)
+			pipe.HSet(ctx, liveKey(websiteID, "session"), event.VisitorID, event.SessionID)
+			pipe.HSetNX(ctx, liveKey(websiteID, "referrer"), event.VisitorID, utils.LiveReferrer(event.Referrer))
+
+			minute := strconv.FormatInt(at.Unix()/60, 10)
+			pipe.HIncrBy(ctx, liveKey(websiteID, "minutes"), minute, 1)
+			if event.TimeOnPage != nil && *event.TimeOnPage > 0 {
+				pipe.HIncrBy(ctx, liveKey(websiteID, "minutes"), minute+":time", int64(*event.TimeOnPage))
+				pipe.HIncrBy(ctx, liveKey(websiteID, "minutes"), minute+":timed", 1)
+			}
+
+			pagesKey := livePagesKey(websiteID, at.Unix()/int64(livePageBucket.Seconds()))
+			pipe.ZIncrBy(ctx, pagesKey, 1, event.Page)
+			pipe.Expire(ctx, pagesKey, time.Hour+livePageBucket)
+		default:
+			continue
+		}
+		touched[websiteID] = true
+	}
+
+	if len(touched) == 0 {
+		return nil
+	}
+	for websiteID := range touched {
+		pipe.Expire(ctx, liveKey(websiteID, "visitors"), liveKeyTTL)
+		pipe.Expire(ctx, liveKey(websiteID, "minutes"), liveKeyTTL)
+		for _, name := range liveVisitorHashes {
+			pipe.Expire(ctx, liveKey(websiteID, name), liveKeyTTL)
+		}
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+
+	// Pruned by one instance at a time, at most every livePruneInterval
+	for websiteID := range touched {
+		due, err := s.client.SetNX(ctx, liveKey(websiteID, "pruned"), 1, livePruneInterval).Result()
+		if err != nil {
+			return err
+		}
+		if due {
+			if err := s.prune(ctx, websiteID, now); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// prune forgets the visitors of a website who are no longer active at now,
+// and the minute counters older than an hour
+func (s *LiveStore) prune(ctx context.Context, websiteID string, now time.Time) error {
+	visitorsKey := liveKey(websiteID, "visitors")
+	cutoff := "(" + strconv.FormatInt(now.Add(-models.LiveWindow).Unix(), 10)
+	for {
+		stale, err := s.client.ZRangeByScore(ctx, visitorsKey, &redis.ZRangeBy{Min: "-inf", Max: cutoff, Count: livePruneBatch}).Result()
+		if err != nil {
+			return err
+		}
+		if len(stale) == 0 {
+			break
+		}
+
+		members := make([]interface{}, len(stale))
+		for i, visitorID := range stale {
+			members[i] = visitorID
+		}
+		pipe := s.client.Pipeline()
+		pipe.ZRem(ctx, visitorsKey, members...)
+		for _, name := range liveVisitorHashes {
+			pipe.HDel(ctx, liveKey(websiteID, name), stale...)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			return err
+		}
+		if len(stale) < livePruneBatch {
+			break
+		}
+	}
+
+	minutesKey := liveKey(websiteID, "minutes")
+	fields, err := s.client.HKeys(ctx, minutesKey).Result()
+	if err != nil {
+		return err
+	}
+	oldest := now.Unix()/60 - 60
+	var expired []string
+	for _, field := range fields {
+		minuteField, _, _ := strings.Cut(field, ":")
+		if minute, err := strconv.ParseInt(minuteField, 10, 64); err != nil || minute < oldest {
+			expired = append(expired, field)
+		}
+	}
+	if len(expired) > 0 {
+		return s.client.HDel(ctx, minutesKey, expired...).Err()
+	}
+	return nil
+}
+
+// Snapshot returns the live metrics of a website at now, forgetting visitors
+// who are no longer active
+func (s *LiveStore) Snapshot(ctx context.Context, websiteID string, now time.Time) (*models.LiveMetrics, error) {
+	if err := s.prune(ctx, websiteID, now); err != nil {
+		return nil, err
+	}
+	visitorsKey := liveKey(websiteID, "visitors")
+	cutoff := strconv.FormatInt(now.Add(-models.LiveWindow).Unix(), 10)
+
+	activeUsers, err := s.client.ZCount(ctx, visitorsKey, cutoff, "+inf").Result()
+	if err != nil {
+		return nil, err
+	}
+	active, err := s.client.ZRangeByScore(ctx, visitorsKey, &redis.ZRangeBy{Min: cutoff, Max: "+inf", Count: maxLiveVisitors}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := s.client.Pipeline()
+	attributes := make(map[string]*redis.SliceCmd, len(liveVisitorHashes))
+	if len(active) > 0 {
+		for _, name := range liveVisitorHashes {
+			attributes[name] = pipe.HMGet(ctx, liveKey(websiteID, name), active...)
+		}
+	}
+	minutesCmd := pipe.HGetAll(ctx, liveKey(websiteID, "minutes"))
+	currentBucket := now.Unix() / int64(livePageBucket.Seconds())
+	bucketsPerHour := int64(time.Hour / livePageBucket)
+	pageCmds := make([]*redis.ZSliceCmd, 0, bucketsPerHour)
+	for bucket := currentBucket - bucketsPerHour + 1; bucket <= currentBucket; bucket++ {
+		pageCmds = append(pageCmds, pipe.ZRangeWithScores(ctx, livePagesKey(websiteID, bucket), 0, -1))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	values := func(name string) []string {
+		cmd, ok := attributes[name]
+		if !ok {
+			return nil
+		}
+		result := make([]string, 0, len(active))
+		for _, value := range cmd.Val() {
+			if str, ok := value.(string); ok {
+				result = append(result, str)
+			}
+		}
+		return result
+	}
+
+	metrics := &models.LiveMetrics{
+		RealtimeMetric: models.RealtimeMetric{
+			WebsiteID:   websiteID,
+			Timestamp:   now.UTC(),
+			ActiveUsers: int(activeUsers),
+		},
+		CurrentPages: utils.CountLive(values("page"), LiveTopLimit),
+		Referrers:    utils.CountLive(values("referrer"), LiveTopLimit),
+		Countries:    utils.CountLive(values("country"), LiveTopLimit),
+	}
+	metrics.CurrentSessions = len(utils.CountLive(values("session"), 0))
+
+	counts := make(map[int64]int)
+	var timeSum, timed int64
+	windowStart := now.Add(-models.LiveWindow).Unix() / 60
+	for field, value := range minutesCmd.Val() {
+		minuteField, suffix, _ := strings.Cut(field, ":")
+		minute, err := strconv.ParseInt(minuteField, 10, 64)
+		if err != nil {
+			continue
+		}
+		n, _ := strconv.ParseInt(value, 10, 64)
+		switch suffix {
+		case "":
+			counts[minute] = int(n)
+		case "time":
+			if minute >= windowStart {
+				timeSum += n
+			}
+		case "timed":
+			if minute >= windowStart {
+				timed += n
+			}
+		}
+	}
+	metrics.PageviewSeries = utils.PageviewSeries(counts, now, LiveSeriesMinutes)
+	metrics.PageViewsPerMinute = metrics.PageviewSeries[len(metrics.PageviewSeries)-1].Pageviews
+	if timed > 0 {
+		metrics.AvgTimeOnPageNow = math.Round(float64(timeSum)/float64(timed)*100) / 100
+	}
+
+	pageViews := make(map[string]float64)
+	for _, cmd := range pageCmds {
+		for _, z := range cmd.Val() {
+			if page, ok := z.Member.(string); ok {
+				pageViews[page] += z.Score
+			}
+		}
+	}
+	var topViews float64
+	for page, views := range pageViews {
+		if views > topViews || (views == topViews && page < metrics.TopPageLastHour) {
+			metrics.TopPageLastHour, topViews = page, views
+		}
+	}
+
+	return metrics, nil
+}
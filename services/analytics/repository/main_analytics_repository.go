@@ -3,7 +3,9 @@ package repository
 import (
 	"analytics-app/models"
 	"context"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
@@ -19,99 +21,205 @@ type MainAnalyticsRepository struct {
 	topOS          *TopOSAnalytics
 	trafficSummary *TrafficSummaryAnalytics
 	timeSeries     *TimeSeriesAnalytics
+	activityTrends *ActivityTrendsAnalytics
+	cohorts        *CohortAnalytics
 	customEvents   *CustomEventsAnalytics
+	bots           *BotAnalytics
+	goals          *GoalRepository
+	goalConversion *GoalConversionsAnalytics
+	customMetrics  *CustomMetricRepository
+	metricValues   *CustomMetricsAnalytics
+	revenue        *RevenueRepository
+	revenueByDim   *RevenueAnalytics
+	attribution    *AttributionAnalytics
+	sessions       *SessionsAnalytics
+	export         *ExportAnalytics
 }
 
 // NewMainAnalyticsRepository creates a new main analytics repository
 func NewMainAnalyticsRepository(db *pgxpool.Pool) *MainAnalyticsRepository {
+	rollups := NewRollups(db)
 	return &MainAnalyticsRepository{
-		dashboard:      NewDashboardAnalytics(db),
+		dashboard:      NewDashboardAnalytics(db, rollups),
 		topPages:       NewTopPagesAnalytics(db),
 		topReferrers:   NewTopReferrersAnalytics(db),
 		topSources:     NewTopSourcesAnalytics(db),
-		topCountries:   NewTopCountriesAnalytics(db),
-		topBrowsers:    NewTopBrowsersAnalytics(db),
-		topDevices:     NewTopDevicesAnalytics(db),
-		topOS:          NewTopOSAnalytics(db),
+		topCountries:   NewTopCountriesAnalytics(db, rollups),
+		topBrowsers:    NewTopBrowsersAnalytics(db, rollups),
+		topDevices:     NewTopDevicesAnalytics(db, rollups),
+		topOS:          NewTopOSAnalytics(db, rollups),
 		trafficSummary: NewTrafficSummaryAnalytics(db),
-		timeSeries:     NewTimeSeriesAnalytics(db),
+		timeSeries:     NewTimeSeriesAnalytics(db, rollups),
+		activityTrends: NewActivityTrendsAnalytics(db),
+		cohorts:        NewCohortAnalytics(db),
 		customEvents:   NewCustomEventsAnalytics(db),
+		bots:           NewBotAnalytics(db),
+		goals:          NewGoalRepository(db),
+		goalConversion: NewGoalConversionsAnalytics(db),
+		customMetrics:  NewCustomMetricRepository(db),
+		metricValues:   NewCustomMetricsAnalytics(db),
+		revenue:        NewRevenueRepository(db),
+		revenueByDim:   NewRevenueAnalytics(db),
+		attribution:    NewAttributionAnalytics(db),
+		sessions:       NewSessionsAnalytics(db),
+		export:         NewExportAnalytics(db),
 	}
 }
 
 // Dashboard Analytics Methods
-func (r *MainAnalyticsRepository) GetDashboardMetrics(ctx context.Context, websiteID string, days int) (*models.DashboardMetrics, error) {
-	return r.dashboard.GetDashboardMetrics(ctx, websiteID, days)
+func (r *MainAnalyticsRepository) GetDashboardMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (*models.DashboardMetrics, error) {
+	return r.dashboard.GetDashboardMetrics(ctx, websiteID, dateRange, filters)
 }
 
-func (r *MainAnalyticsRepository) GetComparisonMetrics(ctx context.Context, websiteID string, days int) (*models.ComparisonMetrics, error) {
-	return r.dashboard.GetComparisonMetrics(ctx, websiteID, days)
+func (r *MainAnalyticsRepository) GetComparisonMetrics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (*models.ComparisonMetrics, error) {
+	return r.dashboard.GetComparisonMetrics(ctx, websiteID, dateRange, filters)
 }
 
-func (r *MainAnalyticsRepository) GetUTMAnalytics(ctx context.Context, websiteID string, days int) (map[string]interface{}, error) {
-	return r.dashboard.GetUTMAnalytics(ctx, websiteID, days)
+func (r *MainAnalyticsRepository) GetUTMAnalytics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (map[string]interface{}, error) {
+	return r.dashboard.GetUTMAnalytics(ctx, websiteID, dateRange, filters)
 }
 
 // Top Pages Analytics Methods
-func (r *MainAnalyticsRepository) GetTopPages(ctx context.Context, websiteID string, days int, limit int) ([]models.PageStat, error) {
-	return r.topPages.GetTopPages(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.PageStat, error) {
+	return r.topPages.GetTopPages(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (r *MainAnalyticsRepository) GetTopPagesWithTimeBucket(ctx context.Context, websiteID string, days int, limit int) ([]models.PageStat, error) {
-	return r.topPages.GetTopPagesWithTimeBucket(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopPagesWithTimeBucket(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.PageStat, error) {
+	return r.topPages.GetTopPagesWithTimeBucket(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (r *MainAnalyticsRepository) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, days int) (map[string]interface{}, error) {
-	return r.topPages.GetPageUTMBreakdown(ctx, websiteID, pagePath, days)
+func (r *MainAnalyticsRepository) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, dateRange models.DateRange, filters models.AnalyticsFilters) (map[string]interface{}, error) {
+	return r.topPages.GetPageUTMBreakdown(ctx, websiteID, pagePath, dateRange, filters)
 }
 
 // Top Referrers Analytics Methods
-func (r *MainAnalyticsRepository) GetTopReferrers(ctx context.Context, websiteID string, days int, limit int) ([]models.ReferrerStat, error) {
-	return r.topReferrers.GetTopReferrers(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopReferrers(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.ReferrerStat, error) {
+	return r.topReferrers.GetTopReferrers(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Top Sources Analytics Methods
-func (r *MainAnalyticsRepository) GetTopSources(ctx context.Context, websiteID string, days int, limit int) ([]models.SourceStat, error) {
-	return r.topSources.GetTopSources(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopSources(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.SourceStat, error) {
+	return r.topSources.GetTopSources(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Top Countries Analytics Methods
-func (r *MainAnalyticsRepository) GetTopCountries(ctx context.Context, websiteID string, days int, limit int) ([]models.CountryStat, error) {
-	return r.topCountries.GetTopCountries(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopCountries(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.CountryStat, error) {
+	return r.topCountries.GetTopCountries(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Top Browsers Analytics Methods
-func (r *MainAnalyticsRepository) GetTopBrowsers(ctx context.Context, websiteID string, days int, limit int) ([]models.BrowserStat, error) {
-	return r.topBrowsers.GetTopBrowsers(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopBrowsers(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.BrowserStat, error) {
+	return r.topBrowsers.GetTopBrowsers(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Top Devices Analytics Methods
-func (r *MainAnalyticsRepository) GetTopDevices(ctx context.Context, websiteID string, days int, limit int) ([]models.DeviceStat, error) {
-	return r.topDevices.GetTopDevices(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopDevices(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.DeviceStat, error) {
+	return r.topDevices.GetTopDevices(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Top OS Analytics Methods
-func (r *MainAnalyticsRepository) GetTopOS(ctx context.Context, websiteID string, days int, limit int) ([]models.OSStat, error) {
-	return r.topOS.GetTopOS(ctx, websiteID, days, limit)
+func (r *MainAnalyticsRepository) GetTopOS(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.OSStat, error) {
+	return r.topOS.GetTopOS(ctx, websiteID, dateRange, filters, limit)
 }
 
 // Traffic Summary Analytics Methods
-func (r *MainAnalyticsRepository) GetTrafficSummary(ctx context.Context, websiteID string, days int) (*models.TrafficSummary, error) {
-	return r.trafficSummary.GetTrafficSummary(ctx, websiteID, days)
+func (r *MainAnalyticsRepository) GetTrafficSummary(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, retentionDays int) (*models.TrafficSummary, error) {
+	return r.trafficSummary.GetTrafficSummary(ctx, websiteID, dateRange, filters, retentionDays)
 }
 
 // Time Series Analytics Methods
-func (r *MainAnalyticsRepository) GetDailyStats(ctx context.Context, websiteID string, days int) ([]models.DailyStat, error) {
-	return r.timeSeries.GetDailyStats(ctx, websiteID, days)
+func (r *MainAnalyticsRepository) GetDailyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) ([]models.DailyStat, error) {
+	return r.timeSeries.GetDailyStats(ctx, websiteID, dateRange, filters)
 }
 
-func (r *MainAnalyticsRepository) GetHourlyStats(ctx context.Context, websiteID string, days int, timezone string) ([]models.HourlyStat, error) {
-	return r.timeSeries.GetHourlyStats(ctx, websiteID, days, timezone)
+func (r *MainAnalyticsRepository) GetHourlyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) ([]models.HourlyStat, error) {
+	return r.timeSeries.GetHourlyStats(ctx, websiteID, dateRange, filters)
+}
+
+// Activity Trends Analytics Methods
+func (r *MainAnalyticsRepository) GetActivityCounts(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, granularity string) ([]models.ActivityCount, error) {
+	return r.activityTrends.GetActivityCounts(ctx, websiteID, dateRange, filters, granularity)
+}
+
+// Cohort Analytics Methods
+func (r *MainAnalyticsRepository) GetCohortCells(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, granularity, cohortEvent string, periods int) ([]models.CohortCell, error) {
+	return r.cohorts.GetCohortCells(ctx, websiteID, dateRange, filters, granularity, cohortEvent, periods)
 }
 
 // Custom Events Analytics Methods
-func (r *MainAnalyticsRepository) GetCustomEventStats(ctx context.Context, websiteID string, days int) ([]models.CustomEventStat, error) {
-	return r.customEvents.GetCustomEventStats(ctx, websiteID, days)
+func (r *MainAnalyticsRepository) GetCustomEventStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) ([]models.CustomEventStat, error) {
+	return r.customEvents.GetCustomEventStats(ctx, websiteID, dateRange, filters)
+}
+
+// Bot Analytics Methods
+func (r *MainAnalyticsRepository) GetBotTraffic(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) (*models.BotTrafficReport, error) {
+	return r.bots.GetBotTraffic(ctx, websiteID, dateRange, filters, limit)
+}
+
+// Goal Analytics Methods
+func (r *MainAnalyticsRepository) GetGoal(ctx context.Context, goalID uuid.UUID) (*models.Goal, error) {
+	return r.goals.GetByID(ctx, goalID)
+}
+
+func (r *MainAnalyticsRepository) GetGoalConversions(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, goal *models.Goal, dimension string) (map[string]models.GoalCounts, error) {
+	return r.goalConversion.GetGoalConversions(ctx, websiteID, dateRange, filters, goal, dimension)
+}
+
+// Custom Metric Methods
+func (r *MainAnalyticsRepository) GetCustomMetric(ctx context.Context, metricID uuid.UUID) (*models.CustomMetric, error) {
+	return r.customMetrics.GetByID(ctx, metricID)
+}
+
+func (r *MainAnalyticsRepository) GetCustomMetricValues(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, metrics []models.CustomMetric, groupBy string) (map[string]map[string]*float64, error) {
+	return r.metricValues.GetCustomMetricValues(ctx, websiteID, dateRange, filters, metrics, groupBy)
+}
+
+// Revenue Methods
+func (r *MainAnalyticsRepository) GetRevenueSettings(ctx context.Context, websiteID string) (*models.RevenueSettings, error) {
+	return r.revenue.GetSettings(ctx, websiteID)
+}
+
+func (r *MainAnalyticsRepository) GetExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	return r.revenue.GetExchangeRates(ctx)
+}
+
+func (r *MainAnalyticsRepository) GetRevenueByDimension(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, dimension string) (map[string]map[string]models.CurrencyTotals, error) {
+	return r.revenueByDim.GetRevenueByDimension(ctx, websiteID, dateRange, filters, dimension)
+}
+
+// Attribution Methods
+func (r *MainAnalyticsRepository) StreamConversionJourneys(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, goal *models.Goal, dimension string, lookback time.Duration, fn func(convertedAt time.Time, touches []models.Touchpoint) error) error {
+	return r.attribution.StreamConversionJourneys(ctx, websiteID, dateRange, filters, goal, dimension, lookback, fn)
+}
+
+// Session Methods
+func (r *MainAnalyticsRepository) GetSessions(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit, offset int) ([]models.Session, error) {
+	return r.sessions.GetSessions(ctx, websiteID, dateRange, filters, limit, offset)
+}
+
+func (r *MainAnalyticsRepository) GetSessionSummary(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (*models.SessionAnalytics, error) {
+	return r.sessions.GetSessionSummary(ctx, websiteID, dateRange, filters)
+}
+
+func (r *MainAnalyticsRepository) GetVisitorSessions(ctx context.Context, websiteID, visitorID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.Session, error) {
+	return r.sessions.GetVisitorSessions(ctx, websiteID, visitorID, dateRange, filters, limit)
+}
+
+func (r *MainAnalyticsRepository) GetVisitorSeen(ctx context.Context, websiteID, visitorID string) (*time.Time, *time.Time, error) {
+	return r.sessions.GetVisitorSeen(ctx, websiteID, visitorID)
+}
+
+func (r *MainAnalyticsRepository) GetVisitorActivity(ctx context.Context, websiteID, visitorID string, dateRange models.DateRange, limit int) ([]models.TimelineItem, error) {
+	return r.sessions.GetVisitorActivity(ctx, websiteID, visitorID, dateRange, limit)
+}
+
+func (r *MainAnalyticsRepository) GetVisitorFunnelProgress(ctx context.Context, websiteID, visitorID string, dateRange models.DateRange, limit int) ([]models.TimelineItem, error) {
+	return r.sessions.GetVisitorFunnelProgress(ctx, websiteID, visitorID, dateRange, limit)
+}
+
+// Export Methods
+func (r *MainAnalyticsRepository) StreamEvents(ctx context.Context, websiteID, dataset string, dateRange models.DateRange, filters models.AnalyticsFilters, fn func(values []interface{}) error) error {
+	return r.export.StreamEvents(ctx, websiteID, dataset, dateRange, filters, fn)
 }
 
 // GetLiveVisitors returns the number of currently active visitors
@@ -121,7 +229,8 @@ func (r *MainAnalyticsRepository) GetLiveVisitors(ctx context.Context, websiteID
 		FROM events
 		WHERE website_id = $1 
 		AND timestamp >= NOW() - INTERVAL '5 minutes'
-		AND event_type = 'pageview'`
+		AND event_type = 'pageview'
+		AND NOT is_bot`
 
 	var liveVisitors int
 	err := r.dashboard.db.QueryRow(ctx, query, websiteID).Scan(&liveVisitors)
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// ErrObjectNotFound is returned when the object store has no object at a key
+var ErrObjectNotFound = errors.New("object not found")
+
+// s3PartSize bounds the memory used to upload objects of unknown size
+const s3PartSize = 16 << 20
+
+// ObjectStore keeps files such as background exports
+type ObjectStore interface {
+	// Put stores what r reads until EOF at key; nothing is stored when
+	// reading fails
+	Put(ctx context.Context, key string, r io.Reader, contentType string) error
+	// Get opens the object at key
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+// LocalObjectStore keeps objects as files under a directory
+type LocalObjectStore struct {
+	dir string
+}
+
+func NewLocalObjectStore(dir string) (*LocalObjectStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalObjectStore{dir: dir}, nil
+}
+
+func (s *LocalObjectStore) path(key string) (string, error) {
+	path := filepath.Join(s.dir, filepath.FromSlash(key))
+	if !strings.HasPrefix(path, filepath.Clean(s.dir)+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid object key %q", key)
+	}
+	return path, nil
+}
+
+// Put writes to a temporary file renamed into place once complete, so
+// readers never see partial objects
+func (s *LocalObjectStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := io.Copy(file, r); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
+
+func (s *LocalObjectStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrObjectNotFound
+	}
+	return file, err
+}
+
+// S3ObjectStore keeps objects in a bucket of an S3-compatible service
+type S3ObjectStore struct {
+	client *minio.Client
+	bucket string
+}
+
+// S3Options configures the connection to an S3-compatible service
+type S3Options struct {
+	Endpoint  string
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+func NewS3ObjectStore(options S3Options) (*S3ObjectStore, error) {
+	if options.Endpoint == "" || options.Bucket == "" {
+		return nil, errors.New("S3 endpoint and bucket are required")
+	}
+	client, err := minio.New(options.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(options.AccessKey, options.SecretKey, ""),
+		Secure: options.UseSSL,
+		Region: options.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &S3ObjectStore{client: client, bucket: options.Bucket}, nil
+}
+
+// Put uploads in parts as r is read, without knowing the object size
+func (s *S3ObjectStore) Put(ctx context.Context, key string, r io.Reader, contentType string) error {
+	_, err := s.client.PutObject(ctx, s.bucket, key, r, -1, minio.PutObjectOptions{
+		ContentType: contentType,
+		PartSize:    s3PartSize,
+	})
+	return err
+}
+
+func (s *S3ObjectStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; Stat reports missing objects
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		if minio.ToErrorResponse(err).Code == "NoSuchKey" {
+			return nil, ErrObjectNotFound
+		}
+		return nil, err
+	}
+	return object, nil
+}
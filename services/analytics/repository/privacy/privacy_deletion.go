@@ -28,6 +28,11 @@ func (r *PrivacyRepository) DeleteEventsData(userID string) error {
 	}
 
 	rowsAffected := result.RowsAffected()
+
+	// First-seen history is derived from the deleted pageviews
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM visitors WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete visitors: %w", err)
+	}
 	fmt.Printf("Privacy operation: delete_events for user %s - Deleted %d events for %d websites\n", userID, rowsAffected, len(websiteIDs))
 
 	return nil
@@ -43,12 +48,18 @@ func (r *PrivacyRepository) DeleteEventsDataForWebsite(websiteID string) error {
 	}
 
 	rowsAffected := result.RowsAffected()
+
+	// First-seen history is derived from the deleted pageviews
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM visitors WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete visitors for website %s: %w", websiteID, err)
+	}
 	fmt.Printf("Privacy operation: delete_events for website %s - Deleted %d events\n", websiteID, rowsAffected)
 
 	return nil
 }
 
-// DeleteAnalyticsData deletes all analytics data for a specific user
+// DeleteAnalyticsData deletes all analytics data for a specific user:
+// custom events, orders, imports, report schedules, alerts and custom metrics
 func (r *PrivacyRepository) DeleteAnalyticsData(userID string) error {
 	// Get website IDs for this user
 	websiteIDs, err := r.GetUserWebsitesFromUserService(userID)
@@ -62,29 +73,110 @@ func (r *PrivacyRepository) DeleteAnalyticsData(userID string) error {
 		return nil
 	}
 
-	// Delete custom events aggregated data
-	deleteCustomEventsQuery := `DELETE FROM custom_events_aggregated WHERE website_id = ANY($1)`
+	// Delete raw custom events; the hourly aggregate drops them on its next refresh
+	deleteCustomEventsQuery := `DELETE FROM custom_events WHERE website_id = ANY($1)`
 	result, err := r.db.Exec(context.Background(), deleteCustomEventsQuery, websiteIDs)
 	if err != nil {
 		return fmt.Errorf("failed to delete custom events: %w", err)
 	}
 
 	customEventsDeleted := result.RowsAffected()
+
+	// Aggregates written before custom events were stored row by row
+	deleteLegacyQuery := `DELETE FROM custom_events_aggregated_legacy WHERE website_id = ANY($1)`
+	if _, err := r.db.Exec(context.Background(), deleteLegacyQuery, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete legacy custom event aggregates: %w", err)
+	}
+
+	// Orders recorded from purchase events, and the revenue settings
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM orders WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete orders: %w", err)
+	}
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM revenue_settings WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete revenue settings: %w", err)
+	}
+
+	// Historical data imported from other tools, then the imports themselves
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM imported_daily_stats WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete imported stats: %w", err)
+	}
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM imports WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete imports: %w", err)
+	}
+
+	// Report schedules, which hold recipients and webhook secrets
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM report_schedules WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete report schedules: %w", err)
+	}
+
+	// Alerts and their rules, which hold recipients and webhook URLs
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM alerts WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete alerts: %w", err)
+	}
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM alert_rules WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete alert rules: %w", err)
+	}
+
+	// Custom metrics defined for the websites
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM custom_metrics WHERE website_id = ANY($1)`, websiteIDs); err != nil {
+		return fmt.Errorf("failed to delete custom metrics: %w", err)
+	}
 	fmt.Printf("Privacy operation: delete_analytics for user %s - Deleted %d custom events for %d websites\n", userID, customEventsDeleted, len(websiteIDs))
 
 	return nil
 }
 
-// DeleteAnalyticsDataForWebsite deletes all analytics data for a specific website
+// DeleteAnalyticsDataForWebsite deletes all analytics data for a specific
+// website: custom events, orders, imports, report schedules, alerts and custom metrics
 func (r *PrivacyRepository) DeleteAnalyticsDataForWebsite(websiteID string) error {
-	// Delete custom events aggregated data
-	deleteCustomEventsQuery := `DELETE FROM custom_events_aggregated WHERE website_id = $1`
+	// Delete raw custom events; the hourly aggregate drops them on its next refresh
+	deleteCustomEventsQuery := `DELETE FROM custom_events WHERE website_id = $1`
 	result, err := r.db.Exec(context.Background(), deleteCustomEventsQuery, websiteID)
 	if err != nil {
 		return fmt.Errorf("failed to delete custom events for website %s: %w", websiteID, err)
 	}
 
 	customEventsDeleted := result.RowsAffected()
+
+	// Aggregates written before custom events were stored row by row
+	deleteLegacyQuery := `DELETE FROM custom_events_aggregated_legacy WHERE website_id = $1`
+	if _, err := r.db.Exec(context.Background(), deleteLegacyQuery, websiteID); err != nil {
+		return fmt.Errorf("failed to delete legacy custom event aggregates for website %s: %w", websiteID, err)
+	}
+
+	// Orders recorded from purchase events, and the revenue settings
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM orders WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete orders for website %s: %w", websiteID, err)
+	}
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM revenue_settings WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete revenue settings for website %s: %w", websiteID, err)
+	}
+
+	// Historical data imported from other tools, then the imports themselves
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM imported_daily_stats WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete imported stats for website %s: %w", websiteID, err)
+	}
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM imports WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete imports for website %s: %w", websiteID, err)
+	}
+
+	// Report schedules, which hold recipients and webhook secrets
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM report_schedules WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete report schedules for website %s: %w", websiteID, err)
+	}
+
+	// Alerts and their rules, which hold recipients and webhook URLs
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM alerts WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete alerts for website %s: %w", websiteID, err)
+	}
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM alert_rules WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete alert rules for website %s: %w", websiteID, err)
+	}
+
+	// Custom metrics defined for the website
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM custom_metrics WHERE website_id = $1`, websiteID); err != nil {
+		return fmt.Errorf("failed to delete custom metrics for website %s: %w", websiteID, err)
+	}
 	fmt.Printf("Privacy operation: delete_analytics for website %s - Deleted %d custom events\n", websiteID, customEventsDeleted)
 
 	return nil
@@ -118,6 +210,18 @@ func (r *PrivacyRepository) DeleteFunnelData(userID string) error {
 	}
 	funnelEventsDeleted := result.RowsAffected()
 
+	// Delete experiments, which may use the funnels as goals
+	_, err = r.db.Exec(context.Background(), `DELETE FROM experiments WHERE website_id = ANY($1)`, websiteIDs)
+	if err != nil {
+		return fmt.Errorf("failed to delete experiments: %w", err)
+	}
+
+	// Delete goals, which are stored alongside the funnels
+	_, err = r.db.Exec(context.Background(), `DELETE FROM goals WHERE website_id = ANY($1)`, websiteIDs)
+	if err != nil {
+		return fmt.Errorf("failed to delete goals: %w", err)
+	}
+
 	// Delete funnels
 	deleteFunnelsQuery := `DELETE FROM funnels WHERE website_id = ANY($1)`
 
@@ -148,6 +252,18 @@ func (r *PrivacyRepository) DeleteFunnelDataForWebsite(websiteID string) error {
 	}
 	funnelEventsDeleted := result.RowsAffected()
 
+	// Delete experiments, which may use the funnels as goals
+	_, err = r.db.Exec(context.Background(), `DELETE FROM experiments WHERE website_id = $1`, websiteID)
+	if err != nil {
+		return fmt.Errorf("failed to delete experiments for website %s: %w", websiteID, err)
+	}
+
+	// Delete goals, which are stored alongside the funnels
+	_, err = r.db.Exec(context.Background(), `DELETE FROM goals WHERE website_id = $1`, websiteID)
+	if err != nil {
+		return fmt.Errorf("failed to delete goals for website %s: %w", websiteID, err)
+	}
+
 	// Delete funnels
 	deleteFunnelsQuery := `DELETE FROM funnels WHERE website_id = $1`
 
@@ -35,6 +35,13 @@ func (r *PrivacyRepository) ExportEventsData(userID string) ([]map[string]interf
 		       time_on_page, properties, timestamp, created_at
 		FROM events 
 		WHERE website_id = ANY($1)
+		UNION ALL
+		SELECT id, website_id, visitor_id, session_id, event_type, page, referrer, 
+		       user_agent, ip_address, country, city, browser, device, os,
+		       utm_source, utm_medium, utm_campaign, utm_term, utm_content,
+		       time_on_page, properties, timestamp, created_at
+		FROM custom_events 
+		WHERE website_id = ANY($1)
 		ORDER BY timestamp DESC
 	`
 
@@ -118,7 +125,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 			COUNT(DISTINCT session_id) as sessions,
 			ROUND(AVG(CASE WHEN time_on_page IS NOT NULL AND time_on_page > 0 THEN time_on_page END)) as avg_time_on_page
 		FROM events 
-		WHERE website_id = ANY($1)
+		WHERE website_id = ANY($1) AND event_type = 'pageview'
 	`
 
 	var pageViews, uniqueVisitors, sessions int
@@ -141,7 +148,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	topPagesQuery := `
 		SELECT page, COUNT(*) as views, COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events 
-		WHERE website_id = ANY($1) AND page IS NOT NULL
+		WHERE website_id = ANY($1) AND event_type = 'pageview' AND page IS NOT NULL
 		GROUP BY page 
 		ORDER BY views DESC 
 		LIMIT 50
@@ -172,7 +179,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	topReferrersQuery := `
 		SELECT COALESCE(referrer, 'Direct') as referrer, COUNT(*) as views, COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events 
-		WHERE website_id = ANY($1)
+		WHERE website_id = ANY($1) AND event_type = 'pageview'
 		GROUP BY COALESCE(referrer, 'Direct')
 		ORDER BY views DESC 
 		LIMIT 20
@@ -203,7 +210,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	countriesQuery := `
 		SELECT COALESCE(country, 'Unknown') as country, COUNT(*) as views, COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events 
-		WHERE website_id = ANY($1)
+		WHERE website_id = ANY($1) AND event_type = 'pageview'
 		GROUP BY COALESCE(country, 'Unknown')
 		ORDER BY views DESC 
 		LIMIT 20
@@ -234,7 +241,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	browsersQuery := `
 		SELECT COALESCE(browser, 'Unknown') as browser, COUNT(*) as views, COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events 
-		WHERE website_id = ANY($1)
+		WHERE website_id = ANY($1) AND event_type = 'pageview'
 		GROUP BY COALESCE(browser, 'Unknown')
 		ORDER BY views DESC 
 		LIMIT 15
@@ -265,7 +272,7 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	devicesQuery := `
 		SELECT COALESCE(device, 'Unknown') as device, COUNT(*) as views, COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events 
-		WHERE website_id = ANY($1)
+		WHERE website_id = ANY($1) AND event_type = 'pageview'
 		GROUP BY COALESCE(device, 'Unknown')
 		ORDER BY views DESC 
 		LIMIT 10
@@ -295,8 +302,8 @@ func (r *PrivacyRepository) ExportAnalyticsData(userID string) ([]map[string]int
 	// Get custom events
 	customEventsQuery := `
 		SELECT event_type, COUNT(*) as count
-		FROM events 
-		WHERE website_id = ANY($1) AND event_type != 'pageview'
+		FROM custom_events 
+		WHERE website_id = ANY($1)
 		GROUP BY event_type
 		ORDER BY count DESC
 	`
@@ -20,6 +20,23 @@ func (r *PrivacyRepository) CleanupOldEvents() error {
 
 	rowsAffected := result.RowsAffected()
 
+	// Custom events are kept in their own hypertable
+	result, err = r.db.Exec(context.Background(), `DELETE FROM custom_events WHERE timestamp < $1`, cutoffDate)
+	if err != nil {
+		return fmt.Errorf("failed to cleanup old custom events: %w", err)
+	}
+	rowsAffected += result.RowsAffected()
+
+	// Orders are kept as long as the purchase events they come from
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM orders WHERE timestamp < $1`, cutoffDate); err != nil {
+		return fmt.Errorf("failed to cleanup old orders: %w", err)
+	}
+
+	// Visitors whose pageviews have all been deleted
+	if _, err := r.db.Exec(context.Background(), `DELETE FROM visitors WHERE last_seen < $1`, cutoffDate); err != nil {
+		return fmt.Errorf("failed to cleanup old visitors: %w", err)
+	}
+
 	// Log the cleanup operation
 	r.LogPrivacyOperation("cleanup_old_events", "system", fmt.Sprintf("Cleaned up %d events older than %s", rowsAffected, cutoffDate.Format(time.RFC3339)))
 
@@ -0,0 +1,154 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type ReportRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewReportRepository(db *pgxpool.Pool) *ReportRepository {
+	return &ReportRepository{db: db}
+}
+
+const reportScheduleColumns = `id, website_id, name, cron, timezone, range_days, channel, recipients, webhook_url,
+		webhook_secret, enabled, next_run_at, last_run_at, last_error, created_at, updated_at`
+
+func (r *ReportRepository) Create(ctx context.Context, schedule *models.ReportSchedule) error {
+	schedule.ID = uuid.New()
+	schedule.CreatedAt = time.Now()
+	schedule.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO report_schedules (` + reportScheduleColumns + `)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`
+
+	_, err := r.db.Exec(ctx, query,
+		schedule.ID, schedule.WebsiteID, schedule.Name, schedule.Cron, schedule.Timezone, schedule.RangeDays,
+		schedule.Channel, schedule.Recipients, schedule.WebhookURL, schedule.WebhookSecret, schedule.Enabled,
+		schedule.NextRunAt, schedule.LastRunAt, schedule.LastError, schedule.CreatedAt, schedule.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *ReportRepository) GetByWebsiteID(ctx context.Context, websiteID string) ([]models.ReportSchedule, error) {
+	query := `
+		SELECT ` + reportScheduleColumns + `
+		FROM report_schedules
+		WHERE website_id = $1
+		ORDER BY created_at DESC`
+
+	return r.query(ctx, query, websiteID)
+}
+
+func (r *ReportRepository) GetByID(ctx context.Context, scheduleID uuid.UUID) (*models.ReportSchedule, error) {
+	query := `
+		SELECT ` + reportScheduleColumns + `
+		FROM report_schedules
+		WHERE id = $1`
+
+	return scanReportSchedule(r.db.QueryRow(ctx, query, scheduleID))
+}
+
+func (r *ReportRepository) Update(ctx context.Context, schedule *models.ReportSchedule) error {
+	schedule.UpdatedAt = time.Now()
+
+	query := `
+		UPDATE report_schedules
+		SET name = $2, cron = $3, timezone = $4, range_days = $5, channel = $6, recipients = $7,
+			webhook_url = $8, webhook_secret = $9, enabled = $10, next_run_at = $11, updated_at = $12
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query,
+		schedule.ID, schedule.Name, schedule.Cron, schedule.Timezone, schedule.RangeDays, schedule.Channel,
+		schedule.Recipients, schedule.WebhookURL, schedule.WebhookSecret, schedule.Enabled, schedule.NextRunAt,
+		schedule.UpdatedAt,
+	)
+
+	return err
+}
+
+func (r *ReportRepository) Delete(ctx context.Context, scheduleID uuid.UUID) error {
+	query := `DELETE FROM report_schedules WHERE id = $1`
+	_, err := r.db.Exec(ctx, query, scheduleID)
+	return err
+}
+
+// GetDue returns the enabled schedules whose next run is at or before now,
+// earliest first
+func (r *ReportRepository) GetDue(ctx context.Context, now time.Time, limit int) ([]models.ReportSchedule, error) {
+	query := `
+		SELECT ` + reportScheduleColumns + `
+		FROM report_schedules
+		WHERE enabled AND next_run_at <= $1
+		ORDER BY next_run_at
+		LIMIT $2`
+
+	return r.query(ctx, query, now, limit)
+}
+
+// Claim moves a due schedule's next run from dueAt to nextRunAt. Only one
+// instance succeeds for each run; the others get false.
+func (r *ReportRepository) Claim(ctx context.Context, scheduleID uuid.UUID, dueAt, nextRunAt time.Time) (bool, error) {
+	query := `
+		UPDATE report_schedules
+		SET next_run_at = $3
+		WHERE id = $1 AND next_run_at = $2 AND enabled`
+
+	tag, err := r.db.Exec(ctx, query, scheduleID, dueAt, nextRunAt)
+	if err != nil {
+		return false, err
+	}
+	return tag.RowsAffected() == 1, nil
+}
+
+// RecordRun stores the outcome of a delivery; lastError is nil on success
+func (r *ReportRepository) RecordRun(ctx context.Context, scheduleID uuid.UUID, ranAt time.Time, lastError *string) error {
+	query := `
+		UPDATE report_schedules
+		SET last_run_at = $2, last_error = $3
+		WHERE id = $1`
+
+	_, err := r.db.Exec(ctx, query, scheduleID, ranAt, lastError)
+	return err
+}
+
+func (r *ReportRepository) query(ctx context.Context, query string, args ...interface{}) ([]models.ReportSchedule, error) {
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var schedules []models.ReportSchedule
+	for rows.Next() {
+		schedule, err := scanReportSchedule(rows)
+		if err != nil {
+			return nil, err
+		}
+		schedules = append(schedules, *schedule)
+	}
+
+	return schedules, rows.Err()
+}
+
+func scanReportSchedule(row pgx.Row) (*models.ReportSchedule, error) {
+	var schedule models.ReportSchedule
+	err := row.Scan(
+		&schedule.ID, &schedule.WebsiteID, &schedule.Name, &schedule.Cron, &schedule.Timezone, &schedule.RangeDays,
+		&schedule.Channel, &schedule.Recipients, &schedule.WebhookURL, &schedule.WebhookSecret, &schedule.Enabled,
+		&schedule.NextRunAt, &schedule.LastRunAt, &schedule.LastError, &schedule.CreatedAt, &schedule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RevenueAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewRevenueAnalytics(db *pgxpool.Pool) *RevenueAnalytics {
+	return &RevenueAnalytics{db: db}
+}
+
+// GetRevenueByDimension returns, for each value of a breakdown dimension, the
+// orders placed in the range and their amount per currency. Orders are
+// attributed to the value of their session's first pageview.
+func (ra *RevenueAnalytics) GetRevenueByDimension(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, dimension string) (map[string]map[string]models.CurrencyTotals, error) {
+	value, ok := breakdownDimensions[dimension]
+	if !ok {
+		return nil, fmt.Errorf("unsupported revenue dimension %q", dimension)
+	}
+
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To})
+
+	// Sessions may start up to a day before the range
+	query := `
+		WITH session_orders AS (
+			SELECT session_id, currency, COUNT(*) as orders, SUM(amount)::float8 as amount
+			FROM orders
+			WHERE website_id = $1
+			AND timestamp >= $2 AND timestamp < $3
+			AND session_id IS NOT NULL
+			GROUP BY session_id, currency
+		),
+		landing AS (
+			SELECT DISTINCT ON (e.session_id) e.session_id, ` + value + ` as value
+			FROM events e
+			WHERE e.website_id = $1
+			AND e.timestamp >= $2::timestamptz - INTERVAL '1 day' AND e.timestamp < $3
+			AND e.event_type = 'pageview'
+			AND e.session_id IN (SELECT session_id FROM session_orders)` + filterSQL + `
+			ORDER BY e.session_id, e.timestamp
+		)
+		SELECT l.value, o.currency, SUM(o.orders)::int, SUM(o.amount)
+		FROM session_orders o
+		JOIN landing l ON l.session_id = o.session_id
+		GROUP BY 1, 2`
+
+	rows, err := ra.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	totals := make(map[string]map[string]models.CurrencyTotals)
+	for rows.Next() {
+		var key, currency string
+		var row models.CurrencyTotals
+		if err := rows.Scan(&key, &currency, &row.Orders, &row.Amount); err != nil {
+			return nil, err
+		}
+		if totals[key] == nil {
+			totals[key] = make(map[string]models.CurrencyTotals)
+		}
+		totals[key][currency] = row
+	}
+
+	return totals, rows.Err()
+}
@@ -0,0 +1,134 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type RevenueRepository struct {
+	db *pgxpool.Pool
+}
+
+func NewRevenueRepository(db *pgxpool.Pool) *RevenueRepository {
+	return &RevenueRepository{db: db}
+}
+
+// GetSettings returns the website's revenue settings, defaulting to
+// models.DefaultBaseCurrency
+func (r *RevenueRepository) GetSettings(ctx context.Context, websiteID string) (*models.RevenueSettings, error) {
+	query := `
+		SELECT website_id, base_currency, updated_at
+		FROM revenue_settings
+		WHERE website_id = $1`
+
+	var settings models.RevenueSettings
+	err := r.db.QueryRow(ctx, query, websiteID).Scan(&settings.WebsiteID, &settings.BaseCurrency, &settings.UpdatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &models.RevenueSettings{WebsiteID: websiteID, BaseCurrency: models.DefaultBaseCurrency}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (r *RevenueRepository) UpdateSettings(ctx context.Context, settings *models.RevenueSettings) error {
+	settings.UpdatedAt = time.Now()
+
+	query := `
+		INSERT INTO revenue_settings (website_id, base_currency, updated_at)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (website_id) DO UPDATE SET
+			base_currency = EXCLUDED.base_currency,
+			updated_at = EXCLUDED.updated_at`
+
+	_, err := r.db.Exec(ctx, query, settings.WebsiteID, settings.BaseCurrency, settings.UpdatedAt)
+	return err
+}
+
+func (r *RevenueRepository) GetExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	query := `
+		SELECT currency, rate::float8, updated_at
+		FROM exchange_rates
+		ORDER BY currency`
+
+	rows, err := r.db.Query(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.ExchangeRate
+	for rows.Next() {
+		var rate models.ExchangeRate
+		if err := rows.Scan(&rate.Currency, &rate.Rate, &rate.UpdatedAt); err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+
+	return rates, rows.Err()
+}
+
+// UpsertExchangeRates sets the rates of the given currencies; other
+// currencies keep their rates
+func (r *RevenueRepository) UpsertExchangeRates(ctx context.Context, rates map[string]float64) error {
+	now := time.Now()
+	batch := &pgx.Batch{}
+	for currency, rate := range rates {
+		batch.Queue(`
+			INSERT INTO exchange_rates (currency, rate, updated_at)
+			VALUES ($1, $2, $3)
+			ON CONFLICT (currency) DO UPDATE SET
+				rate = EXCLUDED.rate,
+				updated_at = EXCLUDED.updated_at`,
+			currency, rate, now,
+		)
+	}
+	if batch.Len() == 0 {
+		return nil
+	}
+	return r.db.SendBatch(ctx, batch).Close()
+}
+
+// GetOrders returns the website's orders placed in [from, to), oldest first
+func (r *RevenueRepository) GetOrders(ctx context.Context, websiteID string, from, to time.Time) ([]models.Order, error) {
+	query := `
+		SELECT website_id, order_id, event_id, visitor_id, COALESCE(session_id, ''), amount::float8, currency, items, timestamp
+		FROM orders
+		WHERE website_id = $1 AND timestamp >= $2 AND timestamp < $3
+		ORDER BY timestamp`
+
+	rows, err := r.db.Query(ctx, query, websiteID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.Order
+	for rows.Next() {
+		var order models.Order
+		var itemsJSON []byte
+		err := rows.Scan(
+			&order.WebsiteID, &order.OrderID, &order.EventID, &order.VisitorID, &order.SessionID,
+			&order.Amount, &order.Currency, &itemsJSON, &order.Timestamp,
+		)
+		if err != nil {
+			return nil, err
+		}
+		if len(itemsJSON) > 0 {
+			if err := json.Unmarshal(itemsJSON, &order.Items); err != nil {
+				return nil, err
+			}
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
+}
@@ -0,0 +1,387 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// Continuous aggregates created by migration 000006
+const (
+	EventsHourlyView   = "events_hourly"
+	EventsDailyView    = "events_daily"
+	SessionsHourlyView = "sessions_hourly"
+)
+
+// watermarkTTL bounds how often aggregate watermarks are looked up. A stale
+// watermark only means more of the range is read from raw events.
+const watermarkTTL = time.Minute
+
+// TimeRange is a half-open interval [From, To)
+type TimeRange struct {
+	From time.Time
+	To   time.Time
+}
+
+// RollupPlan splits a query range into parts served by the daily and hourly
+// aggregates and parts that must be read from raw events
+type RollupPlan struct {
+	Daily  []TimeRange
+	Hourly []TimeRange
+	Raw    []TimeRange
+}
+
+// PlanRollupRange splits [from, to) so that whole hours before the hourly
+// watermark come from the hourly aggregate, whole UTC days before the daily
+// watermark from the daily aggregate, and everything else from raw events.
+// A zero watermark disables the corresponding aggregate.
+func PlanRollupRange(from, to, hourlyWatermark, dailyWatermark time.Time) RollupPlan {
+	var plan RollupPlan
+	if !from.Before(to) {
+		return plan
+	}
+
+	end := to
+	if !hourlyWatermark.IsZero() && hourlyWatermark.Before(end) {
+		end = hourlyWatermark
+	}
+	hourStart, hourEnd := ceilTime(from, time.Hour), end.Truncate(time.Hour)
+	if hourlyWatermark.IsZero() || !hourStart.Before(hourEnd) {
+		plan.Raw = []TimeRange{{From: from, To: to}}
+		return plan
+	}
+
+	if from.Before(hourStart) {
+		plan.Raw = append(plan.Raw, TimeRange{From: from, To: hourStart})
+	}
+	if hourEnd.Before(to) {
+		plan.Raw = append(plan.Raw, TimeRange{From: hourEnd, To: to})
+	}
+
+	// time.Truncate counts from the zero time, which is a UTC midnight
+	dayStart, dayEnd := ceilTime(hourStart, 24*time.Hour), hourEnd.Truncate(24*time.Hour)
+	if !dailyWatermark.IsZero() && dailyWatermark.Truncate(24*time.Hour).Before(dayEnd) {
+		dayEnd = dailyWatermark.Truncate(24 * time.Hour)
+	}
+	if dailyWatermark.IsZero() || !dayStart.Before(dayEnd) {
+		plan.Hourly = []TimeRange{{From: hourStart, To: hourEnd}}
+		return plan
+	}
+
+	plan.Daily = []TimeRange{{From: dayStart, To: dayEnd}}
+	if hourStart.Before(dayStart) {
+		plan.Hourly = append(plan.Hourly, TimeRange{From: hourStart, To: dayStart})
+	}
+	if dayEnd.Before(hourEnd) {
+		plan.Hourly = append(plan.Hourly, TimeRange{From: dayEnd, To: hourEnd})
+	}
+	return plan
+}
+
+func ceilTime(t time.Time, d time.Duration) time.Time {
+	truncated := t.Truncate(d)
+	if truncated.Before(t) {
+		return truncated.Add(d)
+	}
+	return truncated
+}
+
+// SessionRollupFilters reports whether filters can be answered from
+// sessions_hourly, which only carries the per-visitor dimensions
+func SessionRollupFilters(filters models.AnalyticsFilters) bool {
+	filters.Country, filters.Browser, filters.Device, filters.OS = nil, nil, nil, nil
+	return filters.IsEmpty()
+}
+
+// Rollups reads the continuous aggregates and stitches in the part of a
+// range that has not been materialized yet from raw events
+type Rollups struct {
+	db *pgxpool.Pool
+
+	mu         sync.Mutex
+	watermarks map[string]cachedWatermark
+}
+
+type cachedWatermark struct {
+	value     time.Time
+	fetchedAt time.Time
+}
+
+func NewRollups(db *pgxpool.Pool) *Rollups {
+	return &Rollups{
+		db:         db,
+		watermarks: make(map[string]cachedWatermark),
+	}
+}
+
+// TimescaleDB moved its internal functions to a new schema in 2.12
+var watermarkQueries = []string{
+	`SELECT _timescaledb_functions.to_timestamp(_timescaledb_functions.cagg_watermark(mat_hypertable_id))
+		FROM _timescaledb_catalog.continuous_agg WHERE user_view_name = $1`,
+	`SELECT _timescaledb_internal.to_timestamp(_timescaledb_internal.cagg_watermark(mat_hypertable_id))
+		FROM _timescaledb_catalog.continuous_agg WHERE user_view_name = $1`,
+}
+
+// Watermark returns the end of the materialized part of view, or the zero
+// time when nothing is materialized or the view is unavailable
+func (r *Rollups) Watermark(ctx context.Context, view string) time.Time {
+	r.mu.Lock()
+	cached, ok := r.watermarks[view]
+	r.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < watermarkTTL {
+		return cached.value
+	}
+
+	var watermark time.Time
+	for _, query := range watermarkQueries {
+		var value pgtype.Timestamptz
+		if err := r.db.QueryRow(ctx, query, view).Scan(&value); err != nil {
+			continue
+		}
+		// An empty aggregate reports -infinity
+		if value.Valid && value.InfinityModifier == pgtype.Finite {
+			watermark = value.Time
+		}
+		break
+	}
+
+	r.mu.Lock()
+	r.watermarks[view] = cachedWatermark{value: watermark, fetchedAt: time.Now()}
+	r.mu.Unlock()
+	return watermark
+}
+
+// sessionsQuery returns a query with one row per session in the range:
+// session_id, visitor_id, country, browser, device, os, pageviews and
+// duration (capped at 30 minutes, or time on page for single page sessions).
+// ok is false when the filters need columns sessions_hourly does not have.
+func (r *Rollups) sessionsQuery(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, args []interface{}) (query string, queryArgs []interface{}, ok bool) {
+	if !SessionRollupFilters(filters) {
+		return "", args, false
+	}
+
+	plan := PlanRollupRange(dateRange.From, dateRange.To, r.Watermark(ctx, SessionsHourlyView), time.Time{})
+
+	bind := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	website := bind(websiteID)
+
+	var branches []string
+	if len(plan.Hourly) > 0 {
+		var filterSQL string
+		filterSQL, args = BuildFilterClause(filters, "s", args)
+		branches = append(branches, `
+			SELECT s.session_id, s.visitor_id, s.country, s.browser, s.device, s.os,
+				s.pageviews, s.first_seen, s.last_seen, s.max_time_on_page
+			FROM sessions_hourly s
+			WHERE s.website_id = `+website+`
+			AND `+rangeCondition("s.bucket", plan.Hourly, bind)+filterSQL)
+	}
+	if len(plan.Raw) > 0 {
+		var filterSQL string
+		filterSQL, args = BuildFilterClause(filters, "e", args)
+		branches = append(branches, `
+			SELECT e.session_id, e.visitor_id, e.country, e.browser, e.device, e.os,
+				COUNT(*), MIN(e.timestamp), MAX(e.timestamp), MAX(e.time_on_page)
+			FROM events e
+			WHERE e.website_id = `+website+`
+			AND e.event_type = 'pageview'
+			AND `+rangeCondition("e.timestamp", plan.Raw, bind)+filterSQL+`
+			GROUP BY e.session_id, e.visitor_id, e.country, e.browser, e.device, e.os`)
+	}
+	if len(branches) == 0 {
+		// Empty range
+		branches = append(branches, `
+			SELECT NULL::varchar, NULL::varchar, NULL::varchar, NULL::varchar, NULL::varchar, NULL::varchar,
+				0::bigint, NULL::timestamptz, NULL::timestamptz, NULL::integer
+			WHERE false`)
+	}
+
+	// Events without a session are kept apart per visitor
+	query = `
+		SELECT
+			session_id,
+			MIN(visitor_id) AS visitor_id,
+			MIN(country) AS country,
+			MIN(browser) AS browser,
+			MIN(device) AS device,
+			MIN(os) AS os,
+			SUM(pageviews)::bigint AS pageviews,
+			CASE
+				WHEN SUM(pageviews) > 1 THEN LEAST(EXTRACT(EPOCH FROM (MAX(last_seen) - MIN(first_seen))), 1800)
+				ELSE COALESCE(MAX(max_time_on_page), 30)
+			END AS duration
+		FROM (` + strings.Join(branches, "\n\t\t\tUNION ALL") + `
+		) session_rows (session_id, visitor_id, country, browser, device, os, pageviews, first_seen, last_seen, max_time_on_page)
+		GROUP BY session_id, CASE WHEN session_id IS NULL THEN visitor_id END`
+
+	return query, args, true
+}
+
+// pageviewSeriesQuery returns a query with (period, views, visitors) rows,
+// where period is the local day or hour ("day" or "hour") as a timestamp
+// without time zone and visitors is a HyperLogLog sketch to be combined with
+// rollup(). ok is false when the rollups are missing or nothing is
+// materialized yet, or when the filters or the timezone rule them out.
+func (r *Rollups) pageviewSeriesQuery(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, unit string, args []interface{}) (query string, queryArgs []interface{}, ok bool) {
+	loc := dateRange.Location()
+	// Skip the watermark lookup when the rollups are ruled out anyway
+	if !filters.IsEmpty() || !wholeHourOffset(loc, dateRange) {
+		return "", args, false
+	}
+	hourlyWatermark := r.Watermark(ctx, EventsHourlyView)
+	if !PageviewRollupsUsable(filters, dateRange, hourlyWatermark) {
+		return "", args, false
+	}
+
+	// Daily buckets are UTC days, so they only line up with UTC dates
+	var dailyWatermark time.Time
+	if unit == "day" && loc == time.UTC {
+		dailyWatermark = r.Watermark(ctx, EventsDailyView)
+	}
+	plan := PlanRollupRange(dateRange.From, dateRange.To, hourlyWatermark, dailyWatermark)
+
+	bind := func(value interface{}) string {
+		args = append(args, value)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	website := bind(websiteID)
+	timezone := bind(loc.String())
+
+	var branches []string
+	for _, view := range []struct {
+		name   string
+		ranges []TimeRange
+	}{{EventsDailyView, plan.Daily}, {EventsHourlyView, plan.Hourly}} {
+		if len(view.ranges) == 0 {
+			continue
+		}
+		var filterSQL string
+		filterSQL, args = BuildFilterClause(filters, "a", args)
+		branches = append(branches, `
+			SELECT date_trunc('`+unit+`', a.bucket AT TIME ZONE `+timezone+`), a.pageviews, a.visitors
+			FROM `+view.name+` a
+			WHERE a.website_id = `+website+`
+			AND `+rangeCondition("a.bucket", view.ranges, bind)+filterSQL)
+	}
+	if len(plan.Raw) > 0 || len(branches) == 0 {
+		var filterSQL string
+		filterSQL, args = BuildFilterClause(filters, "e", args)
+		branches = append(branches, `
+			SELECT date_trunc('`+unit+`', e.timestamp AT TIME ZONE `+timezone+`), COUNT(*), hyperloglog(8192, e.visitor_id)
+			FROM events e
+			WHERE e.website_id = `+website+`
+			AND e.event_type = 'pageview'
+			AND `+rangeCondition("e.timestamp", plan.Raw, bind)+filterSQL+`
+			GROUP BY 1`)
+	}
+
+	query = `
+		SELECT period, views, visitors
+		FROM (` + strings.Join(branches, "\n\t\t\tUNION ALL") + `
+		) series (period, views, visitors)`
+	return query, args, true
+}
+
+// PageviewRollupsUsable reports whether a pageview series can be served from
+// events_hourly and events_daily. Their sketches and the raw tail merged into
+// them need the TimescaleDB Toolkit, and the views only exist when it is
+// installed, so a zero hourly watermark (view missing or still empty) sends
+// the query to raw events.
+func PageviewRollupsUsable(filters models.AnalyticsFilters, dateRange models.DateRange, hourlyWatermark time.Time) bool {
+	return filters.IsEmpty() && wholeHourOffset(dateRange.Location(), dateRange) && !hourlyWatermark.IsZero()
+}
+
+// DimensionStat is a top-N row computed from session rollups
+type DimensionStat struct {
+	Value      string
+	Views      int
+	Unique     int
+	BounceRate *float64
+}
+
+// sessionDimensions lists the columns DimensionStats can group by
+var sessionDimensions = map[string]bool{"country": true, "browser": true, "device": true, "os": true}
+
+// DimensionStats returns views, unique visitors and bounce rate per value of
+// a per-visitor dimension, ordered by unique visitors. ok is false when the
+// filters cannot be answered from the rollups and the caller must query raw
+// events instead.
+func (r *Rollups) DimensionStats(ctx context.Context, websiteID, dimension string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) (stats []DimensionStat, ok bool, err error) {
+	if !sessionDimensions[dimension] {
+		return nil, false, fmt.Errorf("unsupported rollup dimension %q", dimension)
+	}
+
+	sessions, args, ok := r.sessionsQuery(ctx, websiteID, dateRange, filters, []interface{}{limit})
+	if !ok {
+		return nil, false, nil
+	}
+
+	query := `
+		WITH sessions AS (` + sessions + `
+		)
+		SELECT
+			COALESCE(` + dimension + `, 'unknown') AS value,
+			SUM(pageviews)::bigint AS views,
+			COUNT(DISTINCT visitor_id) AS unique_visitors,
+			COALESCE(
+				(COUNT(session_id) FILTER (WHERE pageviews = 1) * 100.0) /
+				NULLIF(COUNT(session_id), 0), 0
+			) AS bounce_rate
+		FROM sessions
+		GROUP BY 1
+		ORDER BY unique_visitors DESC
+		LIMIT $1`
+
+	rows, err := r.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, true, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var stat DimensionStat
+		var bounceRate float64
+		if err := rows.Scan(&stat.Value, &stat.Views, &stat.Unique, &bounceRate); err != nil {
+			return nil, true, err
+		}
+		if bounceRate > 100.0 {
+			bounceRate = 100.0
+		}
+		stat.BounceRate = &bounceRate
+		stats = append(stats, stat)
+	}
+
+	return stats, true, rows.Err()
+}
+
+// rangeCondition matches column against any of the ranges
+func rangeCondition(column string, ranges []TimeRange, bind func(interface{}) string) string {
+	if len(ranges) == 0 {
+		return "false"
+	}
+	conditions := make([]string, len(ranges))
+	for i, r := range ranges {
+		conditions[i] = fmt.Sprintf("(%s >= %s AND %s < %s)", column, bind(r.From), column, bind(r.To))
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")"
+}
+
+// wholeHourOffset reports whether loc is a whole number of hours from UTC at
+// both ends of the range, so hourly buckets fall within local hours and days
+func wholeHourOffset(loc *time.Location, dateRange models.DateRange) bool {
+	for _, t := range []time.Time{dateRange.From, dateRange.To} {
+		if _, offset := t.In(loc).Zone(); offset%3600 != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,255 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type SessionsAnalytics struct {
+	db *pgxpool.Pool
+}
+
+func NewSessionsAnalytics(db *pgxpool.Pool) *SessionsAnalytics {
+	return &SessionsAnalytics{db: db}
+}
+
+// sessionsCTE reconstructs the sessions with a pageview in [$2, $3) matching
+// filterSQL. Durations are capped at 4 hours like the traffic summary's, and
+// exit intent and scroll depth come from the session's other events.
+func sessionsCTE(filterSQL string) string {
+	return `
+		WITH pageviews AS (
+			SELECT e.session_id, e.visitor_id, e.page, e.timestamp,
+				COALESCE(e.country, 'unknown') as country,
+				COALESCE(e.device, 'unknown') as device,
+				COALESCE(e.browser, 'unknown') as browser,
+				COALESCE(e.os, 'unknown') as os,
+				` + sourceCategorySQL + ` as source
+			FROM events e
+			WHERE e.website_id = $1
+			AND e.timestamp >= $2 AND e.timestamp < $3
+			AND e.event_type = 'pageview'
+			AND e.session_id IS NOT NULL AND e.session_id != ''` + filterSQL + `
+		),
+		sessions AS (
+			SELECT session_id,
+				MIN(visitor_id) as visitor_id,
+				MIN(timestamp) as started_at,
+				MAX(timestamp) as ended_at,
+				LEAST(EXTRACT(EPOCH FROM (MAX(timestamp) - MIN(timestamp))), 14400)::int as duration,
+				COUNT(*) as pageviews,
+				COUNT(DISTINCT page) as unique_pages,
+				(ARRAY_AGG(page ORDER BY timestamp))[1] as entry_page,
+				(ARRAY_AGG(page ORDER BY timestamp DESC))[1] as exit_page,
+				(ARRAY_AGG(source ORDER BY timestamp))[1] as source,
+				(ARRAY_AGG(country ORDER BY timestamp))[1] as country,
+				(ARRAY_AGG(device ORDER BY timestamp))[1] as device,
+				(ARRAY_AGG(browser ORDER BY timestamp))[1] as browser,
+				(ARRAY_AGG(os ORDER BY timestamp))[1] as os
+			FROM pageviews
+			GROUP BY session_id
+		),
+		signals AS (
+			SELECT session_id,
+				BOOL_OR(event_type = '` + models.ExitIntentEventType + `') as exit_intent,
+				MAX(CASE WHEN jsonb_typeof(properties->'scroll_depth') = 'number'
+					THEN LEAST(GREATEST((properties->>'scroll_depth')::numeric, 0), 100) END)::int as scroll_depth
+			FROM (
+				SELECT session_id, event_type, properties FROM events
+				WHERE website_id = $1 AND timestamp >= $2 AND timestamp < $3::timestamptz + INTERVAL '4 hours'
+				AND session_id IN (SELECT session_id FROM sessions)
+				UNION ALL
+				SELECT session_id, event_type, properties FROM custom_events
+				WHERE website_id = $1 AND timestamp >= $2 AND timestamp < $3::timestamptz + INTERVAL '4 hours'
+				AND session_id IN (SELECT session_id FROM sessions)
+			) session_events
+			GROUP BY session_id
+		)`
+}
+
+// GetSessions returns the sessions of a range, latest first
+func (sa *SessionsAnalytics) GetSessions(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit, offset int) ([]models.Session, error) {
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To})
+	return sa.querySessions(ctx, filterSQL, args, limit, offset)
+}
+
+// GetVisitorSessions returns a visitor's sessions in a range, latest first
+func (sa *SessionsAnalytics) GetVisitorSessions(ctx context.Context, websiteID, visitorID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.Session, error) {
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To, visitorID})
+	return sa.querySessions(ctx, " AND e.visitor_id = $4"+filterSQL, args, limit, 0)
+}
+
+func (sa *SessionsAnalytics) querySessions(ctx context.Context, filterSQL string, args []interface{}, limit, offset int) ([]models.Session, error) {
+	args = append(args, limit, offset)
+	query := sessionsCTE(filterSQL) + `
+		SELECT s.session_id, s.visitor_id, s.started_at, s.ended_at, s.duration,
+			s.pageviews, s.unique_pages, s.entry_page, s.exit_page, s.source,
+			s.country, s.device, s.browser, s.os,
+			COALESCE(sig.exit_intent, false), sig.scroll_depth
+		FROM sessions s
+		LEFT JOIN signals sig ON sig.session_id = s.session_id
+		ORDER BY s.started_at DESC, s.session_id
+		LIMIT $` + fmt.Sprint(len(args)-1) + ` OFFSET $` + fmt.Sprint(len(args))
+
+	rows, err := sa.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []models.Session{}
+	for rows.Next() {
+		var s models.Session
+		if err := rows.Scan(
+			&s.SessionID, &s.VisitorID, &s.StartedAt, &s.EndedAt, &s.Duration,
+			&s.Pageviews, &s.UniquePages, &s.EntryPage, &s.ExitPage, &s.Source,
+			&s.Country, &s.Device, &s.Browser, &s.OS,
+			&s.ExitIntent, &s.ScrollDepth,
+		); err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+// GetSessionSummary returns the totals and averages over the sessions of a
+// range. The average scroll depth is over sessions that reported one.
+func (sa *SessionsAnalytics) GetSessionSummary(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (*models.SessionAnalytics, error) {
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To})
+
+	query := sessionsCTE(filterSQL) + `
+		SELECT
+			COUNT(*) as total_sessions,
+			COALESCE(ROUND(AVG(s.pageviews)), 0)::int as avg_pages_per_session,
+			COALESCE(AVG(s.duration), 0)::float8 as avg_session_duration,
+			COUNT(*) FILTER (WHERE s.pageviews = 1) as single_page_sessions,
+			COALESCE(ROUND(AVG(s.unique_pages)), 0)::int as avg_unique_pages,
+			COUNT(*) FILTER (WHERE sig.exit_intent) as exit_intent_sessions,
+			COALESCE(ROUND(AVG(sig.scroll_depth)), 0)::int as avg_scroll_depth
+		FROM sessions s
+		LEFT JOIN signals sig ON sig.session_id = s.session_id`
+
+	var summary models.SessionAnalytics
+	err := sa.db.QueryRow(ctx, query, args...).Scan(
+		&summary.TotalSessions, &summary.AvgPagesPerSession, &summary.AvgSessionDuration,
+		&summary.SinglePageSessions, &summary.AvgUniquePages, &summary.ExitIntentSessions,
+		&summary.AvgScrollDepth,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &summary, nil
+}
+
+// GetVisitorSeen returns when a visitor's first and last pageviews were
+// tracked, or nil times for visitors without any
+func (sa *SessionsAnalytics) GetVisitorSeen(ctx context.Context, websiteID, visitorID string) (*time.Time, *time.Time, error) {
+	var firstSeen, lastSeen time.Time
+	err := sa.db.QueryRow(ctx, `
+		SELECT first_seen, last_seen FROM visitors
+		WHERE website_id = $1 AND visitor_id = $2`,
+		websiteID, visitorID,
+	).Scan(&firstSeen, &lastSeen)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return &firstSeen, &lastSeen, nil
+}
+
+// GetVisitorActivity returns a visitor's latest pageviews and custom events
+// in a range, up to limit, oldest first. Bot traffic is included, as the
+// visitor was asked for by ID.
+func (sa *SessionsAnalytics) GetVisitorActivity(ctx context.Context, websiteID, visitorID string, dateRange models.DateRange, limit int) ([]models.TimelineItem, error) {
+	query := `
+		SELECT item_type, COALESCE(session_id, ''), event_type, COALESCE(page, ''), properties, timestamp
+		FROM (
+			SELECT * FROM (
+				SELECT CASE WHEN event_type = 'pageview' THEN '` + models.TimelinePageview + `' ELSE '` + models.TimelineEvent + `' END as item_type,
+					session_id, event_type, page, properties, timestamp
+				FROM events
+				WHERE website_id = $1 AND visitor_id = $2 AND timestamp >= $3 AND timestamp < $4
+				UNION ALL
+				SELECT '` + models.TimelineEvent + `', session_id, event_type, page, properties, timestamp
+				FROM custom_events
+				WHERE website_id = $1 AND visitor_id = $2 AND timestamp >= $3 AND timestamp < $4
+			) activity
+			ORDER BY timestamp DESC
+			LIMIT $5
+		) latest
+		ORDER BY timestamp`
+
+	rows, err := sa.db.Query(ctx, query, websiteID, visitorID, dateRange.From, dateRange.To, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.TimelineItem
+	for rows.Next() {
+		var item models.TimelineItem
+		var propertiesJSON []byte
+		if err := rows.Scan(&item.Type, &item.SessionID, &item.EventType, &item.Page, &propertiesJSON, &item.Timestamp); err != nil {
+			return nil, err
+		}
+		if len(propertiesJSON) > 0 {
+			if err := json.Unmarshal(propertiesJSON, &item.Properties); err != nil {
+				item.Properties = nil
+			}
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
+
+// GetVisitorFunnelProgress returns the latest funnel progress reported for a
+// visitor in a range, up to limit, oldest first
+func (sa *SessionsAnalytics) GetVisitorFunnelProgress(ctx context.Context, websiteID, visitorID string, dateRange models.DateRange, limit int) ([]models.TimelineItem, error) {
+	query := `
+		SELECT funnel_id, funnel_name, current_step, step_name, converted, session_id, at
+		FROM (
+			SELECT fe.funnel_id, COALESCE(f.name, '') as funnel_name, fe.current_step,
+				COALESCE(fe.step_name, '') as step_name, fe.converted,
+				COALESCE(fe.session_id, '') as session_id, COALESCE(fe.last_activity, fe.created_at) as at
+			FROM funnel_events fe
+			LEFT JOIN funnels f ON f.id = fe.funnel_id
+			WHERE fe.website_id = $1 AND fe.visitor_id = $2
+			AND COALESCE(fe.last_activity, fe.created_at) >= $3
+			AND COALESCE(fe.last_activity, fe.created_at) < $4
+			ORDER BY at DESC
+			LIMIT $5
+		) latest
+		ORDER BY at`
+
+	rows, err := sa.db.Query(ctx, query, websiteID, visitorID, dateRange.From, dateRange.To, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.TimelineItem
+	for rows.Next() {
+		progress := &models.FunnelProgress{}
+		item := models.TimelineItem{Type: models.TimelineFunnel, Funnel: progress}
+		if err := rows.Scan(&progress.FunnelID, &progress.FunnelName, &progress.Step, &progress.StepName,
+			&progress.Converted, &item.SessionID, &item.Timestamp); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, rows.Err()
+}
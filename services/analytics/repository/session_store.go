@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"analytics-app/models"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+const (
+	sessionKeyPrefix = "analytics:session:"
+	// ActiveSessionsKey indexes open sessions by their last activity
+	ActiveSessionsKey = "analytics:sessions:active"
+
+	maxSessionUpdateAttempts = 5
+)
+
+// SessionKey identifies a visitor's open session
+type SessionKey struct {
+	WebsiteID string
+	VisitorID string
+}
+
+// SessionStore holds the open session of every visitor in Redis. Updates are
+// optimistic transactions, so several instances can stitch the same visitor.
+type SessionStore struct {
+	client *redis.Client
+	// ttl drops sessions that were never closed, e.g. while no instance ran
+	ttl time.Duration
+}
+
+func NewSessionStore(client *redis.Client, ttl time.Duration) *SessionStore {
+	return &SessionStore{client: client, ttl: ttl}
+}
+
+func sessionKey(key SessionKey) string {
+	return sessionKeyPrefix + key.WebsiteID + ":" + key.VisitorID
+}
+
+// Website IDs never contain a colon, so members split on the first one
+func sessionMember(key SessionKey) string {
+	return key.WebsiteID + ":" + key.VisitorID
+}
+
+// Update calls fn with the visitor's open session, nil when there is none,
+// and stores the session fn returns. fn is called again when the session
+// changed concurrently, so it must not have other side effects.
+func (s *SessionStore) Update(ctx context.Context, key SessionKey, fn func(current *models.SessionState) (*models.SessionState, error)) error {
+	redisKey := sessionKey(key)
+	for attempt := 0; attempt < maxSessionUpdateAttempts; attempt++ {
+		err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+			current, err := getSession(ctx, tx, redisKey)
+			if err != nil {
+				return err
+			}
+			next, err := fn(current)
+			if err != nil {
+				return err
+			}
+			data, err := json.Marshal(next)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, redisKey, data, s.ttl)
+				pipe.ZAdd(ctx, ActiveSessionsKey, &redis.Z{Score: float64(next.LastSeen.Unix()), Member: sessionMember(key)})
+				return nil
+			})
+			return err
+		}, redisKey)
+		if !errors.Is(err, redis.TxFailedErr) {
+			return err
+		}
+	}
+	return fmt.Errorf("session of visitor %s kept changing concurrently", key.VisitorID)
+}
+
+// IdleSessions returns up to limit visitors whose last activity was before
+// the given time
+func (s *SessionStore) IdleSessions(ctx context.Context, before time.Time, limit int64) ([]SessionKey, error) {
+	members, err := s.client.ZRangeByScore(ctx, ActiveSessionsKey, &redis.ZRangeBy{
+		Min:   "-inf",
+		Max:   "(" + strconv.FormatInt(before.Unix(), 10),
+		Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]SessionKey, 0, len(members))
+	for _, member := range members {
+		parts := strings.SplitN(member, ":", 2)
+		if len(parts) != 2 {
+			s.client.ZRem(ctx, ActiveSessionsKey, member)
+			continue
+		}
+		keys = append(keys, SessionKey{WebsiteID: parts[0], VisitorID: parts[1]})
+	}
+	return keys, nil
+}
+
+// CloseIdle removes a visitor's session if its last activity was before the
+// given time and returns it. It returns nil when the session is still active
+// or another instance closed or continued it first.
+func (s *SessionStore) CloseIdle(ctx context.Context, key SessionKey, before time.Time) (*models.SessionState, error) {
+	redisKey := sessionKey(key)
+	var closed *models.SessionState
+	err := s.client.Watch(ctx, func(tx *redis.Tx) error {
+		current, err := getSession(ctx, tx, redisKey)
+		if err != nil {
+			return err
+		}
+		if current != nil && !current.LastSeen.Before(before) {
+			return nil
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, redisKey)
+			pipe.ZRem(ctx, ActiveSessionsKey, sessionMember(key))
+			return nil
+		})
+		if err == nil {
+			closed = current
+		}
+		return err
+	}, redisKey)
+	if errors.Is(err, redis.TxFailedErr) {
+		return nil, nil
+	}
+	return closed, err
+}
+
+func getSession(ctx context.Context, tx *redis.Tx, redisKey string) (*models.SessionState, error) {
+	data, err := tx.Get(ctx, redisKey).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var state models.SessionState
+	if err := json.Unmarshal(data, &state); err != nil {
+		// A corrupt session is replaced by a new one
+		return nil, nil
+	}
+	return &state, nil
+}
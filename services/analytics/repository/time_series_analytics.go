@@ -3,35 +3,39 @@ package repository
 import (
 	"analytics-app/models"
 	"context"
-	"time"
+	"sort"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
 
 type TimeSeriesAnalytics struct {
-	db *pgxpool.Pool
+	db      *pgxpool.Pool
+	rollups *Rollups
 }
 
-func NewTimeSeriesAnalytics(db *pgxpool.Pool) *TimeSeriesAnalytics {
-	return &TimeSeriesAnalytics{db: db}
+func NewTimeSeriesAnalytics(db *pgxpool.Pool, rollups *Rollups) *TimeSeriesAnalytics {
+	return &TimeSeriesAnalytics{db: db, rollups: rollups}
 }
 
-// GetDailyStats returns daily statistics for a website
-func (ts *TimeSeriesAnalytics) GetDailyStats(ctx context.Context, websiteID string, days int) ([]models.DailyStat, error) {
-	query := `
-		SELECT 
-			DATE(timestamp) as date,
-			COUNT(*) as views,
-			COUNT(DISTINCT visitor_id) as unique_visitors
-		FROM events
-		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND event_type = 'pageview'
-		GROUP BY DATE(timestamp)
-		ORDER BY date DESC
-		LIMIT $2`
+// GetDailyStats returns daily statistics for a website.
+// Days are calendar days in the range's timezone, not UTC days.
+func (ts *TimeSeriesAnalytics) GetDailyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) ([]models.DailyStat, error) {
+	query, args, ok := ts.rollups.pageviewSeriesQuery(ctx, websiteID, dateRange, filters, "day", nil)
+	if ok {
+		query = `
+		SELECT
+			period::date as date,
+			SUM(views)::bigint as views,
+			distinct_count(rollup(visitors)) as unique_visitors
+		FROM (` + query + `
+		) s
+		GROUP BY period
+		ORDER BY date DESC`
+	} else {
+		query, args = ts.rawDailyStatsQuery(websiteID, dateRange, filters)
+	}
 
-	rows, err := ts.db.Query(ctx, query, websiteID, days)
+	rows, err := ts.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -49,56 +53,140 @@ func (ts *TimeSeriesAnalytics) GetDailyStats(ctx context.Context, websiteID stri
 		stats = append(stats, stat)
 	}
 
+	// Imported aggregates cannot be filtered, so only unfiltered stats
+	// include them
+	if filters.IsEmpty() {
+		return ts.mergeImportedDailyStats(ctx, websiteID, dateRange, stats)
+	}
+	return stats, nil
+}
+
+// mergeImportedDailyStats adds the daily totals imported from other tools
+// to stats, keeping them ordered by date descending
+func (ts *TimeSeriesAnalytics) mergeImportedDailyStats(ctx context.Context, websiteID string, dateRange models.DateRange, stats []models.DailyStat) ([]models.DailyStat, error) {
+	query := `
+		SELECT d.date, SUM(d.pageviews)::bigint, SUM(d.visitors)::bigint
+		FROM imported_daily_stats d
+		WHERE d.website_id = $1
+		AND d.page = '' AND d.source = ''
+		AND d.date::timestamp AT TIME ZONE $4 >= $2 AND d.date::timestamp AT TIME ZONE $4 < $3
+		GROUP BY d.date`
+
+	rows, err := ts.db.Query(ctx, query, websiteID, dateRange.From, dateRange.To, dateRange.Timezone)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	days := make(map[string]int, len(stats))
+	for i, stat := range stats {
+		days[stat.Date.Format("2006-01-02")] = i
+	}
+	merged := false
+	for rows.Next() {
+		var imported models.DailyStat
+		if err := rows.Scan(&imported.Date, &imported.Views, &imported.Unique); err != nil {
+			return nil, err
+		}
+		if i, ok := days[imported.Date.Format("2006-01-02")]; ok {
+			stats[i].Views += imported.Views
+			stats[i].Unique += imported.Unique
+		} else {
+			stats = append(stats, imported)
+		}
+		merged = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if merged {
+		sort.Slice(stats, func(i, j int) bool { return stats[i].Date.After(stats[j].Date) })
+	}
 	return stats, nil
 }
 
-// GetHourlyStats returns hourly statistics for a website
-func (ts *TimeSeriesAnalytics) GetHourlyStats(ctx context.Context, websiteID string, days int, timezone string) ([]models.HourlyStat, error) {
+func (ts *TimeSeriesAnalytics) rawDailyStatsQuery(websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (string, []interface{}) {
+	filterSQL, args := BuildFilterClause(filters, "events", []interface{}{websiteID, dateRange.From, dateRange.To, dateRange.Timezone})
+
 	query := `
 		SELECT 
-			EXTRACT(HOUR FROM timestamp)::integer as hour,
-			DATE_TRUNC('hour', timestamp) as timestamp,
+			date_trunc('day', timestamp AT TIME ZONE $4)::date as date,
 			COUNT(*) as views,
 			COUNT(DISTINCT visitor_id) as unique_visitors
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND event_type = 'pageview'
-		GROUP BY DATE_TRUNC('hour', timestamp), EXTRACT(HOUR FROM timestamp)
-		ORDER BY timestamp ASC
-		LIMIT LEAST($2 * 24, 24*30)`
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'` + filterSQL + `
+		GROUP BY 1
+		ORDER BY date DESC`
 
-	rows, err := ts.db.Query(ctx, query, websiteID, days)
+	return query, args
+}
+
+// GetHourlyStats returns hourly statistics for a website.
+// Hours are truncated in the range's timezone so that zones with
+// non-whole-hour offsets get correct local buckets.
+func (ts *TimeSeriesAnalytics) GetHourlyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) ([]models.HourlyStat, error) {
+	query, args, ok := ts.rollups.pageviewSeriesQuery(ctx, websiteID, dateRange, filters, "hour", []interface{}{dateRange.Location().String()})
+	if ok {
+		query = `
+		SELECT
+			EXTRACT(HOUR FROM period)::integer as hour,
+			period AT TIME ZONE $1 as bucket,
+			SUM(views)::bigint as views,
+			distinct_count(rollup(visitors)) as unique_visitors
+		FROM (` + query + `
+		) s
+		GROUP BY period
+		ORDER BY bucket ASC`
+	} else {
+		query, args = ts.rawHourlyStatsQuery(websiteID, dateRange, filters)
+	}
+
+	rows, err := ts.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	// Load timezone
-	loc, err := time.LoadLocation(timezone)
-	if err != nil {
-		// Fallback to UTC if timezone is invalid
-		loc = time.UTC
-	}
+	loc := dateRange.Location()
 
 	var stats []models.HourlyStat
 	for rows.Next() {
 		var stat models.HourlyStat
-		var timestamp time.Time
 		var uniqueVisitors int
-		err := rows.Scan(&stat.Hour, &timestamp, &stat.Views, &uniqueVisitors)
+		err := rows.Scan(&stat.Hour, &stat.Timestamp, &stat.Views, &uniqueVisitors)
 		if err != nil {
 			continue
 		}
 
-		// Convert UTC timestamp to user's timezone
-		localTime := timestamp.In(loc)
-		stat.Timestamp = localTime
+		// Bucketing already happened in the requested zone; only present the
+		// bucket start in that zone as well
+		stat.Timestamp = stat.Timestamp.In(loc)
 		stat.Unique = uniqueVisitors
-		stat.Hour = localTime.Hour()
-		stat.HourLabel = localTime.Format("15:04")
+		stat.HourLabel = stat.Timestamp.Format("15:04")
 		stats = append(stats, stat)
 	}
 
 	return stats, nil
 }
+
+func (ts *TimeSeriesAnalytics) rawHourlyStatsQuery(websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (string, []interface{}) {
+	filterSQL, args := BuildFilterClause(filters, "events", []interface{}{websiteID, dateRange.From, dateRange.To, dateRange.Timezone})
+
+	query := `
+		SELECT 
+			EXTRACT(HOUR FROM date_trunc('hour', timestamp AT TIME ZONE $4))::integer as hour,
+			date_trunc('hour', timestamp AT TIME ZONE $4) AT TIME ZONE $4 as bucket,
+			COUNT(*) as views,
+			COUNT(DISTINCT visitor_id) as unique_visitors
+		FROM events
+		WHERE website_id = $1 
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'` + filterSQL + `
+		GROUP BY date_trunc('hour', timestamp AT TIME ZONE $4)
+		ORDER BY bucket ASC`
+
+	return query, args
+}
@@ -7,15 +7,30 @@ import (
 )
 
 type TopBrowsersAnalytics struct {
-	db *pgxpool.Pool
+	db      *pgxpool.Pool
+	rollups *Rollups
 }
 
-func NewTopBrowsersAnalytics(db *pgxpool.Pool) *TopBrowsersAnalytics {
-	return &TopBrowsersAnalytics{db: db}
+func NewTopBrowsersAnalytics(db *pgxpool.Pool, rollups *Rollups) *TopBrowsersAnalytics {
+	return &TopBrowsersAnalytics{db: db, rollups: rollups}
 }
 
 // GetTopBrowsers returns the top browsers for a website with analytics
-func (tb *TopBrowsersAnalytics) GetTopBrowsers(ctx context.Context, websiteID string, days int, limit int) ([]models.BrowserStat, error) {
+func (tb *TopBrowsersAnalytics) GetTopBrowsers(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.BrowserStat, error) {
+	stats, ok, err := tb.rollups.DimensionStats(ctx, websiteID, "browser", dateRange, filters, limit)
+	if ok {
+		if err != nil {
+			return nil, err
+		}
+		browsers := make([]models.BrowserStat, len(stats))
+		for i, stat := range stats {
+			browsers[i] = models.BrowserStat{Browser: stat.Value, Views: stat.Views, Unique: stat.Unique, BounceRate: stat.BounceRate}
+		}
+		return browsers, nil
+	}
+
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To, limit})
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -23,7 +38,7 @@ func (tb *TopBrowsersAnalytics) GetTopBrowsers(ctx context.Context, websiteID st
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -38,13 +53,13 @@ func (tb *TopBrowsersAnalytics) GetTopBrowsers(ctx context.Context, websiteID st
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'` + filterSQL + `
 		GROUP BY e.browser
 		ORDER BY unique_visitors DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := tb.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := tb.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -8,15 +8,30 @@ import (
 )
 
 type TopCountriesAnalytics struct {
-	db *pgxpool.Pool
+	db      *pgxpool.Pool
+	rollups *Rollups
 }
 
-func NewTopCountriesAnalytics(db *pgxpool.Pool) *TopCountriesAnalytics {
-	return &TopCountriesAnalytics{db: db}
+func NewTopCountriesAnalytics(db *pgxpool.Pool, rollups *Rollups) *TopCountriesAnalytics {
+	return &TopCountriesAnalytics{db: db, rollups: rollups}
 }
 
 // GetTopCountries returns the top countries for a website with analytics
-func (tc *TopCountriesAnalytics) GetTopCountries(ctx context.Context, websiteID string, days int, limit int) ([]models.CountryStat, error) {
+func (tc *TopCountriesAnalytics) GetTopCountries(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.CountryStat, error) {
+	stats, ok, err := tc.rollups.DimensionStats(ctx, websiteID, "country", dateRange, filters, limit)
+	if ok {
+		if err != nil {
+			return nil, err
+		}
+		countries := make([]models.CountryStat, len(stats))
+		for i, stat := range stats {
+			countries[i] = models.CountryStat{Country: stat.Value, Views: stat.Views, Unique: stat.Unique, BounceRate: stat.BounceRate}
+		}
+		return countries, nil
+	}
+
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To, limit})
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -24,7 +39,7 @@ func (tc *TopCountriesAnalytics) GetTopCountries(ctx context.Context, websiteID
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -39,13 +54,13 @@ func (tc *TopCountriesAnalytics) GetTopCountries(ctx context.Context, websiteID
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'` + filterSQL + `
 		GROUP BY e.country
 		ORDER BY unique_visitors DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := tc.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := tc.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
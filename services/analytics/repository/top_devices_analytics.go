@@ -7,15 +7,30 @@ import (
 )
 
 type TopDevicesAnalytics struct {
-	db *pgxpool.Pool
+	db      *pgxpool.Pool
+	rollups *Rollups
 }
 
-func NewTopDevicesAnalytics(db *pgxpool.Pool) *TopDevicesAnalytics {
-	return &TopDevicesAnalytics{db: db}
+func NewTopDevicesAnalytics(db *pgxpool.Pool, rollups *Rollups) *TopDevicesAnalytics {
+	return &TopDevicesAnalytics{db: db, rollups: rollups}
 }
 
 // GetTopDevices returns the top devices for a website with analytics
-func (td *TopDevicesAnalytics) GetTopDevices(ctx context.Context, websiteID string, days int, limit int) ([]models.DeviceStat, error) {
+func (td *TopDevicesAnalytics) GetTopDevices(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.DeviceStat, error) {
+	stats, ok, err := td.rollups.DimensionStats(ctx, websiteID, "device", dateRange, filters, limit)
+	if ok {
+		if err != nil {
+			return nil, err
+		}
+		devices := make([]models.DeviceStat, len(stats))
+		for i, stat := range stats {
+			devices[i] = models.DeviceStat{Device: stat.Value, Views: stat.Views, Unique: stat.Unique, BounceRate: stat.BounceRate}
+		}
+		return devices, nil
+	}
+
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To, limit})
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -23,7 +38,7 @@ func (td *TopDevicesAnalytics) GetTopDevices(ctx context.Context, websiteID stri
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -38,13 +53,13 @@ func (td *TopDevicesAnalytics) GetTopDevices(ctx context.Context, websiteID stri
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'` + filterSQL + `
 		GROUP BY e.device
 		ORDER BY unique_visitors DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := td.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := td.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -7,15 +7,30 @@ import (
 )
 
 type TopOSAnalytics struct {
-	db *pgxpool.Pool
+	db      *pgxpool.Pool
+	rollups *Rollups
 }
 
-func NewTopOSAnalytics(db *pgxpool.Pool) *TopOSAnalytics {
-	return &TopOSAnalytics{db: db}
+func NewTopOSAnalytics(db *pgxpool.Pool, rollups *Rollups) *TopOSAnalytics {
+	return &TopOSAnalytics{db: db, rollups: rollups}
 }
 
 // GetTopOS returns the top operating systems for a website with analytics
-func (to *TopOSAnalytics) GetTopOS(ctx context.Context, websiteID string, days int, limit int) ([]models.OSStat, error) {
+func (to *TopOSAnalytics) GetTopOS(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.OSStat, error) {
+	stats, ok, err := to.rollups.DimensionStats(ctx, websiteID, "os", dateRange, filters, limit)
+	if ok {
+		if err != nil {
+			return nil, err
+		}
+		osList := make([]models.OSStat, len(stats))
+		for i, stat := range stats {
+			osList[i] = models.OSStat{OS: stat.Value, Views: stat.Views, Unique: stat.Unique, BounceRate: stat.BounceRate}
+		}
+		return osList, nil
+	}
+
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To, limit})
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -23,7 +38,7 @@ func (to *TopOSAnalytics) GetTopOS(ctx context.Context, websiteID string, days i
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -38,13 +53,13 @@ func (to *TopOSAnalytics) GetTopOS(ctx context.Context, websiteID string, days i
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'` + filterSQL + `
 		GROUP BY e.os
 		ORDER BY unique_visitors DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := to.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := to.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -19,6 +19,10 @@ func NewTopPagesAnalytics(db *pgxpool.Pool) *TopPagesAnalytics {
 
 // normalizePage normalizes page paths to eliminate duplicates
 func (tp *TopPagesAnalytics) normalizePage(page string) string {
+	return normalizePagePath(page)
+}
+
+func normalizePagePath(page string) string {
 	if page == "" {
 		return "/"
 	}
@@ -62,7 +66,9 @@ func (tp *TopPagesAnalytics) normalizePage(page string) string {
 }
 
 // GetTopPages returns the top pages for a website with analytics
-func (tp *TopPagesAnalytics) GetTopPages(ctx context.Context, websiteID string, days int, limit int) ([]models.PageStat, error) {
+func (tp *TopPagesAnalytics) GetTopPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.PageStat, error) {
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To, limit})
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -70,7 +76,7 @@ func (tp *TopPagesAnalytics) GetTopPages(ctx context.Context, websiteID string,
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -96,14 +102,14 @@ func (tp *TopPagesAnalytics) GetTopPages(ctx context.Context, websiteID string,
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
+		AND e.timestamp >= $2 AND e.timestamp < $3
 		AND e.event_type = 'pageview'
-		AND e.page IS NOT NULL
+		AND e.page IS NOT NULL` + filterSQL + `
 		GROUP BY e.page
 		ORDER BY views DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := tp.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := tp.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -176,7 +182,9 @@ func (tp *TopPagesAnalytics) GetTopPages(ctx context.Context, websiteID string,
 }
 
 // GetPageUTMBreakdown returns UTM parameter breakdown for a specific page
-func (tp *TopPagesAnalytics) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, days int) (map[string]interface{}, error) {
+func (tp *TopPagesAnalytics) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, dateRange models.DateRange, filters models.AnalyticsFilters) (map[string]interface{}, error) {
+	filterSQL, args := BuildFilterClause(filters, "events", []interface{}{websiteID, pagePath, dateRange.From, dateRange.To})
+
 	query := `
 		SELECT 
 			COALESCE(utm_source, 'direct') as source,
@@ -194,12 +202,12 @@ func (tp *TopPagesAnalytics) GetPageUTMBreakdown(ctx context.Context, websiteID,
 					page = $2 OR page LIKE $2 || '?%'
 				END
 		)
-		AND timestamp >= NOW() - INTERVAL '1 day' * $3
-		AND event_type = 'pageview'
+		AND timestamp >= $3 AND timestamp < $4
+		AND event_type = 'pageview'` + filterSQL + `
 		GROUP BY utm_source, utm_medium, utm_campaign
 		ORDER BY visits DESC`
 
-	rows, err := tp.db.Query(ctx, query, websiteID, pagePath, days)
+	rows, err := tp.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -275,7 +283,9 @@ func (tp *TopPagesAnalytics) GetPageUTMBreakdown(ctx context.Context, websiteID,
 }
 
 // GetTopPagesWithTimeBucket returns top pages with time-bucket aggregation for better performance
-func (tp *TopPagesAnalytics) GetTopPagesWithTimeBucket(ctx context.Context, websiteID string, days int, limit int) ([]models.PageStat, error) {
+func (tp *TopPagesAnalytics) GetTopPagesWithTimeBucket(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.PageStat, error) {
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To, limit, dateRange.Timezone})
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -283,7 +293,8 @@ func (tp *TopPagesAnalytics) GetTopPagesWithTimeBucket(ctx context.Context, webs
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= time_bucket('1 day', NOW()) - INTERVAL '1 day' * $2
+			AND timestamp >= date_trunc('day', $2::timestamptz AT TIME ZONE $5) AT TIME ZONE $5
+			AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
@@ -309,14 +320,15 @@ func (tp *TopPagesAnalytics) GetTopPagesWithTimeBucket(ctx context.Context, webs
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
 		WHERE e.website_id = $1 
-		AND e.timestamp >= time_bucket('1 day', NOW()) - INTERVAL '1 day' * $2
+		AND e.timestamp >= date_trunc('day', $2::timestamptz AT TIME ZONE $5) AT TIME ZONE $5
+		AND e.timestamp < $3
 		AND e.event_type = 'pageview'
-		AND e.page IS NOT NULL
+		AND e.page IS NOT NULL` + filterSQL + `
 		GROUP BY e.page
 		ORDER BY views DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := tp.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := tp.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
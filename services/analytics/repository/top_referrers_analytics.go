@@ -16,7 +16,9 @@ func NewTopReferrersAnalytics(db *pgxpool.Pool) *TopReferrersAnalytics {
 }
 
 // GetTopReferrers returns the top referrers for a website with analytics
-func (tr *TopReferrersAnalytics) GetTopReferrers(ctx context.Context, websiteID string, days int, limit int) ([]models.ReferrerStat, error) {
+func (tr *TopReferrersAnalytics) GetTopReferrers(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.ReferrerStat, error) {
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To, limit})
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -24,48 +26,20 @@ func (tr *TopReferrersAnalytics) GetTopReferrers(ctx context.Context, websiteID
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		),
 		normalized_referrers AS (
 			SELECT 
-				CASE 
-					WHEN e.referrer LIKE '%utm_source=%' THEN 
-						-- Extract base referrer from UTM URLs and normalize
-						CASE 
-							WHEN e.referrer LIKE '%localhost%' THEN 'Internal Navigation'
-							WHEN e.referrer LIKE '%google%' THEN 'Google'
-							WHEN e.referrer LIKE '%facebook%' THEN 'Facebook'
-							WHEN e.referrer LIKE '%twitter%' THEN 'Twitter'
-							WHEN e.referrer LIKE '%linkedin%' THEN 'LinkedIn'
-							WHEN e.referrer LIKE '%youtube%' THEN 'YouTube'
-							WHEN e.referrer LIKE '%instagram%' THEN 'Instagram'
-							ELSE 'External Sites'
-						END
-					ELSE 
-						-- Normalize regular referrers
-						CASE 
-							WHEN e.referrer IS NULL OR e.referrer = '' THEN 'Direct Traffic'
-							WHEN LOWER(e.referrer) LIKE '%localhost%' THEN 'Internal Navigation'
-							WHEN LOWER(e.referrer) LIKE '%google%' THEN 'Google'
-							WHEN LOWER(e.referrer) LIKE '%facebook%' THEN 'Facebook'
-							WHEN LOWER(e.referrer) LIKE '%twitter%' THEN 'Twitter'
-							WHEN LOWER(e.referrer) LIKE '%linkedin%' THEN 'LinkedIn'
-							WHEN LOWER(e.referrer) LIKE '%youtube%' THEN 'YouTube'
-							WHEN LOWER(e.referrer) LIKE '%instagram%' THEN 'Instagram'
-							WHEN LOWER(e.referrer) LIKE '%mail.google%' OR LOWER(e.referrer) LIKE '%accounts.google%' THEN 'Google'
-							WHEN LOWER(e.referrer) IN ('direct', 'none', 'null') THEN 'Direct Traffic'
-							ELSE 'External Sites'
-						END
-				END as normalized_referrer,
+				` + normalizedReferrerSQL + ` as normalized_referrer,
 				e.visitor_id,
 				e.session_id,
 				e.timestamp
 			FROM events e
 			WHERE e.website_id = $1 
-			AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-			AND e.event_type = 'pageview'
+			AND e.timestamp >= $2 AND e.timestamp < $3
+			AND e.event_type = 'pageview'` + filterSQL + `
 		)
 		SELECT 
 			nr.normalized_referrer as referrer,
@@ -80,9 +54,9 @@ func (tr *TopReferrersAnalytics) GetTopReferrers(ctx context.Context, websiteID
 		WHERE nr.normalized_referrer IS NOT NULL AND nr.normalized_referrer != ''
 		GROUP BY nr.normalized_referrer
 		ORDER BY unique_visitors DESC, views DESC
-		LIMIT $3`
+		LIMIT $4`
 
-	rows, err := tr.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := tr.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -148,3 +122,34 @@ func (tr *TopReferrersAnalytics) GetTopReferrers(ctx context.Context, websiteID
 
 	return referrers, nil
 }
+
+// normalizedReferrerSQL groups the referrer of the pageview e by site
+const normalizedReferrerSQL = `CASE 
+					WHEN e.referrer LIKE '%utm_source=%' THEN 
+						-- Extract base referrer from UTM URLs and normalize
+						CASE 
+							WHEN e.referrer LIKE '%localhost%' THEN 'Internal Navigation'
+							WHEN e.referrer LIKE '%google%' THEN 'Google'
+							WHEN e.referrer LIKE '%facebook%' THEN 'Facebook'
+							WHEN e.referrer LIKE '%twitter%' THEN 'Twitter'
+							WHEN e.referrer LIKE '%linkedin%' THEN 'LinkedIn'
+							WHEN e.referrer LIKE '%youtube%' THEN 'YouTube'
+							WHEN e.referrer LIKE '%instagram%' THEN 'Instagram'
+							ELSE 'External Sites'
+						END
+					ELSE 
+						-- Normalize regular referrers
+						CASE 
+							WHEN e.referrer IS NULL OR e.referrer = '' THEN 'Direct Traffic'
+							WHEN LOWER(e.referrer) LIKE '%localhost%' THEN 'Internal Navigation'
+							WHEN LOWER(e.referrer) LIKE '%google%' THEN 'Google'
+							WHEN LOWER(e.referrer) LIKE '%facebook%' THEN 'Facebook'
+							WHEN LOWER(e.referrer) LIKE '%twitter%' THEN 'Twitter'
+							WHEN LOWER(e.referrer) LIKE '%linkedin%' THEN 'LinkedIn'
+							WHEN LOWER(e.referrer) LIKE '%youtube%' THEN 'YouTube'
+							WHEN LOWER(e.referrer) LIKE '%instagram%' THEN 'Instagram'
+							WHEN LOWER(e.referrer) LIKE '%mail.google%' OR LOWER(e.referrer) LIKE '%accounts.google%' THEN 'Google'
+							WHEN LOWER(e.referrer) IN ('direct', 'none', 'null') THEN 'Direct Traffic'
+							ELSE 'External Sites'
+						END
+				END`
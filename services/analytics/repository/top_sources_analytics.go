@@ -16,7 +16,9 @@ func NewTopSourcesAnalytics(db *pgxpool.Pool) *TopSourcesAnalytics {
 }
 
 // GetTopSources returns the top traffic sources for a website with analytics
-func (ts *TopSourcesAnalytics) GetTopSources(ctx context.Context, websiteID string, days int, limit int) ([]models.SourceStat, error) {
+func (ts *TopSourcesAnalytics) GetTopSources(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) ([]models.SourceStat, error) {
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To, limit})
+
 	query := `
 		WITH session_stats AS (
 			SELECT 
@@ -24,42 +26,20 @@ func (ts *TopSourcesAnalytics) GetTopSources(ctx context.Context, websiteID stri
 				COUNT(*) as page_count
 			FROM events
 			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		),
 		source_categorized AS (
 			SELECT 
-				CASE 
-					-- UTM Source takes priority for campaign tracking
-					WHEN e.utm_source IS NOT NULL AND e.utm_source != '' THEN
-						CASE 
-							WHEN LOWER(e.utm_source) IN ('google', 'bing', 'yahoo', 'duckduckgo', 'search') THEN 'Search Engines'
-							WHEN LOWER(e.utm_source) IN ('facebook', 'instagram', 'twitter', 'linkedin', 'youtube', 'tiktok', 'pinterest', 'snapchat') THEN 'Social Media'
-							WHEN LOWER(e.utm_source) IN ('email', 'newsletter', 'mailchimp', 'sendgrid') THEN 'Email Marketing'
-							WHEN LOWER(e.utm_source) IN ('google_ads', 'facebook_ads', 'linkedin_ads', 'twitter_ads') OR LOWER(e.utm_medium) IN ('cpc', 'ppc', 'paid', 'ads') THEN 'Paid Advertising'
-							WHEN LOWER(e.utm_source) IN ('affiliate', 'partner', 'referral') THEN 'Affiliate/Referral'
-							ELSE 'Campaign Traffic'
-						END
-					-- Analyze referrer for organic traffic
-					ELSE 
-						CASE 
-							WHEN e.referrer IS NULL OR e.referrer = '' OR LOWER(e.referrer) IN ('direct', 'none', 'null') THEN 'Direct Traffic'
-							WHEN LOWER(e.referrer) LIKE '%localhost%' OR LOWER(e.referrer) LIKE '%127.0.0.1%' THEN 'Internal Navigation'
-							WHEN LOWER(e.referrer) LIKE '%google.%/search%' OR LOWER(e.referrer) LIKE '%bing.%/search%' OR LOWER(e.referrer) LIKE '%yahoo.%/search%' OR LOWER(e.referrer) LIKE '%duckduckgo.%' THEN 'Organic Search'
-							WHEN LOWER(e.referrer) LIKE '%facebook.%' OR LOWER(e.referrer) LIKE '%twitter.%' OR LOWER(e.referrer) LIKE '%linkedin.%' OR LOWER(e.referrer) LIKE '%youtube.%' OR LOWER(e.referrer) LIKE '%instagram.%' OR LOWER(e.referrer) LIKE '%tiktok.%' THEN 'Social Media'
-							WHEN LOWER(e.referrer) LIKE '%mail.%' OR LOWER(e.referrer) LIKE '%email%' OR LOWER(e.referrer) LIKE '%newsletter%' THEN 'Email Marketing'
-							WHEN LOWER(e.referrer) LIKE '%.edu%' OR LOWER(e.referrer) LIKE '%.gov%' OR LOWER(e.referrer) LIKE '%.org%' THEN 'Institutional'
-							ELSE 'Referral Traffic'
-						END
-				END as source_category,
+				` + sourceCategorySQL + ` as source_category,
 				e.visitor_id,
 				e.session_id,
 				e.timestamp
 			FROM events e
 			WHERE e.website_id = $1 
-			AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-			AND e.event_type = 'pageview'
+			AND e.timestamp >= $2 AND e.timestamp < $3
+			AND e.event_type = 'pageview'` + filterSQL + `
 		)
 		SELECT 
 			sc.source_category as source,
@@ -73,10 +53,10 @@ func (ts *TopSourcesAnalytics) GetTopSources(ctx context.Context, websiteID stri
 		LEFT JOIN session_stats s ON sc.session_id = s.session_id
 		GROUP BY sc.source_category
 		ORDER BY unique_visitors DESC, views DESC
-		LIMIT $3
+		LIMIT $4
 	`
 
-	rows, err := ts.db.Query(ctx, query, websiteID, days, limit)
+	rows, err := ts.db.Query(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -105,3 +85,28 @@ func (ts *TopSourcesAnalytics) GetTopSources(ctx context.Context, websiteID stri
 
 	return sources, rows.Err()
 }
+
+// sourceCategorySQL classifies the pageview e by its UTM source or referrer
+const sourceCategorySQL = `CASE 
+					-- UTM Source takes priority for campaign tracking
+					WHEN e.utm_source IS NOT NULL AND e.utm_source != '' THEN
+						CASE 
+							WHEN LOWER(e.utm_source) IN ('google', 'bing', 'yahoo', 'duckduckgo', 'search') THEN 'Search Engines'
+							WHEN LOWER(e.utm_source) IN ('facebook', 'instagram', 'twitter', 'linkedin', 'youtube', 'tiktok', 'pinterest', 'snapchat') THEN 'Social Media'
+							WHEN LOWER(e.utm_source) IN ('email', 'newsletter', 'mailchimp', 'sendgrid') THEN 'Email Marketing'
+							WHEN LOWER(e.utm_source) IN ('google_ads', 'facebook_ads', 'linkedin_ads', 'twitter_ads') OR LOWER(e.utm_medium) IN ('cpc', 'ppc', 'paid', 'ads') THEN 'Paid Advertising'
+							WHEN LOWER(e.utm_source) IN ('affiliate', 'partner', 'referral') THEN 'Affiliate/Referral'
+							ELSE 'Campaign Traffic'
+						END
+					-- Analyze referrer for organic traffic
+					ELSE 
+						CASE 
+							WHEN e.referrer IS NULL OR e.referrer = '' OR LOWER(e.referrer) IN ('direct', 'none', 'null') THEN 'Direct Traffic'
+							WHEN LOWER(e.referrer) LIKE '%localhost%' OR LOWER(e.referrer) LIKE '%127.0.0.1%' THEN 'Internal Navigation'
+							WHEN LOWER(e.referrer) LIKE '%google.%/search%' OR LOWER(e.referrer) LIKE '%bing.%/search%' OR LOWER(e.referrer) LIKE '%yahoo.%/search%' OR LOWER(e.referrer) LIKE '%duckduckgo.%' THEN 'Organic Search'
+							WHEN LOWER(e.referrer) LIKE '%facebook.%' OR LOWER(e.referrer) LIKE '%twitter.%' OR LOWER(e.referrer) LIKE '%linkedin.%' OR LOWER(e.referrer) LIKE '%youtube.%' OR LOWER(e.referrer) LIKE '%instagram.%' OR LOWER(e.referrer) LIKE '%tiktok.%' THEN 'Social Media'
+							WHEN LOWER(e.referrer) LIKE '%mail.%' OR LOWER(e.referrer) LIKE '%email%' OR LOWER(e.referrer) LIKE '%newsletter%' THEN 'Email Marketing'
+							WHEN LOWER(e.referrer) LIKE '%.edu%' OR LOWER(e.referrer) LIKE '%.gov%' OR LOWER(e.referrer) LIKE '%.org%' THEN 'Institutional'
+							ELSE 'Referral Traffic'
+						END
+				END`
@@ -2,7 +2,9 @@ package repository
 
 import (
 	"analytics-app/models"
+	"analytics-app/utils"
 	"context"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 )
@@ -15,58 +17,90 @@ func NewTrafficSummaryAnalytics(db *pgxpool.Pool) *TrafficSummaryAnalytics {
 	return &TrafficSummaryAnalytics{db: db}
 }
 
-// GetTrafficSummary returns comprehensive traffic summary for a website
-func (ts *TrafficSummaryAnalytics) GetTrafficSummary(ctx context.Context, websiteID string, days int) (*models.TrafficSummary, error) {
+// GetTrafficSummary returns comprehensive traffic summary for a website.
+// Growth rates compare with the range of equal length immediately before,
+// new visitors are those first seen within the range, and the retention rate
+// is the share of new visitors who came back within retentionDays.
+func (ts *TrafficSummaryAnalytics) GetTrafficSummary(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, retentionDays int) (*models.TrafficSummary, error) {
+	summary, err := ts.periodSummary(ctx, websiteID, dateRange, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := ts.periodSummary(ctx, websiteID, dateRange.Previous(), filters)
+	if err != nil {
+		return nil, err
+	}
+	growth := func(current, previous int) float64 {
+		if change := utils.PercentChange(current, previous); change != nil {
+			return *change
+		}
+		return 0
+	}
+	summary.GrowthRate = growth(summary.TotalPageViews, previous.TotalPageViews)
+	summary.VisitorsGrowthRate = growth(summary.UniqueVisitors, previous.UniqueVisitors)
+	summary.SessionsGrowthRate = growth(summary.TotalSessions, previous.TotalSessions)
+
+	summary.NewVisitors, summary.ReturningVisitors, err = ts.newAndReturningVisitors(ctx, websiteID, dateRange, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.RetentionDays = retentionDays
+	summary.RetentionRate, err = ts.retentionRate(ctx, websiteID, dateRange, filters, retentionDays)
+	if err != nil {
+		return nil, err
+	}
+
+	summary.EngagementScore = utils.EngagementScore(summary.BounceRate, summary.PagesPerSession, summary.AvgSessionTime)
+
+	return summary, nil
+}
+
+// periodSummary returns the volume, bounce and session metrics of a range
+func (ts *TrafficSummaryAnalytics) periodSummary(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (*models.TrafficSummary, error) {
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To})
+
 	query := `
 		WITH session_stats AS (
-			SELECT 
+			SELECT
 				session_id,
 				COUNT(*) as page_count,
-				CASE 
-					WHEN COUNT(*) > 1 THEN 
+				CASE
+					WHEN COUNT(*) > 1 THEN
 						-- Cap session duration at 4 hours maximum for any reasonable time period
 						LEAST(EXTRACT(EPOCH FROM (MAX(timestamp) - MIN(timestamp))), 14400)
 					ELSE 0
 				END as session_duration
 			FROM events
-			WHERE website_id = $1 
-			AND timestamp >= NOW() - INTERVAL '1 day' * $2
+			WHERE website_id = $1
+			AND timestamp >= $2 AND timestamp < $3
 			AND event_type = 'pageview'
 			GROUP BY session_id
 		)
-		SELECT 
+		SELECT
 			COUNT(*) as total_page_views,
 			COUNT(DISTINCT e.session_id) as total_visitors,
 			COUNT(DISTINCT e.visitor_id) as unique_visitors,
 			COUNT(DISTINCT e.session_id) as total_sessions,
 			COALESCE(
-				(COUNT(*) FILTER (WHERE s.page_count = 1) * 100.0) / 
+				(COUNT(*) FILTER (WHERE s.page_count = 1) * 100.0) /
 				NULLIF(COUNT(DISTINCT e.session_id), 0), 0
 			) as bounce_rate,
 			COALESCE(
 				CAST(AVG(s.session_duration) AS INTEGER), 0
 			) as avg_session_time,
-			COALESCE(COUNT(*) * 1.0 / NULLIF(COUNT(DISTINCT e.session_id), 0), 0) as pages_per_session,
-			0.0 as growth_rate,
-			0.0 as visitors_growth_rate,
-			0.0 as sessions_growth_rate,
-			0 as new_visitors,
-			0 as returning_visitors,
-			50.0 as engagement_score,
-			25.0 as retention_rate
+			COALESCE(COUNT(*) * 1.0 / NULLIF(COUNT(DISTINCT e.session_id), 0), 0) as pages_per_session
 		FROM events e
 		LEFT JOIN session_stats s ON e.session_id = s.session_id
-		WHERE e.website_id = $1 
-		AND e.timestamp >= NOW() - INTERVAL '1 day' * $2
-		AND e.event_type = 'pageview'`
+		WHERE e.website_id = $1
+		AND e.timestamp >= $2 AND e.timestamp < $3
+		AND e.event_type = 'pageview'` + filterSQL
 
 	var summary models.TrafficSummary
-	err := ts.db.QueryRow(ctx, query, websiteID, days).Scan(
+	err := ts.db.QueryRow(ctx, query, args...).Scan(
 		&summary.TotalPageViews, &summary.TotalVisitors, &summary.UniqueVisitors, &summary.TotalSessions,
 		&summary.BounceRate, &summary.AvgSessionTime, &summary.PagesPerSession,
-		&summary.GrowthRate, &summary.VisitorsGrowthRate, &summary.SessionsGrowthRate,
-		&summary.NewVisitors, &summary.ReturningVisitors, &summary.EngagementScore,
-		&summary.RetentionRate,
 	)
 
 	if err != nil {
@@ -80,3 +114,94 @@ func (ts *TrafficSummaryAnalytics) GetTrafficSummary(ctx context.Context, websit
 
 	return &summary, nil
 }
+
+// newAndReturningVisitors splits the visitors of a range by whether their
+// first pageview falls inside it. Visitors not yet in the visitors table
+// count as new.
+func (ts *TrafficSummaryAnalytics) newAndReturningVisitors(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (int, int, error) {
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To})
+
+	query := `
+		SELECT
+			COUNT(*) FILTER (WHERE v.first_seen IS NULL OR v.first_seen >= $2) as new_visitors,
+			COUNT(*) FILTER (WHERE v.first_seen < $2) as returning_visitors
+		FROM (
+			SELECT DISTINCT e.visitor_id
+			FROM events e
+			WHERE e.website_id = $1
+			AND e.timestamp >= $2 AND e.timestamp < $3
+			AND e.event_type = 'pageview'` + filterSQL + `
+		) seen
+		LEFT JOIN visitors v ON v.website_id = $1 AND v.visitor_id = seen.visitor_id`
+
+	var newVisitors, returningVisitors int
+	if err := ts.db.QueryRow(ctx, query, args...).Scan(&newVisitors, &returningVisitors); err != nil {
+		return 0, 0, err
+	}
+	return newVisitors, returningVisitors, nil
+}
+
+// RetentionPlan bounds the retention rate of a range
+type RetentionPlan struct {
+	// Cohort bounds the first pageviews of the visitors counted
+	Cohort TimeRange
+	// A later pageview at least ReturnAfter and less than ReturnBefore after
+	// a visitor's first pageview counts as the visitor coming back
+	ReturnAfter  time.Duration
+	ReturnBefore time.Duration
+}
+
+// PlanRetention counts visitors first seen in [from, to) as retained when
+// they view another page in the retentionDays days that follow the first day
+// after their first pageview, i.e. from one day until retentionDays+1 days
+// later. Visitors whose window has not ended by now are left out.
+func PlanRetention(from, to, now time.Time, retentionDays int) RetentionPlan {
+	window := time.Duration(retentionDays+1) * 24 * time.Hour
+	plan := RetentionPlan{
+		Cohort:       TimeRange{From: from, To: to},
+		ReturnAfter:  24 * time.Hour,
+		ReturnBefore: window,
+	}
+	if closed := now.Add(-window); closed.Before(to) {
+		plan.Cohort.To = closed
+	}
+	return plan
+}
+
+// retentionRate returns the percentage of visitors first seen in the range
+// who came back within retentionDays, as planned by PlanRetention
+func (ts *TrafficSummaryAnalytics) retentionRate(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, retentionDays int) (float64, error) {
+	plan := PlanRetention(dateRange.From, dateRange.To, time.Now(), retentionDays)
+	filterSQL, args := BuildFilterClause(filters, "e", []interface{}{websiteID, dateRange.From, dateRange.To, plan.Cohort.To, plan.ReturnAfter, plan.ReturnBefore})
+
+	query := `
+		WITH cohort AS (
+			SELECT v.visitor_id, v.first_seen
+			FROM visitors v
+			WHERE v.website_id = $1
+			AND v.first_seen >= $2 AND v.first_seen < $4
+			AND EXISTS (
+				SELECT 1 FROM events e
+				WHERE e.website_id = $1 AND e.visitor_id = v.visitor_id
+				AND e.timestamp >= $2 AND e.timestamp < $3
+				AND e.event_type = 'pageview'` + filterSQL + `
+			)
+		)
+		SELECT COALESCE(
+			COUNT(*) FILTER (WHERE EXISTS (
+				SELECT 1 FROM events r
+				WHERE r.website_id = $1 AND r.visitor_id = c.visitor_id
+				AND r.event_type = 'pageview'
+				AND NOT r.is_bot
+				AND r.timestamp >= c.first_seen + $5::interval
+				AND r.timestamp < c.first_seen + $6::interval
+			)) * 100.0 / NULLIF(COUNT(*), 0), 0
+		) as retention_rate
+		FROM cohort c`
+
+	var rate float64
+	if err := ts.db.QueryRow(ctx, query, args...).Scan(&rate); err != nil {
+		return 0, err
+	}
+	return rate, nil
+}
@@ -1,14 +1,16 @@
 package repository
 
 import (
+	"analytics-app/models"
 	"context"
-	"fmt"
 )
 
 // GetUTMAnalytics returns UTM campaign performance data
-func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID string, days int) (map[string]interface{}, error) {
+func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) (map[string]interface{}, error) {
+	filterSQL, args := BuildFilterClause(filters, "events", []interface{}{websiteID, dateRange.From, dateRange.To})
+
 	// Get UTM sources - group NULL and empty values as 'direct'
-	sourcesQuery := fmt.Sprintf(`
+	sourcesQuery := `
 		SELECT 
 			CASE 
 				WHEN utm_source IS NULL OR utm_source = '' THEN 'direct'
@@ -19,16 +21,16 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 			COUNT(DISTINCT session_id) as sessions
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '%d days'
-		AND event_type = 'pageview'
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'` + filterSQL + `
 		GROUP BY CASE 
 			WHEN utm_source IS NULL OR utm_source = '' THEN 'direct'
 			ELSE utm_source
 		END
 		ORDER BY unique_visitors DESC
-		LIMIT 10`, days)
+		LIMIT 10`
 
-	rows, err := da.db.Query(ctx, sourcesQuery, websiteID)
+	rows, err := da.db.Query(ctx, sourcesQuery, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -52,7 +54,7 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 	}
 
 	// Get UTM mediums
-	mediumsQuery := fmt.Sprintf(`
+	mediumsQuery := `
 		SELECT 
 			CASE 
 				WHEN utm_medium IS NULL OR utm_medium = '' THEN 'none'
@@ -62,16 +64,16 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 			COUNT(*) as total_pageviews
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '%d days'
-		AND event_type = 'pageview'
+		AND timestamp >= $2 AND timestamp < $3
+		AND event_type = 'pageview'` + filterSQL + `
 		GROUP BY CASE 
 			WHEN utm_medium IS NULL OR utm_medium = '' THEN 'none'
 			ELSE utm_medium
 		END
 		ORDER BY unique_visitors DESC
-		LIMIT 10`, days)
+		LIMIT 10`
 
-	rows, err = da.db.Query(ctx, mediumsQuery, websiteID)
+	rows, err = da.db.Query(ctx, mediumsQuery, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -94,22 +96,22 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 	}
 
 	// Get UTM campaigns - only show campaigns that actually exist
-	campaignsQuery := fmt.Sprintf(`
+	campaignsQuery := `
 		SELECT 
 			utm_campaign as campaign,
 			COUNT(DISTINCT visitor_id) as unique_visitors,
 			COUNT(*) as total_pageviews
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '%d days'
+		AND timestamp >= $2 AND timestamp < $3
 		AND event_type = 'pageview'
 		AND utm_campaign IS NOT NULL 
-		AND utm_campaign != ''
+		AND utm_campaign != ''` + filterSQL + `
 		GROUP BY utm_campaign
 		ORDER BY unique_visitors DESC
-		LIMIT 10`, days)
+		LIMIT 10`
 
-	rows, err = da.db.Query(ctx, campaignsQuery, websiteID)
+	rows, err = da.db.Query(ctx, campaignsQuery, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -132,22 +134,22 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 	}
 
 	// Get UTM terms - only show terms that actually exist
-	termsQuery := fmt.Sprintf(`
+	termsQuery := `
 		SELECT 
 			utm_term as term,
 			COUNT(DISTINCT visitor_id) as unique_visitors,
 			COUNT(*) as total_pageviews
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '%d days'
+		AND timestamp >= $2 AND timestamp < $3
 		AND event_type = 'pageview'
 		AND utm_term IS NOT NULL
-		AND utm_term != ''
+		AND utm_term != ''` + filterSQL + `
 		GROUP BY utm_term
 		ORDER BY unique_visitors DESC
-		LIMIT 10`, days)
+		LIMIT 10`
 
-	rows, err = da.db.Query(ctx, termsQuery, websiteID)
+	rows, err = da.db.Query(ctx, termsQuery, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -170,22 +172,22 @@ func (da *DashboardAnalytics) GetUTMAnalytics(ctx context.Context, websiteID str
 	}
 
 	// Get UTM content - only show content that actually exists
-	contentQuery := fmt.Sprintf(`
+	contentQuery := `
 		SELECT 
 			utm_content as content,
 			COUNT(DISTINCT visitor_id) as unique_visitors,
 			COUNT(*) as total_pageviews
 		FROM events
 		WHERE website_id = $1 
-		AND timestamp >= NOW() - INTERVAL '%d days'
+		AND timestamp >= $2 AND timestamp < $3
 		AND event_type = 'pageview'
 		AND utm_content IS NOT NULL
-		AND utm_content != ''
+		AND utm_content != ''` + filterSQL + `
 		GROUP BY utm_content
 		ORDER BY unique_visitors DESC
-		LIMIT 10`, days)
+		LIMIT 10`
 
-	rows, err = da.db.Query(ctx, contentQuery, websiteID)
+	rows, err = da.db.Query(ctx, contentQuery, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,424 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultAlertCheckInterval is how often rules are checked for a newly
+	// completed hour
+	DefaultAlertCheckInterval = 5 * time.Minute
+	// alertEvaluationDelay is how long after an hour ends it is evaluated,
+	// leaving time for queued events of the hour to be stored
+	alertEvaluationDelay = 5 * time.Minute
+	// alertDeliveryTimeout bounds evaluating and delivering one rule
+	alertDeliveryTimeout = time.Minute
+	// alertWebhookEvent is the event name of alert webhook deliveries
+	alertWebhookEvent = "alert"
+	// DefaultAlertHistoryLimit and MaxAlertHistoryLimit bound the alerts
+	// returned by the history
+	DefaultAlertHistoryLimit = 100
+	MaxAlertHistoryLimit     = 1000
+)
+
+// ErrInvalidAlertRule is returned for invalid alert rules
+var ErrInvalidAlertRule = errors.New("invalid alert rule")
+
+// AlertWebhookPayload is the body of an alert webhook delivery
+type AlertWebhookPayload struct {
+	Alert *models.Alert `json:"alert"`
+	Text  string        `json:"text"`
+}
+
+type AlertService struct {
+	repo      *repository.AlertRepository
+	analytics *repository.MainAnalyticsRepository
+	mailer    utils.Mailer
+	webhooks  *utils.WebhookSender
+	interval  time.Duration
+	logger    zerolog.Logger
+
+	// Shutdown control
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewAlertService returns the alert service; email alerts fail to send when
+// mailer is nil
+func NewAlertService(repo *repository.AlertRepository, analytics *repository.MainAnalyticsRepository, mailer utils.Mailer, webhooks *utils.WebhookSender, interval time.Duration, logger zerolog.Logger) *AlertService {
+	if interval <= 0 {
+		interval = DefaultAlertCheckInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &AlertService{
+		repo:      repo,
+		analytics: analytics,
+		mailer:    mailer,
+		webhooks:  webhooks,
+		interval:  interval,
+		logger:    logger,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+func (s *AlertService) CreateRule(ctx context.Context, req *models.CreateAlertRuleRequest) (*models.AlertRule, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("rule_name", req.Name).
+		Msg("Creating alert rule")
+
+	rule := &models.AlertRule{
+		WebsiteID:       req.WebsiteID,
+		Name:            req.Name,
+		Metric:          req.Metric,
+		Type:            req.Type,
+		Direction:       req.Direction,
+		Baseline:        req.Baseline,
+		Threshold:       req.Threshold,
+		Sensitivity:     req.Sensitivity,
+		BaselineWeeks:   req.BaselineWeeks,
+		Channel:         req.Channel,
+		Recipients:      req.Recipients,
+		WebhookURL:      req.WebhookURL,
+		WebhookSecret:   req.WebhookSecret,
+		CooldownMinutes: 360,
+		Enabled:         true,
+	}
+	if rule.Metric == "" {
+		rule.Metric = models.AlertMetricPageviews
+	}
+	if rule.Direction == "" {
+		rule.Direction = models.AlertDirectionBelow
+	}
+	if rule.Type == models.AlertTypeAnomaly {
+		if rule.Sensitivity == 0 {
+			rule.Sensitivity = 3
+		}
+		if rule.BaselineWeeks == 0 {
+			rule.BaselineWeeks = utils.MaxAlertBaselineWeeks
+		}
+	}
+	if req.CooldownMinutes != nil {
+		rule.CooldownMinutes = *req.CooldownMinutes
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if err := s.prepare(rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.CreateRule(ctx, rule); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to create alert rule")
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (s *AlertService) GetRules(ctx context.Context, websiteID string) ([]models.AlertRule, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting alert rules")
+
+	return s.repo.GetRulesByWebsiteID(ctx, websiteID)
+}
+
+func (s *AlertService) GetRule(ctx context.Context, ruleID uuid.UUID) (*models.AlertRule, error) {
+	s.logger.Info().
+		Str("rule_id", ruleID.String()).
+		Msg("Getting alert rule")
+
+	return s.repo.GetRuleByID(ctx, ruleID)
+}
+
+func (s *AlertService) UpdateRule(ctx context.Context, ruleID uuid.UUID, req *models.UpdateAlertRuleRequest) (*models.AlertRule, error) {
+	s.logger.Info().
+		Str("rule_id", ruleID.String()).
+		Msg("Updating alert rule")
+
+	rule, err := s.repo.GetRuleByID(ctx, ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		rule.Name = *req.Name
+	}
+	if req.Metric != nil {
+		rule.Metric = *req.Metric
+	}
+	if req.Type != nil {
+		rule.Type = *req.Type
+	}
+	if req.Direction != nil {
+		rule.Direction = *req.Direction
+	}
+	if req.Baseline != nil {
+		rule.Baseline = *req.Baseline
+	}
+	if req.Threshold != nil {
+		rule.Threshold = *req.Threshold
+	}
+	if req.Sensitivity != nil {
+		rule.Sensitivity = *req.Sensitivity
+	}
+	if req.BaselineWeeks != nil {
+		rule.BaselineWeeks = *req.BaselineWeeks
+	}
+	if req.Channel != nil {
+		rule.Channel = *req.Channel
+	}
+	if req.Recipients != nil {
+		rule.Recipients = req.Recipients
+	}
+	if req.WebhookURL != nil {
+		rule.WebhookURL = req.WebhookURL
+	}
+	if req.WebhookSecret != nil {
+		rule.WebhookSecret = req.WebhookSecret
+	}
+	if req.CooldownMinutes != nil {
+		rule.CooldownMinutes = *req.CooldownMinutes
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if err := s.prepare(rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.UpdateRule(ctx, rule); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update alert rule")
+		return nil, err
+	}
+
+	return rule, nil
+}
+
+func (s *AlertService) DeleteRule(ctx context.Context, ruleID uuid.UUID) error {
+	s.logger.Info().
+		Str("rule_id", ruleID.String()).
+		Msg("Deleting alert rule")
+
+	return s.repo.DeleteRule(ctx, ruleID)
+}
+
+func (s *AlertService) prepare(rule *models.AlertRule) error {
+	if rule.Recipients == nil {
+		rule.Recipients = []string{}
+	}
+	if err := utils.ValidateAlertRule(rule); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidAlertRule, err)
+	}
+	return nil
+}
+
+// GetAlerts returns a website's alert history, latest first, optionally of
+// one rule only
+func (s *AlertService) GetAlerts(ctx context.Context, websiteID string, dateRange models.DateRange, ruleID *uuid.UUID, limit int) ([]models.Alert, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting alerts")
+
+	if limit <= 0 {
+		limit = DefaultAlertHistoryLimit
+	}
+	if limit > MaxAlertHistoryLimit {
+		limit = MaxAlertHistoryLimit
+	}
+	return s.repo.GetAlerts(ctx, websiteID, dateRange, ruleID, limit)
+}
+
+// Start evaluates the enabled rules against each completed hour until
+// Shutdown. Hours are claimed per rule in the database, so several
+// instances evaluate each hour once; hours missed while the service was
+// down are not evaluated.
+func (s *AlertService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.evaluateDue()
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *AlertService) evaluateDue() {
+	// The latest hour that ended at least alertEvaluationDelay ago
+	hour := time.Now().UTC().Add(-alertEvaluationDelay).Truncate(time.Hour).Add(-time.Hour)
+
+	rules, err := s.repo.GetEnabledRules(s.ctx)
+	if err != nil {
+		if s.ctx.Err() == nil {
+			s.logger.Error().Err(err).Msg("Failed to get alert rules")
+		}
+		return
+	}
+
+	for i := range rules {
+		rule := &rules[i]
+		if s.ctx.Err() != nil {
+			return
+		}
+		if rule.LastEvaluatedHour != nil && !rule.LastEvaluatedHour.Before(hour) {
+			continue
+		}
+
+		claimed, err := s.repo.ClaimHour(s.ctx, rule.ID, hour)
+		if err != nil {
+			s.logger.Error().Err(err).Str("rule_id", rule.ID.String()).Msg("Failed to claim alert hour")
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := s.evaluate(rule, hour); err != nil && s.ctx.Err() == nil {
+			s.logger.Error().Err(err).Str("rule_id", rule.ID.String()).Msg("Failed to evaluate alert rule")
+		}
+	}
+}
+
+// evaluate checks a rule against an hour and stores and delivers the alert
+// it triggers. Alerts within the rule's cooldown of the last sent one are
+// stored as throttled without being delivered.
+func (s *AlertService) evaluate(rule *models.AlertRule, hour time.Time) error {
+	ctx, cancel := context.WithTimeout(s.ctx, alertDeliveryTimeout)
+	defer cancel()
+
+	series, err := s.hourlySeries(ctx, rule, hour)
+	if err != nil {
+		return err
+	}
+	alert := utils.EvaluateAlertRule(rule, hour, series)
+	if alert == nil {
+		return nil
+	}
+
+	alert.Status = models.AlertPending
+	lastSent, err := s.repo.LastSentHour(ctx, rule.ID)
+	if err != nil {
+		return err
+	}
+	if lastSent != nil && hour.Sub(*lastSent) < time.Duration(rule.CooldownMinutes)*time.Minute {
+		alert.Status = models.AlertThrottled
+	}
+
+	inserted, err := s.repo.InsertAlert(ctx, alert)
+	if err != nil || !inserted {
+		return err
+	}
+	if alert.Status == models.AlertThrottled {
+		s.logger.Info().Str("rule_id", rule.ID.String()).Time("hour", hour).Msg("Alert throttled")
+		return nil
+	}
+
+	status := models.AlertSent
+	var deliveryError *string
+	if err := s.deliver(ctx, rule, alert); err != nil {
+		message := err.Error()
+		status = models.AlertFailed
+		deliveryError = &message
+		s.logger.Error().Err(err).Str("rule_id", rule.ID.String()).Str("channel", rule.Channel).Msg("Failed to send alert")
+	} else {
+		s.logger.Info().Str("rule_id", rule.ID.String()).Str("channel", rule.Channel).Msg("Alert sent")
+	}
+
+	recordCtx, recordCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer recordCancel()
+	return s.repo.UpdateAlertStatus(recordCtx, alert.ID, status, deliveryError)
+}
+
+// hourlySeries returns the rule's metric for the hours it reads, keyed by
+// the start of the hour in UTC
+func (s *AlertService) hourlySeries(ctx context.Context, rule *models.AlertRule, hour time.Time) (map[time.Time]float64, error) {
+	dateRange := models.DateRange{
+		From:     hour.Add(-utils.AlertLookback(rule)),
+		To:       hour.Add(time.Hour),
+		Timezone: "UTC",
+	}
+	stats, err := s.analytics.GetHourlyStats(ctx, rule.WebsiteID, dateRange, models.AnalyticsFilters{})
+	if err != nil {
+		return nil, err
+	}
+
+	series := make(map[time.Time]float64, len(stats))
+	for _, stat := range stats {
+		value := stat.Views
+		if rule.Metric == models.AlertMetricVisitors {
+			value = stat.Unique
+		}
+		series[stat.Timestamp.UTC()] = float64(value)
+	}
+	return series, nil
+}
+
+func (s *AlertService) deliver(ctx context.Context, rule *models.AlertRule, alert *models.Alert) error {
+	switch rule.Channel {
+	case models.AlertChannelEmail:
+		if s.mailer == nil {
+			return ErrEmailUnavailable
+		}
+		return s.mailer.Send(ctx, &utils.MailMessage{
+			To:      rule.Recipients,
+			Subject: "Alert: " + rule.Name,
+			HTML:    "<p>" + html.EscapeString(alert.Message) + "</p>",
+			Text:    alert.Message,
+		})
+	case models.AlertChannelWebhook:
+		if rule.WebhookURL == nil || rule.WebhookSecret == nil {
+			return fmt.Errorf("%w: webhook is not configured", ErrInvalidAlertRule)
+		}
+		return s.webhooks.Send(ctx, *rule.WebhookURL, *rule.WebhookSecret, alertWebhookEvent, AlertWebhookPayload{
+			Alert: alert,
+			Text:  alert.Message,
+		})
+	case models.AlertChannelSlack:
+		if rule.WebhookURL == nil {
+			return fmt.Errorf("%w: webhook is not configured", ErrInvalidAlertRule)
+		}
+		return s.webhooks.SendSlack(ctx, *rule.WebhookURL, fmt.Sprintf("*%s*: %s", rule.Name, alert.Message))
+	}
+	return fmt.Errorf("%w: unknown channel %q", ErrInvalidAlertRule, rule.Channel)
+}
+
+// Shutdown stops evaluating rules, cancelling the delivery in progress, and
+// waits for the evaluator to stop
+func (s *AlertService) Shutdown(timeout time.Duration) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out waiting for alerts")
+	}
+}
@@ -3,12 +3,28 @@ package services
 import (
 	"analytics-app/models"
 	"analytics-app/repository"
+	"analytics-app/utils"
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 )
 
+// ErrInvalidTrends is returned for unsupported granularities and ranges that
+// would produce too many trend buckets
+var ErrInvalidTrends = errors.New("invalid trends request")
+
+// ErrInvalidGoal is returned when the selected goal does not exist or belongs
+// to another website
+var ErrInvalidGoal = errors.New("invalid goal")
+
+// ErrInvalidCustomMetric is returned for invalid custom metrics and when a
+// selected metric does not exist or belongs to another website
+var ErrInvalidCustomMetric = errors.New("invalid custom metric")
+
 type AnalyticsService struct {
 	repo   *repository.MainAnalyticsRepository
 	logger zerolog.Logger
@@ -21,13 +37,15 @@ func NewAnalyticsService(repo *repository.MainAnalyticsRepository, logger zerolo
 	}
 }
 
-func (s *AnalyticsService) GetDashboard(ctx context.Context, websiteID string, days int) (*models.DashboardData, error) {
+func (s *AnalyticsService) GetDashboard(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, customMetrics []models.CustomMetric) (*models.DashboardData, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Msg("Getting dashboard data")
 
-	metrics, err := s.repo.GetDashboardMetrics(ctx, websiteID, days)
+	metrics, err := s.repo.GetDashboardMetrics(ctx, websiteID, dateRange, filters)
 	if err != nil {
 		s.logger.Error().Err(err).Msg("Failed to get dashboard metrics")
 		return nil, fmt.Errorf("failed to get dashboard metrics: %w", err)
@@ -35,8 +53,8 @@ func (s *AnalyticsService) GetDashboard(ctx context.Context, websiteID string, d
 
 	// Get comparison data if available
 	var comparison *models.ComparisonMetrics
-	if days <= 30 { // Only calculate comparison for reasonable time ranges
-		comparison, _ = s.repo.GetComparisonMetrics(ctx, websiteID, days)
+	if dateRange.Duration() <= 30*24*time.Hour { // Only calculate comparison for reasonable time ranges
+		comparison, _ = s.repo.GetComparisonMetrics(ctx, websiteID, dateRange, filters)
 	}
 
 	// Get live visitors data
@@ -53,9 +71,18 @@ func (s *AnalyticsService) GetDashboard(ctx context.Context, websiteID string, d
 		Int("live_visitors", liveVisitors).
 		Msg("Retrieved live visitors for dashboard")
 
+	var customMetricValues models.CustomMetricValues
+	if len(customMetrics) > 0 {
+		values, err := s.customMetricValues(ctx, websiteID, dateRange, filters, customMetrics, repository.CustomMetricsTotal)
+		if err != nil {
+			return nil, err
+		}
+		customMetricValues = values("")
+	}
+
 	return &models.DashboardData{
 		WebsiteID:       websiteID,
-		DateRange:       fmt.Sprintf("%d days", days),
+		DateRange:       dateRange.Label(),
 		TotalVisitors:   metrics.TotalVisitors,
 		UniqueVisitors:  metrics.UniqueVisitors,
 		LiveVisitors:    liveVisitors,
@@ -63,123 +90,439 @@ func (s *AnalyticsService) GetDashboard(ctx context.Context, websiteID string, d
 		SessionDuration: metrics.AvgSessionTime,
 		BounceRate:      metrics.BounceRate,
 		Comparison:      comparison,
+
+		CustomMetricValues: customMetricValues,
 	}, nil
 }
 
-func (s *AnalyticsService) GetTopPages(ctx context.Context, websiteID string, days, limit int) ([]models.PageStat, error) {
+func (s *AnalyticsService) GetTopPages(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int, goal *models.Goal, metrics []models.CustomMetric) ([]models.PageStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Int("limit", limit).
 		Msg("Getting top pages")
 
-	return s.repo.GetTopPages(ctx, websiteID, days, limit)
+	pages, err := s.repo.GetTopPages(ctx, websiteID, dateRange, filters, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) > 0 {
+		values, err := s.customMetricValues(ctx, websiteID, dateRange, filters, metrics, "page")
+		if err != nil {
+			return nil, err
+		}
+		for i := range pages {
+			pages[i].CustomMetricValues = values(pages[i].Page)
+		}
+	}
+	if goal == nil {
+		return pages, nil
+	}
+
+	conversions, err := s.goalConversions(ctx, websiteID, dateRange, filters, goal, "page")
+	if err != nil {
+		return nil, err
+	}
+	for i := range pages {
+		pages[i].GoalConversions = conversions(pages[i].Page)
+	}
+	return pages, nil
 }
 
-func (s *AnalyticsService) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, days int) (map[string]interface{}, error) {
+func (s *AnalyticsService) GetPageUTMBreakdown(ctx context.Context, websiteID, pagePath string, dateRange models.DateRange, filters models.AnalyticsFilters) (map[string]interface{}, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
 		Str("page_path", pagePath).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Msg("Getting page UTM breakdown")
 
-	return s.repo.GetPageUTMBreakdown(ctx, websiteID, pagePath, days)
+	return s.repo.GetPageUTMBreakdown(ctx, websiteID, pagePath, dateRange, filters)
 }
 
-func (s *AnalyticsService) GetTopReferrers(ctx context.Context, websiteID string, days, limit int) ([]models.ReferrerStat, error) {
+func (s *AnalyticsService) GetTopReferrers(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int, goal *models.Goal, metrics []models.CustomMetric) ([]models.ReferrerStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Int("limit", limit).
 		Msg("Getting top referrers")
 
-	return s.repo.GetTopReferrers(ctx, websiteID, days, limit)
+	referrers, err := s.repo.GetTopReferrers(ctx, websiteID, dateRange, filters, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) > 0 {
+		values, err := s.customMetricValues(ctx, websiteID, dateRange, filters, metrics, "referrer")
+		if err != nil {
+			return nil, err
+		}
+		for i := range referrers {
+			referrers[i].CustomMetricValues = values(referrers[i].Referrer)
+		}
+	}
+	if goal == nil {
+		return referrers, nil
+	}
+
+	conversions, err := s.goalConversions(ctx, websiteID, dateRange, filters, goal, "referrer")
+	if err != nil {
+		return nil, err
+	}
+	for i := range referrers {
+		referrers[i].GoalConversions = conversions(referrers[i].Referrer)
+	}
+	return referrers, nil
 }
 
-func (s *AnalyticsService) GetTopSources(ctx context.Context, websiteID string, days, limit int) ([]models.SourceStat, error) {
+func (s *AnalyticsService) GetTopSources(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int, goal *models.Goal, metrics []models.CustomMetric) ([]models.SourceStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Int("limit", limit).
 		Msg("Getting top sources")
 
-	return s.repo.GetTopSources(ctx, websiteID, days, limit)
+	sources, err := s.repo.GetTopSources(ctx, websiteID, dateRange, filters, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	revenue, err := s.revenueStats(ctx, websiteID, dateRange, filters, "source")
+	if err != nil {
+		return nil, err
+	}
+	for i := range sources {
+		sources[i].RevenueStats = revenue(sources[i].Source)
+	}
+	if len(metrics) > 0 {
+		values, err := s.customMetricValues(ctx, websiteID, dateRange, filters, metrics, "source")
+		if err != nil {
+			return nil, err
+		}
+		for i := range sources {
+			sources[i].CustomMetricValues = values(sources[i].Source)
+		}
+	}
+	if goal == nil {
+		return sources, nil
+	}
+
+	conversions, err := s.goalConversions(ctx, websiteID, dateRange, filters, goal, "source")
+	if err != nil {
+		return nil, err
+	}
+	for i := range sources {
+		sources[i].GoalConversions = conversions(sources[i].Source)
+	}
+	return sources, nil
 }
 
-func (s *AnalyticsService) GetTopCountries(ctx context.Context, websiteID string, days, limit int) ([]models.CountryStat, error) {
+func (s *AnalyticsService) GetTopCountries(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int, goal *models.Goal, metrics []models.CustomMetric) ([]models.CountryStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Int("limit", limit).
 		Msg("Getting top countries")
 
-	return s.repo.GetTopCountries(ctx, websiteID, days, limit)
+	countries, err := s.repo.GetTopCountries(ctx, websiteID, dateRange, filters, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	revenue, err := s.revenueStats(ctx, websiteID, dateRange, filters, "country")
+	if err != nil {
+		return nil, err
+	}
+	for i := range countries {
+		countries[i].RevenueStats = revenue(countries[i].Country)
+	}
+	if len(metrics) > 0 {
+		values, err := s.customMetricValues(ctx, websiteID, dateRange, filters, metrics, "country")
+		if err != nil {
+			return nil, err
+		}
+		for i := range countries {
+			countries[i].CustomMetricValues = values(countries[i].Country)
+		}
+	}
+	if goal == nil {
+		return countries, nil
+	}
+
+	conversions, err := s.goalConversions(ctx, websiteID, dateRange, filters, goal, "country")
+	if err != nil {
+		return nil, err
+	}
+	for i := range countries {
+		countries[i].GoalConversions = conversions(countries[i].Country)
+	}
+	return countries, nil
 }
 
-func (s *AnalyticsService) GetTopBrowsers(ctx context.Context, websiteID string, days, limit int) ([]models.BrowserStat, error) {
+func (s *AnalyticsService) GetTopBrowsers(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int, goal *models.Goal, metrics []models.CustomMetric) ([]models.BrowserStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Int("limit", limit).
 		Msg("Getting top browsers")
 
-	return s.repo.GetTopBrowsers(ctx, websiteID, days, limit)
+	browsers, err := s.repo.GetTopBrowsers(ctx, websiteID, dateRange, filters, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) > 0 {
+		values, err := s.customMetricValues(ctx, websiteID, dateRange, filters, metrics, "browser")
+		if err != nil {
+			return nil, err
+		}
+		for i := range browsers {
+			browsers[i].CustomMetricValues = values(browsers[i].Browser)
+		}
+	}
+	if goal == nil {
+		return browsers, nil
+	}
+
+	conversions, err := s.goalConversions(ctx, websiteID, dateRange, filters, goal, "browser")
+	if err != nil {
+		return nil, err
+	}
+	for i := range browsers {
+		browsers[i].GoalConversions = conversions(browsers[i].Browser)
+	}
+	return browsers, nil
 }
 
-func (s *AnalyticsService) GetTopDevices(ctx context.Context, websiteID string, days, limit int) ([]models.DeviceStat, error) {
+func (s *AnalyticsService) GetTopDevices(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int, goal *models.Goal, metrics []models.CustomMetric) ([]models.DeviceStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Int("limit", limit).
 		Msg("Getting top devices")
 
-	return s.repo.GetTopDevices(ctx, websiteID, days, limit)
+	devices, err := s.repo.GetTopDevices(ctx, websiteID, dateRange, filters, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) > 0 {
+		values, err := s.customMetricValues(ctx, websiteID, dateRange, filters, metrics, "device")
+		if err != nil {
+			return nil, err
+		}
+		for i := range devices {
+			devices[i].CustomMetricValues = values(devices[i].Device)
+		}
+	}
+	if goal == nil {
+		return devices, nil
+	}
+
+	conversions, err := s.goalConversions(ctx, websiteID, dateRange, filters, goal, "device")
+	if err != nil {
+		return nil, err
+	}
+	for i := range devices {
+		devices[i].GoalConversions = conversions(devices[i].Device)
+	}
+	return devices, nil
+}
+
+func (s *AnalyticsService) GetBotTraffic(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int) (*models.BotTrafficReport, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
+		Int("limit", limit).
+		Msg("Getting bot traffic")
+
+	return s.repo.GetBotTraffic(ctx, websiteID, dateRange, filters, limit)
 }
 
-func (s *AnalyticsService) GetTopOS(ctx context.Context, websiteID string, days, limit int) ([]models.OSStat, error) {
+func (s *AnalyticsService) GetTopOS(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit int, goal *models.Goal, metrics []models.CustomMetric) ([]models.OSStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Int("limit", limit).
 		Msg("Getting top operating systems")
 
-	return s.repo.GetTopOS(ctx, websiteID, days, limit)
+	osList, err := s.repo.GetTopOS(ctx, websiteID, dateRange, filters, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(metrics) > 0 {
+		values, err := s.customMetricValues(ctx, websiteID, dateRange, filters, metrics, "os")
+		if err != nil {
+			return nil, err
+		}
+		for i := range osList {
+			osList[i].CustomMetricValues = values(osList[i].OS)
+		}
+	}
+	if goal == nil {
+		return osList, nil
+	}
+
+	conversions, err := s.goalConversions(ctx, websiteID, dateRange, filters, goal, "os")
+	if err != nil {
+		return nil, err
+	}
+	for i := range osList {
+		osList[i].GoalConversions = conversions(osList[i].OS)
+	}
+	return osList, nil
 }
 
-func (s *AnalyticsService) GetTrafficSummary(ctx context.Context, websiteID string, days int) (*models.TrafficSummary, error) {
+func (s *AnalyticsService) GetTrafficSummary(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, retentionDays int) (*models.TrafficSummary, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
+		Int("retention_days", retentionDays).
 		Msg("Getting traffic summary")
 
-	return s.repo.GetTrafficSummary(ctx, websiteID, days)
+	return s.repo.GetTrafficSummary(ctx, websiteID, dateRange, filters, retentionDays)
 }
 
-func (s *AnalyticsService) GetDailyStats(ctx context.Context, websiteID string, days int) ([]models.DailyStat, error) {
+func (s *AnalyticsService) GetDailyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, metrics []models.CustomMetric) ([]models.DailyStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Msg("Getting daily statistics")
 
-	return s.repo.GetDailyStats(ctx, websiteID, days)
+	stats, err := s.repo.GetDailyStats(ctx, websiteID, dateRange, filters)
+	if err != nil || len(metrics) == 0 {
+		return stats, err
+	}
+
+	values, err := s.customMetricValues(ctx, websiteID, dateRange, filters, metrics, repository.CustomMetricsByDay)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		stats[i].CustomMetricValues = values(stats[i].Date.Format("2006-01-02"))
+	}
+	return stats, nil
+}
+
+func (s *AnalyticsService) GetHourlyStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, metrics []models.CustomMetric) ([]models.HourlyStat, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
+		Msg("Getting hourly statistics")
+
+	stats, err := s.repo.GetHourlyStats(ctx, websiteID, dateRange, filters)
+	if err != nil || len(metrics) == 0 {
+		return stats, err
+	}
+
+	values, err := s.customMetricValues(ctx, websiteID, dateRange, filters, metrics, repository.CustomMetricsByHour)
+	if err != nil {
+		return nil, err
+	}
+	for i := range stats {
+		stats[i].CustomMetricValues = values(stats[i].Timestamp.Format("2006-01-02 15:00"))
+	}
+	return stats, nil
+}
+
+// GetActivityTrends returns bucketed activity for the range alongside the
+// bucket at the same position in the previous range of equal length
+func (s *AnalyticsService) GetActivityTrends(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, granularity string) (*models.ActivityTrends, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
+		Str("granularity", granularity).
+		Msg("Getting activity trends")
+
+	previousRange := dateRange.Previous()
+	currentBuckets, err := utils.TrendBuckets(dateRange, granularity)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTrends, err)
+	}
+	previousBuckets, err := utils.TrendBuckets(previousRange, granularity)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidTrends, err)
+	}
+
+	current, err := s.repo.GetActivityCounts(ctx, websiteID, dateRange, filters, granularity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current activity: %w", err)
+	}
+	previous, err := s.repo.GetActivityCounts(ctx, websiteID, previousRange, filters, granularity)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get previous activity: %w", err)
+	}
+
+	return &models.ActivityTrends{
+		Granularity:   granularity,
+		Timezone:      dateRange.Timezone,
+		PreviousRange: previousRange.Label(),
+		Buckets:       utils.BuildTrendBuckets(currentBuckets, previousBuckets, current, previous),
+	}, nil
 }
 
-func (s *AnalyticsService) GetHourlyStats(ctx context.Context, websiteID string, days int, timezone string) ([]models.HourlyStat, error) {
+// GetCohorts returns a retention matrix of the cohorts starting in the range
+func (s *AnalyticsService) GetCohorts(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, granularity, cohortEvent string, periods int) (*models.CohortReport, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Str("timezone", timezone).
-		Msg("Getting hourly statistics (last 24 hours)")
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
+		Str("granularity", granularity).
+		Str("cohort_event", cohortEvent).
+		Int("periods", periods).
+		Msg("Getting cohorts")
+
+	cells, err := s.repo.GetCohortCells(ctx, websiteID, dateRange, filters, granularity, cohortEvent, periods)
+	if err != nil {
+		return nil, err
+	}
 
-	return s.repo.GetHourlyStats(ctx, websiteID, days, timezone)
+	return &models.CohortReport{
+		Granularity: granularity,
+		CohortEvent: cohortEvent,
+		Periods:     periods,
+		Timezone:    dateRange.Timezone,
+		Cohorts:     utils.BuildCohorts(cells, granularity, periods, dateRange.Location(), time.Now()),
+	}, nil
 }
 
-func (s *AnalyticsService) GetCustomEvents(ctx context.Context, websiteID string, days int) ([]models.CustomEventStat, error) {
+func (s *AnalyticsService) GetCustomEvents(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters) ([]models.CustomEventStat, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Msg("Getting custom events")
 
-	return s.repo.GetCustomEventStats(ctx, websiteID, days)
+	return s.repo.GetCustomEventStats(ctx, websiteID, dateRange, filters)
 }
 
 // GetLiveVisitors returns the number of currently active visitors
@@ -191,11 +534,258 @@ func (s *AnalyticsService) GetLiveVisitors(ctx context.Context, websiteID string
 	return s.repo.GetLiveVisitors(ctx, websiteID)
 }
 
-func (s *AnalyticsService) GetUTMAnalytics(ctx context.Context, websiteID string, days int) (map[string]interface{}, error) {
+func (s *AnalyticsService) GetUTMAnalytics(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, goal *models.Goal) (map[string]interface{}, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("timezone", dateRange.Timezone).
 		Msg("Getting UTM analytics")
 
-	return s.repo.GetUTMAnalytics(ctx, websiteID, days)
+	utm, err := s.repo.GetUTMAnalytics(ctx, websiteID, dateRange, filters)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each UTM list is keyed by the parameter without its prefix
+	lists := []struct{ list, key, dimension string }{
+		{"sources", "source", "utm_source"},
+		{"mediums", "medium", "utm_medium"},
+		{"campaigns", "campaign", "utm_campaign"},
+		{"terms", "term", "utm_term"},
+		{"content", "content", "utm_content"},
+	}
+	for _, l := range lists {
+		rows, _ := utm[l.list].([]map[string]interface{})
+		if len(rows) == 0 {
+			continue
+		}
+
+		revenue, err := s.revenueStats(ctx, websiteID, dateRange, filters, l.dimension)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			value, _ := row[l.key].(string)
+			r := revenue(value)
+			row["currency"] = r.Currency
+			row["orders"] = r.Orders
+			row["revenue"] = r.Revenue
+			row["average_order_value"] = r.AverageOrderValue
+		}
+		if goal == nil {
+			continue
+		}
+
+		conversions, err := s.goalConversions(ctx, websiteID, dateRange, filters, goal, l.dimension)
+		if err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			value, _ := row[l.key].(string)
+			c := conversions(value)
+			row["conversions"] = *c.Conversions
+			row["conversion_rate"] = *c.ConversionRate
+			if c.GoalValue != nil {
+				row["goal_value"] = *c.GoalValue
+			}
+		}
+	}
+	return utm, nil
+}
+
+// revenueStats returns a lookup of the revenue in the website's base currency
+// by the value of a breakdown dimension
+func (s *AnalyticsService) revenueStats(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, dimension string) (func(value string) models.RevenueStats, error) {
+	settings, err := s.repo.GetRevenueSettings(ctx, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revenue settings: %w", err)
+	}
+	rates, err := s.repo.GetExchangeRates(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get exchange rates: %w", err)
+	}
+	converter := currencyConverter(settings, rates)
+
+	totals, err := s.repo.GetRevenueByDimension(ctx, websiteID, dateRange, filters, dimension)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revenue: %w", err)
+	}
+	return func(value string) models.RevenueStats {
+		return utils.BuildRevenueStats(totals[value], converter)
+	}, nil
+}
+
+// GetGoal returns a goal of the website, to report its conversions in the
+// breakdowns
+func (s *AnalyticsService) GetGoal(ctx context.Context, websiteID string, goalID uuid.UUID) (*models.Goal, error) {
+	goal, err := s.repo.GetGoal(ctx, goalID)
+	if err != nil {
+		s.logger.Error().Err(err).Str("goal_id", goalID.String()).Msg("Failed to get goal")
+		return nil, fmt.Errorf("%w: goal not found", ErrInvalidGoal)
+	}
+	if goal.WebsiteID != websiteID {
+		return nil, fmt.Errorf("%w: goal does not belong to website", ErrInvalidGoal)
+	}
+	return goal, nil
+}
+
+// goalConversions returns a lookup of the goal's conversions by the value of
+// a breakdown dimension
+func (s *AnalyticsService) goalConversions(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, goal *models.Goal, dimension string) (func(value string) models.GoalConversions, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("goal_id", goal.ID.String()).
+		Str("dimension", dimension).
+		Msg("Getting goal conversions")
+
+	counts, err := s.repo.GetGoalConversions(ctx, websiteID, dateRange, filters, goal, dimension)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get goal conversions: %w", err)
+	}
+	return func(value string) models.GoalConversions {
+		return utils.BuildGoalConversions(counts[value], goal)
+	}, nil
+}
+
+// GetCustomMetrics returns custom metrics of the website, to report them
+// alongside the dashboard, time series and breakdowns
+func (s *AnalyticsService) GetCustomMetrics(ctx context.Context, websiteID string, metricIDs []uuid.UUID) ([]models.CustomMetric, error) {
+	metrics := make([]models.CustomMetric, 0, len(metricIDs))
+	for _, metricID := range metricIDs {
+		metric, err := s.repo.GetCustomMetric(ctx, metricID)
+		if err != nil {
+			s.logger.Error().Err(err).Str("metric_id", metricID.String()).Msg("Failed to get custom metric")
+			return nil, fmt.Errorf("%w: metric %s not found", ErrInvalidCustomMetric, metricID)
+		}
+		if metric.WebsiteID != websiteID {
+			return nil, fmt.Errorf("%w: metric %s does not belong to website", ErrInvalidCustomMetric, metricID)
+		}
+		metrics = append(metrics, *metric)
+	}
+	return metrics, nil
+}
+
+// customMetricValues returns a lookup of the custom metrics by the value of
+// a grouping
+func (s *AnalyticsService) customMetricValues(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, metrics []models.CustomMetric, groupBy string) (func(key string) models.CustomMetricValues, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Int("metrics", len(metrics)).
+		Str("group_by", groupBy).
+		Msg("Getting custom metrics")
+
+	values, err := s.repo.GetCustomMetricValues(ctx, websiteID, dateRange, filters, metrics, groupBy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get custom metrics: %w", err)
+	}
+	return func(key string) models.CustomMetricValues {
+		return utils.BuildCustomMetricValues(values[key], metrics)
+	}, nil
+}
+
+// GetAttribution credits the goal's conversions to the channels of the
+// converting visitors' sessions in the lookback window, under every
+// attribution model
+func (s *AnalyticsService) GetAttribution(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, goal *models.Goal, dimension string, lookbackDays int, halfLife time.Duration) (*models.AttributionReport, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Str("goal_id", goal.ID.String()).
+		Str("dimension", dimension).
+		Int("lookback_days", lookbackDays).
+		Dur("half_life", halfLife).
+		Msg("Getting attribution")
+
+	builder := utils.NewAttributionBuilder(halfLife)
+	lookback := time.Duration(lookbackDays) * 24 * time.Hour
+	err := s.repo.StreamConversionJourneys(ctx, websiteID, dateRange, filters, goal, dimension, lookback, func(convertedAt time.Time, touches []models.Touchpoint) error {
+		builder.Add(touches, convertedAt)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get conversion journeys: %w", err)
+	}
+
+	conversions, unattributed := builder.Conversions()
+	return &models.AttributionReport{
+		WebsiteID:    websiteID,
+		GoalID:       goal.ID,
+		DateRange:    dateRange.Label(),
+		Dimension:    dimension,
+		LookbackDays: lookbackDays,
+		HalfLifeDays: halfLife.Hours() / 24,
+		Conversions:  conversions,
+		Unattributed: unattributed,
+		Models:       models.AttributionModels,
+		Channels:     builder.Channels(goal.Value),
+	}, nil
+}
+
+// GetSessions returns the totals over a range's sessions and a page of them,
+// latest first
+func (s *AnalyticsService) GetSessions(ctx context.Context, websiteID string, dateRange models.DateRange, filters models.AnalyticsFilters, limit, offset int) (*models.SessionAnalytics, []models.Session, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Int("limit", limit).
+		Int("offset", offset).
+		Msg("Getting sessions")
+
+	summary, err := s.repo.GetSessionSummary(ctx, websiteID, dateRange, filters)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get session summary: %w", err)
+	}
+
+	sessions, err := s.repo.GetSessions(ctx, websiteID, dateRange, filters, limit, offset)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	return summary, sessions, nil
+}
+
+// GetVisitorJourney returns a visitor's sessions and a timeline of their
+// latest pageviews, custom events and funnel progress in a range. Filters
+// only apply to the sessions.
+func (s *AnalyticsService) GetVisitorJourney(ctx context.Context, websiteID, visitorID string, dateRange models.DateRange, filters models.AnalyticsFilters, sessionLimit, timelineLimit int) (*models.VisitorJourney, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("visitor_id", visitorID).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Int("limit", timelineLimit).
+		Msg("Getting visitor journey")
+
+	journey := &models.VisitorJourney{
+		WebsiteID: websiteID,
+		VisitorID: visitorID,
+		DateRange: dateRange.Label(),
+	}
+
+	var err error
+	journey.FirstSeen, journey.LastSeen, err = s.repo.GetVisitorSeen(ctx, websiteID, visitorID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get visitor: %w", err)
+	}
+
+	journey.Sessions, err = s.repo.GetVisitorSessions(ctx, websiteID, visitorID, dateRange, filters, sessionLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get visitor sessions: %w", err)
+	}
+
+	activity, err := s.repo.GetVisitorActivity(ctx, websiteID, visitorID, dateRange, timelineLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get visitor activity: %w", err)
+	}
+
+	progress, err := s.repo.GetVisitorFunnelProgress(ctx, websiteID, visitorID, dateRange, timelineLimit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get visitor funnel progress: %w", err)
+	}
+
+	journey.Timeline = utils.MergeTimeline(timelineLimit, activity, progress)
+	return journey, nil
 }
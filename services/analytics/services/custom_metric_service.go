@@ -0,0 +1,112 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type CustomMetricService struct {
+	repo   *repository.CustomMetricRepository
+	logger zerolog.Logger
+}
+
+func NewCustomMetricService(repo *repository.CustomMetricRepository, logger zerolog.Logger) *CustomMetricService {
+	return &CustomMetricService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+func (s *CustomMetricService) CreateMetric(ctx context.Context, req *models.CreateCustomMetricRequest) (*models.CustomMetric, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("metric_name", req.Name).
+		Msg("Creating custom metric")
+
+	metric := &models.CustomMetric{
+		WebsiteID:   req.WebsiteID,
+		Name:        req.Name,
+		Description: req.Description,
+		Expression:  req.Expression,
+		Format:      req.Format,
+	}
+	if metric.Format == "" {
+		metric.Format = models.CustomMetricFormatNumber
+	}
+	if err := utils.ValidateCustomMetric(metric); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCustomMetric, err)
+	}
+
+	err := s.repo.Create(ctx, metric)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to create custom metric")
+		return nil, err
+	}
+
+	return metric, nil
+}
+
+func (s *CustomMetricService) GetMetrics(ctx context.Context, websiteID string) ([]models.CustomMetric, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting custom metrics")
+
+	return s.repo.GetByWebsiteID(ctx, websiteID)
+}
+
+func (s *CustomMetricService) GetMetric(ctx context.Context, metricID uuid.UUID) (*models.CustomMetric, error) {
+	s.logger.Info().
+		Str("metric_id", metricID.String()).
+		Msg("Getting custom metric")
+
+	return s.repo.GetByID(ctx, metricID)
+}
+
+func (s *CustomMetricService) UpdateMetric(ctx context.Context, metricID uuid.UUID, req *models.UpdateCustomMetricRequest) (*models.CustomMetric, error) {
+	s.logger.Info().
+		Str("metric_id", metricID.String()).
+		Msg("Updating custom metric")
+
+	metric, err := s.repo.GetByID(ctx, metricID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		metric.Name = *req.Name
+	}
+	if req.Description != nil {
+		metric.Description = req.Description
+	}
+	if req.Expression != nil {
+		metric.Expression = *req.Expression
+	}
+	if req.Format != nil {
+		metric.Format = *req.Format
+	}
+	if err := utils.ValidateCustomMetric(metric); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCustomMetric, err)
+	}
+
+	err = s.repo.Update(ctx, metric)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update custom metric")
+		return nil, err
+	}
+
+	return metric, nil
+}
+
+func (s *CustomMetricService) DeleteMetric(ctx context.Context, metricID uuid.UUID) error {
+	s.logger.Info().
+		Str("metric_id", metricID.String()).
+		Msg("Deleting custom metric")
+
+	return s.repo.Delete(ctx, metricID)
+}
@@ -1,15 +1,19 @@
 package services
 
 import (
+	"analytics-app/metrics"
 	"analytics-app/models"
 	"analytics-app/repository"
 	"context"
+	"errors"
 	"fmt"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"analytics-app/utils"
 
+	"github.com/google/uuid"
 	"github.com/rs/zerolog"
 )
 
@@ -17,15 +21,42 @@ const (
 	// Simple batch collection - much smaller batches, more frequent flushes
 	BatchSize     = 50
 	FlushInterval = 2 * time.Second
+
+	// MaxWriteAttempts is how often an event the database rejects is retried
+	// before it is moved to the dead-letter stream
+	MaxWriteAttempts = 5
+	// PendingClaimIdle is how long an unacknowledged entry may sit with
+	// another consumer before this one takes it over
+	PendingClaimIdle = time.Minute
+	MaxRetryBackoff  = 30 * time.Second
+
+	// QueueRetryAfter is how long throttled clients are asked to wait
+	QueueRetryAfter = 10 * time.Second
+)
+
+var (
+	// ErrQueueFull is returned when the ingestion backlog exceeds its limit
+	ErrQueueFull = errors.New("event queue full")
+	// ErrInvalidEvent wraps validation failures of tracked events
+	ErrInvalidEvent = errors.New("invalid event")
 )
 
 type EventService struct {
 	repo   *repository.EventRepository
+	stream *repository.EventStream
+	geo    *utils.GeoLocator
+	bots   *utils.BotClassifier
 	logger zerolog.Logger
 
-	// Simple event channel for async processing
-	eventChan chan models.Event
-	batchChan chan []models.Event
+	// sessions assigns sessions server-side; nil keeps the tracker's
+	sessions *SessionStitcher
+	// live receives accepted events for the live metrics; nil disables them
+	live *LiveHub
+
+	// Events are buffered in a Redis stream until stored; new events are
+	// refused while the backlog is above maxBacklog
+	maxBacklog int64
+	backlog    atomic.Int64
 
 	// Shutdown control
 	ctx        context.Context
@@ -35,23 +66,35 @@ type EventService struct {
 	shutdownMu sync.RWMutex
 }
 
-func NewEventService(repo *repository.EventRepository, logger zerolog.Logger) *EventService {
+func NewEventService(repo *repository.EventRepository, stream *repository.EventStream, geo *utils.GeoLocator, bots *utils.BotClassifier, sessions *SessionStitcher, live *LiveHub, maxBacklog int64, logger zerolog.Logger) (*EventService, error) {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	service := &EventService{
-		repo:      repo,
-		logger:    logger,
-		eventChan: make(chan models.Event, 1000), // Buffered channel
-		batchChan: make(chan []models.Event, 100),
-		ctx:       ctx,
-		cancel:    cancel,
+		repo:       repo,
+		stream:     stream,
+		geo:        geo,
+		bots:       bots,
+		sessions:   sessions,
+		live:       live,
+		logger:     logger,
+		maxBacklog: maxBacklog,
+		ctx:        ctx,
+		cancel:     cancel,
 	}
 
-	// Start background workers
-	service.startBatchCollector()
-	service.startBatchProcessor()
+	if err := stream.EnsureGroup(ctx); err != nil {
+		cancel()
+		return nil, err
+	}
+	service.refreshBacklog()
 
-	return service
+	// Start background consumer
+	service.startConsumer()
+	if sessions != nil {
+		service.startSessionSweeper()
+	}
+
+	return service, nil
 }
 
 func (s *EventService) TrackEvent(ctx context.Context, event *models.Event) (*models.EventResponse, error) {
@@ -64,38 +107,48 @@ func (s *EventService) TrackEvent(ctx context.Context, event *models.Event) (*mo
 	s.shutdownMu.RUnlock()
 
 	// Validate and set defaults
-	if event.EventType == "" {
-		event.EventType = "pageview"
+	s.prepareEvent(event)
+	if err := utils.ValidateTrackedEvent(event); err != nil {
+		metrics.CountEvents(metrics.InvalidWebsite, metrics.EventRejected, 1)
+		return nil, fmt.Errorf("%w: %v", ErrInvalidEvent, err)
 	}
-	if event.Timestamp.IsZero() {
-		event.Timestamp = time.Now()
+
+	if s.reserve(1) == 0 {
+		metrics.CountEvents(event.WebsiteID, metrics.EventThrottled, 1)
+		s.logger.Warn().Int64("backlog", s.backlog.Load()).Msg("Event queue full, throttling event")
+		return nil, ErrQueueFull
 	}
 
 	// Enrich event data
 	s.enrichEventData(ctx, event)
 
-	// Try to send to channel (non-blocking)
-	select {
-	case s.eventChan <- *event:
-		s.logger.Debug().
-			Str("website_id", event.WebsiteID).
-			Str("visitor_id", event.VisitorID).
-			Str("event_type", event.EventType).
-			Msg("Event queued")
-	default:
-		// Channel full, log warning but don't block
-		s.logger.Warn().Msg("Event channel full, dropping event")
-		return nil, fmt.Errorf("event queue full")
+	events := []models.Event{*event}
+	events = append(events, s.stitchSessions(ctx, events)...)
+	*event = events[0]
+
+	if err := s.enqueue(ctx, events); err != nil {
+		return nil, err
 	}
+	metrics.CountEvents(event.WebsiteID, metrics.EventAccepted, 1)
+	s.recordLive(events)
+
+	s.logger.Debug().
+		Str("website_id", event.WebsiteID).
+		Str("visitor_id", event.VisitorID).
+		Str("event_type", event.EventType).
+		Msg("Event queued")
 
 	return &models.EventResponse{
-		Status:    "accepted",
+		Status:    models.EventStatusAccepted,
 		EventID:   event.ID.String(),
 		VisitorID: event.VisitorID,
 		SessionID: event.SessionID,
 	}, nil
 }
 
+// TrackBatchEvents queues the valid events of a batch and reports the outcome
+// of every event. When the queue has room for only part of the batch, the
+// events beyond that are throttled and may be resent after Queue.RetryAfter.
 func (s *EventService) TrackBatchEvents(ctx context.Context, req *models.BatchEventRequest) (*models.BatchEventResponse, error) {
 	s.shutdownMu.RLock()
 	if s.isShutdown {
@@ -104,167 +157,447 @@ func (s *EventService) TrackBatchEvents(ctx context.Context, req *models.BatchEv
 	}
 	s.shutdownMu.RUnlock()
 
-	if len(req.Events) == 0 {
-		return &models.BatchEventResponse{
-			Status:      "success",
-			EventsCount: 0,
-			ProcessedAt: time.Now().Unix(),
-		}, nil
+	response := &models.BatchEventResponse{
+		Results:     make([]models.EventResult, len(req.Events)),
+		ProcessedAt: time.Now().Unix(),
 	}
 
-	// Process and enrich all events
+	// Validate all events first so throttling only applies to valid ones
+	valid := make([]int, 0, len(req.Events))
 	for i := range req.Events {
-		if req.Events[i].WebsiteID == "" {
-			req.Events[i].WebsiteID = req.SiteID
-		}
-		if req.Events[i].EventType == "" {
-			req.Events[i].EventType = "pageview"
+		event := &req.Events[i]
+		if event.WebsiteID == "" {
+			event.WebsiteID = req.SiteID
 		}
-		if req.Events[i].Timestamp.IsZero() {
-			req.Events[i].Timestamp = time.Now()
+		s.prepareEvent(event)
+
+		response.Results[i] = models.EventResult{Index: i, EventID: event.ID.String()}
+		if err := utils.ValidateTrackedEvent(event); err != nil {
+			response.Results[i].Status = models.EventStatusRejected
+			response.Results[i].Reason = err.Error()
+			response.Rejected++
+			metrics.CountEvents(metrics.InvalidWebsite, metrics.EventRejected, 1)
+			continue
 		}
+		valid = append(valid, i)
+	}
 
+	granted := s.reserve(len(valid))
+	queued := make([]models.Event, 0, granted)
+	for n, i := range valid {
+		if n >= granted {
+			response.Results[i].Status = models.EventStatusThrottled
+			response.Results[i].Reason = ErrQueueFull.Error()
+			response.Throttled++
+			metrics.CountEvents(req.Events[i].WebsiteID, metrics.EventThrottled, 1)
+			continue
+		}
 		s.enrichEventData(ctx, &req.Events[i])
+		queued = append(queued, req.Events[i])
+		response.Results[i].Status = models.EventStatusAccepted
+		response.Accepted++
 	}
 
-	// Send each event to the channel
-	accepted := 0
-	for _, event := range req.Events {
-		select {
-		case s.eventChan <- event:
-			accepted++
-		default:
-			s.logger.Warn().Msg("Event channel full during batch")
-			break
+	if len(queued) > 0 {
+		synthetic := s.stitchSessions(ctx, queued)
+		events := append(queued, synthetic...)
+		if err := s.enqueue(ctx, events); err != nil {
+			return nil, err
 		}
+		countEvents(queued, metrics.EventAccepted)
+		s.recordLive(events)
 	}
 
-	return &models.BatchEventResponse{
-		Status:      "accepted",
-		EventsCount: accepted,
-		ProcessedAt: time.Now().Unix(),
-	}, nil
+	if response.Throttled > 0 {
+		s.logger.Warn().
+			Int("throttled", response.Throttled).
+			Int64("backlog", s.backlog.Load()).
+			Msg("Event queue full, throttling events")
+	}
+
+	response.EventsCount = response.Accepted
+	response.Status = batchStatus(response)
+	response.Queue = s.QueueStatus(response.Throttled > 0)
+	return response, nil
+}
+
+// batchStatus summarizes the per-event results
+func batchStatus(response *models.BatchEventResponse) string {
+	switch {
+	case response.Accepted == len(response.Results):
+		return models.EventStatusAccepted
+	case response.Accepted > 0:
+		return "partial"
+	case response.Throttled > 0:
+		return models.EventStatusThrottled
+	default:
+		return models.EventStatusRejected
+	}
 }
 
-// startBatchCollector collects events into small batches
-func (s *EventService) startBatchCollector() {
+// QueueStatus reports the queue depth; retryAfter is set when the caller was
+// throttled
+func (s *EventService) QueueStatus(throttled bool) models.QueueStatus {
+	status := models.QueueStatus{
+		Depth:    s.backlog.Load(),
+		Capacity: s.maxBacklog,
+	}
+	if status.Capacity > 0 {
+		status.Utilization = float64(status.Depth) / float64(status.Capacity)
+	}
+	if throttled {
+		status.RetryAfter = int(QueueRetryAfter.Seconds())
+	}
+	return status
+}
+
+// prepareEvent sets defaults. The ID is assigned before queueing so that
+// retried writes of the same event are deduplicated by the database.
+func (s *EventService) prepareEvent(event *models.Event) {
+	if event.ID == uuid.Nil {
+		event.ID = uuid.New()
+	}
+	if event.EventType == "" {
+		event.EventType = "pageview"
+	}
+	if event.Timestamp.IsZero() {
+		event.Timestamp = time.Now()
+	}
+	if event.CreatedAt.IsZero() {
+		event.CreatedAt = time.Now()
+	}
+}
+
+// reserve claims queue room for up to n events and returns how many fit
+func (s *EventService) reserve(n int) int {
+	if s.maxBacklog <= 0 {
+		s.backlog.Add(int64(n))
+		return n
+	}
+	for {
+		backlog := s.backlog.Load()
+		room := s.maxBacklog - backlog
+		if room <= 0 {
+			return 0
+		}
+		granted := int64(n)
+		if granted > room {
+			granted = room
+		}
+		if s.backlog.CompareAndSwap(backlog, backlog+granted) {
+			return int(granted)
+		}
+	}
+}
+
+// stitchSessions assigns events their server-side session and returns the
+// session_start and session_end events to store with them, for which queue
+// room is claimed regardless of the backlog limit
+func (s *EventService) stitchSessions(ctx context.Context, events []models.Event) []models.Event {
+	if s.sessions == nil {
+		return nil
+	}
+	synthetic := s.sessions.Stitch(ctx, events)
+	s.backlog.Add(int64(len(synthetic)))
+	return synthetic
+}
+
+// recordLive hands queued events to the live metrics
+func (s *EventService) recordLive(events []models.Event) {
+	if s.live != nil {
+		s.live.Record(events)
+	}
+}
+
+// startSessionSweeper periodically closes idle sessions and queues their
+// session_end events
+func (s *EventService) startSessionSweeper() {
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
 
-		ticker := time.NewTicker(FlushInterval)
+		ticker := time.NewTicker(SessionSweepInterval)
 		defer ticker.Stop()
 
-		batch := make([]models.Event, 0, BatchSize)
-
 		for {
 			select {
 			case <-s.ctx.Done():
-				// Flush remaining batch before exit
-				if len(batch) > 0 {
-					s.sendBatch(batch)
-				}
-				close(s.batchChan)
 				return
-
-			case event := <-s.eventChan:
-				batch = append(batch, event)
-
-				// Send batch when it's full
-				if len(batch) >= BatchSize {
-					s.sendBatch(batch)
-					batch = make([]models.Event, 0, BatchSize)
-					ticker.Reset(FlushInterval) // Reset timer
-				}
-
 			case <-ticker.C:
-				// Send batch on timer (even if not full)
-				if len(batch) > 0 {
-					s.sendBatch(batch)
-					batch = make([]models.Event, 0, BatchSize)
+				ended, err := s.sessions.CloseIdle(s.ctx, time.Now())
+				if err != nil && s.ctx.Err() == nil {
+					s.logger.Error().Err(err).Msg("Failed to close idle sessions")
+				}
+				if len(ended) == 0 {
+					continue
+				}
+				s.backlog.Add(int64(len(ended)))
+				if err := s.enqueue(s.ctx, ended); err == nil {
+					s.logger.Debug().Int("sessions", len(ended)).Msg("Closed idle sessions")
 				}
 			}
 		}
 	}()
 }
 
-// sendBatch sends a batch to the processor
-func (s *EventService) sendBatch(batch []models.Event) {
-	batchCopy := make([]models.Event, len(batch))
-	copy(batchCopy, batch)
-
-	select {
-	case s.batchChan <- batchCopy:
-		s.logger.Debug().Int("batch_size", len(batchCopy)).Msg("Batch sent for processing")
-	case <-s.ctx.Done():
-		s.logger.Warn().Msg("Batch dropped during shutdown")
-	default:
-		s.logger.Warn().Msg("Batch channel full, dropping batch")
+// enqueue appends events, for which room was reserved, to the durable
+// ingestion stream
+func (s *EventService) enqueue(ctx context.Context, events []models.Event) error {
+	if err := s.stream.Add(ctx, events); err != nil {
+		s.backlog.Add(-int64(len(events)))
+		countEvents(events, metrics.EventFailed)
+		s.logger.Error().Err(err).Int("events_count", len(events)).Msg("Failed to queue events")
+		return fmt.Errorf("failed to queue events: %w", err)
 	}
+	return nil
 }
 
-// startBatchProcessor processes batches and writes to DB
-func (s *EventService) startBatchProcessor() {
+// startConsumer reads the ingestion stream and writes events to the database.
+// Entries left unacknowledged by a previous run are replayed first.
+func (s *EventService) startConsumer() {
 	s.wg.Add(1)
 	go func() {
 		defer s.wg.Done()
 
-		for {
+		s.replayOwnPending()
+
+		claimTicker := time.NewTicker(PendingClaimIdle)
+		defer claimTicker.Stop()
+
+		backoff := time.Second
+		for s.ctx.Err() == nil {
 			select {
-			case <-s.ctx.Done():
-				// Process remaining batches
-				for {
-					select {
-					case batch := <-s.batchChan:
-						s.processBatch(batch)
-					default:
-						return
-					}
+			case <-claimTicker.C:
+				s.claimStalePending()
+			default:
+			}
+
+			entries, err := s.stream.Read(s.ctx, BatchSize, FlushInterval)
+			if err != nil {
+				if s.ctx.Err() != nil {
+					return
+				}
+				s.logger.Error().Err(err).Msg("Failed to read event stream")
+				if !s.sleep(backoff) {
+					return
 				}
+				backoff = nextBackoff(backoff)
+				continue
+			}
+			backoff = time.Second
 
-			case batch := <-s.batchChan:
-				s.processBatch(batch)
+			if len(entries) > 0 {
+				s.storeEntries(entries)
 			}
+			s.refreshBacklog()
 		}
 	}()
 }
 
-// processBatch writes a batch to the database
-func (s *EventService) processBatch(batch []models.Event) {
-	if len(batch) == 0 {
+// replayOwnPending stores entries this consumer read before a restart
+func (s *EventService) replayOwnPending() {
+	replayed := 0
+	for s.ctx.Err() == nil {
+		entries, err := s.stream.ReadOwnPending(s.ctx, BatchSize)
+		if err != nil {
+			s.logger.Error().Err(err).Msg("Failed to read pending events")
+			return
+		}
+		if len(entries) == 0 {
+			break
+		}
+		s.storeEntries(entries)
+		replayed += len(entries)
+	}
+	if replayed > 0 {
+		s.logger.Info().Int("events_count", replayed).Msg("Replayed pending events")
+	}
+}
+
+// claimStalePending takes over entries abandoned by other consumers
+func (s *EventService) claimStalePending() {
+	entries, err := s.stream.ClaimPending(s.ctx, PendingClaimIdle, BatchSize)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to claim pending events")
+		return
+	}
+	if len(entries) > 0 {
+		s.logger.Info().Int("events_count", len(entries)).Msg("Claimed stale pending events")
+		s.storeEntries(entries)
+	}
+}
+
+// storeEntries writes stream entries to the database and acknowledges them.
+// While the database is unavailable the batch is retried with backoff and
+// stays pending in the stream; events the database rejects are retried one
+// by one and eventually dead-lettered.
+func (s *EventService) storeEntries(entries []repository.StreamedEvent) {
+	valid := make([]repository.StreamedEvent, 0, len(entries))
+	for _, entry := range entries {
+		if entry.Err != nil {
+			s.deadLetter(entry, entry.Err)
+			continue
+		}
+		valid = append(valid, entry)
+	}
+	if len(valid) == 0 {
 		return
 	}
 
+	backoff := time.Second
+	for {
+		err := s.processBatch(valid)
+		if err == nil {
+			s.ack(valid...)
+			return
+		}
+		if s.databaseAvailable() {
+			s.logger.Warn().Err(err).Int("events_count", len(valid)).Msg("Batch rejected, storing events one by one")
+			break
+		}
+
+		s.logger.Warn().Err(err).Dur("retry_in", backoff).Msg("Database unavailable, retrying batch")
+		if !s.sleep(backoff) {
+			// Left pending; replayed on the next start
+			return
+		}
+		backoff = nextBackoff(backoff)
+	}
+
+	for _, entry := range valid {
+		s.storeEntry(entry)
+	}
+}
+
+// storeEntry writes a single event, dead-lettering it after MaxWriteAttempts
+// failures that were not caused by the database being down
+func (s *EventService) storeEntry(entry repository.StreamedEvent) {
+	backoff := time.Second
+	for attempt := 1; ; {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		err := s.repo.Create(ctx, &entry.Event)
+		cancel()
+		if err == nil {
+			s.ack(entry)
+			return
+		}
+
+		if s.databaseAvailable() {
+			if attempt >= MaxWriteAttempts {
+				s.deadLetter(entry, err)
+				return
+			}
+			attempt++
+		}
+		if !s.sleep(backoff) {
+			return
+		}
+		backoff = nextBackoff(backoff)
+	}
+}
+
+// processBatch writes a batch to the database
+func (s *EventService) processBatch(entries []repository.StreamedEvent) error {
+	batch := make([]models.Event, len(entries))
+	for i := range entries {
+		batch[i] = entries[i].Event
+	}
+
 	start := time.Now()
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
 	s.logger.Info().Int("events_count", len(batch)).Msg("Processing batch")
 
+	metrics.BatchSize.Observe(float64(len(batch)))
 	result, err := s.repo.CreateBatch(ctx, batch)
+	if err == nil && result.Failed > 0 {
+		err = fmt.Errorf("%d of %d events failed: %w", result.Failed, result.Total, errors.Join(result.Errors...))
+	}
 	if err != nil {
-		s.logger.Error().
-			Err(err).
-			Int("events_count", len(batch)).
-			Msg("Failed to process batch")
-		return
+		metrics.BatchDuration.WithLabelValues("error").Observe(time.Since(start).Seconds())
+		return err
 	}
+	metrics.BatchDuration.WithLabelValues("success").Observe(time.Since(start).Seconds())
 
-	duration := time.Since(start)
 	s.logger.Info().
 		Int("processed", result.Processed).
-		Int("failed", result.Failed).
-		Dur("duration", duration).
+		Dur("duration", time.Since(start)).
 		Msg("Batch processed successfully")
+	return nil
+}
 
-	if result.Failed > 0 {
-		s.logger.Warn().
-			Int("failed_count", result.Failed).
-			Msg("Some events failed in batch")
+func (s *EventService) ack(entries ...repository.StreamedEvent) {
+	ids := make([]string, len(entries))
+	for i, entry := range entries {
+		ids[i] = entry.StreamID
+		metrics.CountEvents(entry.Event.WebsiteID, metrics.EventStored, 1)
+	}
+	// An unacknowledged entry is written again later, which is a no-op
+	if err := s.stream.Ack(context.Background(), ids...); err != nil {
+		s.logger.Error().Err(err).Int("events_count", len(ids)).Msg("Failed to acknowledge events")
+	}
+}
+
+func (s *EventService) deadLetter(entry repository.StreamedEvent, cause error) {
+	s.logger.Error().
+		Err(cause).
+		Str("stream_id", entry.StreamID).
+		Str("event_id", entry.Event.ID.String()).
+		Msg("Moving event to dead-letter stream")
+	metrics.CountEvents(entry.Event.WebsiteID, metrics.EventDeadLettered, 1)
+	if err := s.stream.DeadLetter(context.Background(), entry, cause); err != nil {
+		s.logger.Error().Err(err).Str("stream_id", entry.StreamID).Msg("Failed to dead-letter event")
+	}
+}
+
+func (s *EventService) databaseAvailable() bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return s.repo.HealthCheck(ctx) == nil
+}
+
+func (s *EventService) refreshBacklog() {
+	backlog, _, _, err := s.stream.Stats(s.ctx)
+	if err != nil {
+		return
+	}
+	s.backlog.Store(backlog)
+}
+
+// sleep waits for d and reports false when the service is shutting down
+func (s *EventService) sleep(d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
+
+// countEvents counts events with the given outcome per website
+func countEvents(events []models.Event, status string) {
+	perWebsite := make(map[string]int)
+	for i := range events {
+		perWebsite[events[i].WebsiteID]++
+	}
+	for websiteID, n := range perWebsite {
+		metrics.CountEvents(websiteID, status, n)
+	}
+}
+
+func nextBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > MaxRetryBackoff {
+		return MaxRetryBackoff
 	}
+	return d
 }
 
-// Shutdown gracefully shuts down the service
+// Shutdown gracefully shuts down the service. Events not yet stored stay in
+// the stream and are stored after the next start.
 func (s *EventService) Shutdown(timeout time.Duration) error {
 	s.logger.Info().Msg("Shutting down event service")
 
@@ -307,16 +640,40 @@ func (s *EventService) GetEvents(ctx context.Context, websiteID string, limit in
 	return s.repo.GetByWebsiteID(ctx, websiteID, limit, offset)
 }
 
-// GetStats returns service statistics
-func (s *EventService) GetStats() map[string]interface{} {
+// GetStats returns ingestion queue statistics
+func (s *EventService) GetStats(ctx context.Context) (map[string]interface{}, error) {
+	backlog, pending, dead, err := s.stream.Stats(ctx)
+	if err != nil {
+		return nil, err
+	}
 	return map[string]interface{}{
-		"event_queue_size": len(s.eventChan),
-		"event_queue_cap":  cap(s.eventChan),
-		"batch_queue_size": len(s.batchChan),
-		"batch_queue_cap":  cap(s.batchChan),
-		"batch_size":       BatchSize,
-		"flush_interval":   FlushInterval.String(),
+		"queue_backlog":  backlog,
+		"queue_pending":  pending,
+		"dead_letters":   dead,
+		"max_backlog":    s.maxBacklog,
+		"utilization":    s.QueueStatus(false).Utilization,
+		"batch_size":     BatchSize,
+		"flush_interval": FlushInterval.String(),
+	}, nil
+}
+
+// GetDeadLetters returns events that could not be stored after all retries
+func (s *EventService) GetDeadLetters(ctx context.Context, limit int) ([]repository.DeadLetter, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 100
+	}
+	return s.stream.ListDeadLetters(ctx, limit)
+}
+
+// RequeueDeadLetters moves dead letters back to the ingestion queue, e.g.
+// after fixing the cause of the failures
+func (s *EventService) RequeueDeadLetters(ctx context.Context, limit int) (int, error) {
+	if limit <= 0 || limit > 1000 {
+		limit = 100
 	}
+	requeued, err := s.stream.RequeueDeadLetters(ctx, limit)
+	s.logger.Info().Int("requeued", requeued).Msg("Requeued dead letter events")
+	return requeued, err
 }
 
 func (s *EventService) enrichEventData(ctx context.Context, event *models.Event) {
@@ -340,16 +697,33 @@ func (s *EventService) enrichEventData(ctx context.Context, event *models.Event)
 		}
 	}
 
-	// Get geolocation from IP
-	if (event.Country == nil || *event.Country == "") &&
-		(event.IPAddress != nil && *event.IPAddress != "") {
-
-		location := utils.GetLocationFromIP(*event.IPAddress)
+	// Get geolocation from the IP sent by server-side callers, or else the
+	// IP of the connection
+	connectionIP := utils.GetClientIP(ctx)
+	ip := connectionIP
+	if event.IPAddress != nil && *event.IPAddress != "" {
+		ip = *event.IPAddress
+	}
+	location := s.geo.Locate(ip)
+	if location.Country != "" {
 		if event.Country == nil || *event.Country == "" {
 			event.Country = &location.Country
 		}
-		if event.City == nil || *event.City == "" {
+		if (event.City == nil || *event.City == "") && location.City != "" {
 			event.City = &location.City
 		}
 	}
+
+	// Never trust a client supplied classification; the network comes from
+	// the connection, which unlike ip_address the client can't choose
+	network := location
+	if ip != connectionIP {
+		network = s.geo.Locate(connectionIP)
+	}
+	verdict := s.bots.Classify(event, network.ASN)
+	event.IsBot = verdict.IsBot
+	event.BotName = nil
+	if verdict.IsBot {
+		event.BotName = &verdict.Name
+	}
 }
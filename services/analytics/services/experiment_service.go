@@ -0,0 +1,271 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// ErrInvalidExperiment wraps validation failures of experiment definitions
+var ErrInvalidExperiment = errors.New("invalid experiment")
+
+type ExperimentService struct {
+	repo       *repository.ExperimentRepository
+	funnelRepo *repository.FunnelRepository
+	logger     zerolog.Logger
+}
+
+func NewExperimentService(repo *repository.ExperimentRepository, funnelRepo *repository.FunnelRepository, logger zerolog.Logger) *ExperimentService {
+	return &ExperimentService{
+		repo:       repo,
+		funnelRepo: funnelRepo,
+		logger:     logger,
+	}
+}
+
+func (s *ExperimentService) CreateExperiment(ctx context.Context, req *models.CreateExperimentRequest) (*models.Experiment, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("experiment_name", req.Name).
+		Msg("Creating experiment")
+
+	experiment := &models.Experiment{
+		Name:         req.Name,
+		Description:  req.Description,
+		WebsiteID:    req.WebsiteID,
+		UserID:       req.UserID,
+		Property:     req.Property,
+		Variants:     req.Variants,
+		Control:      req.Control,
+		GoalType:     req.GoalType,
+		GoalEvent:    req.GoalEvent,
+		GoalFunnelID: req.GoalFunnelID,
+		// Zero means the default effect
+		MinimumDetectableEffect: req.MinimumDetectableEffect,
+		Status:                  req.Status,
+	}
+	applyExperimentDefaults(experiment)
+	setExperimentStatus(experiment, experiment.Status, time.Now())
+	if err := s.validateExperiment(ctx, experiment); err != nil {
+		return nil, err
+	}
+
+	err := s.repo.Create(ctx, experiment)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to create experiment")
+		return nil, err
+	}
+
+	return experiment, nil
+}
+
+func (s *ExperimentService) GetExperiments(ctx context.Context, websiteID string) ([]models.Experiment, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting experiments")
+
+	return s.repo.GetByWebsiteID(ctx, websiteID)
+}
+
+func (s *ExperimentService) GetExperiment(ctx context.Context, experimentID uuid.UUID) (*models.Experiment, error) {
+	s.logger.Info().
+		Str("experiment_id", experimentID.String()).
+		Msg("Getting experiment")
+
+	return s.repo.GetByID(ctx, experimentID)
+}
+
+func (s *ExperimentService) UpdateExperiment(ctx context.Context, experimentID uuid.UUID, req *models.UpdateExperimentRequest) (*models.Experiment, error) {
+	s.logger.Info().
+		Str("experiment_id", experimentID.String()).
+		Msg("Updating experiment")
+
+	experiment, err := s.repo.GetByID(ctx, experimentID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Update fields if provided
+	if req.Name != nil {
+		experiment.Name = *req.Name
+	}
+	if req.Description != nil {
+		experiment.Description = req.Description
+	}
+	if req.Property != nil {
+		experiment.Property = *req.Property
+	}
+	if req.Variants != nil {
+		experiment.Variants = *req.Variants
+	}
+	if req.Control != nil {
+		experiment.Control = *req.Control
+	}
+	if req.GoalType != nil {
+		experiment.GoalType = *req.GoalType
+	}
+	if req.GoalEvent != nil {
+		experiment.GoalEvent = req.GoalEvent
+	}
+	if req.GoalFunnelID != nil {
+		experiment.GoalFunnelID = req.GoalFunnelID
+	}
+	if req.MinimumDetectableEffect != nil {
+		experiment.MinimumDetectableEffect = *req.MinimumDetectableEffect
+	}
+	if req.Status != nil {
+		setExperimentStatus(experiment, *req.Status, time.Now())
+	}
+	applyExperimentDefaults(experiment)
+	if err := s.validateExperiment(ctx, experiment); err != nil {
+		return nil, err
+	}
+
+	err = s.repo.Update(ctx, experimentID, experiment)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update experiment")
+		return nil, err
+	}
+
+	return experiment, nil
+}
+
+func (s *ExperimentService) DeleteExperiment(ctx context.Context, experimentID uuid.UUID) error {
+	s.logger.Info().
+		Str("experiment_id", experimentID.String()).
+		Msg("Deleting experiment")
+
+	return s.repo.Delete(ctx, experimentID)
+}
+
+// GetExperimentResults evaluates every variant over the range. A nil range
+// covers the experiment's run, from its start (or creation) until it was
+// stopped or now.
+func (s *ExperimentService) GetExperimentResults(ctx context.Context, experimentID uuid.UUID, dateRange *models.DateRange) (*models.ExperimentResults, error) {
+	experiment, err := s.repo.GetByID(ctx, experimentID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get experiment: %w", err)
+	}
+
+	if dateRange == nil {
+		dateRange = experimentRunRange(experiment, time.Now())
+	}
+
+	s.logger.Info().
+		Str("experiment_id", experimentID.String()).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
+		Msg("Getting experiment results")
+
+	var goalFunnel *models.Funnel
+	if experiment.GoalType == models.ExperimentGoalFunnel {
+		if experiment.GoalFunnelID == nil {
+			return nil, fmt.Errorf("%w: goal funnel was deleted", ErrInvalidExperiment)
+		}
+		goalFunnel, err = s.funnelRepo.GetByID(ctx, *experiment.GoalFunnelID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get goal funnel: %w", err)
+		}
+	}
+
+	evaluator, err := utils.NewExperimentEvaluator(experiment, goalFunnel, dateRange.From, dateRange.To)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExperiment, err)
+	}
+
+	counts := make(map[string]utils.VariantCounts, len(experiment.Variants))
+	err = s.repo.StreamAssignedVisitorEvents(ctx, experiment.WebsiteID, experiment.Property, dateRange.From, dateRange.To, dateRange.To.Add(evaluator.Horizon()),
+		func(visitorID string, events []models.Event) error {
+			variant, converted := evaluator.Evaluate(visitorID, events)
+			if variant == "" {
+				return nil
+			}
+			c := counts[variant]
+			c.Visitors++
+			if converted {
+				c.Conversions++
+			}
+			counts[variant] = c
+			return nil
+		})
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate experiment: %w", err)
+	}
+
+	results := utils.BuildExperimentResults(experiment, counts)
+	results.DateRange = dateRange.Label()
+	return &results, nil
+}
+
+// validateExperiment checks the definition and that a goal funnel exists
+// and belongs to the experiment's website
+func (s *ExperimentService) validateExperiment(ctx context.Context, experiment *models.Experiment) error {
+	if err := utils.ValidateExperiment(experiment); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidExperiment, err)
+	}
+
+	if experiment.GoalType == models.ExperimentGoalFunnel {
+		funnel, err := s.funnelRepo.GetByID(ctx, *experiment.GoalFunnelID)
+		if err != nil {
+			return fmt.Errorf("%w: goal funnel not found", ErrInvalidExperiment)
+		}
+		if funnel.WebsiteID != experiment.WebsiteID {
+			return fmt.Errorf("%w: goal funnel does not belong to website", ErrInvalidExperiment)
+		}
+	}
+
+	return nil
+}
+
+// applyExperimentDefaults fills in the control, effect and status
+func applyExperimentDefaults(experiment *models.Experiment) {
+	if experiment.Control == "" && len(experiment.Variants) > 0 {
+		experiment.Control = experiment.Variants[0].Key
+	}
+	if experiment.MinimumDetectableEffect == 0 {
+		experiment.MinimumDetectableEffect = models.DefaultMinimumDetectableEffect
+	}
+	if experiment.Status == "" {
+		experiment.Status = models.ExperimentStatusDraft
+	}
+}
+
+// setExperimentStatus records when the experiment started and stopped.
+// Restarting a stopped experiment keeps its original start.
+func setExperimentStatus(experiment *models.Experiment, status string, now time.Time) {
+	switch status {
+	case models.ExperimentStatusRunning:
+		if experiment.StartedAt == nil {
+			experiment.StartedAt = &now
+		}
+		experiment.EndedAt = nil
+	case models.ExperimentStatusStopped:
+		if experiment.EndedAt == nil || experiment.Status != models.ExperimentStatusStopped {
+			experiment.EndedAt = &now
+		}
+	}
+	experiment.Status = status
+}
+
+// experimentRunRange returns the range the experiment has been running for
+func experimentRunRange(experiment *models.Experiment, now time.Time) *models.DateRange {
+	from := experiment.CreatedAt
+	if experiment.StartedAt != nil {
+		from = *experiment.StartedAt
+	}
+	to := now
+	if experiment.EndedAt != nil {
+		to = *experiment.EndedAt
+	}
+	if !from.Before(to) {
+		to = from.Add(time.Second)
+	}
+	return &models.DateRange{From: from.UTC(), To: to.UTC(), Timezone: "UTC"}
+}
@@ -0,0 +1,331 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// maxExportReportRows caps the rows of report exports; raw event exports
+	// are not capped
+	maxExportReportRows = 100000
+	// DefaultExportWorkers is the number of background exports run at once
+	DefaultExportWorkers = 2
+	// ExportJobTTL is how long background export jobs are reported on
+	ExportJobTTL = 7 * 24 * time.Hour
+)
+
+// ErrExportUnavailable is returned for background exports when no object
+// store is configured
+var ErrExportUnavailable = errors.New("background exports are not configured")
+
+type ExportService struct {
+	repo   *repository.MainAnalyticsRepository
+	jobs   *repository.ExportJobStore
+	store  repository.ObjectStore
+	logger zerolog.Logger
+
+	// slots limits the background exports running at once
+	slots chan struct{}
+
+	// Shutdown control
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewExportService returns the export service; background exports are
+// refused when store is nil
+func NewExportService(repo *repository.MainAnalyticsRepository, jobs *repository.ExportJobStore, store repository.ObjectStore, workers int, logger zerolog.Logger) *ExportService {
+	if workers <= 0 {
+		workers = DefaultExportWorkers
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ExportService{
+		repo:   repo,
+		jobs:   jobs,
+		store:  store,
+		logger: logger,
+		slots:  make(chan struct{}, workers),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Export writes the dataset of req to w in req's format and returns the
+// number of rows written
+func (s *ExportService) Export(ctx context.Context, req models.ExportRequest, w io.Writer) (int64, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("dataset", req.Dataset).
+		Str("format", req.Format).
+		Time("from", req.DateRange.From).
+		Time("to", req.DateRange.To).
+		Msg("Exporting analytics data")
+
+	var rows int64
+	switch req.Dataset {
+	case models.ExportDatasetEvents, models.ExportDatasetCustomEvents:
+		writer, err := utils.NewExportWriter(w, req.Format, repository.EventExportColumns)
+		if err != nil {
+			return 0, err
+		}
+		err = s.repo.StreamEvents(ctx, req.WebsiteID, req.Dataset, req.DateRange, req.Filters, func(values []interface{}) error {
+			rows++
+			return writer.Write(values)
+		})
+		if err != nil {
+			return rows, err
+		}
+		return rows, writer.Close()
+	}
+
+	columns, values, err := s.report(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	writer, err := utils.NewExportWriter(w, req.Format, columns)
+	if err != nil {
+		return 0, err
+	}
+	for _, row := range values {
+		if err := writer.Write(row); err != nil {
+			return rows, err
+		}
+		rows++
+	}
+	return rows, writer.Close()
+}
+
+// report returns the columns and rows of a report dataset
+func (s *ExportService) report(ctx context.Context, req models.ExportRequest) ([]models.ExportColumn, [][]interface{}, error) {
+	var rows [][]interface{}
+	switch req.Dataset {
+	case models.ExportDatasetTopPages:
+		pages, err := s.repo.GetTopPages(ctx, req.WebsiteID, req.DateRange, req.Filters, maxExportReportRows)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, page := range pages {
+			rows = append(rows, []interface{}{page.Page, page.Views, page.Unique, page.BounceRate, page.AvgTime, page.ExitRate, page.EntryRate})
+		}
+		return []models.ExportColumn{
+			{Name: "page", Type: models.ExportString},
+			{Name: "views", Type: models.ExportInt},
+			{Name: "unique", Type: models.ExportInt},
+			{Name: "bounce_rate", Type: models.ExportFloat},
+			{Name: "avg_time", Type: models.ExportInt},
+			{Name: "exit_rate", Type: models.ExportFloat},
+			{Name: "entry_rate", Type: models.ExportFloat},
+		}, rows, nil
+
+	case models.ExportDatasetTopReferrers:
+		referrers, err := s.repo.GetTopReferrers(ctx, req.WebsiteID, req.DateRange, req.Filters, maxExportReportRows)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, referrer := range referrers {
+			rows = append(rows, []interface{}{referrer.Referrer, referrer.Views, referrer.Unique, referrer.BounceRate})
+		}
+		return []models.ExportColumn{
+			{Name: "referrer", Type: models.ExportString},
+			{Name: "views", Type: models.ExportInt},
+			{Name: "unique", Type: models.ExportInt},
+			{Name: "bounce_rate", Type: models.ExportFloat},
+		}, rows, nil
+
+	case models.ExportDatasetTopSources:
+		sources, err := s.repo.GetTopSources(ctx, req.WebsiteID, req.DateRange, req.Filters, maxExportReportRows)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, source := range sources {
+			rows = append(rows, []interface{}{source.Source, source.Views, source.UniqueVisitors, source.BounceRate})
+		}
+		return []models.ExportColumn{
+			{Name: "source", Type: models.ExportString},
+			{Name: "views", Type: models.ExportInt},
+			{Name: "unique_visitors", Type: models.ExportInt},
+			{Name: "bounce_rate", Type: models.ExportFloat},
+		}, rows, nil
+
+	case models.ExportDatasetTopCountries:
+		countries, err := s.repo.GetTopCountries(ctx, req.WebsiteID, req.DateRange, req.Filters, maxExportReportRows)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, country := range countries {
+			rows = append(rows, []interface{}{country.Country, country.Views, country.Unique, country.BounceRate})
+		}
+		return []models.ExportColumn{
+			{Name: "country", Type: models.ExportString},
+			{Name: "views", Type: models.ExportInt},
+			{Name: "unique", Type: models.ExportInt},
+			{Name: "bounce_rate", Type: models.ExportFloat},
+		}, rows, nil
+
+	case models.ExportDatasetDailyStats:
+		days, err := s.repo.GetDailyStats(ctx, req.WebsiteID, req.DateRange, req.Filters)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, day := range days {
+			rows = append(rows, []interface{}{day.Date, day.Views, day.Unique})
+		}
+		return []models.ExportColumn{
+			{Name: "date", Type: models.ExportTime},
+			{Name: "views", Type: models.ExportInt},
+			{Name: "unique", Type: models.ExportInt},
+		}, rows, nil
+
+	case models.ExportDatasetHourlyStats:
+		hours, err := s.repo.GetHourlyStats(ctx, req.WebsiteID, req.DateRange, req.Filters)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, hour := range hours {
+			rows = append(rows, []interface{}{hour.Timestamp, hour.Views, hour.Unique})
+		}
+		return []models.ExportColumn{
+			{Name: "timestamp", Type: models.ExportTime},
+			{Name: "views", Type: models.ExportInt},
+			{Name: "unique", Type: models.ExportInt},
+		}, rows, nil
+	}
+
+	return nil, nil, fmt.Errorf("unsupported export dataset %q", req.Dataset)
+}
+
+// CreateJob queues req to be exported to the object store in the background
+func (s *ExportService) CreateJob(ctx context.Context, req models.ExportRequest) (*models.ExportJob, error) {
+	if s.store == nil {
+		return nil, ErrExportUnavailable
+	}
+
+	job := &models.ExportJob{
+		ID:            uuid.New().String(),
+		ExportRequest: req,
+		Status:        models.ExportJobQueued,
+		CreatedAt:     time.Now().UTC(),
+	}
+	if err := s.jobs.Save(ctx, job); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().
+		Str("job_id", job.ID).
+		Str("website_id", req.WebsiteID).
+		Str("dataset", req.Dataset).
+		Str("format", req.Format).
+		Msg("Export job queued")
+
+	s.wg.Add(1)
+	go s.runJob(*job)
+	return job, nil
+}
+
+// GetJob returns a background export
+func (s *ExportService) GetJob(ctx context.Context, id string) (*models.ExportJob, error) {
+	return s.jobs.Get(ctx, id)
+}
+
+// OpenJobResult opens the file of a completed background export
+func (s *ExportService) OpenJobResult(ctx context.Context, job *models.ExportJob) (io.ReadCloser, error) {
+	if s.store == nil {
+		return nil, ErrExportUnavailable
+	}
+	return s.store.Get(ctx, job.ObjectKey)
+}
+
+func (s *ExportService) runJob(job models.ExportJob) {
+	defer s.wg.Done()
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-s.ctx.Done():
+		s.finishJob(&job, 0, s.ctx.Err())
+		return
+	}
+
+	started := time.Now().UTC()
+	job.Status = models.ExportJobRunning
+	job.StartedAt = &started
+	s.saveJob(&job)
+
+	// The export is uploaded as it is written
+	key := fmt.Sprintf("exports/%s/%s.%s", job.WebsiteID, job.ID, job.Format)
+	reader, writer := io.Pipe()
+	exported := make(chan error, 1)
+	var rows int64
+	go func() {
+		var err error
+		rows, err = s.Export(s.ctx, job.ExportRequest, writer)
+		writer.CloseWithError(err)
+		exported <- err
+	}()
+
+	err := s.store.Put(s.ctx, key, reader, models.ExportContentTypes[job.Format])
+	// Unblocks the export when the upload failed
+	reader.CloseWithError(err)
+	if exportErr := <-exported; exportErr != nil {
+		err = exportErr
+	}
+
+	if err == nil {
+		job.ObjectKey = key
+	}
+	s.finishJob(&job, rows, err)
+}
+
+func (s *ExportService) finishJob(job *models.ExportJob, rows int64, err error) {
+	completed := time.Now().UTC()
+	job.CompletedAt = &completed
+	job.Rows = rows
+	if err != nil {
+		job.Status = models.ExportJobFailed
+		job.Error = err.Error()
+		s.logger.Error().Err(err).Str("job_id", job.ID).Msg("Export job failed")
+	} else {
+		job.Status = models.ExportJobCompleted
+		s.logger.Info().Str("job_id", job.ID).Int64("rows", rows).Msg("Export job completed")
+	}
+	s.saveJob(job)
+}
+
+func (s *ExportService) saveJob(job *models.ExportJob) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.jobs.Save(ctx, job); err != nil {
+		s.logger.Error().Err(err).Str("job_id", job.ID).Msg("Failed to save export job")
+	}
+}
+
+// Shutdown cancels running background exports, which are reported as
+// failed, and waits for them to stop
+func (s *ExportService) Shutdown(timeout time.Duration) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out waiting for export jobs")
+	}
+}
@@ -0,0 +1,202 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"math"
+	"sort"
+	"time"
+)
+
+// funnelReport accumulates evaluated visitor progress into funnel statistics
+type funnelReport struct {
+	steps    int
+	location *time.Location
+
+	reached     []int
+	stepSeconds []float64 // time spent on step i before reaching i+1
+	conversions int
+	convertSecs float64
+	abandoned   int
+	abandonSecs float64
+	byEntryDate map[string]*funnelDay
+
+	// value is the revenue of conversions, in currency
+	value    float64
+	currency string
+}
+
+type funnelDay struct {
+	starts      int
+	conversions int
+	convertSecs float64
+}
+
+func newFunnelReport(steps int, location *time.Location) *funnelReport {
+	return &funnelReport{
+		steps:       steps,
+		location:    location,
+		reached:     make([]int, steps),
+		stepSeconds: make([]float64, steps),
+		byEntryDate: make(map[string]*funnelDay),
+	}
+}
+
+// add counts a visitor's progress; value is the revenue of a conversion
+func (r *funnelReport) add(progress utils.FunnelProgress, value float64) {
+	completed := progress.StepsCompleted()
+	if completed == 0 {
+		return
+	}
+
+	for i := 0; i < completed; i++ {
+		r.reached[i]++
+		if i+1 < completed {
+			r.stepSeconds[i] += progress.StepTimes[i+1].Sub(progress.StepTimes[i]).Seconds()
+		}
+	}
+
+	date := progress.StartedAt().In(r.location).Format("2006-01-02")
+	day, ok := r.byEntryDate[date]
+	if !ok {
+		day = &funnelDay{}
+		r.byEntryDate[date] = day
+	}
+	day.starts++
+
+	seconds := progress.Duration().Seconds()
+	if completed == r.steps {
+		r.conversions++
+		r.convertSecs += seconds
+		r.value += value
+		day.conversions++
+		day.convertSecs += seconds
+	} else {
+		r.abandoned++
+		r.abandonSecs += seconds
+	}
+}
+
+func (r *funnelReport) starts() int {
+	return r.reached[0]
+}
+
+func (r *funnelReport) analytics(funnel *models.Funnel) *models.FunnelAnalytics {
+	conversionRate := percentage(r.conversions, r.starts())
+
+	analytics := &models.FunnelAnalytics{
+		FunnelID:         funnel.ID,
+		WebsiteID:        funnel.WebsiteID,
+		Date:             time.Now().Format("2006-01-02"),
+		TotalStarts:      r.starts(),
+		TotalConversions: r.conversions,
+		ConversionRate:   conversionRate,
+	}
+	if r.starts() > 0 {
+		analytics.DropOffRate = 100.0 - conversionRate
+		analytics.AbandonmentRate = 100.0 - conversionRate
+	}
+	if r.conversions > 0 {
+		avg := int(r.convertSecs / float64(r.conversions))
+		analytics.AvgTimeToConvert = &avg
+	}
+	if r.abandoned > 0 {
+		avg := int(r.abandonSecs / float64(r.abandoned))
+		analytics.AvgTimeToAbandon = &avg
+	}
+	// Funnels without orders have no value
+	if r.value > 0 {
+		total := math.Round(r.value*100) / 100
+		avg := math.Round(r.value/float64(r.conversions)*100) / 100
+		analytics.TotalValue = &total
+		analytics.AvgValue = &avg
+		analytics.Currency = r.currency
+	}
+
+	return analytics
+}
+
+func (r *funnelReport) stepAnalytics(funnel *models.Funnel) []models.FunnelStepAnalytics {
+	steps := make([]models.FunnelStep, len(funnel.Steps))
+	copy(steps, funnel.Steps)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].Order < steps[j].Order })
+
+	stepAnalytics := make([]models.FunnelStepAnalytics, len(steps))
+	for i, step := range steps {
+		var conversionToNext float64
+		var avgTimeOnStep *float64
+		if i < len(steps)-1 {
+			conversionToNext = percentage(r.reached[i+1], r.reached[i])
+			if r.reached[i+1] > 0 {
+				avg := r.stepSeconds[i] / float64(r.reached[i+1])
+				avgTimeOnStep = &avg
+			}
+		} else if r.reached[i] > 0 {
+			// Reaching the last step is the conversion
+			conversionToNext = 100
+		}
+
+		stepAnalytics[i] = models.FunnelStepAnalytics{
+			StepID:          step.ID,
+			StepName:        step.Name,
+			StepOrder:       step.Order,
+			VisitorsReached: r.reached[i],
+			ConversionRate:  conversionToNext,
+			DropOffRate:     100 - conversionToNext,
+			AvgTimeOnStep:   avgTimeOnStep,
+		}
+	}
+
+	return stepAnalytics
+}
+
+func (r *funnelReport) dailyPerformance() []models.DailyFunnelPerformance {
+	performance := make([]models.DailyFunnelPerformance, 0, len(r.byEntryDate))
+	for _, date := range r.entryDates() {
+		day := r.byEntryDate[date]
+		performance = append(performance, models.DailyFunnelPerformance{
+			Date:           date,
+			TotalStarts:    day.starts,
+			Conversions:    day.conversions,
+			ConversionRate: percentage(day.conversions, day.starts),
+		})
+	}
+	return performance
+}
+
+// cohorts groups visitors by the day they entered the funnel
+func (r *funnelReport) cohorts() []models.FunnelCohortData {
+	cohorts := make([]models.FunnelCohortData, 0, len(r.byEntryDate))
+	for _, date := range r.entryDates() {
+		day := r.byEntryDate[date]
+		var avgTimeToConvert int
+		if day.conversions > 0 {
+			avgTimeToConvert = int(day.convertSecs / float64(day.conversions))
+		}
+		cohorts = append(cohorts, models.FunnelCohortData{
+			CohortDate:       date,
+			CohortSize:       day.starts,
+			Conversions:      day.conversions,
+			ConversionRate:   percentage(day.conversions, day.starts),
+			AvgTimeToConvert: avgTimeToConvert,
+		})
+	}
+	return cohorts
+}
+
+// entryDates returns the entry dates, most recent first
+func (r *funnelReport) entryDates() []string {
+	dates := make([]string, 0, len(r.byEntryDate))
+	for date := range r.byEntryDate {
+		dates = append(dates, date)
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(dates)))
+	return dates
+}
+
+func percentage(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(part) * 100 / float64(total)
+}
@@ -3,8 +3,10 @@ package services
 import (
 	"analytics-app/models"
 	"analytics-app/repository"
+	"analytics-app/utils"
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/go-redis/redis/v8"
 	"github.com/google/uuid"
@@ -12,14 +14,16 @@ import (
 )
 
 type FunnelService struct {
-	repo   *repository.FunnelRepository
-	logger zerolog.Logger
+	repo    *repository.FunnelRepository
+	revenue *repository.RevenueRepository
+	logger  zerolog.Logger
 }
 
-func NewFunnelService(repo *repository.FunnelRepository, logger zerolog.Logger, redisClient *redis.Client) *FunnelService {
+func NewFunnelService(repo *repository.FunnelRepository, revenue *repository.RevenueRepository, logger zerolog.Logger, redisClient *redis.Client) *FunnelService {
 	return &FunnelService{
-		repo:   repo,
-		logger: logger,
+		repo:    repo,
+		revenue: revenue,
+		logger:  logger,
 	}
 }
 
@@ -35,8 +39,16 @@ func (s *FunnelService) CreateFunnel(ctx context.Context, req *models.CreateFunn
 		WebsiteID:   req.WebsiteID,
 		UserID:      req.UserID,
 		Steps:       req.Steps,
-		IsActive:    req.IsActive,
+		StepOrder:   req.StepOrder,
+		// Zero means the default window
+		ConversionWindowHours: req.ConversionWindowHours,
+		IsActive:              req.IsActive,
 	}
+	// Validate before defaults so an invalid window is rejected, not replaced
+	if err := utils.ValidateFunnel(funnel); err != nil {
+		return nil, fmt.Errorf("invalid funnel: %w", err)
+	}
+	applyFunnelDefaults(funnel)
 
 	err := s.repo.Create(ctx, funnel)
 	if err != nil {
@@ -84,9 +96,19 @@ func (s *FunnelService) UpdateFunnel(ctx context.Context, funnelID uuid.UUID, re
 	if req.Steps != nil {
 		funnel.Steps = *req.Steps
 	}
+	if req.StepOrder != nil {
+		funnel.StepOrder = *req.StepOrder
+	}
+	if req.ConversionWindowHours != nil {
+		funnel.ConversionWindowHours = *req.ConversionWindowHours
+	}
 	if req.IsActive != nil {
 		funnel.IsActive = *req.IsActive
 	}
+	if err := utils.ValidateFunnel(funnel); err != nil {
+		return nil, fmt.Errorf("invalid funnel: %w", err)
+	}
+	applyFunnelDefaults(funnel)
 
 	err = s.repo.Update(ctx, funnelID, funnel)
 	if err != nil {
@@ -121,50 +143,128 @@ func (s *FunnelService) TrackFunnelEvent(ctx context.Context, event *models.Funn
 		return fmt.Errorf("invalid funnel: funnel does not belong to website")
 	}
 
-	// Store the event in the database
+	// Kept for older trackers only; funnel analytics are evaluated from the
+	// events tables and never trust the client reported step
 	if err := s.repo.CreateFunnelEvent(ctx, event); err != nil {
 		return err
 	}
 
-	// Funnel events are now handled client-side by workflow-tracker.js
-	// No need to emit events to workflow service
-
 	return nil
 }
 
-func (s *FunnelService) GetFunnelAnalytics(ctx context.Context, funnelID uuid.UUID, days int) (*models.FunnelAnalytics, error) {
+func (s *FunnelService) GetFunnelAnalytics(ctx context.Context, funnelID uuid.UUID, dateRange models.DateRange) (*models.FunnelAnalytics, error) {
 	s.logger.Info().
 		Str("funnel_id", funnelID.String()).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
 		Msg("Getting funnel analytics")
 
-	analytics, err := s.repo.GetFunnelAnalytics(ctx, funnelID, days)
+	funnel, err := s.repo.GetByID(ctx, funnelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funnel: %w", err)
+	}
+
+	report, err := s.evaluateFunnel(ctx, funnel, dateRange)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get funnel analytics: %w", err)
 	}
 
-	return analytics, nil
+	return report.analytics(funnel), nil
 }
 
-func (s *FunnelService) GetDetailedFunnelAnalytics(ctx context.Context, funnelID uuid.UUID, days int) (*models.DetailedFunnelAnalytics, error) {
+func (s *FunnelService) GetDetailedFunnelAnalytics(ctx context.Context, funnelID uuid.UUID, dateRange models.DateRange) (*models.DetailedFunnelAnalytics, error) {
 	s.logger.Info().
 		Str("funnel_id", funnelID.String()).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
 		Msg("Getting detailed funnel analytics")
 
-	analytics, err := s.repo.GetDetailedFunnelAnalytics(ctx, funnelID, days)
+	funnel, err := s.repo.GetByID(ctx, funnelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funnel: %w", err)
+	}
+
+	report, err := s.evaluateFunnel(ctx, funnel, dateRange)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get detailed funnel analytics: %w", err)
 	}
 
-	return analytics, nil
+	return &models.DetailedFunnelAnalytics{
+		FunnelID:         funnelID,
+		WebsiteID:        funnel.WebsiteID,
+		StepAnalytics:    report.stepAnalytics(funnel),
+		DailyPerformance: report.dailyPerformance(),
+		CohortData:       report.cohorts(),
+		DateRange:        dateRange.Days(),
+	}, nil
 }
 
-func (s *FunnelService) CompareFunnels(ctx context.Context, websiteID string, funnelIDs []string, days int) ([]models.FunnelComparisonResult, error) {
+// evaluateFunnel replays the website's events through the funnel definition.
+// Visitors must enter the funnel inside the range, but may complete it up to
+// one conversion window later. The value of a conversion is the revenue of
+// the orders the visitor placed within the window after entering.
+func (s *FunnelService) evaluateFunnel(ctx context.Context, funnel *models.Funnel, dateRange models.DateRange) (*funnelReport, error) {
+	evaluator, err := utils.NewFunnelEvaluator(funnel, dateRange.From, dateRange.To)
+	if err != nil {
+		return nil, err
+	}
+	until := dateRange.To.Add(evaluator.Window())
+
+	converter, orders, err := s.visitorOrders(ctx, funnel.WebsiteID, dateRange.From, until)
+	if err != nil {
+		return nil, err
+	}
+
+	report := newFunnelReport(evaluator.StepCount(), dateRange.Location())
+	report.currency = converter.Base()
+	err = s.repo.StreamVisitorEvents(ctx, funnel.WebsiteID, dateRange.From, until, evaluator.EventTypes(),
+		func(visitorID string, events []models.Event) error {
+			progress := evaluator.Evaluate(visitorID, events)
+			var value float64
+			if progress.StepsCompleted() == evaluator.StepCount() {
+				started := progress.StartedAt()
+				value = converter.OrdersValue(orders[visitorID], started, started.Add(evaluator.Window()))
+			}
+			report.add(progress, value)
+			return nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
+
+// visitorOrders returns the website's orders placed in [from, to) by visitor,
+// and the converter to its base currency
+func (s *FunnelService) visitorOrders(ctx context.Context, websiteID string, from, to time.Time) (*utils.CurrencyConverter, map[string][]models.Order, error) {
+	settings, err := s.revenue.GetSettings(ctx, websiteID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get revenue settings: %w", err)
+	}
+	rates, err := s.revenue.GetExchangeRates(ctx)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get exchange rates: %w", err)
+	}
+
+	orders, err := s.revenue.GetOrders(ctx, websiteID, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get orders: %w", err)
+	}
+	byVisitor := make(map[string][]models.Order)
+	for _, order := range orders {
+		byVisitor[order.VisitorID] = append(byVisitor[order.VisitorID], order)
+	}
+
+	return currencyConverter(settings, rates), byVisitor, nil
+}
+
+func (s *FunnelService) CompareFunnels(ctx context.Context, websiteID string, funnelIDs []string, dateRange models.DateRange) ([]models.FunnelComparisonResult, error) {
 	s.logger.Info().
 		Str("website_id", websiteID).
 		Strs("funnel_ids", funnelIDs).
-		Int("days", days).
+		Time("from", dateRange.From).
+		Time("to", dateRange.To).
 		Msg("Comparing funnels")
 
 	var results []models.FunnelComparisonResult
@@ -184,11 +284,12 @@ func (s *FunnelService) CompareFunnels(ctx context.Context, websiteID string, fu
 		}
 
 		// Get analytics
-		analytics, err := s.repo.GetFunnelAnalytics(ctx, funnelID, days)
+		report, err := s.evaluateFunnel(ctx, funnel, dateRange)
 		if err != nil {
 			s.logger.Error().Err(err).Str("funnel_id", funnelIDStr).Msg("Failed to get funnel analytics")
 			continue
 		}
+		analytics := report.analytics(funnel)
 
 		// Calculate performance score (weighted combination of metrics)
 		performanceScore := s.calculatePerformanceScore(analytics)
@@ -243,3 +344,13 @@ func (s *FunnelService) calculatePerformanceScore(analytics *models.FunnelAnalyt
 
 	return conversionScore + dropOffScore + timeScore
 }
+
+// applyFunnelDefaults fills in the step order and conversion window
+func applyFunnelDefaults(funnel *models.Funnel) {
+	if funnel.StepOrder == "" {
+		funnel.StepOrder = models.FunnelStepOrderStrict
+	}
+	if funnel.ConversionWindowHours == 0 {
+		funnel.ConversionWindowHours = models.DefaultConversionWindowHours
+	}
+}
@@ -0,0 +1,126 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"context"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+type GoalService struct {
+	repo   *repository.GoalRepository
+	logger zerolog.Logger
+}
+
+func NewGoalService(repo *repository.GoalRepository, logger zerolog.Logger) *GoalService {
+	return &GoalService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+func (s *GoalService) CreateGoal(ctx context.Context, req *models.CreateGoalRequest) (*models.Goal, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("goal_name", req.Name).
+		Msg("Creating goal")
+
+	goal := &models.Goal{
+		Name:          req.Name,
+		Description:   req.Description,
+		WebsiteID:     req.WebsiteID,
+		UserID:        req.UserID,
+		Type:          req.Type,
+		PagePattern:   req.PagePattern,
+		EventName:     req.EventName,
+		PropertyKey:   req.PropertyKey,
+		PropertyValue: req.PropertyValue,
+		Value:         req.Value,
+	}
+	if err := utils.ValidateGoal(goal); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGoal, err)
+	}
+
+	err := s.repo.Create(ctx, goal)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to create goal")
+		return nil, err
+	}
+
+	return goal, nil
+}
+
+func (s *GoalService) GetGoals(ctx context.Context, websiteID string) ([]models.Goal, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting goals")
+
+	return s.repo.GetByWebsiteID(ctx, websiteID)
+}
+
+func (s *GoalService) GetGoal(ctx context.Context, goalID uuid.UUID) (*models.Goal, error) {
+	s.logger.Info().
+		Str("goal_id", goalID.String()).
+		Msg("Getting goal")
+
+	return s.repo.GetByID(ctx, goalID)
+}
+
+func (s *GoalService) UpdateGoal(ctx context.Context, goalID uuid.UUID, req *models.UpdateGoalRequest) (*models.Goal, error) {
+	s.logger.Info().
+		Str("goal_id", goalID.String()).
+		Msg("Updating goal")
+
+	goal, err := s.repo.GetByID(ctx, goalID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		goal.Name = *req.Name
+	}
+	if req.Description != nil {
+		goal.Description = req.Description
+	}
+	if req.Type != nil {
+		goal.Type = *req.Type
+	}
+	if req.PagePattern != nil {
+		goal.PagePattern = req.PagePattern
+	}
+	if req.EventName != nil {
+		goal.EventName = req.EventName
+	}
+	if req.PropertyKey != nil {
+		goal.PropertyKey = req.PropertyKey
+	}
+	if req.PropertyValue != nil {
+		goal.PropertyValue = req.PropertyValue
+	}
+	if req.Value != nil {
+		goal.Value = req.Value
+	}
+	if err := utils.ValidateGoal(goal); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidGoal, err)
+	}
+
+	err = s.repo.Update(ctx, goalID, goal)
+	if err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update goal")
+		return nil, err
+	}
+
+	return goal, nil
+}
+
+func (s *GoalService) DeleteGoal(ctx context.Context, goalID uuid.UUID) error {
+	s.logger.Info().
+		Str("goal_id", goalID.String()).
+		Msg("Deleting goal")
+
+	return s.repo.Delete(ctx, goalID)
+}
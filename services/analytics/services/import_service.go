@@ -0,0 +1,293 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+// maxConcurrentImports is the number of imports run at once; later imports
+// wait for a slot
+const maxConcurrentImports = 2
+
+var (
+	// ErrInvalidImport is returned for files that are not an export of the
+	// given source
+	ErrInvalidImport = errors.New("invalid import file")
+	// ErrImportRunning is returned when rolling back an unfinished import
+	ErrImportRunning = errors.New("import is still running")
+)
+
+type ImportService struct {
+	repo   *repository.ImportRepository
+	events *repository.EventRepository
+	logger zerolog.Logger
+
+	// slots limits the imports running at once
+	slots chan struct{}
+
+	// Shutdown control
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewImportService(repo *repository.ImportRepository, events *repository.EventRepository, logger zerolog.Logger) *ImportService {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ImportService{
+		repo:   repo,
+		events: events,
+		logger: logger,
+		slots:  make(chan struct{}, maxConcurrentImports),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Import stores an export of source read from r and imports it into
+// websiteID in the background. The returned import reports its progress.
+func (s *ImportService) Import(ctx context.Context, websiteID, source, filename string, r io.Reader) (*models.Import, error) {
+	file, err := os.CreateTemp("", "analytics-import-*")
+	if err != nil {
+		return nil, err
+	}
+	started := false
+	defer func() {
+		if !started {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}()
+
+	if _, err := io.Copy(file, r); err != nil {
+		return nil, fmt.Errorf("failed to read import file: %w", err)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	// Files without the columns of the source are refused right away
+	importer, err := utils.NewImporter(source, file, websiteID)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImport, err)
+	}
+
+	imp := &models.Import{WebsiteID: websiteID, Source: source, Filename: filename}
+	if err := s.repo.Create(ctx, imp); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().
+		Str("import_id", imp.ID.String()).
+		Str("website_id", websiteID).
+		Str("source", source).
+		Str("filename", filename).
+		Msg("Import started")
+
+	started = true
+	s.wg.Add(1)
+	go func() {
+		defer os.Remove(file.Name())
+		defer file.Close()
+		s.run(*imp, importer)
+	}()
+	return imp, nil
+}
+
+// ListImports returns the imports of a website, newest first
+func (s *ImportService) ListImports(ctx context.Context, websiteID string) ([]models.Import, error) {
+	return s.repo.List(ctx, websiteID)
+}
+
+func (s *ImportService) GetImport(ctx context.Context, websiteID string, importID uuid.UUID) (*models.Import, error) {
+	return s.repo.Get(ctx, websiteID, importID)
+}
+
+// Rollback deletes the events and aggregates of a finished import. Rolling
+// back an import twice is a no-op.
+func (s *ImportService) Rollback(ctx context.Context, websiteID string, importID uuid.UUID) (*models.Import, error) {
+	imp, err := s.repo.Get(ctx, websiteID, importID)
+	if err != nil {
+		return nil, err
+	}
+	switch imp.Status {
+	case models.ImportRunning:
+		return nil, ErrImportRunning
+	case models.ImportRolledBack:
+		return imp, nil
+	}
+
+	if err := s.repo.Rollback(ctx, imp); err != nil {
+		return nil, err
+	}
+
+	s.logger.Info().
+		Str("import_id", imp.ID.String()).
+		Str("website_id", websiteID).
+		Int64("events", imp.EventsImported).
+		Int64("aggregates", imp.AggregatesImported).
+		Msg("Import rolled back")
+	return imp, nil
+}
+
+func (s *ImportService) run(imp models.Import, importer utils.Importer) {
+	defer s.wg.Done()
+
+	select {
+	case s.slots <- struct{}{}:
+		defer func() { <-s.slots }()
+	case <-s.ctx.Done():
+		s.finish(&imp, s.ctx.Err())
+		return
+	}
+
+	s.finish(&imp, s.importRecords(&imp, importer))
+}
+
+// importRecords stores the records of importer in batches, counting them on
+// imp. Batches stored before a failure stay and can be rolled back.
+func (s *ImportService) importRecords(imp *models.Import, importer utils.Importer) error {
+	events := make([]models.Event, 0, repository.MaxBatchSize)
+	aggregates := make([]models.ImportedDailyStat, 0, repository.MaxBatchSize)
+
+	flushEvents := func() error {
+		if len(events) == 0 {
+			return nil
+		}
+		result, err := s.events.CreateBatch(s.ctx, events)
+		if err != nil {
+			return err
+		}
+		if result.Failed == 0 {
+			imp.EventsImported += int64(result.Processed)
+			events = events[:0]
+			return nil
+		}
+
+		// A rejected row fails the whole chunk it was copied with; storing
+		// the events one by one only skips the rejected rows. Writes are
+		// idempotent, so events of the batch already stored are not doubled.
+		for i := range events {
+			if err := s.ctx.Err(); err != nil {
+				return err
+			}
+			if err := s.events.Create(s.ctx, &events[i]); err != nil {
+				imp.RowsSkipped++
+				if imp.Error == "" {
+					imp.Error = fmt.Sprintf("event %s rejected by the database: %v", events[i].ID, err)
+				}
+				continue
+			}
+			imp.EventsImported++
+		}
+		events = events[:0]
+		return nil
+	}
+	flushAggregates := func() error {
+		if len(aggregates) == 0 {
+			return nil
+		}
+		if err := s.repo.InsertAggregates(s.ctx, imp, aggregates); err != nil {
+			return err
+		}
+		imp.AggregatesImported += int64(len(aggregates))
+		aggregates = aggregates[:0]
+		return nil
+	}
+
+	for {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+
+		record, err := importer.Next()
+		if err == io.EOF {
+			break
+		}
+		imp.RowsRead++
+		if errors.Is(err, utils.ErrImportRowSkipped) {
+			imp.RowsSkipped++
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if record.Event != nil {
+			record.Event.ImportID = &imp.ID
+			events = append(events, *record.Event)
+			if len(events) == repository.MaxBatchSize {
+				if err := flushEvents(); err != nil {
+					return err
+				}
+			}
+		}
+		if record.Aggregate != nil {
+			aggregates = append(aggregates, *record.Aggregate)
+			if len(aggregates) == repository.MaxBatchSize {
+				if err := flushAggregates(); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if err := flushEvents(); err != nil {
+		return err
+	}
+	return flushAggregates()
+}
+
+func (s *ImportService) finish(imp *models.Import, err error) {
+	completed := time.Now().UTC()
+	imp.CompletedAt = &completed
+	if err != nil {
+		imp.Status = models.ImportFailed
+		imp.Error = err.Error()
+		s.logger.Error().Err(err).Str("import_id", imp.ID.String()).Int64("rows_read", imp.RowsRead).Msg("Import failed")
+	} else {
+		imp.Status = models.ImportCompleted
+		s.logger.Info().
+			Str("import_id", imp.ID.String()).
+			Int64("rows_read", imp.RowsRead).
+			Int64("rows_skipped", imp.RowsSkipped).
+			Int64("events", imp.EventsImported).
+			Int64("aggregates", imp.AggregatesImported).
+			Msg("Import completed")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := s.repo.Finish(ctx, imp); err != nil {
+		s.logger.Error().Err(err).Str("import_id", imp.ID.String()).Msg("Failed to save import")
+	}
+}
+
+// Shutdown cancels running imports, which are reported as failed, and waits
+// for them to stop
+func (s *ImportService) Shutdown(timeout time.Duration) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out waiting for imports")
+	}
+}
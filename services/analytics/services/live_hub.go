@@ -0,0 +1,212 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// DefaultLiveInterval is how often live metrics are pushed to subscribers
+const DefaultLiveInterval = 5 * time.Second
+
+const (
+	// liveRecordBuffer is the number of event batches waiting to be added to
+	// the live store; beyond it batches are dropped from the live view
+	liveRecordBuffer = 1024
+	// liveRecordTimeout bounds each write to the live store
+	liveRecordTimeout = 5 * time.Second
+)
+
+// LiveHub feeds ingested events to the live store in the background and
+// pushes each website's live metrics to its subscribers. A website's metrics
+// are read once per interval however many dashboards watch it.
+type LiveHub struct {
+	store    *repository.LiveStore
+	interval time.Duration
+	logger   zerolog.Logger
+
+	mu    sync.Mutex
+	feeds map[string]*liveFeed
+
+	// Events waiting to be recorded, closed by Shutdown
+	records    chan []models.Event
+	isShutdown bool
+	shutdownMu sync.RWMutex
+	wg         sync.WaitGroup
+}
+
+type liveFeed struct {
+	subscribers map[chan *models.LiveMetrics]struct{}
+	stop        chan struct{}
+}
+
+func NewLiveHub(store *repository.LiveStore, interval time.Duration, logger zerolog.Logger) *LiveHub {
+	if interval <= 0 {
+		interval = DefaultLiveInterval
+	}
+	hub := &LiveHub{
+		store:    store,
+		interval: interval,
+		logger:   logger,
+		feeds:    make(map[string]*liveFeed),
+		records:  make(chan []models.Event, liveRecordBuffer),
+	}
+	hub.startRecorder()
+	return hub
+}
+
+// Record queues accepted events for the live metrics without waiting for
+// Redis. When the queue is full or the hub is shut down the events are left
+// out of the live view, which never affects their storage.
+func (h *LiveHub) Record(events []models.Event) {
+	h.shutdownMu.RLock()
+	defer h.shutdownMu.RUnlock()
+	if h.isShutdown {
+		return
+	}
+
+	select {
+	case h.records <- events:
+	default:
+		h.logger.Warn().Int("events_count", len(events)).Msg("Live metrics queue full, dropping events")
+	}
+}
+
+// startRecorder adds queued events to the live store until Shutdown. Failures
+// only affect the live view, so they are logged.
+func (h *LiveHub) startRecorder() {
+	h.wg.Add(1)
+	go func() {
+		defer h.wg.Done()
+
+		for events := range h.records {
+			ctx, cancel := context.WithTimeout(context.Background(), liveRecordTimeout)
+			if err := h.store.Record(ctx, events); err != nil {
+				h.logger.Warn().Err(err).Int("events_count", len(events)).Msg("Failed to record live metrics")
+			}
+			cancel()
+		}
+	}()
+}
+
+// Shutdown stops accepting events and waits for the queued ones to be
+// recorded
+func (h *LiveHub) Shutdown(timeout time.Duration) error {
+	h.shutdownMu.Lock()
+	if !h.isShutdown {
+		h.isShutdown = true
+		close(h.records)
+	}
+	h.shutdownMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		h.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out waiting for live metrics")
+	}
+}
+
+// Snapshot returns a website's live metrics now
+func (h *LiveHub) Snapshot(ctx context.Context, websiteID string) (*models.LiveMetrics, error) {
+	return h.store.Snapshot(ctx, websiteID, time.Now())
+}
+
+// Subscribe returns a channel receiving the website's live metrics right away
+// and then every interval, and the function to stop receiving them. Slow
+// subscribers only get the latest metrics.
+func (h *LiveHub) Subscribe(websiteID string) (<-chan *models.LiveMetrics, func()) {
+	updates := make(chan *models.LiveMetrics, 1)
+
+	h.mu.Lock()
+	feed, ok := h.feeds[websiteID]
+	if !ok {
+		feed = &liveFeed{
+			subscribers: make(map[chan *models.LiveMetrics]struct{}),
+			stop:        make(chan struct{}),
+		}
+		h.feeds[websiteID] = feed
+		go h.run(websiteID, feed)
+	}
+	feed.subscribers[updates] = struct{}{}
+	h.mu.Unlock()
+
+	// New subscribers do not wait for the next tick
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+		defer cancel()
+		if metrics, err := h.Snapshot(ctx, websiteID); err == nil {
+			h.send(feed, updates, metrics)
+		}
+	}()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			h.mu.Lock()
+			defer h.mu.Unlock()
+			delete(feed.subscribers, updates)
+			if len(feed.subscribers) == 0 {
+				close(feed.stop)
+				delete(h.feeds, websiteID)
+			}
+		})
+	}
+	return updates, unsubscribe
+}
+
+// run reads a website's live metrics every interval until its last
+// subscriber leaves
+func (h *LiveHub) run(websiteID string, feed *liveFeed) {
+	ticker := time.NewTicker(h.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-feed.stop:
+			return
+		case <-ticker.C:
+			ctx, cancel := context.WithTimeout(context.Background(), h.interval)
+			metrics, err := h.Snapshot(ctx, websiteID)
+			cancel()
+			if err != nil {
+				h.logger.Warn().Err(err).Str("website_id", websiteID).Msg("Failed to read live metrics")
+				continue
+			}
+
+			h.mu.Lock()
+			for updates := range feed.subscribers {
+				h.send(feed, updates, metrics)
+			}
+			h.mu.Unlock()
+		}
+	}
+}
+
+// send replaces an update the subscriber has not read yet
+func (h *LiveHub) send(feed *liveFeed, updates chan *models.LiveMetrics, metrics *models.LiveMetrics) {
+	select {
+	case updates <- metrics:
+		return
+	default:
+	}
+	select {
+	case <-updates:
+	default:
+	}
+	select {
+	case updates <- metrics:
+	default:
+	}
+}
@@ -0,0 +1,421 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// DefaultReportCheckInterval is how often due reports are looked for
+	DefaultReportCheckInterval = time.Minute
+	// reportTopLimit is the number of top pages and sources in a digest
+	reportTopLimit = 10
+	// reportDueBatch caps the reports sent per check; the rest wait for
+	// the next one
+	reportDueBatch = 100
+	// reportDeliveryTimeout bounds building and delivering one report
+	reportDeliveryTimeout = 2 * time.Minute
+	// reportWebhookEvent is the event name of report webhook deliveries
+	reportWebhookEvent = "report"
+)
+
+var (
+	// ErrInvalidReport is returned for invalid report schedules
+	ErrInvalidReport = errors.New("invalid report schedule")
+	// ErrEmailUnavailable is returned for email reports when no mailer is
+	// configured
+	ErrEmailUnavailable = errors.New("email delivery is not configured")
+)
+
+// ReportWebhookPayload is the body of a report webhook delivery
+type ReportWebhookPayload struct {
+	ReportID uuid.UUID            `json:"report_id"`
+	Subject  string               `json:"subject"`
+	Text     string               `json:"text"`
+	Digest   *models.ReportDigest `json:"digest"`
+}
+
+type ReportService struct {
+	repo      *repository.ReportRepository
+	analytics *AnalyticsService
+	funnels   *FunnelService
+	mailer    utils.Mailer
+	webhooks  *utils.WebhookSender
+	interval  time.Duration
+	logger    zerolog.Logger
+
+	// Shutdown control
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// NewReportService returns the report service; email reports fail to send
+// when mailer is nil
+func NewReportService(repo *repository.ReportRepository, analytics *AnalyticsService, funnels *FunnelService, mailer utils.Mailer, webhooks *utils.WebhookSender, interval time.Duration, logger zerolog.Logger) *ReportService {
+	if interval <= 0 {
+		interval = DefaultReportCheckInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &ReportService{
+		repo:      repo,
+		analytics: analytics,
+		funnels:   funnels,
+		mailer:    mailer,
+		webhooks:  webhooks,
+		interval:  interval,
+		logger:    logger,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+}
+
+func (s *ReportService) CreateReport(ctx context.Context, req *models.CreateReportScheduleRequest) (*models.ReportSchedule, error) {
+	s.logger.Info().
+		Str("website_id", req.WebsiteID).
+		Str("report_name", req.Name).
+		Msg("Creating report schedule")
+
+	schedule := &models.ReportSchedule{
+		WebsiteID:     req.WebsiteID,
+		Name:          req.Name,
+		Cron:          req.Cron,
+		Timezone:      req.Timezone,
+		RangeDays:     req.RangeDays,
+		Channel:       req.Channel,
+		Recipients:    req.Recipients,
+		WebhookURL:    req.WebhookURL,
+		WebhookSecret: req.WebhookSecret,
+		Enabled:       true,
+	}
+	if schedule.Timezone == "" {
+		schedule.Timezone = "UTC"
+	}
+	if schedule.RangeDays == 0 {
+		schedule.RangeDays = 7
+	}
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	if err := s.prepare(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, schedule); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to create report schedule")
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (s *ReportService) GetReports(ctx context.Context, websiteID string) ([]models.ReportSchedule, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting report schedules")
+
+	return s.repo.GetByWebsiteID(ctx, websiteID)
+}
+
+func (s *ReportService) GetReport(ctx context.Context, reportID uuid.UUID) (*models.ReportSchedule, error) {
+	s.logger.Info().
+		Str("report_id", reportID.String()).
+		Msg("Getting report schedule")
+
+	return s.repo.GetByID(ctx, reportID)
+}
+
+func (s *ReportService) UpdateReport(ctx context.Context, reportID uuid.UUID, req *models.UpdateReportScheduleRequest) (*models.ReportSchedule, error) {
+	s.logger.Info().
+		Str("report_id", reportID.String()).
+		Msg("Updating report schedule")
+
+	schedule, err := s.repo.GetByID(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		schedule.Name = *req.Name
+	}
+	if req.Cron != nil {
+		schedule.Cron = *req.Cron
+	}
+	if req.Timezone != nil {
+		schedule.Timezone = *req.Timezone
+	}
+	if req.RangeDays != nil {
+		schedule.RangeDays = *req.RangeDays
+	}
+	if req.Channel != nil {
+		schedule.Channel = *req.Channel
+	}
+	if req.Recipients != nil {
+		schedule.Recipients = req.Recipients
+	}
+	if req.WebhookURL != nil {
+		schedule.WebhookURL = req.WebhookURL
+	}
+	if req.WebhookSecret != nil {
+		schedule.WebhookSecret = req.WebhookSecret
+	}
+	if req.Enabled != nil {
+		schedule.Enabled = *req.Enabled
+	}
+	if err := s.prepare(schedule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, schedule); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update report schedule")
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (s *ReportService) DeleteReport(ctx context.Context, reportID uuid.UUID) error {
+	s.logger.Info().
+		Str("report_id", reportID.String()).
+		Msg("Deleting report schedule")
+
+	return s.repo.Delete(ctx, reportID)
+}
+
+// prepare validates a schedule and sets its next run from now
+func (s *ReportService) prepare(schedule *models.ReportSchedule) error {
+	if schedule.Recipients == nil {
+		schedule.Recipients = []string{}
+	}
+	if err := utils.ValidateReportSchedule(schedule); err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidReport, err)
+	}
+	next, err := utils.NextReportRun(schedule, time.Now())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidReport, err)
+	}
+	schedule.NextRunAt = next
+	return nil
+}
+
+// PreviewReport renders the report a schedule would send now
+func (s *ReportService) PreviewReport(ctx context.Context, reportID uuid.UUID) (*utils.RenderedReport, error) {
+	schedule, err := s.repo.GetByID(ctx, reportID)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := s.BuildDigest(ctx, schedule, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return utils.RenderReport(digest)
+}
+
+// SendReport delivers a schedule's report right away, without moving its
+// next scheduled run
+func (s *ReportService) SendReport(ctx context.Context, reportID uuid.UUID) error {
+	schedule, err := s.repo.GetByID(ctx, reportID)
+	if err != nil {
+		return err
+	}
+	return s.deliver(ctx, schedule, time.Now())
+}
+
+// BuildDigest gathers the numbers of a schedule's report run at the given time
+func (s *ReportService) BuildDigest(ctx context.Context, schedule *models.ReportSchedule, at time.Time) (*models.ReportDigest, error) {
+	dateRange := utils.ReportRange(schedule, at)
+	filters := models.AnalyticsFilters{}
+
+	dashboard, err := s.analytics.GetDashboard(ctx, schedule.WebsiteID, dateRange, filters, nil)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := s.analytics.GetTopPages(ctx, schedule.WebsiteID, dateRange, filters, reportTopLimit, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top pages: %w", err)
+	}
+	sources, err := s.analytics.GetTopSources(ctx, schedule.WebsiteID, dateRange, filters, reportTopLimit, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get top sources: %w", err)
+	}
+
+	funnels, err := s.funnels.GetFunnels(ctx, schedule.WebsiteID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get funnels: %w", err)
+	}
+	var reportFunnels []models.ReportFunnel
+	for _, funnel := range funnels {
+		if !funnel.IsActive {
+			continue
+		}
+		analytics, err := s.funnels.GetFunnelAnalytics(ctx, funnel.ID, dateRange)
+		if err != nil {
+			return nil, err
+		}
+		reportFunnels = append(reportFunnels, models.ReportFunnel{
+			ID:             funnel.ID,
+			Name:           funnel.Name,
+			Starts:         analytics.TotalStarts,
+			Conversions:    analytics.TotalConversions,
+			ConversionRate: analytics.ConversionRate,
+		})
+	}
+
+	return &models.ReportDigest{
+		WebsiteID:   schedule.WebsiteID,
+		Name:        schedule.Name,
+		From:        dateRange.From,
+		To:          dateRange.To,
+		Timezone:    dateRange.Timezone,
+		Dashboard:   dashboard,
+		TopPages:    pages,
+		TopSources:  sources,
+		Funnels:     reportFunnels,
+		GeneratedAt: time.Now().UTC(),
+	}, nil
+}
+
+// deliver builds, renders and sends a schedule's report, recording the
+// outcome on the schedule
+func (s *ReportService) deliver(ctx context.Context, schedule *models.ReportSchedule, at time.Time) error {
+	ctx, cancel := context.WithTimeout(ctx, reportDeliveryTimeout)
+	defer cancel()
+
+	err := s.send(ctx, schedule, at)
+
+	var lastError *string
+	if err != nil {
+		message := err.Error()
+		lastError = &message
+		s.logger.Error().Err(err).Str("report_id", schedule.ID.String()).Str("channel", schedule.Channel).Msg("Failed to send report")
+	} else {
+		s.logger.Info().Str("report_id", schedule.ID.String()).Str("channel", schedule.Channel).Msg("Report sent")
+	}
+
+	recordCtx, recordCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer recordCancel()
+	if recordErr := s.repo.RecordRun(recordCtx, schedule.ID, time.Now().UTC(), lastError); recordErr != nil {
+		s.logger.Error().Err(recordErr).Str("report_id", schedule.ID.String()).Msg("Failed to record report run")
+	}
+	return err
+}
+
+func (s *ReportService) send(ctx context.Context, schedule *models.ReportSchedule, at time.Time) error {
+	digest, err := s.BuildDigest(ctx, schedule, at)
+	if err != nil {
+		return err
+	}
+	rendered, err := utils.RenderReport(digest)
+	if err != nil {
+		return err
+	}
+
+	switch schedule.Channel {
+	case models.ReportChannelEmail:
+		if s.mailer == nil {
+			return ErrEmailUnavailable
+		}
+		return s.mailer.Send(ctx, &utils.MailMessage{
+			To:      schedule.Recipients,
+			Subject: rendered.Subject,
+			HTML:    rendered.HTML,
+			Text:    rendered.Text,
+		})
+	case models.ReportChannelWebhook:
+		if schedule.WebhookURL == nil || schedule.WebhookSecret == nil {
+			return fmt.Errorf("%w: webhook is not configured", ErrInvalidReport)
+		}
+		return s.webhooks.Send(ctx, *schedule.WebhookURL, *schedule.WebhookSecret, reportWebhookEvent, ReportWebhookPayload{
+			ReportID: schedule.ID,
+			Subject:  rendered.Subject,
+			Text:     rendered.Text,
+			Digest:   digest,
+		})
+	}
+	return fmt.Errorf("%w: unknown channel %q", ErrInvalidReport, schedule.Channel)
+}
+
+// Start sends due reports every check interval until Shutdown. Each run is
+// claimed in the database, so several instances send it once.
+func (s *ReportService) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+		for {
+			s.sendDue()
+			select {
+			case <-s.ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func (s *ReportService) sendDue() {
+	now := time.Now()
+	schedules, err := s.repo.GetDue(s.ctx, now, reportDueBatch)
+	if err != nil {
+		if s.ctx.Err() == nil {
+			s.logger.Error().Err(err).Msg("Failed to get due reports")
+		}
+		return
+	}
+
+	for i := range schedules {
+		schedule := &schedules[i]
+		if s.ctx.Err() != nil {
+			return
+		}
+
+		// Runs missed while the service was down are sent once, not
+		// once per missed run
+		next, err := utils.NextReportRun(schedule, now)
+		if err != nil {
+			s.logger.Error().Err(err).Str("report_id", schedule.ID.String()).Msg("Invalid report schedule")
+			continue
+		}
+		claimed, err := s.repo.Claim(s.ctx, schedule.ID, schedule.NextRunAt, next)
+		if err != nil {
+			s.logger.Error().Err(err).Str("report_id", schedule.ID.String()).Msg("Failed to claim report run")
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		// The report covers the days before its scheduled time
+		s.deliver(s.ctx, schedule, schedule.NextRunAt)
+	}
+}
+
+// Shutdown stops sending reports, cancelling the one being sent, and waits
+// for the scheduler to stop
+func (s *ReportService) Shutdown(timeout time.Duration) error {
+	s.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out waiting for reports")
+	}
+}
@@ -0,0 +1,102 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog"
+)
+
+// ErrInvalidCurrency wraps invalid currency codes and exchange rates
+var ErrInvalidCurrency = errors.New("invalid currency")
+
+type RevenueService struct {
+	repo   *repository.RevenueRepository
+	logger zerolog.Logger
+}
+
+func NewRevenueService(repo *repository.RevenueRepository, logger zerolog.Logger) *RevenueService {
+	return &RevenueService{
+		repo:   repo,
+		logger: logger,
+	}
+}
+
+func (s *RevenueService) GetSettings(ctx context.Context, websiteID string) (*models.RevenueSettings, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Msg("Getting revenue settings")
+
+	return s.repo.GetSettings(ctx, websiteID)
+}
+
+// UpdateSettings sets the currency the website's revenue is reported in.
+// Orders keep their own currency, so this also applies to past orders.
+func (s *RevenueService) UpdateSettings(ctx context.Context, websiteID string, req *models.UpdateRevenueSettingsRequest) (*models.RevenueSettings, error) {
+	s.logger.Info().
+		Str("website_id", websiteID).
+		Str("base_currency", req.BaseCurrency).
+		Msg("Updating revenue settings")
+
+	currency, err := utils.NormalizeCurrency(req.BaseCurrency)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidCurrency, err)
+	}
+
+	settings := &models.RevenueSettings{WebsiteID: websiteID, BaseCurrency: currency}
+	if err := s.repo.UpdateSettings(ctx, settings); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update revenue settings")
+		return nil, err
+	}
+
+	return settings, nil
+}
+
+func (s *RevenueService) GetExchangeRates(ctx context.Context) ([]models.ExchangeRate, error) {
+	s.logger.Info().Msg("Getting exchange rates")
+
+	return s.repo.GetExchangeRates(ctx)
+}
+
+// UpdateExchangeRates sets the units of each currency per one unit of
+// models.ReferenceCurrency
+func (s *RevenueService) UpdateExchangeRates(ctx context.Context, req *models.UpdateExchangeRatesRequest) ([]models.ExchangeRate, error) {
+	s.logger.Info().
+		Int("currencies", len(req.Rates)).
+		Msg("Updating exchange rates")
+
+	rates := make(map[string]float64, len(req.Rates))
+	for code, rate := range req.Rates {
+		currency, err := utils.NormalizeCurrency(code)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidCurrency, err)
+		}
+		if currency == models.ReferenceCurrency {
+			return nil, fmt.Errorf("%w: rates are quoted against %s", ErrInvalidCurrency, models.ReferenceCurrency)
+		}
+		if rate <= 0 {
+			return nil, fmt.Errorf("%w: rate of %s must be positive", ErrInvalidCurrency, currency)
+		}
+		rates[currency] = rate
+	}
+
+	if err := s.repo.UpsertExchangeRates(ctx, rates); err != nil {
+		s.logger.Error().Err(err).Msg("Failed to update exchange rates")
+		return nil, err
+	}
+
+	return s.repo.GetExchangeRates(ctx)
+}
+
+// currencyConverter converts to the base currency of the settings' website
+func currencyConverter(settings *models.RevenueSettings, rates []models.ExchangeRate) *utils.CurrencyConverter {
+	byCurrency := make(map[string]float64, len(rates))
+	for _, rate := range rates {
+		byCurrency[rate.Currency] = rate.Rate
+	}
+	return utils.NewCurrencyConverter(settings.BaseCurrency, byCurrency)
+}
@@ -0,0 +1,112 @@
+package services
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"context"
+	"sort"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	// SessionSweepInterval is how often idle sessions are closed
+	SessionSweepInterval = time.Minute
+	sessionSweepBatch    = 500
+)
+
+// SessionStitcher assigns sessions server-side instead of trusting the
+// tracker's session_id, and emits session_start and session_end events
+type SessionStitcher struct {
+	store  *repository.SessionStore
+	rules  utils.SessionRules
+	logger zerolog.Logger
+}
+
+func NewSessionStitcher(store *repository.SessionStore, rules utils.SessionRules, logger zerolog.Logger) *SessionStitcher {
+	return &SessionStitcher{
+		store:  store,
+		rules:  rules,
+		logger: logger,
+	}
+}
+
+// Stitch sets the session of events, visiting them in time order, and
+// returns the session_start and session_end events of the sessions they
+// opened and closed. Events from before their visitor's open session
+// started, and all events while the session store is unavailable, keep the
+// tracker's session.
+func (s *SessionStitcher) Stitch(ctx context.Context, events []models.Event) []models.Event {
+	order := make([]int, len(events))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool {
+		return events[order[a]].Timestamp.Before(events[order[b]].Timestamp)
+	})
+
+	var synthetic []models.Event
+	for _, i := range order {
+		event := &events[i]
+		if event.WebsiteID == "" || event.VisitorID == "" {
+			continue
+		}
+
+		clientSessionID := event.SessionID
+		var emitted []models.Event
+		err := s.store.Update(ctx, repository.SessionKey{WebsiteID: event.WebsiteID, VisitorID: event.VisitorID}, func(current *models.SessionState) (*models.SessionState, error) {
+			event.SessionID = clientSessionID
+			emitted = emitted[:0]
+
+			if current != nil {
+				if event.Timestamp.Before(current.StartedAt) {
+					return current, nil
+				}
+				reason := utils.SessionBreak(current, event, s.rules)
+				if reason == "" {
+					utils.ContinueSession(current, event)
+					event.SessionID = current.SessionID
+					return current, nil
+				}
+				emitted = append(emitted, utils.SessionEndEvent(event.WebsiteID, event.VisitorID, current, reason))
+			}
+
+			next := utils.StartSession(event, current)
+			event.SessionID = next.SessionID
+			emitted = append(emitted, utils.SessionStartEvent(event, next))
+			return next, nil
+		})
+		if err != nil {
+			event.SessionID = clientSessionID
+			s.logger.Warn().Err(err).Str("visitor_id", event.VisitorID).Msg("Failed to stitch session, keeping tracker session")
+			continue
+		}
+		synthetic = append(synthetic, emitted...)
+	}
+
+	return synthetic
+}
+
+// CloseIdle closes the sessions without activity for longer than the timeout
+// and returns their session_end events
+func (s *SessionStitcher) CloseIdle(ctx context.Context, now time.Time) ([]models.Event, error) {
+	before := now.Add(-s.rules.Timeout)
+	keys, err := s.store.IdleSessions(ctx, before, sessionSweepBatch)
+	if err != nil {
+		return nil, err
+	}
+
+	var ended []models.Event
+	for _, key := range keys {
+		state, err := s.store.CloseIdle(ctx, key, before)
+		if err != nil {
+			return ended, err
+		}
+		if state != nil {
+			ended = append(ended, utils.SessionEndEvent(key.WebsiteID, key.VisitorID, state, models.SessionEndTimeout))
+		}
+	}
+	return ended, nil
+}
@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func alertRule() *models.AlertRule {
+	return &models.AlertRule{
+		WebsiteID:       "site",
+		Name:            "Traffic drop",
+		Metric:          models.AlertMetricPageviews,
+		Type:            models.AlertTypeThreshold,
+		Direction:       models.AlertDirectionBelow,
+		Baseline:        models.AlertBaselineSameHourLastWeek,
+		Threshold:       50,
+		Channel:         models.AlertChannelEmail,
+		Recipients:      []string{"team@example.com"},
+		CooldownMinutes: 360,
+	}
+}
+
+func TestValidateAlertRule(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(r *models.AlertRule)
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name:   "valid threshold rule",
+			modify: func(r *models.AlertRule) {},
+		},
+		{
+			name: "valid anomaly rule with slack",
+			modify: func(r *models.AlertRule) {
+				r.Type = models.AlertTypeAnomaly
+				r.Direction = models.AlertDirectionOutside
+				r.Sensitivity = 3
+				r.BaselineWeeks = 4
+				r.Channel = models.AlertChannelSlack
+				r.WebhookURL = stringPtr("https://hooks.slack.com/services/T000/B000/XXXX")
+			},
+		},
+		{
+			name:    "unknown metric",
+			modify:  func(r *models.AlertRule) { r.Metric = "sessions" },
+			wantErr: true,
+			errMsg:  "metric must be 'pageviews' or 'visitors'",
+		},
+		{
+			name:    "threshold rule outside direction",
+			modify:  func(r *models.AlertRule) { r.Direction = models.AlertDirectionOutside },
+			wantErr: true,
+			errMsg:  "direction of a threshold alert must be 'below' or 'above'",
+		},
+		{
+			name:    "unknown baseline",
+			modify:  func(r *models.AlertRule) { r.Baseline = "yesterday" },
+			wantErr: true,
+			errMsg:  "baseline must be 'absolute', 'previous_hour' or 'same_hour_last_week'",
+		},
+		{
+			name:    "zero relative threshold",
+			modify:  func(r *models.AlertRule) { r.Threshold = 0 },
+			wantErr: true,
+			errMsg:  "threshold must be positive",
+		},
+		{
+			name:    "anomaly rule without sensitivity",
+			modify:  func(r *models.AlertRule) { r.Type = models.AlertTypeAnomaly },
+			wantErr: true,
+			errMsg:  "sensitivity must be positive",
+		},
+		{
+			name:    "negative cooldown",
+			modify:  func(r *models.AlertRule) { r.CooldownMinutes = -1 },
+			wantErr: true,
+			errMsg:  "cooldown_minutes must be between 0 and 10080",
+		},
+		{
+			name:    "email without recipients",
+			modify:  func(r *models.AlertRule) { r.Recipients = nil },
+			wantErr: true,
+			errMsg:  "channel 'email' requires recipients",
+		},
+		{
+			name:    "slack without webhook_url",
+			modify:  func(r *models.AlertRule) { r.Channel = models.AlertChannelSlack },
+			wantErr: true,
+			errMsg:  "alert of channel 'slack' requires a public http or https webhook_url",
+		},
+		{
+			name: "slack webhook_url on the metadata address",
+			modify: func(r *models.AlertRule) {
+				r.Channel = models.AlertChannelSlack
+				r.WebhookURL = stringPtr("http://169.254.169.254/latest/meta-data/")
+			},
+			wantErr: true,
+			errMsg:  "alert of channel 'slack' requires a public http or https webhook_url",
+		},
+		{
+			name:    "unknown channel",
+			modify:  func(r *models.AlertRule) { r.Channel = "sms" },
+			wantErr: true,
+			errMsg:  "channel must be 'email', 'webhook' or 'slack'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule := alertRule()
+			tt.modify(rule)
+			err := utils.ValidateAlertRule(rule)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestEvaluateThresholdAlert(t *testing.T) {
+	hour := time.Date(2024, 3, 18, 10, 0, 0, 0, time.UTC)
+	lastWeek := hour.Add(-7 * 24 * time.Hour)
+	rule := alertRule()
+	assert.Equal(t, 7*24*time.Hour, utils.AlertLookback(rule))
+
+	alert := utils.EvaluateAlertRule(rule, hour, map[time.Time]float64{hour: 12, lastWeek: 48})
+	require.NotNil(t, alert)
+	assert.Equal(t, 12.0, alert.Value)
+	assert.Equal(t, 48.0, alert.Expected)
+	assert.Nil(t, alert.ZScore)
+	assert.Equal(t, "12 pageviews in the hour from 2024-03-18 10:00 UTC, down 75% from 48 the same hour last week", alert.Message)
+
+	assert.Nil(t, utils.EvaluateAlertRule(rule, hour, map[time.Time]float64{hour: 30, lastWeek: 48}))
+	// Too little traffic last week to compare with
+	assert.Nil(t, utils.EvaluateAlertRule(rule, hour, map[time.Time]float64{lastWeek: 8}))
+
+	// Hours without traffic are missing from the series and count as zero
+	absolute := alertRule()
+	absolute.Baseline = models.AlertBaselineAbsolute
+	absolute.Threshold = 5
+	assert.Equal(t, time.Duration(0), utils.AlertLookback(absolute))
+	alert = utils.EvaluateAlertRule(absolute, hour, map[time.Time]float64{})
+	require.NotNil(t, alert)
+	assert.Equal(t, 0.0, alert.Value)
+	assert.Equal(t, "0 pageviews in the hour from 2024-03-18 10:00 UTC, below the threshold of 5", alert.Message)
+
+	spike := alertRule()
+	spike.Direction = models.AlertDirectionAbove
+	spike.Baseline = models.AlertBaselinePreviousHour
+	spike.Threshold = 200
+	previous := hour.Add(-time.Hour)
+	assert.NotNil(t, utils.EvaluateAlertRule(spike, hour, map[time.Time]float64{hour: 250, previous: 100}))
+	assert.Nil(t, utils.EvaluateAlertRule(spike, hour, map[time.Time]float64{hour: 150, previous: 100}))
+}
+
+func TestEvaluateAnomalyAlert(t *testing.T) {
+	hour := time.Date(2024, 3, 18, 10, 0, 0, 0, time.UTC)
+	rule := alertRule()
+	rule.Type = models.AlertTypeAnomaly
+	rule.Direction = models.AlertDirectionOutside
+	rule.Sensitivity = 3
+	rule.BaselineWeeks = 4
+	assert.Equal(t, 4*7*24*time.Hour, utils.AlertLookback(rule))
+
+	series := func(value float64, weeks ...float64) map[time.Time]float64 {
+		s := map[time.Time]float64{hour: value}
+		for i, v := range weeks {
+			s[hour.Add(-time.Duration(i+1)*7*24*time.Hour)] = v
+		}
+		return s
+	}
+
+	// Mean 100, sample deviation 16.3: 50 is 3.1 deviations below
+	alert := utils.EvaluateAlertRule(rule, hour, series(50, 80, 100, 120, 100))
+	require.NotNil(t, alert)
+	assert.Equal(t, 100.0, alert.Expected)
+	require.NotNil(t, alert.ZScore)
+	assert.InDelta(t, -3.06, *alert.ZScore, 0.01)
+	assert.Contains(t, alert.Message, "down 50% from the usual 100 for this hour (z-score -3.1)")
+
+	assert.Nil(t, utils.EvaluateAlertRule(rule, hour, series(60, 80, 100, 120, 100)))
+
+	// Identical weeks have no deviation; the square root of the mean stands
+	// in for it, so 110 against 100 (z = 1) is no anomaly but 140 (z = 4) is
+	assert.Nil(t, utils.EvaluateAlertRule(rule, hour, series(110, 100, 100, 100, 100)))
+	alert = utils.EvaluateAlertRule(rule, hour, series(140, 100, 100, 100, 100))
+	require.NotNil(t, alert)
+	assert.InDelta(t, 4, *alert.ZScore, 0.001)
+
+	rule.Direction = models.AlertDirectionBelow
+	assert.Nil(t, utils.EvaluateAlertRule(rule, hour, series(140, 100, 100, 100, 100)))
+
+	// Quiet hours are not evaluated
+	assert.Nil(t, utils.EvaluateAlertRule(rule, hour, series(0, 5, 4, 6, 5)))
+}
+
+func TestWebhookSenderSlack(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	sender := utils.NewWebhookSender(5 * time.Second).AllowPrivateAddresses()
+	require.NoError(t, sender.SendSlack(context.Background(), server.URL, "*Traffic drop*: 12 pageviews"))
+	assert.Equal(t, map[string]string{"text": "*Traffic drop*: 12 pageviews"}, received)
+}
@@ -0,0 +1,116 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttributeConversion(t *testing.T) {
+	convertedAt := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+	touches := []models.Touchpoint{
+		{Channel: "google", Timestamp: convertedAt.Add(-14 * day)},
+		{Channel: "newsletter", Timestamp: convertedAt.Add(-7 * day)},
+		{Channel: "twitter", Timestamp: convertedAt.Add(-7 * day)},
+		{Channel: "direct", Direct: true, Timestamp: convertedAt},
+	}
+
+	credits := utils.AttributeConversion(touches, convertedAt, 7*day)
+	require.Len(t, credits, len(models.AttributionModels))
+
+	assert.Equal(t, map[string]float64{"google": 1}, credits[models.AttributionFirstTouch])
+	assert.Equal(t, map[string]float64{"direct": 1}, credits[models.AttributionLastTouch])
+	// Direct visits are skipped when there is a campaign or referral touch
+	assert.Equal(t, map[string]float64{"twitter": 1}, credits[models.AttributionLastNonDirect])
+
+	for _, channel := range []string{"google", "newsletter", "twitter", "direct"} {
+		assert.InDelta(t, 0.25, credits[models.AttributionLinear][channel], 1e-9)
+	}
+
+	position := credits[models.AttributionPositionBased]
+	assert.InDelta(t, 0.4, position["google"], 1e-9)
+	assert.InDelta(t, 0.1, position["newsletter"], 1e-9)
+	assert.InDelta(t, 0.1, position["twitter"], 1e-9)
+	assert.InDelta(t, 0.4, position["direct"], 1e-9)
+
+	// Weights halve every half-life: 1/4, 1/2, 1/2 and 1 out of 2.25
+	decay := credits[models.AttributionTimeDecay]
+	assert.InDelta(t, 0.25/2.25, decay["google"], 1e-9)
+	assert.InDelta(t, 0.5/2.25, decay["newsletter"], 1e-9)
+	assert.InDelta(t, 1/2.25, decay["direct"], 1e-9)
+
+	for _, model := range models.AttributionModels {
+		var total float64
+		for _, credit := range credits[model] {
+			total += credit
+		}
+		assert.InDelta(t, 1, total, 1e-9, model)
+	}
+}
+
+func TestAttributeConversionDirectOnly(t *testing.T) {
+	convertedAt := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	touches := []models.Touchpoint{
+		{Channel: "direct", Direct: true, Timestamp: convertedAt.Add(-time.Hour)},
+		{Channel: "direct", Direct: true, Timestamp: convertedAt},
+	}
+
+	credits := utils.AttributeConversion(touches, convertedAt, utils.DefaultAttributionHalfLife)
+	assert.Equal(t, map[string]float64{"direct": 1}, credits[models.AttributionLastNonDirect])
+	assert.InDelta(t, 1, credits[models.AttributionPositionBased]["direct"], 1e-9)
+
+	empty := utils.AttributeConversion(nil, convertedAt, utils.DefaultAttributionHalfLife)
+	assert.Empty(t, empty[models.AttributionLinear])
+}
+
+func TestAttributeConversionShortHalfLife(t *testing.T) {
+	convertedAt := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	touches := []models.Touchpoint{
+		{Channel: "google", Timestamp: convertedAt.Add(-60 * 24 * time.Hour)},
+		{Channel: "newsletter", Timestamp: convertedAt.Add(-30 * 24 * time.Hour)},
+	}
+
+	// Every touch is thousands of half-lives before the conversion
+	credits := utils.AttributeConversion(touches, convertedAt, time.Minute)
+	decay := credits[models.AttributionTimeDecay]
+	assert.InDelta(t, 0, decay["google"], 1e-9)
+	assert.InDelta(t, 1, decay["newsletter"], 1e-9)
+}
+
+func TestAttributionBuilder(t *testing.T) {
+	convertedAt := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	builder := utils.NewAttributionBuilder(0)
+
+	builder.Add([]models.Touchpoint{
+		{Channel: "google", Timestamp: convertedAt.Add(-48 * time.Hour)},
+		{Channel: "newsletter", Timestamp: convertedAt},
+	}, convertedAt)
+	builder.Add([]models.Touchpoint{{Channel: "newsletter", Timestamp: convertedAt}}, convertedAt)
+	builder.Add(nil, convertedAt)
+
+	conversions, unattributed := builder.Conversions()
+	assert.Equal(t, 3, conversions)
+	assert.Equal(t, 1, unattributed)
+
+	worth := 10.0
+	channels := builder.Channels(&worth)
+	require.Len(t, channels, 2)
+
+	assert.Equal(t, "newsletter", channels[0].Channel)
+	assert.Equal(t, 2, channels[0].Touchpoints)
+	assert.Equal(t, 1.5, channels[0].Credits[models.AttributionLinear])
+	assert.Equal(t, 2.0, channels[0].Credits[models.AttributionLastTouch])
+	assert.Equal(t, 1.0, channels[0].Credits[models.AttributionFirstTouch])
+	assert.Equal(t, 15.0, channels[0].Value[models.AttributionLinear])
+
+	assert.Equal(t, "google", channels[1].Channel)
+	assert.Equal(t, 0.5, channels[1].Credits[models.AttributionPositionBased])
+	assert.Equal(t, 0.0, channels[1].Credits[models.AttributionLastTouch])
+
+	assert.Nil(t, builder.Channels(nil)[0].Value)
+}
@@ -0,0 +1,76 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const chromeUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36"
+
+func TestBotNameFromUserAgent(t *testing.T) {
+	tests := []struct {
+		userAgent string
+		expected  string
+	}{
+		{chromeUA, ""},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_4 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4 Mobile/15E148 Safari/604.1", ""},
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", "Googlebot"},
+		{"Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)", "Bingbot"},
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/124.0.0.0 Safari/537.36", "Headless Chrome"},
+		{"curl/8.4.0", "curl"},
+		{"Mozilla/5.0 (compatible; SomeNewCrawler/1.0)", "Other bot"},
+		{"MyScript/1.0", "Other bot"},
+		{"", "Empty user agent"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, utils.BotNameFromUserAgent(tt.userAgent), tt.userAgent)
+	}
+}
+
+func TestBotClassifier(t *testing.T) {
+	ua := chromeUA
+	newEvent := func(visitorID string) *models.Event {
+		return &models.Event{WebsiteID: "site", VisitorID: visitorID, EventType: "pageview", UserAgent: &ua}
+	}
+
+	t.Run("regular browser", func(t *testing.T) {
+		classifier := utils.NewBotClassifier(utils.DefaultBotClassifierConfig())
+		assert.False(t, classifier.Classify(newEvent("v1"), 3320).IsBot)
+	})
+
+	t.Run("datacenter network", func(t *testing.T) {
+		classifier := utils.NewBotClassifier(utils.DefaultBotClassifierConfig())
+		verdict := classifier.Classify(newEvent("v1"), 16509)
+		assert.True(t, verdict.IsBot)
+		assert.Equal(t, "Datacenter (AWS)", verdict.Name)
+	})
+
+	t.Run("webdriver signal", func(t *testing.T) {
+		classifier := utils.NewBotClassifier(utils.DefaultBotClassifierConfig())
+		event := newEvent("v1")
+		event.Properties = models.Properties{"webdriver": true}
+		assert.True(t, classifier.Classify(event, 0).IsBot)
+	})
+
+	t.Run("implausible event rate", func(t *testing.T) {
+		classifier := utils.NewBotClassifier(utils.BotClassifierConfig{MaxEventsPerMinute: 3})
+		for i := 0; i < 3; i++ {
+			assert.False(t, classifier.Classify(newEvent("v1"), 0).IsBot)
+		}
+		verdict := classifier.Classify(newEvent("v1"), 0)
+		assert.True(t, verdict.IsBot)
+		assert.Equal(t, "High event rate", verdict.Name)
+
+		// Other visitors are unaffected
+		assert.False(t, classifier.Classify(newEvent("v2"), 0).IsBot)
+	})
+}
+
+func TestParseUserAgentBotDevice(t *testing.T) {
+	info := utils.ParseUserAgent("Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+	assert.Equal(t, "bot", info.Device)
+}
@@ -0,0 +1,52 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildCohorts(t *testing.T) {
+	loc, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// Cohort starts come back from the database as zone-less local midnights
+	week1 := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	week2 := time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)
+	cells := []models.CohortCell{
+		{Start: week1, Period: 0, Visitors: 40},
+		{Start: week1, Period: 1, Visitors: 10},
+		{Start: week1, Period: 3, Visitors: 4},
+		{Start: week2, Period: 0, Visitors: 20},
+		{Start: week2, Period: 1, Visitors: 5},
+	}
+	now := time.Date(2026, 3, 24, 12, 0, 0, 0, loc)
+
+	cohorts := utils.BuildCohorts(cells, models.GranularityWeek, 4, loc, now)
+	require.Len(t, cohorts, 2)
+
+	first := cohorts[0]
+	assert.Equal(t, time.Date(2026, 3, 2, 0, 0, 0, 0, loc), first.Start)
+	assert.Equal(t, 40, first.Size)
+	// Periods 0-3 have begun; period 4 starts on March 30
+	require.Len(t, first.Retention, 4)
+	assert.Equal(t, 100.0, first.Retention[0].Rate)
+	assert.Equal(t, 25.0, first.Retention[1].Rate)
+	assert.Equal(t, models.CohortRetention{Period: 2, Visitors: 0, Rate: 0}, first.Retention[2])
+	assert.Equal(t, 10.0, first.Retention[3].Rate)
+
+	second := cohorts[1]
+	assert.Equal(t, 20, second.Size)
+	require.Len(t, second.Retention, 3)
+	assert.Equal(t, 25.0, second.Retention[1].Rate)
+}
+
+func TestCohortPeriodStart(t *testing.T) {
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC), utils.CohortPeriodStart(start, models.GranularityWeek, 2))
+	assert.Equal(t, time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC), utils.CohortPeriodStart(start, models.GranularityMonth, 3))
+}
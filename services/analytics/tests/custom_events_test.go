@@ -0,0 +1,21 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventSignature(t *testing.T) {
+	base := repository.EventSignature("signup", models.Properties{"plan": "pro", "source": "hero"})
+
+	// Stable regardless of map order, event type case and noisy keys
+	assert.Equal(t, base, repository.EventSignature("Signup", models.Properties{"source": "hero", "plan": "pro"}))
+	assert.Equal(t, base, repository.EventSignature("signup", models.Properties{"plan": "pro", "source": "hero", "xpath": "/html/body"}))
+
+	assert.NotEqual(t, base, repository.EventSignature("signup", models.Properties{"plan": "free", "source": "hero"}))
+	assert.NotEqual(t, base, repository.EventSignature("purchase", models.Properties{"plan": "pro", "source": "hero"}))
+	assert.Len(t, base, 64)
+}
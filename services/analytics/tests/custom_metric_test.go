@@ -0,0 +1,158 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMetricExpression(t *testing.T) {
+	expression, err := utils.ParseMetricExpression(`count("signup") / visitors * 100 + count('signup')`)
+	require.NoError(t, err)
+	// Repeated aggregates are computed once
+	assert.Equal(t, []utils.MetricAggregate{
+		{Func: utils.MetricCount, Event: "signup"},
+		{Func: utils.MetricVisitors, Event: "pageview"},
+	}, expression.Aggregates())
+
+	expression, err = utils.ParseMetricExpression(`sum("purchase", "value") / sessions("purchase")`)
+	require.NoError(t, err)
+	assert.Equal(t, []utils.MetricAggregate{
+		{Func: utils.MetricSum, Event: "purchase", Property: "value"},
+		{Func: utils.MetricSessions, Event: "purchase"},
+	}, expression.Aggregates())
+
+	invalid := []string{
+		"",
+		"100",
+		"pageviews +",
+		"count()",
+		`count("signup"`,
+		`sum("purchase")`,
+		`median("purchase", "value")`,
+		`count(signup)`,
+		`pageviews; DROP TABLE events`,
+		`count("it's)`,
+		"(((((((((((((((((((((pageviews)))))))))))))))))))))",
+	}
+	for _, input := range invalid {
+		_, err := utils.ParseMetricExpression(input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestMetricExpressionSQL(t *testing.T) {
+	expression, err := utils.ParseMetricExpression(`count("x'); DROP TABLE events; --") / visitors * 100`)
+	require.NoError(t, err)
+
+	columns := map[utils.MetricAggregate]string{}
+	for i, aggregate := range expression.Aggregates() {
+		columns[aggregate] = []string{"a0", "a1"}[i]
+	}
+	sql := expression.SQL(func(aggregate utils.MetricAggregate) string { return columns[aggregate] })
+
+	assert.Equal(t, "((a0 / NULLIF(a1, 0)) * (100)::double precision)", sql)
+	assert.NotContains(t, sql, "DROP")
+}
+
+func TestMetricExpressionEvaluate(t *testing.T) {
+	expression, err := utils.ParseMetricExpression(`-count("signup") / visitors * 100`)
+	require.NoError(t, err)
+
+	values := map[string]float64{utils.MetricCount: 5, utils.MetricVisitors: 200}
+	value := func(aggregate utils.MetricAggregate) *float64 {
+		v, ok := values[aggregate.Func]
+		if !ok {
+			return nil
+		}
+		return &v
+	}
+	result := expression.Evaluate(value)
+	require.NotNil(t, result)
+	assert.InDelta(t, -2.5, *result, 1e-9)
+
+	values[utils.MetricVisitors] = 0
+	assert.Nil(t, expression.Evaluate(value))
+	delete(values, utils.MetricVisitors)
+	assert.Nil(t, expression.Evaluate(value))
+}
+
+func TestBuildCustomMetricValues(t *testing.T) {
+	total := models.CustomMetric{ID: uuid.New(), Expression: `sum("purchase", "value") + 10`}
+	ratio := models.CustomMetric{ID: uuid.New(), Expression: `count("signup") / visitors`}
+	average := models.CustomMetric{ID: uuid.New(), Expression: `avg("purchase", "value")`}
+	metrics := []models.CustomMetric{total, ratio, average}
+
+	computed := map[string]*float64{total.ID.String(): float64Ptr(42)}
+	assert.Equal(t, computed, utils.BuildCustomMetricValues(computed, metrics).CustomMetrics)
+
+	// Groups without events get the values of the metrics over no events
+	empty := utils.BuildCustomMetricValues(nil, metrics).CustomMetrics
+	require.Len(t, empty, 3)
+	require.NotNil(t, empty[total.ID.String()])
+	assert.Equal(t, 10.0, *empty[total.ID.String()])
+	assert.Nil(t, empty[ratio.ID.String()])
+	assert.Nil(t, empty[average.ID.String()])
+}
+
+func TestValidateCustomMetric(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(m *models.CustomMetric)
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name:   "valid metric",
+			modify: func(m *models.CustomMetric) {},
+		},
+		{
+			name:    "missing name",
+			modify:  func(m *models.CustomMetric) { m.Name = "" },
+			wantErr: true,
+			errMsg:  "metric name is required",
+		},
+		{
+			name:    "missing website_id",
+			modify:  func(m *models.CustomMetric) { m.WebsiteID = "" },
+			wantErr: true,
+			errMsg:  "website_id is required",
+		},
+		{
+			name:    "unknown format",
+			modify:  func(m *models.CustomMetric) { m.Format = "ratio" },
+			wantErr: true,
+			errMsg:  "format must be 'number', 'percentage' or 'currency'",
+		},
+		{
+			name:    "incomplete expression",
+			modify:  func(m *models.CustomMetric) { m.Expression = "visitors /" },
+			wantErr: true,
+			errMsg:  "invalid expression: unexpected end of expression",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			metric := &models.CustomMetric{
+				WebsiteID:  "site",
+				Name:       "Signups per 100 visitors",
+				Expression: `count("signup") / visitors * 100`,
+				Format:     models.CustomMetricFormatNumber,
+			}
+			tt.modify(metric)
+			err := utils.ValidateCustomMetric(metric)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
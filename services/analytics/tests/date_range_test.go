@@ -0,0 +1,92 @@
+package tests
+
+import (
+	"analytics-app/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseDateRange(t *testing.T) {
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+
+	t.Run("defaults to relative days", func(t *testing.T) {
+		r, err := utils.ParseDateRange(utils.DateRangeParams{}, 7, now)
+		require.NoError(t, err)
+		assert.Equal(t, now.AddDate(0, 0, -7), r.From)
+		assert.Equal(t, now, r.To)
+		assert.Equal(t, "UTC", r.Timezone)
+		assert.Equal(t, "7 days", r.Label())
+	})
+
+	t.Run("legacy days parameter", func(t *testing.T) {
+		r, err := utils.ParseDateRange(utils.DateRangeParams{Days: "30"}, 7, now)
+		require.NoError(t, err)
+		assert.Equal(t, 30, r.Days())
+	})
+
+	t.Run("calendar dates in timezone are inclusive", func(t *testing.T) {
+		r, err := utils.ParseDateRange(utils.DateRangeParams{
+			From:     "2026-03-01",
+			To:       "2026-03-15",
+			Timezone: "Europe/Berlin",
+		}, 7, now)
+		require.NoError(t, err)
+		// Berlin is UTC+1 in early March
+		assert.Equal(t, time.Date(2026, 2, 28, 23, 0, 0, 0, time.UTC), r.From)
+		assert.Equal(t, time.Date(2026, 3, 15, 23, 0, 0, 0, time.UTC), r.To)
+		assert.Equal(t, 15, r.Days())
+	})
+
+	t.Run("RFC3339 bounds", func(t *testing.T) {
+		r, err := utils.ParseDateRange(utils.DateRangeParams{
+			From: "2026-03-01T06:00:00Z",
+			To:   "2026-03-01T18:00:00Z",
+		}, 7, now)
+		require.NoError(t, err)
+		assert.Equal(t, 12*time.Hour, r.Duration())
+	})
+
+	t.Run("previous period has equal length", func(t *testing.T) {
+		r, err := utils.ParseDateRange(utils.DateRangeParams{From: "2026-03-08", To: "2026-03-14"}, 7, now)
+		require.NoError(t, err)
+		prev := r.Previous()
+		assert.Equal(t, r.Duration(), prev.Duration())
+		assert.Equal(t, r.From, prev.To)
+	})
+
+	t.Run("invalid input", func(t *testing.T) {
+		_, err := utils.ParseDateRange(utils.DateRangeParams{Timezone: "Mars/Olympus"}, 7, now)
+		assert.Error(t, err)
+
+		_, err = utils.ParseDateRange(utils.DateRangeParams{From: "yesterday"}, 7, now)
+		assert.Error(t, err)
+
+		_, err = utils.ParseDateRange(utils.DateRangeParams{From: "2026-03-15", To: "2026-03-01"}, 7, now)
+		assert.Error(t, err)
+	})
+
+	t.Run("range length is bounded", func(t *testing.T) {
+		for _, days := range []string{"0", "-3", "ten", "200000"} {
+			_, err := utils.ParseDateRange(utils.DateRangeParams{Days: days}, 7, now)
+			assert.EqualError(t, err, "days must be between 1 and 7320", days)
+		}
+
+		_, err := utils.ParseDateRange(utils.DateRangeParams{From: "1900-01-01", To: "2026-03-01"}, 7, now)
+		assert.EqualError(t, err, "date range must be at most 7320 days")
+	})
+}
+
+func TestCheckHourlyRange(t *testing.T) {
+	now := time.Date(2026, 3, 20, 12, 0, 0, 0, time.UTC)
+
+	r, err := utils.ParseDateRange(utils.DateRangeParams{Days: "92"}, 1, now)
+	require.NoError(t, err)
+	assert.NoError(t, utils.CheckHourlyRange(r))
+
+	r, err = utils.ParseDateRange(utils.DateRangeParams{Days: "93"}, 1, now)
+	require.NoError(t, err)
+	assert.EqualError(t, utils.CheckHourlyRange(r), "hourly stats cover at most 92 days")
+}
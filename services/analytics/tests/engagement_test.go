@@ -0,0 +1,85 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEngagementScore(t *testing.T) {
+	assert.Equal(t, 0.0, utils.EngagementScore(100, 1, 0))
+	assert.Equal(t, 100.0, utils.EngagementScore(0, 5, 300))
+	// Components are capped at their targets
+	assert.Equal(t, 100.0, utils.EngagementScore(0, 12, 3600))
+	// 50% bounce, 3 pages and 60 seconds: 20 + 15 + 6
+	assert.Equal(t, 41.0, utils.EngagementScore(50, 3, 60))
+}
+
+func TestPercentChange(t *testing.T) {
+	assert.Nil(t, utils.PercentChange(10, 0))
+	assert.InDelta(t, -25.0, *utils.PercentChange(75, 100), 0.001)
+	assert.InDelta(t, 200.0, *utils.PercentChange(3, 1), 0.001)
+}
+
+func TestSeenVisitors(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2024, time.March, day, hour, 0, 0, 0, time.UTC)
+	}
+	events := []models.Event{
+		{WebsiteID: "site", VisitorID: "v1", EventType: "pageview", Timestamp: at(1, 9), IsBot: true},
+		{WebsiteID: "site", VisitorID: "v1", EventType: "pageview", Timestamp: at(5, 12)},
+		{WebsiteID: "site", VisitorID: "v2", EventType: "pageview", Timestamp: at(4, 8)},
+		{WebsiteID: "site", VisitorID: "v2", EventType: "signup", Timestamp: at(2, 8)},
+		{WebsiteID: "site", VisitorID: "v2", EventType: "pageview", Timestamp: at(2, 10)},
+		{WebsiteID: "site", VisitorID: "v3", EventType: "pageview", Timestamp: at(3, 10), IsBot: true},
+		{WebsiteID: "other", VisitorID: "v2", EventType: "pageview", Timestamp: at(1, 10)},
+	}
+
+	seen := utils.SeenVisitors(events)
+	assert.Equal(t, []utils.SeenVisitor{
+		// First seen at the first human pageview, so new in a range from day 3
+		{WebsiteID: "site", VisitorID: "v1", FirstSeen: at(5, 12), LastSeen: at(5, 12)},
+		// Returning in a range from day 3; only pageviews count
+		{WebsiteID: "site", VisitorID: "v2", FirstSeen: at(2, 10), LastSeen: at(4, 8)},
+		{WebsiteID: "other", VisitorID: "v2", FirstSeen: at(1, 10), LastSeen: at(1, 10)},
+	}, seen)
+}
+
+func TestPlanRetention(t *testing.T) {
+	from := time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+
+	t.Run("return window", func(t *testing.T) {
+		plan := repository.PlanRetention(from, to, to.AddDate(0, 1, 0), 7)
+		// A pageview on the day of the first visit is not a return; one
+		// exactly 8 days later is past the window
+		assert.Equal(t, 24*time.Hour, plan.ReturnAfter)
+		assert.Equal(t, 8*24*time.Hour, plan.ReturnBefore)
+		assert.Equal(t, repository.TimeRange{From: from, To: to}, plan.Cohort)
+
+		// A one day window is the day after the first visit
+		plan = repository.PlanRetention(from, to, to.AddDate(0, 1, 0), 1)
+		assert.Equal(t, 24*time.Hour, plan.ReturnAfter)
+		assert.Equal(t, 2*24*time.Hour, plan.ReturnBefore)
+	})
+
+	t.Run("open windows are left out", func(t *testing.T) {
+		now := to.Add(3 * 24 * time.Hour)
+		plan := repository.PlanRetention(from, to, now, 7)
+		assert.Equal(t, from, plan.Cohort.From)
+		assert.Equal(t, now.Add(-8*24*time.Hour), plan.Cohort.To)
+
+		// A window closing exactly now has ended
+		plan = repository.PlanRetention(from, to, to.Add(8*24*time.Hour), 7)
+		assert.Equal(t, to, plan.Cohort.To)
+	})
+
+	t.Run("range without closed windows", func(t *testing.T) {
+		plan := repository.PlanRetention(from, to, to, 7)
+		assert.False(t, plan.Cohort.From.Before(plan.Cohort.To))
+	})
+}
@@ -0,0 +1,118 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/services"
+	"analytics-app/utils"
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestEventService returns an event service whose database is
+// unreachable, so tracked events stay queued in the stream as they would be
+// stored
+func newTestEventService(t *testing.T, geo *utils.GeoLocator) (*services.EventService, *redis.Client) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	db, err := pgxpool.New(context.Background(), "postgres://analytics@127.0.0.1:1/analytics?connect_timeout=1")
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+
+	repo := repository.NewEventRepository(db, zerolog.Nop())
+	stream := repository.NewEventStream(client, "writer-1")
+	service, err := services.NewEventService(repo, stream, geo, utils.NewBotClassifier(utils.DefaultBotClassifierConfig()), nil, nil, 1000, zerolog.Nop())
+	require.NoError(t, err)
+	t.Cleanup(func() { service.Shutdown(5 * time.Second) })
+	return service, client
+}
+
+// queuedEvents returns the events waiting in the ingestion stream
+func queuedEvents(t *testing.T, client *redis.Client) []models.Event {
+	messages, err := client.XRange(context.Background(), repository.EventStreamKey, "-", "+").Result()
+	require.NoError(t, err)
+
+	events := make([]models.Event, len(messages))
+	for i, message := range messages {
+		require.NoError(t, json.Unmarshal([]byte(message.Values["event"].(string)), &events[i]))
+	}
+	return events
+}
+
+func TestTrackEventLocatesConnectionIP(t *testing.T) {
+	geo := utils.NewGeoLocator(10, &stubGeoProvider{locations: map[string]utils.LocationInfo{
+		"81.2.69.160": {Country: "GB", City: "London", ASN: 20712},
+	}})
+	service, client := newTestEventService(t, geo)
+
+	// The tracker sends no ip_address; the connection's is used
+	ua := chromeUA
+	ctx := utils.SetClientIPInContext(context.Background(), "81.2.69.160")
+	_, err := service.TrackEvent(ctx, &models.Event{WebsiteID: "site", VisitorID: "v1", UserAgent: &ua})
+	require.NoError(t, err)
+
+	// Unresolved and private addresses leave the location unset rather than
+	// storing values that don't fit the two-letter country column
+	for _, ip := range []string{"203.0.113.1", "10.0.0.5", ""} {
+		ctx := utils.SetClientIPInContext(context.Background(), ip)
+		_, err := service.TrackEvent(ctx, &models.Event{WebsiteID: "site", VisitorID: "v2", UserAgent: &ua})
+		require.NoError(t, err)
+	}
+
+	events := queuedEvents(t, client)
+	require.Len(t, events, 4)
+	require.NotNil(t, events[0].Country)
+	assert.Equal(t, "GB", *events[0].Country)
+	assert.Equal(t, "London", *events[0].City)
+	assert.Nil(t, events[0].IPAddress)
+	for _, event := range events[1:] {
+		assert.Nil(t, event.Country)
+		assert.Nil(t, event.City)
+	}
+}
+
+func TestTrackEventFlagsDatacenterConnection(t *testing.T) {
+	geo := utils.NewGeoLocator(10, &stubGeoProvider{locations: map[string]utils.LocationInfo{
+		"3.5.140.2":   {Country: "US", ASN: 16509},
+		"81.2.69.160": {Country: "GB", City: "London", ASN: 20712},
+	}})
+	service, client := newTestEventService(t, geo)
+	ua := chromeUA
+
+	// No ip_address in the body: the connection's network flags the event
+	ctx := utils.SetClientIPInContext(context.Background(), "3.5.140.2")
+	_, err := service.TrackEvent(ctx, &models.Event{WebsiteID: "site", VisitorID: "v1", UserAgent: &ua})
+	require.NoError(t, err)
+
+	// A residential ip_address doesn't hide the datacenter connection
+	residential := "81.2.69.160"
+	_, err = service.TrackEvent(ctx, &models.Event{WebsiteID: "site", VisitorID: "v2", UserAgent: &ua, IPAddress: &residential})
+	require.NoError(t, err)
+
+	// Nor does a datacenter ip_address flag a residential connection
+	ctx = utils.SetClientIPInContext(context.Background(), "81.2.69.160")
+	datacenter := "3.5.140.2"
+	_, err = service.TrackEvent(ctx, &models.Event{WebsiteID: "site", VisitorID: "v3", UserAgent: &ua, IPAddress: &datacenter})
+	require.NoError(t, err)
+
+	events := queuedEvents(t, client)
+	require.Len(t, events, 3)
+	for _, event := range events[:2] {
+		assert.True(t, event.IsBot, event.VisitorID)
+		require.NotNil(t, event.BotName)
+		assert.Equal(t, "Datacenter (AWS)", *event.BotName)
+	}
+	assert.False(t, events[2].IsBot)
+	assert.Equal(t, "GB", *events[1].Country)
+}
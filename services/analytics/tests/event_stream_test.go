@@ -0,0 +1,101 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestEventStream(t *testing.T, consumer string) (*repository.EventStream, *redis.Client) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	stream := repository.NewEventStream(client, consumer)
+	require.NoError(t, stream.EnsureGroup(context.Background()))
+	// Creating the group twice is not an error
+	require.NoError(t, stream.EnsureGroup(context.Background()))
+	return stream, client
+}
+
+func TestEventStream(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("unacknowledged events are replayed", func(t *testing.T) {
+		stream, client := newTestEventStream(t, "writer-1")
+		events := []models.Event{
+			{ID: uuid.New(), WebsiteID: "site", VisitorID: "v1", EventType: "pageview"},
+			{ID: uuid.New(), WebsiteID: "site", VisitorID: "v2", EventType: "pageview"},
+		}
+		require.NoError(t, stream.Add(ctx, events))
+
+		read, err := stream.Read(ctx, 10, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.Len(t, read, 2)
+		assert.Equal(t, events[0].ID, read[0].Event.ID)
+		require.NoError(t, stream.Ack(ctx, read[0].StreamID))
+
+		// A restarted consumer with the same name sees what it did not ack
+		restarted := repository.NewEventStream(client, "writer-1")
+		pending, err := restarted.ReadOwnPending(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, pending, 1)
+		assert.Equal(t, events[1].ID, pending[0].Event.ID)
+
+		backlog, pendingCount, dead, err := stream.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), backlog)
+		assert.Equal(t, int64(1), pendingCount)
+		assert.Zero(t, dead)
+	})
+
+	t.Run("dead letters can be requeued", func(t *testing.T) {
+		stream, _ := newTestEventStream(t, "writer-1")
+		event := models.Event{ID: uuid.New(), WebsiteID: "site", VisitorID: "v1", EventType: "pageview"}
+		require.NoError(t, stream.Add(ctx, []models.Event{event}))
+
+		read, err := stream.Read(ctx, 10, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.Len(t, read, 1)
+		require.NoError(t, stream.DeadLetter(ctx, read[0], errors.New("value too long")))
+
+		letters, err := stream.ListDeadLetters(ctx, 10)
+		require.NoError(t, err)
+		require.Len(t, letters, 1)
+		assert.Equal(t, event.ID, letters[0].Event.ID)
+		assert.Equal(t, "value too long", letters[0].Error)
+
+		moved, err := stream.RequeueDeadLetters(ctx, 10)
+		require.NoError(t, err)
+		assert.Equal(t, 1, moved)
+
+		backlog, pending, dead, err := stream.Stats(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), backlog)
+		assert.Zero(t, pending)
+		assert.Zero(t, dead)
+	})
+
+	t.Run("undecodable entries are reported", func(t *testing.T) {
+		stream, client := newTestEventStream(t, "writer-1")
+		require.NoError(t, client.XAdd(ctx, &redis.XAddArgs{
+			Stream: repository.EventStreamKey,
+			Values: map[string]interface{}{"event": "{not json"},
+		}).Err())
+
+		read, err := stream.Read(ctx, 10, 10*time.Millisecond)
+		require.NoError(t, err)
+		require.Len(t, read, 1)
+		assert.Error(t, read[0].Err)
+		assert.Equal(t, "{not json", read[0].Raw)
+	})
+}
@@ -98,6 +98,115 @@ func TestValidateEvent(t *testing.T) {
 	}
 }
 
+func TestValidateTrackedEvent(t *testing.T) {
+	negative := -5
+	tests := []struct {
+		name    string
+		event   models.Event
+		wantErr bool
+		errMsg  string
+	}{
+		{
+			name: "relative page is accepted",
+			event: models.Event{
+				WebsiteID: "test-site",
+				VisitorID: "visitor-123",
+				Page:      "/pricing",
+				EventType: "pageview",
+				Timestamp: time.Now(),
+			},
+		},
+		{
+			name:    "missing visitor_id",
+			event:   models.Event{WebsiteID: "test-site"},
+			wantErr: true,
+			errMsg:  "visitor_id is required",
+		},
+		{
+			name: "website_id too long",
+			event: models.Event{
+				WebsiteID: "0123456789012345678901234",
+				VisitorID: "visitor-123",
+			},
+			wantErr: true,
+			errMsg:  "website_id must be at most 24 characters",
+		},
+		{
+			name: "reserved session_start event",
+			event: models.Event{
+				WebsiteID: "test-site",
+				VisitorID: "visitor-123",
+				EventType: models.SessionStartEventType,
+			},
+			wantErr: true,
+			errMsg:  `event_type "session_start" is reserved`,
+		},
+		{
+			name: "reserved session_end event",
+			event: models.Event{
+				WebsiteID: "test-site",
+				VisitorID: "visitor-123",
+				EventType: models.SessionEndEventType,
+			},
+			wantErr: true,
+			errMsg:  `event_type "session_end" is reserved`,
+		},
+		{
+			name: "negative time on page",
+			event: models.Event{
+				WebsiteID:  "test-site",
+				VisitorID:  "visitor-123",
+				TimeOnPage: &negative,
+			},
+			wantErr: true,
+			errMsg:  "time_on_page must be non-negative",
+		},
+		{
+			name: "timestamp in the future",
+			event: models.Event{
+				WebsiteID: "test-site",
+				VisitorID: "visitor-123",
+				Timestamp: time.Now().Add(2 * time.Hour),
+			},
+			wantErr: true,
+			errMsg:  "timestamp is in the future",
+		},
+		{
+			name: "purchase without currency",
+			event: models.Event{
+				WebsiteID:  "test-site",
+				VisitorID:  "visitor-123",
+				EventType:  "purchase",
+				Properties: models.Properties{"order_id": "A-1", "amount": 10.0},
+			},
+			wantErr: true,
+			errMsg:  "currency",
+		},
+		{
+			name: "purchase without order data is a custom event",
+			event: models.Event{
+				WebsiteID:  "test-site",
+				VisitorID:  "visitor-123",
+				EventType:  "purchase",
+				Properties: models.Properties{"plan": "pro"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := utils.ValidateTrackedEvent(&tt.event)
+
+			if tt.wantErr {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), tt.errMsg)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
 func TestValidateFunnel(t *testing.T) {
 	tests := []struct {
 		name    string
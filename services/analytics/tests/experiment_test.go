@@ -0,0 +1,144 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func pricingExperiment(goalType string) *models.Experiment {
+	goal := "purchase?plan=pro"
+	return &models.Experiment{
+		Name:      "Pricing page",
+		WebsiteID: "site",
+		Property:  "exp_pricing",
+		Variants: models.ExperimentVariants{
+			{Key: "control", Name: "Current"},
+			{Key: "b", Name: "Annual first"},
+		},
+		Control:                 "control",
+		GoalType:                goalType,
+		GoalEvent:               &goal,
+		MinimumDetectableEffect: 0.1,
+		Status:                  models.ExperimentStatusRunning,
+	}
+}
+
+func TestValidateExperiment(t *testing.T) {
+	assert.NoError(t, utils.ValidateExperiment(pricingExperiment(models.ExperimentGoalEvent)))
+
+	experiment := pricingExperiment(models.ExperimentGoalEvent)
+	experiment.Variants = experiment.Variants[:1]
+	assert.Error(t, utils.ValidateExperiment(experiment))
+
+	experiment = pricingExperiment(models.ExperimentGoalEvent)
+	experiment.Variants[1].Key = "control"
+	assert.Error(t, utils.ValidateExperiment(experiment))
+
+	experiment = pricingExperiment(models.ExperimentGoalEvent)
+	experiment.Control = "missing"
+	assert.Error(t, utils.ValidateExperiment(experiment))
+
+	// Funnel goals need the funnel
+	assert.Error(t, utils.ValidateExperiment(pricingExperiment(models.ExperimentGoalFunnel)))
+}
+
+func TestExperimentEvaluator(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	at := func(hours int) time.Time { return from.Add(time.Duration(hours) * time.Hour) }
+	event := func(eventType string, props models.Properties, hours int) models.Event {
+		return models.Event{EventType: eventType, Properties: props, Timestamp: at(hours)}
+	}
+
+	t.Run("event goal", func(t *testing.T) {
+		evaluator, err := utils.NewExperimentEvaluator(pricingExperiment(models.ExperimentGoalEvent), nil, from, to)
+		require.NoError(t, err)
+
+		variant, converted := evaluator.Evaluate("v1", []models.Event{
+			event("purchase", models.Properties{"plan": "pro"}, 1),
+			event("pageview", models.Properties{"exp_pricing": "b"}, 2),
+			event("pageview", models.Properties{"exp_pricing": "control"}, 3),
+			event("purchase", models.Properties{"plan": "pro"}, 4),
+		})
+		// The first assignment wins and only later conversions count
+		assert.Equal(t, "b", variant)
+		assert.True(t, converted)
+
+		variant, converted = evaluator.Evaluate("v2", []models.Event{
+			event("pageview", models.Properties{"exp_pricing": "control"}, 1),
+			event("purchase", models.Properties{"plan": "pro"}, 30),
+		})
+		assert.Equal(t, "control", variant)
+		assert.False(t, converted, "conversion outside the window")
+
+		variant, _ = evaluator.Evaluate("v3", []models.Event{
+			event("pageview", models.Properties{"exp_pricing": "unknown"}, 1),
+		})
+		assert.Empty(t, variant)
+
+		// Assignments after the range are ignored
+		variant, _ = evaluator.Evaluate("v4", []models.Event{
+			event("pageview", models.Properties{"exp_pricing": "b"}, 24*7+1),
+		})
+		assert.Empty(t, variant)
+	})
+
+	t.Run("funnel goal", func(t *testing.T) {
+		_, err := utils.NewExperimentEvaluator(pricingExperiment(models.ExperimentGoalFunnel), nil, from, to)
+		assert.Error(t, err)
+
+		evaluator, err := utils.NewExperimentEvaluator(pricingExperiment(models.ExperimentGoalFunnel), checkoutFunnel("strict", 24), from, to)
+		require.NoError(t, err)
+		assert.Equal(t, 48*time.Hour, evaluator.Horizon())
+
+		variant, converted := evaluator.Evaluate("v1", []models.Event{
+			event("pageview", models.Properties{"exp_pricing": "control"}, 1),
+			{EventType: "pageview", Page: "/pricing", Timestamp: at(2)},
+			event("signup", nil, 3),
+			event("purchase", models.Properties{"plan": "pro"}, 4),
+		})
+		assert.Equal(t, "control", variant)
+		assert.True(t, converted)
+	})
+}
+
+func TestBuildExperimentResults(t *testing.T) {
+	experiment := pricingExperiment(models.ExperimentGoalEvent)
+	results := utils.BuildExperimentResults(experiment, map[string]utils.VariantCounts{
+		"control": {Visitors: 1000, Conversions: 200},
+		"b":       {Visitors: 1000, Conversions: 250},
+	})
+
+	require.Len(t, results.Variants, 2)
+	control, variant := results.Variants[0], results.Variants[1]
+	assert.True(t, control.Control)
+	assert.Nil(t, control.PValue)
+	assert.Equal(t, 20.0, control.ConversionRate)
+
+	assert.Equal(t, 25.0, variant.ConversionRate)
+	require.NotNil(t, variant.Lift)
+	assert.Equal(t, 25.0, *variant.Lift)
+	require.NotNil(t, variant.PValue)
+	assert.InDelta(t, 0.0074, *variant.PValue, 0.0001)
+	assert.True(t, variant.Significant)
+	require.NotNil(t, variant.ProbabilityToWin)
+	assert.Greater(t, *variant.ProbabilityToWin, 0.99)
+
+	assert.Equal(t, "z-test", results.Test)
+	assert.True(t, results.Significant)
+	assert.Equal(t, 20.0, results.SampleSize.BaselineRate)
+	assert.Greater(t, results.SampleSize.PerVariant, 1000)
+	assert.Equal(t, results.SampleSize.PerVariant-1000, results.SampleSize.Remaining)
+
+	// No data yet: nothing to test, but every variant is listed
+	results = utils.BuildExperimentResults(experiment, nil)
+	require.Len(t, results.Variants, 2)
+	assert.Nil(t, results.PValue)
+	assert.False(t, results.Significant)
+	assert.Zero(t, results.SampleSize.PerVariant)
+}
@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/parquet-go/parquet-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var exportColumns = []models.ExportColumn{
+	{Name: "page", Type: models.ExportString},
+	{Name: "views", Type: models.ExportInt},
+	{Name: "bounce_rate", Type: models.ExportFloat},
+	{Name: "is_bot", Type: models.ExportBool},
+	{Name: "date", Type: models.ExportTime},
+}
+
+func exportRows() [][]interface{} {
+	rate := 42.5
+	day := time.Date(2024, 3, 15, 0, 0, 0, 0, time.FixedZone("CET", 3600))
+	return [][]interface{}{
+		{"/pricing, plans", int32(12), &rate, false, day},
+		{"/", 3, (*float64)(nil), nil, nil},
+	}
+}
+
+func writeExport(t *testing.T, format string) []byte {
+	var buf bytes.Buffer
+	writer, err := utils.NewExportWriter(&buf, format, exportColumns)
+	require.NoError(t, err)
+	for _, row := range exportRows() {
+		require.NoError(t, writer.Write(row))
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestExportWriters(t *testing.T) {
+	_, err := utils.NewExportWriter(io.Discard, "xlsx", exportColumns)
+	assert.Error(t, err)
+
+	assert.Equal(t,
+		"page,views,bounce_rate,is_bot,date\n"+
+			"\"/pricing, plans\",12,42.5,false,2024-03-14T23:00:00Z\n"+
+			"/,3,,,\n",
+		string(writeExport(t, models.ExportFormatCSV)))
+
+	lines := strings.Split(strings.TrimSpace(string(writeExport(t, models.ExportFormatNDJSON))), "\n")
+	require.Len(t, lines, 2)
+	assert.Equal(t, `{"page":"/pricing, plans","views":12,"bounce_rate":42.5,"is_bot":false,"date":"2024-03-14T23:00:00Z"}`, lines[0])
+	var second map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &second))
+	assert.Nil(t, second["bounce_rate"])
+	assert.Equal(t, float64(3), second["views"])
+
+	data := writeExport(t, models.ExportFormatParquet)
+	file, err := parquet.OpenFile(bytes.NewReader(data), int64(len(data)))
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), file.NumRows())
+
+	reader := parquet.NewReader(file)
+	rows := make([]parquet.Row, 2)
+	n, err := reader.ReadRows(rows)
+	if err != io.EOF {
+		require.NoError(t, err)
+	}
+	require.Equal(t, 2, n)
+
+	column := func(name string) int {
+		leaf, ok := file.Schema().Lookup(name)
+		require.True(t, ok, name)
+		return leaf.ColumnIndex
+	}
+	assert.Equal(t, "/pricing, plans", rows[0][column("page")].String())
+	assert.Equal(t, int64(12), rows[0][column("views")].Int64())
+	assert.Equal(t, 42.5, rows[0][column("bounce_rate")].Double())
+	assert.Equal(t, time.Date(2024, 3, 14, 23, 0, 0, 0, time.UTC).UnixMicro(), rows[0][column("date")].Int64())
+	assert.True(t, rows[1][column("bounce_rate")].IsNull())
+	assert.True(t, rows[1][column("date")].IsNull())
+}
+
+func TestLocalObjectStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := repository.NewLocalObjectStore(t.TempDir())
+	require.NoError(t, err)
+
+	require.NoError(t, store.Put(ctx, "exports/site/job.csv", strings.NewReader("a,b\n"), "text/csv"))
+	file, err := store.Get(ctx, "exports/site/job.csv")
+	require.NoError(t, err)
+	content, err := io.ReadAll(file)
+	file.Close()
+	require.NoError(t, err)
+	assert.Equal(t, "a,b\n", string(content))
+
+	// Failed uploads leave nothing behind
+	failing := io.MultiReader(strings.NewReader("partial"), failingReader{})
+	assert.Error(t, store.Put(ctx, "exports/site/failed.csv", failing, "text/csv"))
+	_, err = store.Get(ctx, "exports/site/failed.csv")
+	assert.ErrorIs(t, err, repository.ErrObjectNotFound)
+
+	assert.Error(t, store.Put(ctx, "../outside.csv", strings.NewReader(""), "text/csv"))
+}
+
+// failingReader fails every read
+type failingReader struct{}
+
+func (failingReader) Read([]byte) (int, error) { return 0, io.ErrUnexpectedEOF }
+
+func TestExportJobStore(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	jobs := repository.NewExportJobStore(client, time.Hour)
+	_, err := jobs.Get(ctx, "missing")
+	assert.ErrorIs(t, err, repository.ErrExportJobNotFound)
+
+	job := &models.ExportJob{
+		ID:            "job-1",
+		ExportRequest: models.ExportRequest{WebsiteID: "site", Dataset: models.ExportDatasetEvents, Format: models.ExportFormatParquet},
+		Status:        models.ExportJobQueued,
+		CreatedAt:     time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC),
+	}
+	require.NoError(t, jobs.Save(ctx, job))
+
+	job.Status = models.ExportJobCompleted
+	job.Rows = 10
+	require.NoError(t, jobs.Save(ctx, job))
+
+	stored, err := jobs.Get(ctx, "job-1")
+	require.NoError(t, err)
+	assert.Equal(t, job, stored)
+
+	server.FastForward(2 * time.Hour)
+	_, err = jobs.Get(ctx, "job-1")
+	assert.ErrorIs(t, err, repository.ErrExportJobNotFound)
+}
@@ -0,0 +1,107 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/utils"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAnalyticsFilters(t *testing.T) {
+	query, _ := url.ParseQuery("country=DE,AT&device=mobile&device=tablet&utm_source=newsletter&page=/blog/*&property_key=plan")
+
+	filters, err := utils.ParseAnalyticsFilters(query)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"DE", "AT"}, filters.Country)
+	assert.Equal(t, []string{"mobile", "tablet"}, filters.Device)
+	assert.Equal(t, []string{"newsletter"}, filters.UTMSource)
+	assert.Equal(t, "/blog/*", filters.Page)
+	assert.Equal(t, "plan", filters.PropertyKey)
+	assert.False(t, filters.IsEmpty())
+
+	empty, err := utils.ParseAnalyticsFilters(url.Values{})
+	require.NoError(t, err)
+	assert.True(t, empty.IsEmpty())
+
+	_, err = utils.ParseAnalyticsFilters(url.Values{"property_value": {"pro"}})
+	assert.Error(t, err)
+
+	withBots, err := utils.ParseAnalyticsFilters(url.Values{"include_bots": {"true"}})
+	require.NoError(t, err)
+	assert.True(t, withBots.IncludeBots)
+
+	_, err = utils.ParseAnalyticsFilters(url.Values{"include_bots": {"maybe"}})
+	assert.Error(t, err)
+}
+
+func TestBuildFilterClause(t *testing.T) {
+	baseArgs := []interface{}{"site", "from", "to", 10}
+
+	t.Run("no filters", func(t *testing.T) {
+		clause, args := repository.BuildFilterClause(models.AnalyticsFilters{IncludeBots: true}, "e", baseArgs)
+		assert.Empty(t, clause)
+		assert.Len(t, args, 4)
+	})
+
+	t.Run("bots are excluded by default", func(t *testing.T) {
+		clause, args := repository.BuildFilterClause(models.AnalyticsFilters{}, "e", baseArgs)
+		assert.Equal(t, "\n\t\tAND NOT e.is_bot", clause)
+		assert.Len(t, args, 4)
+	})
+
+	t.Run("placeholders continue after existing args", func(t *testing.T) {
+		clause, args := repository.BuildFilterClause(models.AnalyticsFilters{
+			Country:   []string{"DE"},
+			Device:    []string{"Mobile"},
+			UTMSource: []string{"newsletter"},
+		}, "e", baseArgs)
+
+		assert.Contains(t, clause, "LOWER(e.country) = ANY($5)")
+		assert.Contains(t, clause, "LOWER(e.device) = ANY($6)")
+		assert.Contains(t, clause, "LOWER(e.utm_source) = ANY($7)")
+		require.Len(t, args, 7)
+		assert.Equal(t, []string{"mobile"}, args[5])
+	})
+
+	t.Run("page wildcard is escaped", func(t *testing.T) {
+		clause, args := repository.BuildFilterClause(models.AnalyticsFilters{Page: "/50%_off/*"}, "e", baseArgs)
+		assert.Contains(t, clause, "e.page LIKE $5")
+		assert.Equal(t, `/50\%\_off/%`, args[4])
+
+		clause, args = repository.BuildFilterClause(models.AnalyticsFilters{Page: "/pricing"}, "e", baseArgs)
+		assert.Contains(t, clause, "e.page = $5")
+		assert.Equal(t, "/pricing", args[4])
+	})
+
+	t.Run("direct referrer", func(t *testing.T) {
+		clause, args := repository.BuildFilterClause(models.AnalyticsFilters{Referrer: "direct"}, "events", baseArgs)
+		assert.Contains(t, clause, "events.referrer IS NULL")
+		assert.Len(t, args, 4)
+	})
+
+	t.Run("event and property filters match sessions", func(t *testing.T) {
+		clause, args := repository.BuildFilterClause(models.AnalyticsFilters{
+			EventType:     "signup",
+			PropertyKey:   "plan",
+			PropertyValue: "pro",
+		}, "e", baseArgs)
+
+		assert.Contains(t, clause, "EXISTS (SELECT 1 FROM custom_events fe WHERE")
+		assert.NotContains(t, clause, "FROM events fe")
+		assert.Contains(t, clause, "fe.session_id = e.session_id")
+		assert.Contains(t, clause, "fe.event_type = $5")
+		assert.Contains(t, clause, "fe.properties ->> $6 = $7")
+		assert.Equal(t, []interface{}{"site", "from", "to", 10, "signup", "plan", "pro"}, args)
+	})
+
+	t.Run("property filter without event type searches both tables", func(t *testing.T) {
+		clause, args := repository.BuildFilterClause(models.AnalyticsFilters{PropertyKey: "plan"}, "e", baseArgs)
+		assert.Contains(t, clause, "EXISTS (SELECT 1 FROM events fe WHERE")
+		assert.Contains(t, clause, "EXISTS (SELECT 1 FROM custom_events fe WHERE")
+		assert.Len(t, args, 5)
+	})
+}
@@ -0,0 +1,115 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func checkoutFunnel(stepOrder string, windowHours int) *models.Funnel {
+	pricing, signup, purchase := "/pricing", "signup", "purchase?plan=pro"
+	return &models.Funnel{
+		StepOrder:             stepOrder,
+		ConversionWindowHours: windowHours,
+		Steps: models.FunnelSteps{
+			{ID: "3", Name: "Purchase", Type: "custom", Order: 3, Condition: models.FunnelCondition{Custom: &purchase}},
+			{ID: "1", Name: "Pricing", Type: "page", Order: 1, Condition: models.FunnelCondition{Page: &pricing}},
+			{ID: "2", Name: "Signup", Type: "event", Order: 2, Condition: models.FunnelCondition{Event: &signup}},
+		},
+	}
+}
+
+func TestFunnelEvaluator(t *testing.T) {
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	to := from.AddDate(0, 0, 7)
+	at := func(hours int) time.Time { return from.Add(time.Duration(hours) * time.Hour) }
+
+	pageview := func(page string, hours int) models.Event {
+		return models.Event{EventType: "pageview", Page: page, Timestamp: at(hours)}
+	}
+	event := func(eventType string, props models.Properties, hours int) models.Event {
+		return models.Event{EventType: eventType, Properties: props, Timestamp: at(hours)}
+	}
+
+	t.Run("strict order", func(t *testing.T) {
+		evaluator, err := utils.NewFunnelEvaluator(checkoutFunnel("strict", 24), from, to)
+		require.NoError(t, err)
+
+		progress := evaluator.Evaluate("v1", []models.Event{
+			pageview("/pricing/", 1),
+			event("signup", nil, 2),
+			event("purchase", models.Properties{"plan": "pro"}, 3),
+		})
+		assert.Equal(t, 3, progress.StepsCompleted())
+		assert.Equal(t, at(1), progress.StartedAt())
+		assert.Equal(t, 2*time.Hour, progress.Duration())
+
+		// Signing up before seeing the pricing page does not count
+		progress = evaluator.Evaluate("v2", []models.Event{
+			event("signup", nil, 1),
+			pageview("/pricing", 2),
+			event("purchase", models.Properties{"plan": "pro"}, 3),
+		})
+		assert.Equal(t, 1, progress.StepsCompleted())
+	})
+
+	t.Run("any order", func(t *testing.T) {
+		evaluator, err := utils.NewFunnelEvaluator(checkoutFunnel("any", 24), from, to)
+		require.NoError(t, err)
+
+		progress := evaluator.Evaluate("v1", []models.Event{
+			event("signup", nil, 1),
+			pageview("/pricing", 2),
+			event("purchase", models.Properties{"plan": "pro"}, 3),
+		})
+		assert.Equal(t, 3, progress.StepsCompleted())
+		assert.Equal(t, at(2), progress.StartedAt())
+	})
+
+	t.Run("conversion window", func(t *testing.T) {
+		evaluator, err := utils.NewFunnelEvaluator(checkoutFunnel("strict", 2), from, to)
+		require.NoError(t, err)
+
+		progress := evaluator.Evaluate("v1", []models.Event{
+			pageview("/pricing", 1),
+			event("signup", nil, 2),
+			event("purchase", models.Properties{"plan": "pro"}, 5),
+		})
+		assert.Equal(t, 2, progress.StepsCompleted())
+	})
+
+	t.Run("custom condition properties", func(t *testing.T) {
+		evaluator, err := utils.NewFunnelEvaluator(checkoutFunnel("strict", 24), from, to)
+		require.NoError(t, err)
+
+		progress := evaluator.Evaluate("v1", []models.Event{
+			pageview("/pricing", 1),
+			event("signup", nil, 2),
+			event("purchase", models.Properties{"plan": "free"}, 3),
+		})
+		assert.Equal(t, 2, progress.StepsCompleted())
+	})
+
+	t.Run("entry must be inside the range", func(t *testing.T) {
+		evaluator, err := utils.NewFunnelEvaluator(checkoutFunnel("strict", 24), from, to)
+		require.NoError(t, err)
+
+		progress := evaluator.Evaluate("v1", []models.Event{
+			pageview("/pricing", -1),
+			event("signup", nil, 1),
+		})
+		assert.Equal(t, 0, progress.StepsCompleted())
+	})
+}
+
+func TestValidateCustomCondition(t *testing.T) {
+	assert.NoError(t, utils.ValidateCustomCondition("purchase?plan=pro&currency=*"))
+	assert.NoError(t, utils.ValidateCustomCondition("*?page=/checkout/*"))
+	assert.NoError(t, utils.ValidateCustomCondition("plan=pro"))
+	assert.Error(t, utils.ValidateCustomCondition("*"))
+	assert.Error(t, utils.ValidateCustomCondition("purchase?plan=%zz"))
+}
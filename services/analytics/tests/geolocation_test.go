@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"analytics-app/utils"
+	"errors"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type stubGeoProvider struct {
+	locations map[string]utils.LocationInfo
+	calls     int
+}
+
+func (p *stubGeoProvider) Lookup(ip string) (*utils.LocationInfo, error) {
+	p.calls++
+	if location, ok := p.locations[ip]; ok {
+		return &location, nil
+	}
+	return nil, errors.New("not found")
+}
+
+func TestGeoLocator(t *testing.T) {
+	primary := &stubGeoProvider{locations: map[string]utils.LocationInfo{
+		"81.2.69.160": {Country: "GB", Region: "England", City: "London", ASN: 20712},
+	}}
+	fallback := &stubGeoProvider{locations: map[string]utils.LocationInfo{
+		"89.160.20.112": {Country: "SE", City: "Linköping"},
+	}}
+	locator := utils.NewGeoLocator(10, primary, fallback)
+
+	t.Run("private and empty addresses skip providers", func(t *testing.T) {
+		assert.Equal(t, utils.LocationInfo{}, locator.Locate("192.168.1.10"))
+		assert.Equal(t, utils.LocationInfo{}, locator.Locate(""))
+		assert.Zero(t, primary.calls)
+	})
+
+	t.Run("lookups are cached", func(t *testing.T) {
+		location := locator.Locate("81.2.69.160")
+		assert.Equal(t, "London", location.City)
+		assert.Equal(t, uint(20712), location.ASN)
+
+		locator.Locate("81.2.69.160")
+		assert.Equal(t, 1, primary.calls)
+	})
+
+	t.Run("falls back to the next provider", func(t *testing.T) {
+		assert.Equal(t, "SE", locator.Locate("89.160.20.112").Country)
+		assert.Equal(t, utils.LocationInfo{}, locator.Locate("203.0.113.1"))
+	})
+
+	t.Run("only country codes are locations", func(t *testing.T) {
+		invalid := &stubGeoProvider{locations: map[string]utils.LocationInfo{
+			"198.51.100.7": {Country: "Unknown", City: "Unknown"},
+			"198.51.100.8": {Country: "de", City: "Berlin"},
+		}}
+		locator := utils.NewGeoLocator(10, invalid)
+		assert.Equal(t, utils.LocationInfo{}, locator.Locate("198.51.100.7"))
+		assert.Equal(t, "DE", locator.Locate("198.51.100.8").Country)
+	})
+
+	t.Run("purge drops cached locations", func(t *testing.T) {
+		calls := primary.calls
+		locator.Purge()
+		locator.Locate("81.2.69.160")
+		assert.Equal(t, calls+1, primary.calls)
+	})
+}
+
+func TestLRUCache(t *testing.T) {
+	cache := utils.NewLRUCache[string, int](2)
+	cache.Add("a", 1)
+	cache.Add("b", 2)
+
+	// Touching "a" makes "b" the eviction candidate
+	_, ok := cache.Get("a")
+	require.True(t, ok)
+	cache.Add("c", 3)
+
+	_, ok = cache.Get("b")
+	assert.False(t, ok)
+	value, ok := cache.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, value)
+	assert.Equal(t, 2, cache.Len())
+}
+
+func TestMMDBProviderMissingFile(t *testing.T) {
+	_, err := utils.NewMMDBProvider("/nonexistent/GeoLite2-City.mmdb", "", 0, zerolog.Nop())
+	assert.Error(t, err)
+}
@@ -0,0 +1,55 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateGoal(t *testing.T) {
+	page := "/thank-you"
+	event := "signup"
+	key := "plan"
+	value := "pro"
+	negative := -5.0
+
+	assert.NoError(t, utils.ValidateGoal(&models.Goal{Name: "Thanks", WebsiteID: "site", Type: models.GoalTypePage, PagePattern: &page}))
+	assert.NoError(t, utils.ValidateGoal(&models.Goal{Name: "Pro signup", WebsiteID: "site", Type: models.GoalTypeEvent, EventName: &event, PropertyKey: &key, PropertyValue: &value}))
+
+	assert.Error(t, utils.ValidateGoal(&models.Goal{Name: "Thanks", WebsiteID: "site", Type: models.GoalTypePage}))
+	assert.Error(t, utils.ValidateGoal(&models.Goal{Name: "Signup", WebsiteID: "site", Type: models.GoalTypeEvent}))
+	assert.Error(t, utils.ValidateGoal(&models.Goal{Name: "Signup", WebsiteID: "site", Type: models.GoalTypeEvent, EventName: &event, PropertyValue: &value}))
+	assert.Error(t, utils.ValidateGoal(&models.Goal{Name: "Thanks", WebsiteID: "site", Type: "funnel", PagePattern: &page}))
+	assert.Error(t, utils.ValidateGoal(&models.Goal{Name: "Thanks", WebsiteID: "site", Type: models.GoalTypePage, PagePattern: &page, Value: &negative}))
+}
+
+func TestGoalPatterns(t *testing.T) {
+	assert.Equal(t, "/blog/%", utils.GlobToLike("/blog/*"))
+	assert.Equal(t, `50\%\_off%`, utils.GlobToLike("50%_off*"))
+
+	// Page patterns are normalized like tracked pages
+	assert.Equal(t, "/checkout/done", utils.GoalPagePattern("https://example.com/checkout/done/?ref=1"))
+	assert.Equal(t, "/docs/%", utils.GoalPagePattern("/docs/*"))
+}
+
+func TestBuildGoalConversions(t *testing.T) {
+	worth := 12.5
+	goal := &models.Goal{Type: models.GoalTypePage, Value: &worth}
+
+	result := utils.BuildGoalConversions(models.GoalCounts{Sessions: 40, Conversions: 3}, goal)
+	require.NotNil(t, result.Conversions)
+	assert.Equal(t, 3, *result.Conversions)
+	require.NotNil(t, result.ConversionRate)
+	assert.Equal(t, 7.5, *result.ConversionRate)
+	require.NotNil(t, result.GoalValue)
+	assert.Equal(t, 37.5, *result.GoalValue)
+
+	// Rows without sessions report zero, and goals without a value no value
+	result = utils.BuildGoalConversions(models.GoalCounts{}, &models.Goal{Type: models.GoalTypePage})
+	assert.Equal(t, 0, *result.Conversions)
+	assert.Equal(t, 0.0, *result.ConversionRate)
+	assert.Nil(t, result.GoalValue)
+}
@@ -0,0 +1,187 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readImport returns the records of an export and the number of skipped rows
+func readImport(t *testing.T, source, data string) ([]*utils.ImportRecord, int) {
+	importer, err := utils.NewImporter(source, strings.NewReader(data), "site")
+	require.NoError(t, err)
+
+	var records []*utils.ImportRecord
+	skipped := 0
+	for {
+		record, err := importer.Next()
+		if err == io.EOF {
+			return records, skipped
+		}
+		if errors.Is(err, utils.ErrImportRowSkipped) {
+			skipped++
+			continue
+		}
+		require.NoError(t, err)
+		records = append(records, record)
+	}
+}
+
+func TestGA4Importer(t *testing.T) {
+	data := `{"event_timestamp":"1710504000000000","event_name":"page_view","user_pseudo_id":"123.456","event_params":[{"key":"page_location","value":{"string_value":"https://example.com/pricing?utm_source=newsletter"}},{"key":"page_referrer","value":{"string_value":"https://google.com/"}},{"key":"ga_session_id","value":{"int_value":"1710504000"}}],"device":{"category":"mobile","operating_system":"iOS","web_info":{"browser":"Safari"}},"geo":{"country":"Germany","city":"Berlin"}}
+{"event_timestamp":"1710504060000000","event_name":"session_start","user_pseudo_id":"123.456"}
+not json
+{"event_timestamp":1710504120000000,"event_name":"purchase","user_pseudo_id":"123.456","event_params":[{"key":"page_location","value":{"string_value":"https://example.com/checkout"}},{"key":"currency","value":{"string_value":"EUR"}},{"key":"value","value":{"double_value":19.5}}],"geo":{"country":"(not set)"},"ecommerce":{"transaction_id":"T-1","purchase_revenue":19.5}}`
+
+	records, skipped := readImport(t, models.ImportSourceGA4, data)
+	assert.Equal(t, 2, skipped)
+	require.Len(t, records, 2)
+
+	pageview := records[0].Event
+	require.NotNil(t, pageview)
+	assert.Equal(t, "pageview", pageview.EventType)
+	assert.Equal(t, "site", pageview.WebsiteID)
+	assert.Equal(t, "123.456", pageview.VisitorID)
+	assert.Equal(t, "123.456:1710504000", pageview.SessionID)
+	assert.Equal(t, "/pricing", pageview.Page)
+	assert.Equal(t, time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC), pageview.Timestamp)
+	assert.Equal(t, "https://google.com/", *pageview.Referrer)
+	assert.Equal(t, "newsletter", *pageview.UTMSource)
+	assert.Equal(t, "DE", *pageview.Country)
+	assert.Equal(t, "Berlin", *pageview.City)
+	assert.Equal(t, "mobile", *pageview.Device)
+	assert.Nil(t, pageview.Properties)
+
+	purchase := records[1].Event
+	require.NotNil(t, purchase)
+	assert.Equal(t, models.PurchaseEventType, purchase.EventType)
+	assert.Equal(t, "/checkout", purchase.Page)
+	assert.Nil(t, purchase.Country)
+	assert.True(t, utils.IsOrder(purchase))
+	order, err := utils.ParsePurchase(purchase)
+	require.NoError(t, err)
+	assert.Equal(t, "T-1", order.OrderID)
+	assert.Equal(t, "EUR", order.Currency)
+	assert.InDelta(t, 19.5, order.Amount, 0.001)
+}
+
+func TestUniversalAnalyticsImporter(t *testing.T) {
+	data := `# ----------------------------------------
+# All Web Site Data
+# 20240301-20240302
+# ----------------------------------------
+
+Date,Users,Sessions,Bounce Rate,Avg. Session Duration,Pageviews
+20240301,"1,200","1,500",40.00%,00:02:30,"3,000"
+20240302,10,20,50%,00:00:10,30
+,"1,210","1,520",40.13%,00:02:28,"3,030"
+
+# ----------------------------------------
+`
+	records, skipped := readImport(t, models.ImportSourceUniversalAnalytics, data)
+	assert.Equal(t, 1, skipped)
+	require.Len(t, records, 2)
+
+	assert.Equal(t, &models.ImportedDailyStat{
+		Date:          time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Pageviews:     3000,
+		Visitors:      1200,
+		Visits:        1500,
+		Bounces:       600,
+		VisitDuration: 225000,
+	}, records[0].Aggregate)
+	assert.Equal(t, int64(10), records[1].Aggregate.Bounces)
+
+	byPage := "Date,Page,Pageviews\n2024-03-01,/pricing,12\n"
+	records, _ = readImport(t, models.ImportSourceUniversalAnalytics, byPage)
+	require.Len(t, records, 1)
+	assert.Equal(t, "/pricing", records[0].Aggregate.Page)
+
+	bySource := "Date,Source / Medium,Sessions\n2024-03-01,google / organic,7\n"
+	records, _ = readImport(t, models.ImportSourceUniversalAnalytics, bySource)
+	require.Len(t, records, 1)
+	assert.Equal(t, "google", records[0].Aggregate.Source)
+
+	_, err := utils.NewImporter(models.ImportSourceUniversalAnalytics, strings.NewReader("Page,Pageviews\n/,1\n"), "site")
+	assert.Error(t, err)
+}
+
+func TestPlausibleImporter(t *testing.T) {
+	data := "date,visitors,pageviews,bounces,visits,visit_duration\n2024-03-01,10,25,4,12,600\n"
+	records, skipped := readImport(t, models.ImportSourcePlausible, data)
+	assert.Zero(t, skipped)
+	require.Len(t, records, 1)
+	assert.Equal(t, &models.ImportedDailyStat{
+		Date:          time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Pageviews:     25,
+		Visitors:      10,
+		Visits:        12,
+		Bounces:       4,
+		VisitDuration: 600,
+	}, records[0].Aggregate)
+
+	// Dashboard exports report rates and averages
+	dashboard := "date,visitors,pageviews,visits,bounce_rate,visit_duration\n2024-03-01,10,25,20,25,30\n"
+	records, _ = readImport(t, models.ImportSourcePlausible, dashboard)
+	require.Len(t, records, 1)
+	assert.Equal(t, int64(5), records[0].Aggregate.Bounces)
+	assert.Equal(t, int64(600), records[0].Aggregate.VisitDuration)
+}
+
+func TestUmamiImporter(t *testing.T) {
+	data := `event_id,website_id,session_id,visit_id,created_at,url_path,url_query,referrer_domain,referrer_path,event_type,event_name,browser,os,device,country,city,utm_source
+e1,w,s1,v1,2024-03-15 12:00:00,/blog,utm_source=twitter,news.ycombinator.com,/item,1,,chrome,Mac OS,laptop,DE,Berlin,
+e2,w,s1,v1,2024-03-15 12:01:00.123+00,/blog,,,,2,signup,chrome,Mac OS,laptop,DE,Berlin,
+e3,w,,v1,2024-03-15 12:02:00,/blog,,,,1,,,,,,,
+e4,w,s1,v1,yesterday,/blog,,,,1,,,,,,,
+`
+	records, skipped := readImport(t, models.ImportSourceUmami, data)
+	assert.Equal(t, 2, skipped)
+	require.Len(t, records, 2)
+
+	pageview := records[0].Event
+	assert.Equal(t, "pageview", pageview.EventType)
+	assert.Equal(t, "s1", pageview.VisitorID)
+	assert.Equal(t, "v1", pageview.SessionID)
+	assert.Equal(t, "/blog", pageview.Page)
+	assert.Equal(t, "https://news.ycombinator.com/item", *pageview.Referrer)
+	assert.Equal(t, "twitter", *pageview.UTMSource)
+	assert.Equal(t, "Chrome", *pageview.Browser)
+	assert.Equal(t, "macOS", *pageview.OS)
+	assert.Equal(t, "desktop", *pageview.Device)
+	assert.Equal(t, "DE", *pageview.Country)
+	assert.Equal(t, time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC), pageview.Timestamp)
+
+	signup := records[1].Event
+	assert.Equal(t, "signup", signup.EventType)
+	assert.Nil(t, signup.Referrer)
+
+	_, err := utils.NewImporter(models.ImportSourceUmami, strings.NewReader("url_path\n/\n"), "site")
+	assert.Error(t, err)
+	_, err = utils.NewImporter("matomo", strings.NewReader(""), "site")
+	assert.Error(t, err)
+}
+
+func TestImportedEventsFitColumns(t *testing.T) {
+	city := strings.Repeat("ü", 150)
+	source := strings.Repeat("s", 300)
+	data := "session_id,visit_id,created_at,url_path,event_type,event_name,city,utm_source\n" +
+		"s1,v1,2024-03-15 12:00:00,/,1,," + city + "," + source + "\n" +
+		"s1,v1,2024-03-15 12:01:00,/,2," + strings.Repeat("e", 101) + ",,\n"
+
+	records, skipped := readImport(t, models.ImportSourceUmami, data)
+	// Event names are identifiers and aren't cut
+	assert.Equal(t, 1, skipped)
+	require.Len(t, records, 1)
+
+	event := records[0].Event
+	assert.Equal(t, strings.Repeat("ü", 100), *event.City)
+	assert.Len(t, *event.UTMSource, 255)
+}
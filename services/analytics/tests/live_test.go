@@ -0,0 +1,149 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/services"
+	"analytics-app/utils"
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLiveHelpers(t *testing.T) {
+	referrer := func(value string) *string { return &value }
+	assert.Equal(t, "direct", utils.LiveReferrer(nil))
+	assert.Equal(t, "direct", utils.LiveReferrer(referrer(" ")))
+	assert.Equal(t, "google.com", utils.LiveReferrer(referrer("https://www.Google.com/search?q=x")))
+	assert.Equal(t, "news.ycombinator.com", utils.LiveReferrer(referrer("news.ycombinator.com/item")))
+
+	counts := utils.CountLive([]string{"/b", "/a", "/b", "", "/c", "/a"}, 2)
+	assert.Equal(t, []models.LiveCount{{Name: "/a", Visitors: 2}, {Name: "/b", Visitors: 2}}, counts)
+	assert.Len(t, utils.CountLive([]string{"/a", "/b", "/c"}, 0), 3)
+
+	now := time.Date(2024, 3, 15, 12, 10, 30, 0, time.UTC)
+	series := utils.PageviewSeries(map[int64]int{now.Unix()/60 - 1: 4, now.Unix() / 60: 9}, now, 3)
+	require.Len(t, series, 3)
+	assert.Equal(t, time.Date(2024, 3, 15, 12, 7, 0, 0, time.UTC), series[0].Minute)
+	assert.Equal(t, 4, series[2].Pageviews)
+}
+
+func TestLiveStore(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	store := repository.NewLiveStore(client)
+	now := time.Now()
+	google := "https://www.google.com/"
+	us, de := "US", "DE"
+	pageview := func(visitorID, page string, country *string, ago time.Duration) models.Event {
+		return models.Event{
+			WebsiteID: "site", VisitorID: visitorID, SessionID: "s-" + visitorID,
+			EventType: "pageview", Page: page, Country: country, Timestamp: now.Add(-ago),
+		}
+	}
+
+	events := []models.Event{
+		{WebsiteID: "site", VisitorID: "v1", SessionID: "s-v1", EventType: models.SessionStartEventType, Referrer: &google, Timestamp: now.Add(-2 * time.Minute)},
+		pageview("v1", "/", &us, 2*time.Minute),
+		pageview("v1", "/pricing", &us, time.Minute),
+		pageview("v2", "/pricing", &de, 30*time.Second),
+		pageview("v3", "/", nil, 10*time.Second),
+		// Stale events and bots are not live
+		pageview("v4", "/old", &us, 10*time.Minute),
+		{WebsiteID: "site", VisitorID: "bot", EventType: "pageview", Page: "/", IsBot: true, Timestamp: now},
+		{WebsiteID: "site", VisitorID: "v2", EventType: "click", Page: "/pricing", Timestamp: now},
+	}
+	require.NoError(t, store.Record(ctx, events))
+
+	metrics, err := store.Snapshot(ctx, "site", now)
+	require.NoError(t, err)
+	assert.Equal(t, "site", metrics.WebsiteID)
+	assert.Equal(t, 3, metrics.ActiveUsers)
+	assert.Equal(t, 3, metrics.CurrentSessions)
+	assert.Equal(t, []models.LiveCount{{Name: "/pricing", Visitors: 2}, {Name: "/", Visitors: 1}}, metrics.CurrentPages)
+	assert.Equal(t, []models.LiveCount{{Name: "direct", Visitors: 2}, {Name: "google.com", Visitors: 1}}, metrics.Referrers)
+	assert.Equal(t, []models.LiveCount{{Name: "DE", Visitors: 1}, {Name: "US", Visitors: 1}, {Name: "unknown", Visitors: 1}}, metrics.Countries)
+	assert.Equal(t, "/", metrics.TopPageLastHour)
+	assert.Len(t, metrics.PageviewSeries, repository.LiveSeriesMinutes)
+
+	total := 0
+	for _, minute := range metrics.PageviewSeries {
+		total += minute.Pageviews
+	}
+	assert.LessOrEqual(t, total, 4)
+
+	// Visitors drop out once LiveWindow has passed since their last pageview
+	metrics, err = store.Snapshot(ctx, "site", now.Add(models.LiveWindow+45*time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, 0, metrics.ActiveUsers)
+	assert.Empty(t, metrics.CurrentPages)
+
+	metrics, err = store.Snapshot(ctx, "other", now)
+	require.NoError(t, err)
+	assert.Equal(t, 0, metrics.ActiveUsers)
+	assert.Empty(t, metrics.Referrers)
+}
+
+func TestLiveStorePrunesOnRecord(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	store := repository.NewLiveStore(client)
+	now := time.Now()
+
+	// State left by visitors and minutes long gone, on a website nobody
+	// streams
+	old := now.Add(-time.Hour)
+	oldMinute := strconv.FormatInt(old.Unix()/60-1, 10)
+	require.NoError(t, client.ZAdd(ctx, "analytics:live:site:visitors", &redis.Z{Score: float64(old.Unix()), Member: "gone"}).Err())
+	require.NoError(t, client.HSet(ctx, "analytics:live:site:page", "gone", "/old").Err())
+	require.NoError(t, client.HSet(ctx, "analytics:live:site:minutes", oldMinute, 3, oldMinute+":time", 40).Err())
+
+	event := models.Event{WebsiteID: "site", VisitorID: "v1", SessionID: "s1", EventType: "pageview", Page: "/", Timestamp: now}
+	require.NoError(t, store.Record(ctx, []models.Event{event}))
+
+	visitors, err := client.ZRange(ctx, "analytics:live:site:visitors", 0, -1).Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"v1"}, visitors)
+	pages, err := client.HGetAll(ctx, "analytics:live:site:page").Result()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"v1": "/"}, pages)
+	minutes, err := client.HKeys(ctx, "analytics:live:site:minutes").Result()
+	require.NoError(t, err)
+	assert.Equal(t, []string{strconv.FormatInt(now.Unix()/60, 10)}, minutes)
+}
+
+func TestLiveHubRecordsInBackground(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	hub := services.NewLiveHub(repository.NewLiveStore(client), time.Second, zerolog.Nop())
+	now := time.Now()
+	hub.Record([]models.Event{{WebsiteID: "site", VisitorID: "v1", SessionID: "s1", EventType: "pageview", Page: "/", Timestamp: now}})
+
+	// Shutdown records what is queued before returning
+	require.NoError(t, hub.Shutdown(5*time.Second))
+	metrics, err := hub.Snapshot(ctx, "site")
+	require.NoError(t, err)
+	assert.Equal(t, 1, metrics.ActiveUsers)
+
+	// Events arriving after shutdown are left out
+	hub.Record([]models.Event{{WebsiteID: "site", VisitorID: "v2", SessionID: "s2", EventType: "pageview", Page: "/", Timestamp: now}})
+	metrics, err = hub.Snapshot(ctx, "site")
+	require.NoError(t, err)
+	assert.Equal(t, 1, metrics.ActiveUsers)
+}
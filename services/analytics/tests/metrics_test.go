@@ -0,0 +1,54 @@
+package tests
+
+import (
+	"analytics-app/metrics"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	metrics.CountEvents("metrics-test-site", metrics.EventAccepted, 3)
+	metrics.CountEvents("metrics-test-site", metrics.EventThrottled, 0)
+	metrics.CountEvents(metrics.InvalidWebsite, metrics.EventRejected, 2)
+
+	recorder := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	require.Equal(t, 200, recorder.Code)
+	body := recorder.Body.String()
+	assert.Contains(t, body, `analytics_events_total{status="accepted",website_id="metrics-test-site"} 3`)
+	// Zero counts do not create series
+	assert.NotContains(t, body, `status="throttled",website_id="metrics-test-site"`)
+	assert.Contains(t, body, `analytics_events_total{status="rejected",website_id="invalid"} 2`)
+	assert.Contains(t, body, "go_goroutines")
+}
+
+func TestQueueCollector(t *testing.T) {
+	collector := metrics.NewQueueCollector(func(ctx context.Context) (int64, int64, int64, error) {
+		return 12, 3, 1, nil
+	}, zerolog.Nop())
+
+	expected := `
+# HELP analytics_queue_backlog Events in the ingestion stream not yet stored.
+# TYPE analytics_queue_backlog gauge
+analytics_queue_backlog 12
+# HELP analytics_queue_dead_letters Events in the dead-letter stream.
+# TYPE analytics_queue_dead_letters gauge
+analytics_queue_dead_letters 1
+`
+	require.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected),
+		"analytics_queue_backlog", "analytics_queue_dead_letters"))
+
+	failing := metrics.NewQueueCollector(func(ctx context.Context) (int64, int64, int64, error) {
+		return 0, 0, 0, errors.New("redis down")
+	}, zerolog.Nop())
+	assert.Zero(t, testutil.CollectAndCount(failing))
+}
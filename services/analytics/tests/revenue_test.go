@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func purchase(props models.Properties) *models.Event {
+	return &models.Event{
+		WebsiteID:  "site",
+		VisitorID:  "v1",
+		SessionID:  "s1",
+		EventType:  models.PurchaseEventType,
+		Properties: props,
+		Timestamp:  time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestParsePurchase(t *testing.T) {
+	order, err := utils.ParsePurchase(purchase(models.Properties{
+		"order_id": 1042.0,
+		"amount":   "59.90",
+		"currency": "eur",
+		"items": []interface{}{
+			map[string]interface{}{"id": "sku-1", "name": "Mug", "price": 19.95, "quantity": 2.0},
+			map[string]interface{}{"id": "sku-2", "price": 20.0},
+		},
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, "1042", order.OrderID)
+	assert.Equal(t, 59.9, order.Amount)
+	assert.Equal(t, "EUR", order.Currency)
+	assert.Equal(t, "s1", order.SessionID)
+	require.Len(t, order.Items, 2)
+	assert.Equal(t, 2, order.Items[0].Quantity)
+	assert.Equal(t, 1, order.Items[1].Quantity)
+
+	invalid := []models.Properties{
+		{"amount": 10.0, "currency": "USD"},
+		{"order_id": "A-1", "amount": -1.0, "currency": "USD"},
+		{"order_id": "A-1", "amount": "ten", "currency": "USD"},
+		{"order_id": "A-1", "amount": "+Inf", "currency": "USD"},
+		{"order_id": "A-1", "amount": 1e14, "currency": "USD"},
+		{"order_id": "A-1", "amount": "1e20", "currency": "USD"},
+		{"order_id": "A-1", "amount": 10.0, "currency": "dollars"},
+		{"order_id": "A-1", "amount": 10.0, "currency": "USD", "items": "sku-1"},
+		{"order_id": "A-1", "amount": 10.0, "currency": "USD", "items": []interface{}{map[string]interface{}{"id": "sku-1", "quantity": 1.5}}},
+	}
+	for _, props := range invalid {
+		_, err := utils.ParsePurchase(purchase(props))
+		assert.Error(t, err, "%v", props)
+	}
+
+	assert.True(t, utils.IsOrder(purchase(models.Properties{"order_id": "A-1"})))
+	assert.False(t, utils.IsOrder(purchase(models.Properties{"plan": "pro"})))
+}
+
+func TestCurrencyConverter(t *testing.T) {
+	rates := map[string]float64{"EUR": 0.8, "GBP": 0.5}
+
+	converter := utils.NewCurrencyConverter("EUR", rates)
+	amount, ok := converter.Convert(10, "GBP")
+	require.True(t, ok)
+	assert.InDelta(t, 16.0, amount, 1e-9)
+	amount, ok = converter.Convert(10, "USD")
+	require.True(t, ok)
+	assert.InDelta(t, 8.0, amount, 1e-9)
+	amount, ok = converter.Convert(10, "EUR")
+	require.True(t, ok)
+	assert.Equal(t, 10.0, amount)
+	_, ok = converter.Convert(10, "JPY")
+	assert.False(t, ok)
+
+	// Unknown currencies are left out of the totals
+	stats := utils.BuildRevenueStats(map[string]models.CurrencyTotals{
+		"EUR": {Orders: 2, Amount: 30},
+		"USD": {Orders: 1, Amount: 25},
+		"JPY": {Orders: 4, Amount: 9000},
+	}, converter)
+	assert.Equal(t, "EUR", stats.Currency)
+	assert.Equal(t, 3, stats.Orders)
+	assert.Equal(t, 50.0, stats.Revenue)
+	assert.Equal(t, 16.67, stats.AverageOrderValue)
+
+	from := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	orders := []models.Order{
+		{Amount: 10, Currency: "EUR", Timestamp: from.Add(-time.Hour)},
+		{Amount: 20, Currency: "EUR", Timestamp: from},
+		{Amount: 10, Currency: "USD", Timestamp: from.Add(time.Hour)},
+		{Amount: 99, Currency: "EUR", Timestamp: from.Add(24 * time.Hour)},
+	}
+	assert.InDelta(t, 28.0, converter.OrdersValue(orders, from, from.Add(24*time.Hour)), 1e-9)
+}
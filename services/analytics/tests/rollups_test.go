@@ -0,0 +1,94 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanRollupRange(t *testing.T) {
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, time.March, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	t.Run("no watermark reads raw events", func(t *testing.T) {
+		plan := repository.PlanRollupRange(at(1, 0, 0), at(3, 0, 0), time.Time{}, time.Time{})
+		assert.Empty(t, plan.Daily)
+		assert.Empty(t, plan.Hourly)
+		assert.Equal(t, []repository.TimeRange{{From: at(1, 0, 0), To: at(3, 0, 0)}}, plan.Raw)
+	})
+
+	t.Run("partial hours and unmaterialized tail are raw", func(t *testing.T) {
+		plan := repository.PlanRollupRange(at(1, 10, 30), at(1, 18, 0), at(1, 15, 20), time.Time{})
+		assert.Empty(t, plan.Daily)
+		assert.Equal(t, []repository.TimeRange{{From: at(1, 11, 0), To: at(1, 15, 0)}}, plan.Hourly)
+		assert.Equal(t, []repository.TimeRange{
+			{From: at(1, 10, 30), To: at(1, 11, 0)},
+			{From: at(1, 15, 0), To: at(1, 18, 0)},
+		}, plan.Raw)
+	})
+
+	t.Run("whole days come from the daily aggregate", func(t *testing.T) {
+		plan := repository.PlanRollupRange(at(1, 6, 0), at(5, 12, 0), at(5, 9, 0), at(4, 0, 0))
+		assert.Equal(t, []repository.TimeRange{{From: at(2, 0, 0), To: at(4, 0, 0)}}, plan.Daily)
+		assert.Equal(t, []repository.TimeRange{
+			{From: at(1, 6, 0), To: at(2, 0, 0)},
+			{From: at(4, 0, 0), To: at(5, 9, 0)},
+		}, plan.Hourly)
+		assert.Equal(t, []repository.TimeRange{{From: at(5, 9, 0), To: at(5, 12, 0)}}, plan.Raw)
+	})
+
+	t.Run("range shorter than an hour", func(t *testing.T) {
+		plan := repository.PlanRollupRange(at(1, 10, 5), at(1, 10, 50), at(2, 0, 0), at(2, 0, 0))
+		assert.Empty(t, plan.Hourly)
+		assert.Equal(t, []repository.TimeRange{{From: at(1, 10, 5), To: at(1, 10, 50)}}, plan.Raw)
+	})
+
+	t.Run("empty range", func(t *testing.T) {
+		plan := repository.PlanRollupRange(at(2, 0, 0), at(1, 0, 0), at(3, 0, 0), at(3, 0, 0))
+		assert.Empty(t, plan.Daily)
+		assert.Empty(t, plan.Hourly)
+		assert.Empty(t, plan.Raw)
+	})
+}
+
+func TestSessionRollupFilters(t *testing.T) {
+	assert.True(t, repository.SessionRollupFilters(models.AnalyticsFilters{}))
+	assert.True(t, repository.SessionRollupFilters(models.AnalyticsFilters{Country: []string{"US"}, Browser: []string{"Firefox"}}))
+	assert.False(t, repository.SessionRollupFilters(models.AnalyticsFilters{Page: "/pricing"}))
+}
+
+func TestPageviewRollupsUsable(t *testing.T) {
+	dateRange := models.DateRange{
+		From:     time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC),
+		To:       time.Date(2024, time.March, 8, 0, 0, 0, 0, time.UTC),
+		Timezone: "UTC",
+	}
+	watermark := time.Date(2024, time.March, 7, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name      string
+		filters   models.AnalyticsFilters
+		timezone  string
+		watermark time.Time
+		expected  bool
+	}{
+		{name: "materialized rollups", watermark: watermark, expected: true},
+		{name: "rollups missing without the toolkit", watermark: time.Time{}, expected: false},
+		{name: "filtered", filters: models.AnalyticsFilters{Country: []string{"US"}}, watermark: watermark, expected: false},
+		{name: "non-whole-hour offset", timezone: "Asia/Kolkata", watermark: watermark, expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := dateRange
+			if tt.timezone != "" {
+				r.Timezone = tt.timezone
+			}
+			assert.Equal(t, tt.expected, repository.PageviewRollupsUsable(tt.filters, r, tt.watermark))
+		})
+	}
+}
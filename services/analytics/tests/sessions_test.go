@@ -0,0 +1,106 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/repository"
+	"analytics-app/services"
+	"analytics-app/utils"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSessionBreak(t *testing.T) {
+	rules := utils.DefaultSessionRules()
+	start := time.Date(2024, 3, 15, 22, 0, 0, 0, time.UTC)
+	google := "google"
+	newsletter := "newsletter"
+
+	state := utils.StartSession(&models.Event{EventType: "pageview", SessionID: "client", Timestamp: start, UTMSource: &google}, nil)
+	assert.Equal(t, "client", state.SessionID)
+	assert.Equal(t, "google||", state.Campaign)
+	assert.Equal(t, 1, state.Pageviews)
+
+	at := func(d time.Duration) *models.Event {
+		return &models.Event{EventType: "pageview", Timestamp: start.Add(d)}
+	}
+	assert.Equal(t, "", utils.SessionBreak(state, at(29*time.Minute), rules))
+	assert.Equal(t, models.SessionEndTimeout, utils.SessionBreak(state, at(31*time.Minute), rules))
+	// Late events continue the session
+	assert.Equal(t, "", utils.SessionBreak(state, at(-10*time.Minute), rules))
+
+	// Pageviews without UTMs keep the campaign, a new one starts a session
+	assert.Equal(t, "", utils.SessionBreak(state, &models.Event{EventType: "pageview", Timestamp: start, UTMSource: &google}, rules))
+	assert.Equal(t, models.SessionEndCampaign, utils.SessionBreak(state, &models.Event{EventType: "pageview", Timestamp: start, UTMSource: &newsletter}, rules))
+	assert.Equal(t, "", utils.SessionBreak(state, &models.Event{EventType: "click", Timestamp: start, UTMSource: &newsletter}, rules))
+	rules.SplitOnCampaign = false
+	assert.Equal(t, "", utils.SessionBreak(state, &models.Event{EventType: "pageview", Timestamp: start, UTMSource: &newsletter}, rules))
+
+	// Sessions end at midnight of the configured timezone
+	utils.ContinueSession(state, at(115*time.Minute))
+	assert.Equal(t, 2, state.Pageviews)
+	assert.Equal(t, models.SessionEndMidnight, utils.SessionBreak(state, at(125*time.Minute), rules))
+	rules.Location = time.FixedZone("UTC-5", -5*3600)
+	assert.Equal(t, "", utils.SessionBreak(state, at(125*time.Minute), rules))
+
+	// A reused session ID is replaced
+	next := utils.StartSession(&models.Event{SessionID: "client", Timestamp: start.Add(3 * time.Hour)}, state)
+	assert.NotEqual(t, "client", next.SessionID)
+	assert.Equal(t, 0, next.Pageviews)
+}
+
+func TestSessionStitcher(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	stitcher := services.NewSessionStitcher(repository.NewSessionStore(client, 24*time.Hour), utils.DefaultSessionRules(), zerolog.Nop())
+	start := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	event := func(sessionID string, d time.Duration) models.Event {
+		return models.Event{WebsiteID: "site", VisitorID: "v1", SessionID: sessionID, EventType: "pageview", Timestamp: start.Add(d)}
+	}
+
+	// Out of order events are stitched in time order; the tracker rotating
+	// its session ID does not split the session
+	events := []models.Event{event("b", 10*time.Minute), event("a", 0), event("", 45*time.Minute)}
+	synthetic := stitcher.Stitch(ctx, events)
+
+	assert.Equal(t, "a", events[0].SessionID)
+	assert.Equal(t, "a", events[1].SessionID)
+	require.NotEmpty(t, events[2].SessionID)
+	assert.NotEqual(t, "a", events[2].SessionID)
+
+	require.Len(t, synthetic, 3)
+	assert.Equal(t, models.SessionStartEventType, synthetic[0].EventType)
+	assert.Equal(t, "a", synthetic[0].SessionID)
+	assert.Equal(t, models.SessionEndEventType, synthetic[1].EventType)
+	assert.Equal(t, "a", synthetic[1].SessionID)
+	assert.Equal(t, start.Add(10*time.Minute), synthetic[1].Timestamp)
+	assert.Equal(t, models.SessionEndTimeout, synthetic[1].Properties["reason"])
+	assert.Equal(t, 600, synthetic[1].Properties["duration"])
+	assert.Equal(t, 2, synthetic[1].Properties["pageviews"])
+	assert.Equal(t, models.SessionStartEventType, synthetic[2].EventType)
+	assert.Equal(t, events[2].SessionID, synthetic[2].SessionID)
+
+	// Idle sessions are closed once
+	ended, err := stitcher.CloseIdle(ctx, start.Add(50*time.Minute))
+	require.NoError(t, err)
+	assert.Empty(t, ended)
+
+	ended, err = stitcher.CloseIdle(ctx, start.Add(2*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, ended, 1)
+	assert.Equal(t, events[2].SessionID, ended[0].SessionID)
+	assert.Equal(t, "v1", ended[0].VisitorID)
+
+	ended, err = stitcher.CloseIdle(ctx, start.Add(3*time.Hour))
+	require.NoError(t, err)
+	assert.Empty(t, ended)
+}
@@ -0,0 +1,72 @@
+package tests
+
+import (
+	"analytics-app/utils"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWilsonInterval(t *testing.T) {
+	low, high := utils.WilsonInterval(50, 100, 0.95)
+	assert.InDelta(t, 0.4038, low, 0.0001)
+	assert.InDelta(t, 0.5962, high, 0.0001)
+
+	low, high = utils.WilsonInterval(0, 20, 0.95)
+	assert.Equal(t, 0.0, low)
+	assert.InDelta(t, 0.1611, high, 0.0001)
+
+	low, high = utils.WilsonInterval(0, 0, 0.95)
+	assert.Zero(t, low)
+	assert.Zero(t, high)
+}
+
+func TestTwoProportionZTest(t *testing.T) {
+	z, p, ok := utils.TwoProportionZTest(200, 1000, 250, 1000)
+	assert.True(t, ok)
+	assert.InDelta(t, 2.6774, z, 0.0001)
+	assert.InDelta(t, 0.00742, p, 0.00001)
+
+	_, _, ok = utils.TwoProportionZTest(0, 100, 0, 100)
+	assert.False(t, ok)
+	_, _, ok = utils.TwoProportionZTest(5, 0, 5, 100)
+	assert.False(t, ok)
+}
+
+func TestChiSquaredTest(t *testing.T) {
+	// With two samples the statistic is the square of the z-test's
+	statistic, p, df, ok := utils.ChiSquaredTest([]int{200, 250}, []int{1000, 1000})
+	assert.True(t, ok)
+	assert.Equal(t, 1, df)
+	assert.InDelta(t, 2.6774*2.6774, statistic, 0.001)
+	assert.InDelta(t, 0.00742, p, 0.00001)
+
+	_, p, df, ok = utils.ChiSquaredTest([]int{100, 110, 130}, []int{1000, 1000, 1000})
+	assert.True(t, ok)
+	assert.Equal(t, 2, df)
+	// Two degrees of freedom: p = exp(-statistic / 2)
+	assert.InDelta(t, 0.0981, p, 0.0001)
+
+	_, _, _, ok = utils.ChiSquaredTest([]int{10}, []int{100})
+	assert.False(t, ok)
+}
+
+func TestChiSquaredSurvival(t *testing.T) {
+	assert.InDelta(t, 0.05, utils.ChiSquaredSurvival(3.8415, 1), 0.0001)
+	assert.InDelta(t, 0.05, utils.ChiSquaredSurvival(5.9915, 2), 0.0001)
+	assert.InDelta(t, 0.01, utils.ChiSquaredSurvival(23.2093, 10), 0.0001)
+	assert.Equal(t, 1.0, utils.ChiSquaredSurvival(0, 3))
+}
+
+func TestSampleSizePerVariant(t *testing.T) {
+	// 10% baseline, detecting +20% relative (10% -> 12%)
+	assert.InDelta(t, 3841, utils.SampleSizePerVariant(0.10, 0.20, 0.05, 0.80), 5)
+	assert.Zero(t, utils.SampleSizePerVariant(0, 0.2, 0.05, 0.8))
+	assert.Zero(t, utils.SampleSizePerVariant(0.9, 0.2, 0.05, 0.8))
+}
+
+func TestProbabilityToBeat(t *testing.T) {
+	assert.InDelta(t, 0.5, utils.ProbabilityToBeat(50, 1000, 50, 1000), 0.001)
+	assert.InDelta(t, 0.996, utils.ProbabilityToBeat(200, 1000, 250, 1000), 0.002)
+	assert.InDelta(t, 0.004, utils.ProbabilityToBeat(250, 1000, 200, 1000), 0.002)
+}
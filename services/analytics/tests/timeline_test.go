@@ -0,0 +1,42 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergeTimeline(t *testing.T) {
+	start := time.Date(2024, 3, 15, 12, 0, 0, 0, time.UTC)
+	activity := []models.TimelineItem{
+		{Type: models.TimelinePageview, Page: "/", Timestamp: start},
+		{Type: models.TimelinePageview, Page: "/pricing", Timestamp: start.Add(time.Minute)},
+		{Type: models.TimelineEvent, EventType: "signup", Timestamp: start.Add(3 * time.Minute)},
+	}
+	progress := []models.TimelineItem{
+		{Type: models.TimelineFunnel, Funnel: &models.FunnelProgress{FunnelID: uuid.New(), Step: 1}, Timestamp: start.Add(time.Minute)},
+		{Type: models.TimelineFunnel, Funnel: &models.FunnelProgress{FunnelID: uuid.New(), Step: 2, Converted: true}, Timestamp: start.Add(4 * time.Minute)},
+	}
+
+	merged := utils.MergeTimeline(0, activity, progress)
+	require.Len(t, merged, 5)
+	types := make([]string, len(merged))
+	for i, item := range merged {
+		types[i] = item.Type
+	}
+	// Items at the same time keep the order of their sources
+	assert.Equal(t, []string{models.TimelinePageview, models.TimelinePageview, models.TimelineFunnel, models.TimelineEvent, models.TimelineFunnel}, types)
+
+	latest := utils.MergeTimeline(2, activity, progress)
+	require.Len(t, latest, 2)
+	assert.Equal(t, "signup", latest[0].EventType)
+	assert.True(t, latest[1].Funnel.Converted)
+
+	assert.NotNil(t, utils.MergeTimeline(10))
+	assert.Empty(t, utils.MergeTimeline(10))
+}
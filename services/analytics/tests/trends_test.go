@@ -0,0 +1,105 @@
+package tests
+
+import (
+	"analytics-app/models"
+	"analytics-app/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrendBuckets(t *testing.T) {
+	t.Run("daily buckets in the range timezone", func(t *testing.T) {
+		r := models.DateRange{
+			From:     time.Date(2026, 3, 1, 14, 30, 0, 0, time.UTC),
+			To:       time.Date(2026, 3, 4, 14, 30, 0, 0, time.UTC),
+			Timezone: "America/New_York",
+		}
+		buckets, err := utils.TrendBuckets(r, models.GranularityDay)
+		require.NoError(t, err)
+
+		loc := r.Location()
+		assert.Equal(t, []time.Time{
+			time.Date(2026, 3, 1, 0, 0, 0, 0, loc),
+			time.Date(2026, 3, 2, 0, 0, 0, 0, loc),
+			time.Date(2026, 3, 3, 0, 0, 0, 0, loc),
+			time.Date(2026, 3, 4, 0, 0, 0, 0, loc),
+		}, buckets)
+	})
+
+	t.Run("weeks start on Monday", func(t *testing.T) {
+		r := models.DateRange{
+			From: time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC), // Thursday
+			To:   time.Date(2026, 3, 17, 0, 0, 0, 0, time.UTC),
+		}
+		buckets, err := utils.TrendBuckets(r, models.GranularityWeek)
+		require.NoError(t, err)
+		assert.Equal(t, []time.Time{
+			time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 3, 16, 0, 0, 0, 0, time.UTC),
+		}, buckets)
+	})
+
+	t.Run("months", func(t *testing.T) {
+		r := models.DateRange{
+			From: time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+			To:   time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		}
+		buckets, err := utils.TrendBuckets(r, models.GranularityMonth)
+		require.NoError(t, err)
+		assert.Equal(t, []time.Time{
+			time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		}, buckets)
+	})
+
+	t.Run("hours skipped by DST are not repeated", func(t *testing.T) {
+		loc, err := time.LoadLocation("Europe/Berlin")
+		require.NoError(t, err)
+		r := models.DateRange{
+			From:     time.Date(2026, 3, 29, 0, 0, 0, 0, loc),
+			To:       time.Date(2026, 3, 29, 5, 0, 0, 0, loc),
+			Timezone: "Europe/Berlin",
+		}
+		buckets, err := utils.TrendBuckets(r, models.GranularityHour)
+		require.NoError(t, err)
+		assert.Len(t, buckets, 4)
+	})
+
+	t.Run("rejects unknown granularity and oversized series", func(t *testing.T) {
+		r := models.DateRange{From: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+		_, err := utils.TrendBuckets(r, "minute")
+		assert.Error(t, err)
+		_, err = utils.TrendBuckets(r, models.GranularityHour)
+		assert.Error(t, err)
+	})
+}
+
+func TestBuildTrendBuckets(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 3, d, 0, 0, 0, 0, time.UTC) }
+
+	current := []time.Time{day(8), day(9), day(10)}
+	previous := []time.Time{day(5), day(6)}
+	buckets := utils.BuildTrendBuckets(current, previous,
+		[]models.ActivityCount{{Period: day(8), PageViews: 30, Visitors: 10, Sessions: 12}, {Period: day(9), PageViews: 5, Visitors: 2, Sessions: 2}},
+		[]models.ActivityCount{{Period: day(5), PageViews: 20, Visitors: 10, Sessions: 8}},
+	)
+
+	require.Len(t, buckets, 3)
+	assert.Equal(t, day(5), *buckets[0].PreviousPeriod)
+	assert.Equal(t, 20, buckets[0].PreviousPageViews)
+	assert.InDelta(t, 50.0, *buckets[0].PageViewsChange, 0.001)
+	assert.InDelta(t, 0.0, *buckets[0].VisitorsChange, 0.001)
+	assert.InDelta(t, 50.0, *buckets[0].SessionsChange, 0.001)
+
+	// No previous activity, so there is no change to report
+	assert.Equal(t, day(6), *buckets[1].PreviousPeriod)
+	assert.Nil(t, buckets[1].PageViewsChange)
+
+	// The previous range had fewer buckets
+	assert.Nil(t, buckets[2].PreviousPeriod)
+	assert.Zero(t, buckets[2].PageViews)
+}
@@ -0,0 +1,157 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"fmt"
+	"math"
+	"time"
+)
+
+const (
+	// AlertMinBaseline is the smallest baseline relative rules and anomaly
+	// detection compare with; quieter hours are too noisy to alert on
+	AlertMinBaseline = 10
+	// MaxAlertBaselineWeeks caps the weeks anomaly rules learn from
+	MaxAlertBaselineWeeks = 4
+
+	week = 7 * 24 * time.Hour
+)
+
+// AlertLookback returns how far before the evaluated hour a rule reads the
+// hourly series
+func AlertLookback(rule *models.AlertRule) time.Duration {
+	if rule.Type == models.AlertTypeAnomaly {
+		return time.Duration(rule.BaselineWeeks) * week
+	}
+	switch rule.Baseline {
+	case models.AlertBaselinePreviousHour:
+		return time.Hour
+	case models.AlertBaselineSameHourLastWeek:
+		return week
+	}
+	return 0
+}
+
+// EvaluateAlertRule checks a rule against the hour starting at hour. series
+// maps the start of each hour to the rule's metric; hours without traffic
+// may be missing and count as zero. It returns the triggered alert, or nil.
+func EvaluateAlertRule(rule *models.AlertRule, hour time.Time, series map[time.Time]float64) *models.Alert {
+	value := series[hour]
+
+	alert := &models.Alert{
+		RuleID:    rule.ID,
+		RuleName:  rule.Name,
+		WebsiteID: rule.WebsiteID,
+		Hour:      hour,
+		Metric:    rule.Metric,
+		Value:     value,
+	}
+
+	if rule.Type == models.AlertTypeAnomaly {
+		samples := make([]float64, 0, rule.BaselineWeeks)
+		for i := 1; i <= rule.BaselineWeeks; i++ {
+			samples = append(samples, series[hour.Add(-time.Duration(i)*week)])
+		}
+		mean, std := meanAndStdDev(samples)
+		if mean < AlertMinBaseline {
+			return nil
+		}
+		// Counts are roughly Poisson, so the deviation is never taken to be
+		// below the square root of the mean; steady weeks would otherwise
+		// alert on the smallest change
+		std = math.Max(std, math.Sqrt(mean))
+		z := (value - mean) / std
+
+		triggered := false
+		switch rule.Direction {
+		case models.AlertDirectionBelow:
+			triggered = z <= -rule.Sensitivity
+		case models.AlertDirectionAbove:
+			triggered = z >= rule.Sensitivity
+		case models.AlertDirectionOutside:
+			triggered = math.Abs(z) >= rule.Sensitivity
+		}
+		if !triggered {
+			return nil
+		}
+
+		alert.Expected = mean
+		alert.ZScore = &z
+		alert.Message = fmt.Sprintf("%s %s %s, %s the usual %s for this hour (z-score %.1f)",
+			formatAlertValue(value), rule.Metric, alertHourText(hour), changeText(value, mean),
+			formatAlertValue(mean), z)
+		return alert
+	}
+
+	limit := rule.Threshold
+	alert.Expected = rule.Threshold
+	if rule.Baseline != models.AlertBaselineAbsolute {
+		base := series[hour.Add(-AlertLookback(rule))]
+		if base < AlertMinBaseline {
+			return nil
+		}
+		limit = base * rule.Threshold / 100
+		alert.Expected = base
+	}
+
+	if (rule.Direction == models.AlertDirectionBelow && value >= limit) ||
+		(rule.Direction == models.AlertDirectionAbove && value <= limit) {
+		return nil
+	}
+
+	switch rule.Baseline {
+	case models.AlertBaselinePreviousHour:
+		alert.Message = fmt.Sprintf("%s %s %s, %s %s the hour before",
+			formatAlertValue(value), rule.Metric, alertHourText(hour), changeText(value, alert.Expected),
+			formatAlertValue(alert.Expected))
+	case models.AlertBaselineSameHourLastWeek:
+		alert.Message = fmt.Sprintf("%s %s %s, %s %s the same hour last week",
+			formatAlertValue(value), rule.Metric, alertHourText(hour), changeText(value, alert.Expected),
+			formatAlertValue(alert.Expected))
+	default:
+		alert.Message = fmt.Sprintf("%s %s %s, %s the threshold of %s",
+			formatAlertValue(value), rule.Metric, alertHourText(hour), rule.Direction,
+			formatAlertValue(alert.Expected))
+	}
+	return alert
+}
+
+// meanAndStdDev returns the mean and sample standard deviation of values
+func meanAndStdDev(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+func alertHourText(hour time.Time) string {
+	return "in the hour from " + hour.UTC().Format("2006-01-02 15:04") + " UTC"
+}
+
+// changeText describes value relative to base, e.g. "down 75% from"
+func changeText(value, base float64) string {
+	change := (value - base) / base * 100
+	if change < 0 {
+		return fmt.Sprintf("down %.0f%% from", -change)
+	}
+	return fmt.Sprintf("up %.0f%% from", change)
+}
+
+func formatAlertValue(v float64) string {
+	if v == math.Trunc(v) {
+		return fmt.Sprintf("%.0f", v)
+	}
+	return fmt.Sprintf("%.1f", v)
+}
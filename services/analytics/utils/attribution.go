@@ -0,0 +1,174 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"math"
+	"sort"
+	"time"
+)
+
+// Default and maximum attribution lookback, and the default half-life of the
+// time-decay model
+const (
+	DefaultAttributionLookbackDays = 30
+	MaxAttributionLookbackDays     = 90
+	DefaultAttributionHalfLife     = 7 * 24 * time.Hour
+)
+
+// Position-based credit of the first and last touchpoints; the rest share
+// what is left
+const positionBasedEndpointShare = 0.4
+
+// AttributeConversion splits one conversion between the channels of its
+// touchpoints, oldest first, under every attribution model
+func AttributeConversion(touches []models.Touchpoint, convertedAt time.Time, halfLife time.Duration) map[string]map[string]float64 {
+	credits := make(map[string]map[string]float64, len(models.AttributionModels))
+	for _, model := range models.AttributionModels {
+		credits[model] = make(map[string]float64)
+	}
+	n := len(touches)
+	if n == 0 {
+		return credits
+	}
+
+	credits[models.AttributionFirstTouch][touches[0].Channel] += 1
+	credits[models.AttributionLastTouch][touches[n-1].Channel] += 1
+
+	// Direct visits only get the credit when there is nothing else
+	lastNonDirect := touches[n-1]
+	for i := n - 1; i >= 0; i-- {
+		if !touches[i].Direct {
+			lastNonDirect = touches[i]
+			break
+		}
+	}
+	credits[models.AttributionLastNonDirect][lastNonDirect.Channel] += 1
+
+	// Ages are taken from the newest touch rather than the conversion. The
+	// shares are the same, but the newest touch keeps a weight of 1, so the
+	// total cannot underflow to zero with a short half-life.
+	newest := touches[0].Timestamp
+	for _, touch := range touches[1:] {
+		if touch.Timestamp.After(newest) {
+			newest = touch.Timestamp
+		}
+	}
+	if newest.After(convertedAt) {
+		newest = convertedAt
+	}
+	weights := make([]float64, n)
+	var total float64
+	for i, touch := range touches {
+		age := newest.Sub(touch.Timestamp)
+		if age < 0 {
+			age = 0
+		}
+		weights[i] = math.Exp2(-age.Hours() / halfLife.Hours())
+		total += weights[i]
+	}
+
+	for i, touch := range touches {
+		credits[models.AttributionLinear][touch.Channel] += 1 / float64(n)
+		credits[models.AttributionTimeDecay][touch.Channel] += weights[i] / total
+		credits[models.AttributionPositionBased][touch.Channel] += positionBasedShare(i, n)
+	}
+
+	return credits
+}
+
+func positionBasedShare(i, n int) float64 {
+	switch {
+	case n == 1:
+		return 1
+	case n == 2:
+		return 0.5
+	case i == 0 || i == n-1:
+		return positionBasedEndpointShare
+	default:
+		return (1 - 2*positionBasedEndpointShare) / float64(n-2)
+	}
+}
+
+// AttributionBuilder accumulates attributed conversions into report channels
+type AttributionBuilder struct {
+	halfLife     time.Duration
+	conversions  int
+	unattributed int
+	channels     map[string]*models.AttributionChannel
+}
+
+func NewAttributionBuilder(halfLife time.Duration) *AttributionBuilder {
+	if halfLife <= 0 {
+		halfLife = DefaultAttributionHalfLife
+	}
+	return &AttributionBuilder{
+		halfLife: halfLife,
+		channels: make(map[string]*models.AttributionChannel),
+	}
+}
+
+// Add attributes a conversion to its touchpoints, oldest first
+func (b *AttributionBuilder) Add(touches []models.Touchpoint, convertedAt time.Time) {
+	b.conversions++
+	if len(touches) == 0 {
+		b.unattributed++
+		return
+	}
+
+	for _, touch := range touches {
+		b.channel(touch.Channel).Touchpoints++
+	}
+	for model, byChannel := range AttributeConversion(touches, convertedAt, b.halfLife) {
+		for channel, credit := range byChannel {
+			b.channel(channel).Credits[model] += credit
+		}
+	}
+}
+
+func (b *AttributionBuilder) channel(name string) *models.AttributionChannel {
+	channel, ok := b.channels[name]
+	if !ok {
+		channel = &models.AttributionChannel{Channel: name, Credits: make(models.AttributionCredits)}
+		b.channels[name] = channel
+	}
+	return channel
+}
+
+// Conversions returns the number of conversions added and how many of them
+// had no touchpoints
+func (b *AttributionBuilder) Conversions() (int, int) {
+	return b.conversions, b.unattributed
+}
+
+// Channels returns the channels by linear credit, valuing credits at
+// goalValue when set
+func (b *AttributionBuilder) Channels(goalValue *float64) []models.AttributionChannel {
+	channels := make([]models.AttributionChannel, 0, len(b.channels))
+	for _, channel := range b.channels {
+		credits := make(models.AttributionCredits, len(models.AttributionModels))
+		for _, model := range models.AttributionModels {
+			credits[model] = round4(channel.Credits[model])
+		}
+		result := models.AttributionChannel{
+			Channel:     channel.Channel,
+			Touchpoints: channel.Touchpoints,
+			Credits:     credits,
+		}
+		if goalValue != nil {
+			result.Value = make(models.AttributionCredits, len(credits))
+			for _, model := range models.AttributionModels {
+				result.Value[model] = round2(channel.Credits[model] * *goalValue)
+			}
+		}
+		channels = append(channels, result)
+	}
+
+	sort.Slice(channels, func(i, j int) bool {
+		li, lj := channels[i].Credits[models.AttributionLinear], channels[j].Credits[models.AttributionLinear]
+		if li != lj {
+			return li > lj
+		}
+		return channels[i].Channel < channels[j].Channel
+	})
+	return channels
+}
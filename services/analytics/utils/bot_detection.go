@@ -0,0 +1,238 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"strings"
+	"sync"
+	"time"
+)
+
+// BotVerdict is the result of classifying an event
+type BotVerdict struct {
+	IsBot bool
+	Name  string
+}
+
+// botPattern maps a lowercase user agent substring to a bot name
+type botPattern struct {
+	pattern string
+	name    string
+}
+
+// knownBots is checked in order, so specific names come before generic ones
+var knownBots = []botPattern{
+	{"googlebot", "Googlebot"},
+	{"adsbot-google", "Google AdsBot"},
+	{"mediapartners-google", "Google AdSense"},
+	{"google-inspectiontool", "Google Inspection Tool"},
+	{"storebot-google", "Google StoreBot"},
+	{"bingbot", "Bingbot"},
+	{"bingpreview", "Bing Preview"},
+	{"yandex", "YandexBot"},
+	{"baiduspider", "Baiduspider"},
+	{"duckduckbot", "DuckDuckBot"},
+	{"slurp", "Yahoo Slurp"},
+	{"applebot", "Applebot"},
+	{"petalbot", "PetalBot"},
+	{"seznambot", "SeznamBot"},
+	{"ahrefsbot", "AhrefsBot"},
+	{"semrushbot", "SemrushBot"},
+	{"mj12bot", "MJ12bot"},
+	{"dotbot", "DotBot"},
+	{"rogerbot", "Rogerbot"},
+	{"screaming frog", "Screaming Frog"},
+	{"gptbot", "GPTBot"},
+	{"chatgpt-user", "ChatGPT-User"},
+	{"oai-searchbot", "OAI-SearchBot"},
+	{"claudebot", "ClaudeBot"},
+	{"anthropic-ai", "Anthropic"},
+	{"perplexitybot", "PerplexityBot"},
+	{"ccbot", "CCBot"},
+	{"bytespider", "Bytespider"},
+	{"amazonbot", "Amazonbot"},
+	{"facebookexternalhit", "Facebook"},
+	{"facebookbot", "Facebook"},
+	{"meta-externalagent", "Meta"},
+	{"twitterbot", "Twitterbot"},
+	{"linkedinbot", "LinkedInBot"},
+	{"slackbot", "Slackbot"},
+	{"discordbot", "Discordbot"},
+	{"telegrambot", "TelegramBot"},
+	{"whatsapp", "WhatsApp"},
+	{"pinterestbot", "Pinterestbot"},
+	{"uptimerobot", "UptimeRobot"},
+	{"pingdom", "Pingdom"},
+	{"statuscake", "StatusCake"},
+	{"site24x7", "Site24x7"},
+	{"lighthouse", "Lighthouse"},
+	{"pagespeed", "PageSpeed Insights"},
+	{"gtmetrix", "GTmetrix"},
+	{"headlesschrome", "Headless Chrome"},
+	{"phantomjs", "PhantomJS"},
+	{"selenium", "Selenium"},
+	{"puppeteer", "Puppeteer"},
+	{"playwright", "Playwright"},
+	{"curl/", "curl"},
+	{"wget/", "Wget"},
+	{"python-requests", "Python Requests"},
+	{"python-urllib", "Python urllib"},
+	{"aiohttp", "aiohttp"},
+	{"httpx", "HTTPX"},
+	{"scrapy", "Scrapy"},
+	{"go-http-client", "Go HTTP client"},
+	{"java/", "Java"},
+	{"okhttp", "OkHttp"},
+	{"apache-httpclient", "Apache HttpClient"},
+	{"node-fetch", "node-fetch"},
+	{"axios/", "axios"},
+	{"libwww-perl", "libwww-perl"},
+	{"postmanruntime", "Postman"},
+	{"insomnia", "Insomnia"},
+}
+
+// genericBotKeywords catch crawlers that are not in knownBots
+var genericBotKeywords = []string{"bot", "crawler", "spider", "scraper", "crawling", "fetcher", "monitor", "preview"}
+
+// datacenterASNs are hosting providers; real visitors rarely browse from them
+var datacenterASNs = map[uint]string{
+	16509:  "AWS",
+	14618:  "AWS",
+	8987:   "AWS",
+	396982: "Google Cloud",
+	8075:   "Microsoft Azure",
+	14061:  "DigitalOcean",
+	16276:  "OVH",
+	24940:  "Hetzner",
+	63949:  "Linode",
+	20473:  "Vultr",
+	51167:  "Contabo",
+	12876:  "Scaleway",
+	31898:  "Oracle Cloud",
+	45102:  "Alibaba Cloud",
+	132203: "Tencent Cloud",
+	9009:   "M247",
+	60781:  "Leaseweb",
+	36352:  "ColoCrossing",
+}
+
+// BotClassifierConfig tunes the rate based detection
+type BotClassifierConfig struct {
+	// MaxEventsPerMinute is the highest plausible event rate for one visitor
+	MaxEventsPerMinute int
+	// TrackedVisitors bounds the memory used for per-visitor rate tracking
+	TrackedVisitors int
+}
+
+// DefaultBotClassifierConfig returns the default limits
+func DefaultBotClassifierConfig() BotClassifierConfig {
+	return BotClassifierConfig{
+		MaxEventsPerMinute: 120,
+		TrackedVisitors:    100000,
+	}
+}
+
+// BotClassifier flags automated traffic at ingestion time using user agent
+// patterns, headless browser signals, datacenter networks and implausible
+// per-visitor event rates
+type BotClassifier struct {
+	config BotClassifierConfig
+	// rates is keyed by website and visitor
+	rates *LRUCache[string, *visitorRate]
+}
+
+type visitorRate struct {
+	mu          sync.Mutex
+	windowStart time.Time
+	count       int
+	flagged     bool
+}
+
+// NewBotClassifier creates a classifier
+func NewBotClassifier(config BotClassifierConfig) *BotClassifier {
+	defaults := DefaultBotClassifierConfig()
+	if config.MaxEventsPerMinute <= 0 {
+		config.MaxEventsPerMinute = defaults.MaxEventsPerMinute
+	}
+	if config.TrackedVisitors <= 0 {
+		config.TrackedVisitors = defaults.TrackedVisitors
+	}
+	return &BotClassifier{
+		config: config,
+		rates:  NewLRUCache[string, *visitorRate](config.TrackedVisitors),
+	}
+}
+
+// Classify decides whether an event was sent by a bot. asn is the network of
+// the visitor's IP, or zero when unknown.
+func (c *BotClassifier) Classify(event *models.Event, asn uint) BotVerdict {
+	if event.UserAgent != nil {
+		if name := BotNameFromUserAgent(*event.UserAgent); name != "" {
+			return BotVerdict{IsBot: true, Name: name}
+		}
+	}
+
+	if webdriver, ok := event.Properties["webdriver"].(bool); ok && webdriver {
+		return BotVerdict{IsBot: true, Name: "Automated browser"}
+	}
+
+	if provider, ok := datacenterASNs[asn]; ok {
+		return BotVerdict{IsBot: true, Name: "Datacenter (" + provider + ")"}
+	}
+
+	if event.VisitorID != "" && c.exceedsRate(event.WebsiteID+":"+event.VisitorID, time.Now()) {
+		return BotVerdict{IsBot: true, Name: "High event rate"}
+	}
+
+	return BotVerdict{}
+}
+
+// exceedsRate counts the visitor's events per minute. Once a visitor goes
+// over the limit it stays flagged while it is tracked.
+func (c *BotClassifier) exceedsRate(key string, now time.Time) bool {
+	rate, ok := c.rates.Get(key)
+	if !ok {
+		rate = &visitorRate{windowStart: now}
+		c.rates.Add(key, rate)
+	}
+
+	rate.mu.Lock()
+	defer rate.mu.Unlock()
+
+	if now.Sub(rate.windowStart) >= time.Minute {
+		rate.windowStart = now
+		rate.count = 0
+	}
+	rate.count++
+	if rate.count > c.config.MaxEventsPerMinute {
+		rate.flagged = true
+	}
+	return rate.flagged
+}
+
+// BotNameFromUserAgent returns the bot name for a crawler, monitoring tool,
+// headless browser or HTTP library user agent, or "" for regular browsers
+func BotNameFromUserAgent(userAgent string) string {
+	ua := strings.ToLower(strings.TrimSpace(userAgent))
+	if ua == "" {
+		return "Empty user agent"
+	}
+
+	for _, bot := range knownBots {
+		if strings.Contains(ua, bot.pattern) {
+			return bot.name
+		}
+	}
+
+	for _, keyword := range genericBotKeywords {
+		if strings.Contains(ua, keyword) {
+			return "Other bot"
+		}
+	}
+
+	// Every mainstream browser sends a Mozilla/ or Opera/ token
+	if !strings.HasPrefix(ua, "mozilla/") && !strings.HasPrefix(ua, "opera/") {
+		return "Other bot"
+	}
+
+	return ""
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"time"
+)
+
+// CohortPeriodStart returns the start of the period-th period after start
+func CohortPeriodStart(start time.Time, granularity string, period int) time.Time {
+	if granularity == models.GranularityMonth {
+		return start.AddDate(0, period, 0)
+	}
+	return start.AddDate(0, 0, 7*period)
+}
+
+// BuildCohorts turns cohort cells into a retention matrix. Cell starts are
+// local midnights read back without a zone and are placed in loc. Each
+// cohort lists periods 0 to periods that have begun by now, with zeros for
+// periods without returning visitors.
+func BuildCohorts(cells []models.CohortCell, granularity string, periods int, loc *time.Location, now time.Time) []models.Cohort {
+	var cohorts []models.Cohort
+	index := make(map[time.Time]int)
+	visitors := make(map[time.Time]map[int]int)
+
+	for _, cell := range cells {
+		y, m, d := cell.Start.Date()
+		start := time.Date(y, m, d, 0, 0, 0, 0, loc)
+		if _, ok := index[start]; !ok {
+			index[start] = len(cohorts)
+			cohorts = append(cohorts, models.Cohort{Start: start})
+			visitors[start] = make(map[int]int)
+		}
+		if cell.Period == 0 {
+			cohorts[index[start]].Size = cell.Visitors
+		}
+		visitors[start][cell.Period] = cell.Visitors
+	}
+
+	for i := range cohorts {
+		cohort := &cohorts[i]
+		cohort.Retention = []models.CohortRetention{}
+		for period := 0; period <= periods; period++ {
+			if CohortPeriodStart(cohort.Start, granularity, period).After(now) {
+				break
+			}
+			retention := models.CohortRetention{Period: period, Visitors: visitors[cohort.Start][period]}
+			if cohort.Size > 0 {
+				retention.Rate = float64(retention.Visitors) * 100 / float64(cohort.Size)
+			}
+			cohort.Retention = append(cohort.Retention, retention)
+		}
+	}
+	return cohorts
+}
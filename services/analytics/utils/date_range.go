@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// DateRangeParams holds the raw query parameters used to build a DateRange
+type DateRangeParams struct {
+	From     string
+	To       string
+	Timezone string
+	Days     string
+}
+
+const dateOnlyLayout = "2006-01-02"
+
+// MaxDateRangeDays bounds the length of a date range, which leaves room for
+// imported history
+const MaxDateRangeDays = 20 * 366
+
+// MaxHourlyRangeDays bounds the length of a range reported hour by hour
+const MaxHourlyRangeDays = 92
+
+// ParseDateRange builds a DateRange from query parameters.
+//
+// `from` and `to` accept either RFC3339 timestamps or plain dates (YYYY-MM-DD).
+// Plain dates are interpreted in the requested timezone and `to` is inclusive,
+// so from=2026-03-01&to=2026-03-15 covers both days completely. When neither
+// bound is given the legacy `days` parameter (or defaultDays) is used.
+func ParseDateRange(params DateRangeParams, defaultDays int, now time.Time) (models.DateRange, error) {
+	timezone := params.Timezone
+	if timezone == "" {
+		timezone = "UTC"
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return models.DateRange{}, fmt.Errorf("invalid timezone: %s", timezone)
+	}
+
+	days := defaultDays
+	if params.Days != "" {
+		parsedDays, err := strconv.Atoi(params.Days)
+		if err != nil || parsedDays < 1 || parsedDays > MaxDateRangeDays {
+			return models.DateRange{}, fmt.Errorf("days must be between 1 and %d", MaxDateRangeDays)
+		}
+		days = parsedDays
+	}
+
+	if params.From == "" && params.To == "" {
+		now = now.UTC()
+		return models.DateRange{
+			From:         now.Add(-time.Duration(days) * 24 * time.Hour),
+			To:           now,
+			Timezone:     timezone,
+			RelativeDays: days,
+		}, nil
+	}
+
+	var from, to time.Time
+	if params.To != "" {
+		to, err = parseRangeBound(params.To, loc, true)
+		if err != nil {
+			return models.DateRange{}, fmt.Errorf("invalid to: %w", err)
+		}
+	} else {
+		to = now
+	}
+
+	if params.From != "" {
+		from, err = parseRangeBound(params.From, loc, false)
+		if err != nil {
+			return models.DateRange{}, fmt.Errorf("invalid from: %w", err)
+		}
+	} else {
+		from = to.AddDate(0, 0, -days)
+	}
+
+	if !from.Before(to) {
+		return models.DateRange{}, errors.New("from must be before to")
+	}
+	if to.Sub(from) > MaxDateRangeDays*24*time.Hour {
+		return models.DateRange{}, fmt.Errorf("date range must be at most %d days", MaxDateRangeDays)
+	}
+
+	return models.DateRange{
+		From:     from.UTC(),
+		To:       to.UTC(),
+		Timezone: timezone,
+	}, nil
+}
+
+// CheckHourlyRange rejects ranges too long to report hour by hour
+func CheckHourlyRange(dateRange models.DateRange) error {
+	if dateRange.Duration() > MaxHourlyRangeDays*24*time.Hour {
+		return fmt.Errorf("hourly stats cover at most %d days", MaxHourlyRangeDays)
+	}
+	return nil
+}
+
+// parseRangeBound parses a single bound; plain dates used as an upper bound
+// are advanced to the following midnight so the day is included.
+func parseRangeBound(value string, loc *time.Location, upper bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+
+	t, err := time.ParseInLocation(dateOnlyLayout, value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("expected YYYY-MM-DD or RFC3339, got %q", value)
+	}
+	if upper {
+		t = t.AddDate(0, 0, 1)
+	}
+	return t, nil
+}
@@ -0,0 +1,33 @@
+package utils
+
+import "math"
+
+// Engagement score weights and the values at which a component maxes out
+const (
+	engagementBounceWeight   = 40.0
+	engagementPagesWeight    = 30.0
+	engagementDurationWeight = 30.0
+
+	EngagementTargetPages   = 5.0   // pages per session
+	EngagementTargetSeconds = 300.0 // average session time
+)
+
+// EngagementScore rates sessions from 0 to 100:
+//
+//   - 40 points for sessions that do not bounce, (100 - bounce rate) * 0.4
+//   - 30 points for pages per session beyond the first, reaching the maximum
+//     at 5 pages
+//   - 30 points for average session time, reaching the maximum at 5 minutes
+//
+// A site where every session bounces scores 0; one where sessions average 5
+// pages over 5 minutes without bounces scores 100.
+func EngagementScore(bounceRate, pagesPerSession, avgSessionSeconds float64) float64 {
+	ratio := func(value, target float64) float64 {
+		return math.Max(0, math.Min(value/target, 1))
+	}
+
+	score := engagementBounceWeight*ratio(100-bounceRate, 100) +
+		engagementPagesWeight*ratio(pagesPerSession-1, EngagementTargetPages-1) +
+		engagementDurationWeight*ratio(avgSessionSeconds, EngagementTargetSeconds)
+	return math.Round(score*10) / 10
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"fmt"
+	"time"
+)
+
+// ExperimentEvaluator assigns visitors to experiment variants and checks
+// whether they reached the goal.
+//
+// A visitor is assigned by their first event inside [from, to) whose
+// experiment property holds a known variant key. They convert when they
+// enter the goal within the conversion window after that event and, for
+// funnel goals, complete the funnel within the funnel's own window.
+type ExperimentEvaluator struct {
+	property string
+	variants map[string]bool
+	goal     *FunnelEvaluator
+	window   time.Duration
+	from, to time.Time
+}
+
+// NewExperimentEvaluator compiles the experiment's goal. goalFunnel is the
+// funnel of a funnel goal and is ignored for event goals, which are matched
+// like a single custom funnel step within the default conversion window.
+func NewExperimentEvaluator(experiment *models.Experiment, goalFunnel *models.Funnel, from, to time.Time) (*ExperimentEvaluator, error) {
+	var funnel *models.Funnel
+	switch experiment.GoalType {
+	case models.ExperimentGoalEvent:
+		if experiment.GoalEvent == nil {
+			return nil, fmt.Errorf("experiment has no goal event")
+		}
+		funnel = &models.Funnel{
+			Steps: models.FunnelSteps{{
+				Name:      "goal",
+				Type:      "custom",
+				Order:     1,
+				Condition: models.FunnelCondition{Custom: experiment.GoalEvent},
+			}},
+		}
+	case models.ExperimentGoalFunnel:
+		if goalFunnel == nil {
+			return nil, fmt.Errorf("experiment goal funnel not found")
+		}
+		funnel = goalFunnel
+	default:
+		return nil, fmt.Errorf("unknown goal type %q", experiment.GoalType)
+	}
+
+	goal, err := NewFunnelEvaluator(funnel, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("goal: %w", err)
+	}
+
+	variants := make(map[string]bool, len(experiment.Variants))
+	for _, variant := range experiment.Variants {
+		variants[variant.Key] = true
+	}
+
+	return &ExperimentEvaluator{
+		property: experiment.Property,
+		variants: variants,
+		goal:     goal,
+		window:   goal.Window(),
+		from:     from,
+		to:       to,
+	}, nil
+}
+
+// Horizon returns how long after the range's end events can still count:
+// one window to enter the goal and, for multi-step goals, another to
+// complete it
+func (e *ExperimentEvaluator) Horizon() time.Duration {
+	if e.goal.StepCount() > 1 {
+		return 2 * e.window
+	}
+	return e.window
+}
+
+// Evaluate returns the visitor's variant, empty when they were not assigned
+// in the range, and whether they converted. Events must belong to a single
+// visitor and be sorted by timestamp.
+func (e *ExperimentEvaluator) Evaluate(visitorID string, events []models.Event) (variant string, converted bool) {
+	for i, event := range events {
+		if event.Timestamp.Before(e.from) || !event.Timestamp.Before(e.to) {
+			continue
+		}
+		value, ok := event.Properties[e.property]
+		if !ok || value == nil {
+			continue
+		}
+		key := fmt.Sprintf("%v", value)
+		if !e.variants[key] {
+			continue
+		}
+
+		assignedAt := event.Timestamp
+		progress := e.goal.EvaluateWithin(visitorID, events[i:], assignedAt, assignedAt.Add(e.window))
+		return key, progress.StepsCompleted() == e.goal.StepCount()
+	}
+	return "", false
+}
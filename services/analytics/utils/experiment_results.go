@@ -0,0 +1,122 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"math"
+)
+
+const (
+	// ExperimentConfidence is the confidence level of intervals and tests
+	ExperimentConfidence = 0.95
+	// ExperimentPower is the statistical power used for sample size guidance
+	ExperimentPower = 0.8
+)
+
+// VariantCounts holds the visitors assigned to a variant and how many converted
+type VariantCounts struct {
+	Visitors    int
+	Conversions int
+}
+
+// BuildExperimentResults compares every variant with the control. Variants
+// are listed in the experiment's order; variants without visitors are
+// included with zero counts.
+func BuildExperimentResults(experiment *models.Experiment, counts map[string]VariantCounts) models.ExperimentResults {
+	alpha := 1 - ExperimentConfidence
+	control := counts[experiment.Control]
+
+	results := models.ExperimentResults{
+		ExperimentID: experiment.ID,
+		Confidence:   ExperimentConfidence * 100,
+		Test:         "z-test",
+	}
+	if len(experiment.Variants) > 2 {
+		results.Test = "chi-squared"
+	}
+
+	successes := make([]int, 0, len(experiment.Variants))
+	trials := make([]int, 0, len(experiment.Variants))
+	smallest := -1
+	for _, variant := range experiment.Variants {
+		c := counts[variant.Key]
+		successes = append(successes, c.Conversions)
+		trials = append(trials, c.Visitors)
+		if smallest == -1 || c.Visitors < smallest {
+			smallest = c.Visitors
+		}
+
+		low, high := WilsonInterval(c.Conversions, c.Visitors, ExperimentConfidence)
+		result := models.VariantResult{
+			Key:            variant.Key,
+			Name:           variant.Name,
+			Control:        variant.Key == experiment.Control,
+			Visitors:       c.Visitors,
+			Conversions:    c.Conversions,
+			ConversionRate: percentage(c.Conversions, c.Visitors),
+			ConfidenceLow:  round2(low * 100),
+			ConfidenceHigh: round2(high * 100),
+		}
+
+		if !result.Control && c.Visitors > 0 && control.Visitors > 0 {
+			if control.Conversions > 0 {
+				variantRate := float64(c.Conversions) / float64(c.Visitors)
+				controlRate := float64(control.Conversions) / float64(control.Visitors)
+				lift := round2((variantRate/controlRate - 1) * 100)
+				result.Lift = &lift
+			}
+			if z, p, ok := TwoProportionZTest(control.Conversions, control.Visitors, c.Conversions, c.Visitors); ok {
+				z, p = round4(z), round4(p)
+				result.ZScore = &z
+				result.PValue = &p
+				result.Significant = p < alpha
+			}
+			probability := round4(ProbabilityToBeat(control.Conversions, control.Visitors, c.Conversions, c.Visitors))
+			result.ProbabilityToWin = &probability
+		}
+
+		results.Variants = append(results.Variants, result)
+	}
+
+	if len(experiment.Variants) == 2 {
+		for _, variant := range results.Variants {
+			if !variant.Control && variant.PValue != nil {
+				results.PValue = variant.PValue
+			}
+		}
+	} else if _, p, _, ok := ChiSquaredTest(successes, trials); ok {
+		p = round4(p)
+		results.PValue = &p
+	}
+	results.Significant = results.PValue != nil && *results.PValue < alpha
+
+	baseline := 0.0
+	if control.Visitors > 0 {
+		baseline = float64(control.Conversions) / float64(control.Visitors)
+	}
+	results.SampleSize = models.SampleSize{
+		BaselineRate:            round2(baseline * 100),
+		MinimumDetectableEffect: experiment.MinimumDetectableEffect,
+		Power:                   ExperimentPower,
+		PerVariant:              SampleSizePerVariant(baseline, experiment.MinimumDetectableEffect, alpha, ExperimentPower),
+	}
+	if results.SampleSize.PerVariant > smallest {
+		results.SampleSize.Remaining = results.SampleSize.PerVariant - smallest
+	}
+
+	return results
+}
+
+func percentage(part, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return round2(float64(part) * 100 / float64(total))
+}
+
+func round2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+func round4(v float64) float64 {
+	return math.Round(v*10000) / 10000
+}
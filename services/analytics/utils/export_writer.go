@@ -0,0 +1,294 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/parquet-go/parquet-go"
+)
+
+// parquetRowGroupSize is the number of rows buffered before a Parquet row
+// group is written out
+const parquetRowGroupSize = 10000
+
+// ExportWriter encodes export rows in one of the export formats
+type ExportWriter interface {
+	// Write adds a row whose values are in the order of the columns
+	Write(values []interface{}) error
+	// Close writes what is still buffered; it does not close the underlying
+	// writer
+	Close() error
+}
+
+// NewExportWriter returns a writer encoding rows of columns to w in format
+func NewExportWriter(w io.Writer, format string, columns []models.ExportColumn) (ExportWriter, error) {
+	switch format {
+	case models.ExportFormatCSV:
+		return newCSVExportWriter(w, columns)
+	case models.ExportFormatNDJSON:
+		return newNDJSONExportWriter(w, columns), nil
+	case models.ExportFormatParquet:
+		return newParquetExportWriter(w, columns), nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q", format)
+	}
+}
+
+// exportValue normalizes a value read from the database to nil, string,
+// int64, float64, bool or a UTC time.Time
+func exportValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case nil, string, int64, float64, bool:
+		return v
+	case int:
+		return int64(v)
+	case int16:
+		return int64(v)
+	case int32:
+		return int64(v)
+	case float32:
+		return float64(v)
+	case time.Time:
+		return v.UTC()
+	case []byte:
+		return string(v)
+	case *string:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *int:
+		if v == nil {
+			return nil
+		}
+		return int64(*v)
+	case *float64:
+		if v == nil {
+			return nil
+		}
+		return *v
+	case *time.Time:
+		if v == nil {
+			return nil
+		}
+		return v.UTC()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+type csvExportWriter struct {
+	writer *csv.Writer
+	record []string
+}
+
+func newCSVExportWriter(w io.Writer, columns []models.ExportColumn) (*csvExportWriter, error) {
+	writer := csv.NewWriter(w)
+	header := make([]string, len(columns))
+	for i, column := range columns {
+		header[i] = column.Name
+	}
+	if err := writer.Write(header); err != nil {
+		return nil, err
+	}
+	return &csvExportWriter{writer: writer, record: make([]string, len(columns))}, nil
+}
+
+func (w *csvExportWriter) Write(values []interface{}) error {
+	for i := range w.record {
+		w.record[i] = ""
+		if i >= len(values) {
+			continue
+		}
+		switch v := exportValue(values[i]).(type) {
+		case nil:
+		case string:
+			w.record[i] = v
+		case int64:
+			w.record[i] = strconv.FormatInt(v, 10)
+		case float64:
+			w.record[i] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			w.record[i] = strconv.FormatBool(v)
+		case time.Time:
+			w.record[i] = v.Format(time.RFC3339Nano)
+		}
+	}
+	return w.writer.Write(w.record)
+}
+
+func (w *csvExportWriter) Close() error {
+	w.writer.Flush()
+	return w.writer.Error()
+}
+
+type ndjsonExportWriter struct {
+	writer *bufio.Writer
+	keys   [][]byte
+}
+
+func newNDJSONExportWriter(w io.Writer, columns []models.ExportColumn) *ndjsonExportWriter {
+	keys := make([][]byte, len(columns))
+	for i, column := range columns {
+		key, _ := json.Marshal(column.Name)
+		keys[i] = key
+	}
+	return &ndjsonExportWriter{writer: bufio.NewWriter(w), keys: keys}
+}
+
+// Write encodes the row as an object keeping the order of the columns
+func (w *ndjsonExportWriter) Write(values []interface{}) error {
+	w.writer.WriteByte('{')
+	for i, key := range w.keys {
+		var value interface{}
+		if i < len(values) {
+			value = exportValue(values[i])
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		if i > 0 {
+			w.writer.WriteByte(',')
+		}
+		w.writer.Write(key)
+		w.writer.WriteByte(':')
+		w.writer.Write(encoded)
+	}
+	w.writer.WriteByte('}')
+	_, err := w.writer.WriteString("\n")
+	return err
+}
+
+func (w *ndjsonExportWriter) Close() error {
+	return w.writer.Flush()
+}
+
+type parquetExportWriter struct {
+	writer  *parquet.Writer
+	columns []models.ExportColumn
+	// index holds the position of each column in the schema, which orders
+	// columns by name
+	index []int
+	rows  []parquet.Row
+}
+
+func newParquetExportWriter(w io.Writer, columns []models.ExportColumn) *parquetExportWriter {
+	group := parquet.Group{}
+	for _, column := range columns {
+		var node parquet.Node
+		switch column.Type {
+		case models.ExportInt:
+			node = parquet.Int(64)
+		case models.ExportFloat:
+			node = parquet.Leaf(parquet.DoubleType)
+		case models.ExportBool:
+			node = parquet.Leaf(parquet.BooleanType)
+		case models.ExportTime:
+			node = parquet.Timestamp(parquet.Microsecond)
+		default:
+			node = parquet.String()
+		}
+		group[column.Name] = parquet.Optional(node)
+	}
+	schema := parquet.NewSchema("export", group)
+
+	index := make([]int, len(columns))
+	for i, column := range columns {
+		leaf, _ := schema.Lookup(column.Name)
+		index[i] = leaf.ColumnIndex
+	}
+
+	return &parquetExportWriter{
+		writer:  parquet.NewWriter(w, schema, parquet.Compression(&parquet.Snappy)),
+		columns: columns,
+		index:   index,
+		rows:    make([]parquet.Row, 0, parquetRowGroupSize),
+	}
+}
+
+func (w *parquetExportWriter) Write(values []interface{}) error {
+	row := make(parquet.Row, len(w.columns))
+	for i, column := range w.columns {
+		var value interface{}
+		if i < len(values) {
+			value = exportValue(values[i])
+		}
+		converted := parquetValue(column.Type, value)
+		definition := 1
+		if converted.IsNull() {
+			definition = 0
+		}
+		row[w.index[i]] = converted.Level(0, definition, w.index[i])
+	}
+	w.rows = append(w.rows, row)
+	if len(w.rows) == cap(w.rows) {
+		return w.flush()
+	}
+	return nil
+}
+
+func (w *parquetExportWriter) flush() error {
+	if len(w.rows) == 0 {
+		return nil
+	}
+	if _, err := w.writer.WriteRows(w.rows); err != nil {
+		return err
+	}
+	w.rows = w.rows[:0]
+	return w.writer.Flush()
+}
+
+// Close writes the buffered rows and the file footer
+func (w *parquetExportWriter) Close() error {
+	if err := w.flush(); err != nil {
+		return err
+	}
+	return w.writer.Close()
+}
+
+// parquetValue converts a normalized value to the physical type of a
+// column; values that do not fit the column are written as null
+func parquetValue(columnType string, value interface{}) parquet.Value {
+	if value == nil {
+		return parquet.NullValue()
+	}
+	switch columnType {
+	case models.ExportInt:
+		switch v := value.(type) {
+		case int64:
+			return parquet.Int64Value(v)
+		case float64:
+			return parquet.Int64Value(int64(v))
+		}
+	case models.ExportFloat:
+		switch v := value.(type) {
+		case float64:
+			return parquet.DoubleValue(v)
+		case int64:
+			return parquet.DoubleValue(float64(v))
+		}
+	case models.ExportBool:
+		if v, ok := value.(bool); ok {
+			return parquet.BooleanValue(v)
+		}
+	case models.ExportTime:
+		if v, ok := value.(time.Time); ok {
+			return parquet.Int64Value(v.UnixMicro())
+		}
+	default:
+		if v, ok := value.(string); ok {
+			return parquet.ByteArrayValue([]byte(v))
+		}
+		return parquet.ByteArrayValue([]byte(fmt.Sprint(value)))
+	}
+	return parquet.NullValue()
+}
@@ -0,0 +1,98 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxFilterValues      = 20
+	maxFilterValueLength = 255
+)
+
+// ParseAnalyticsFilters builds AnalyticsFilters from query parameters.
+//
+// List dimensions accept comma separated values or repeated parameters,
+// e.g. country=DE,AT or country=DE&country=AT.
+func ParseAnalyticsFilters(query url.Values) (models.AnalyticsFilters, error) {
+	var filters models.AnalyticsFilters
+	var err error
+
+	lists := []struct {
+		param  string
+		target *[]string
+	}{
+		{"country", &filters.Country},
+		{"city", &filters.City},
+		{"browser", &filters.Browser},
+		{"device", &filters.Device},
+		{"os", &filters.OS},
+		{"utm_source", &filters.UTMSource},
+		{"utm_medium", &filters.UTMMedium},
+		{"utm_campaign", &filters.UTMCampaign},
+		{"utm_term", &filters.UTMTerm},
+		{"utm_content", &filters.UTMContent},
+	}
+	for _, l := range lists {
+		if *l.target, err = filterValues(l.param, query[l.param]); err != nil {
+			return models.AnalyticsFilters{}, err
+		}
+	}
+
+	singles := []struct {
+		param  string
+		target *string
+	}{
+		{"page", &filters.Page},
+		{"referrer", &filters.Referrer},
+		{"event_type", &filters.EventType},
+		{"property_key", &filters.PropertyKey},
+		{"property_value", &filters.PropertyValue},
+	}
+	for _, s := range singles {
+		value := strings.TrimSpace(query.Get(s.param))
+		if len(value) > maxFilterValueLength {
+			return models.AnalyticsFilters{}, fmt.Errorf("%s filter is too long", s.param)
+		}
+		*s.target = value
+	}
+
+	if raw := query.Get("include_bots"); raw != "" {
+		includeBots, err := strconv.ParseBool(raw)
+		if err != nil {
+			return models.AnalyticsFilters{}, errors.New("include_bots must be true or false")
+		}
+		filters.IncludeBots = includeBots
+	}
+
+	if filters.PropertyValue != "" && filters.PropertyKey == "" {
+		return models.AnalyticsFilters{}, errors.New("property_value requires property_key")
+	}
+
+	return filters, nil
+}
+
+// filterValues splits and validates the values of a list filter
+func filterValues(param string, raw []string) ([]string, error) {
+	var values []string
+	for _, entry := range raw {
+		for _, value := range strings.Split(entry, ",") {
+			value = strings.TrimSpace(value)
+			if value == "" {
+				continue
+			}
+			if len(value) > maxFilterValueLength {
+				return nil, fmt.Errorf("%s filter is too long", param)
+			}
+			values = append(values, value)
+		}
+	}
+	if len(values) > maxFilterValues {
+		return nil, fmt.Errorf("too many values for %s filter (max %d)", param, maxFilterValues)
+	}
+	return values, nil
+}
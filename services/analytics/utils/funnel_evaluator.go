@@ -0,0 +1,342 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FunnelProgress is how far a single visitor got through a funnel
+type FunnelProgress struct {
+	VisitorID string
+	// StepTimes holds the time each completed step was reached, in step order
+	StepTimes []time.Time
+}
+
+// StepsCompleted returns the number of funnel steps the visitor completed
+func (p FunnelProgress) StepsCompleted() int {
+	return len(p.StepTimes)
+}
+
+// StartedAt returns when the visitor entered the funnel
+func (p FunnelProgress) StartedAt() time.Time {
+	if len(p.StepTimes) == 0 {
+		return time.Time{}
+	}
+	return p.StepTimes[0]
+}
+
+// Duration returns the time between entering the funnel and the last completed step
+func (p FunnelProgress) Duration() time.Duration {
+	if len(p.StepTimes) < 2 {
+		return 0
+	}
+	return p.StepTimes[len(p.StepTimes)-1].Sub(p.StepTimes[0])
+}
+
+// FunnelEvaluator matches a visitor's events against a funnel definition.
+//
+// A visitor enters the funnel with a matching event inside [from, to). In
+// strict mode the entry must match the first step and the following steps
+// must happen in order; in "any" mode any step can be the entry and steps may
+// happen in any order. All steps must be reached within the funnel's
+// conversion window, measured from the entry event.
+type FunnelEvaluator struct {
+	steps    []stepMatcher
+	strict   bool
+	window   time.Duration
+	from, to time.Time
+}
+
+type stepMatcher struct {
+	eventType  string // empty matches any event type
+	page       string // glob on the normalized page path
+	properties map[string]string
+}
+
+// NewFunnelEvaluator compiles the funnel's step conditions
+func NewFunnelEvaluator(funnel *models.Funnel, from, to time.Time) (*FunnelEvaluator, error) {
+	if len(funnel.Steps) == 0 {
+		return nil, fmt.Errorf("funnel has no steps")
+	}
+
+	steps := make([]models.FunnelStep, len(funnel.Steps))
+	copy(steps, funnel.Steps)
+	sort.SliceStable(steps, func(i, j int) bool { return steps[i].Order < steps[j].Order })
+
+	evaluator := &FunnelEvaluator{
+		strict: funnel.StepOrder != models.FunnelStepOrderAny,
+		window: time.Duration(ConversionWindowHours(funnel)) * time.Hour,
+		from:   from,
+		to:     to,
+	}
+
+	for i, step := range steps {
+		matcher, err := compileStep(step)
+		if err != nil {
+			return nil, fmt.Errorf("step %d (%s): %w", i+1, step.Name, err)
+		}
+		evaluator.steps = append(evaluator.steps, matcher)
+	}
+
+	return evaluator, nil
+}
+
+// ConversionWindowHours returns the funnel's window, applying the default
+func ConversionWindowHours(funnel *models.Funnel) int {
+	if funnel.ConversionWindowHours <= 0 {
+		return models.DefaultConversionWindowHours
+	}
+	return funnel.ConversionWindowHours
+}
+
+// StepCount returns the number of steps in the funnel
+func (e *FunnelEvaluator) StepCount() int {
+	return len(e.steps)
+}
+
+// Window returns the conversion window
+func (e *FunnelEvaluator) Window() time.Duration {
+	return e.window
+}
+
+// EventTypes returns the event types that can match any step, or nil when a
+// step accepts every event type
+func (e *FunnelEvaluator) EventTypes() []string {
+	seen := make(map[string]bool)
+	var types []string
+	for _, step := range e.steps {
+		if step.eventType == "" {
+			return nil
+		}
+		if !seen[step.eventType] {
+			seen[step.eventType] = true
+			types = append(types, step.eventType)
+		}
+	}
+	return types
+}
+
+// Evaluate returns the furthest progress of a visitor. Events must belong to
+// a single visitor and be sorted by timestamp.
+func (e *FunnelEvaluator) Evaluate(visitorID string, events []models.Event) FunnelProgress {
+	return e.EvaluateWithin(visitorID, events, e.from, e.to)
+}
+
+// EvaluateWithin is Evaluate with the funnel entered inside [from, to)
+// instead of the evaluator's range
+func (e *FunnelEvaluator) EvaluateWithin(visitorID string, events []models.Event, from, to time.Time) FunnelProgress {
+	best := FunnelProgress{VisitorID: visitorID}
+
+	for start, event := range events {
+		if event.Timestamp.Before(from) || !event.Timestamp.Before(to) {
+			continue
+		}
+		if !e.canStart(&event) {
+			continue
+		}
+
+		var stepTimes []time.Time
+		if e.strict {
+			stepTimes = e.strictProgress(events[start:])
+		} else {
+			stepTimes = e.anyOrderProgress(events[start:])
+		}
+
+		// Keep the earliest attempt that got furthest
+		if len(stepTimes) > len(best.StepTimes) {
+			best.StepTimes = stepTimes
+			if len(stepTimes) == len(e.steps) {
+				break
+			}
+		}
+	}
+
+	return best
+}
+
+// canStart reports whether an event can open a funnel attempt: the first
+// step in strict mode, any step otherwise
+func (e *FunnelEvaluator) canStart(event *models.Event) bool {
+	if e.strict {
+		return e.steps[0].matches(event)
+	}
+	for _, step := range e.steps {
+		if step.matches(event) {
+			return true
+		}
+	}
+	return false
+}
+
+// strictProgress greedily matches steps in order; taking the earliest match
+// for each step is optimal for ordered funnels
+func (e *FunnelEvaluator) strictProgress(events []models.Event) []time.Time {
+	deadline := events[0].Timestamp.Add(e.window)
+	stepTimes := []time.Time{events[0].Timestamp}
+
+	for i := 1; i < len(events) && len(stepTimes) < len(e.steps); i++ {
+		if events[i].Timestamp.After(deadline) {
+			break
+		}
+		if e.steps[len(stepTimes)].matches(&events[i]) {
+			stepTimes = append(stepTimes, events[i].Timestamp)
+		}
+	}
+
+	return stepTimes
+}
+
+// anyOrderProgress records the first time each step was matched inside the
+// window; a visitor reached step N once steps 1..N were all matched
+func (e *FunnelEvaluator) anyOrderProgress(events []models.Event) []time.Time {
+	deadline := events[0].Timestamp.Add(e.window)
+	reached := make([]time.Time, len(e.steps))
+
+	for i := 0; i < len(events); i++ {
+		if events[i].Timestamp.After(deadline) {
+			break
+		}
+		for s := range e.steps {
+			if reached[s].IsZero() && e.steps[s].matches(&events[i]) {
+				reached[s] = events[i].Timestamp
+			}
+		}
+	}
+
+	var stepTimes []time.Time
+	var latest time.Time
+	for _, t := range reached {
+		if t.IsZero() {
+			break
+		}
+		// A step counts as reached once all previous ones are done too
+		if t.After(latest) {
+			latest = t
+		}
+		stepTimes = append(stepTimes, latest)
+	}
+	return stepTimes
+}
+
+func compileStep(step models.FunnelStep) (stepMatcher, error) {
+	switch step.Type {
+	case "page":
+		if step.Condition.Page == nil || *step.Condition.Page == "" {
+			return stepMatcher{}, fmt.Errorf("page condition is required")
+		}
+		return stepMatcher{eventType: "pageview", page: normalizeFunnelPage(*step.Condition.Page)}, nil
+	case "event":
+		if step.Condition.Event == nil || *step.Condition.Event == "" {
+			return stepMatcher{}, fmt.Errorf("event condition is required")
+		}
+		return stepMatcher{eventType: *step.Condition.Event}, nil
+	case "custom":
+		if step.Condition.Custom == nil || *step.Condition.Custom == "" {
+			return stepMatcher{}, fmt.Errorf("custom condition is required")
+		}
+		return parseCustomCondition(*step.Condition.Custom)
+	default:
+		return stepMatcher{}, fmt.Errorf("unknown step type %q", step.Type)
+	}
+}
+
+// ValidateCustomCondition checks the syntax of a custom step condition
+func ValidateCustomCondition(condition string) error {
+	_, err := parseCustomCondition(condition)
+	return err
+}
+
+// parseCustomCondition parses "event?key=value&key2=value2". The event name
+// may be omitted or "*" to match any event, and values may contain `*`
+// wildcards. A "page" key matches the event's page.
+func parseCustomCondition(condition string) (stepMatcher, error) {
+	eventType, query, _ := strings.Cut(strings.TrimSpace(condition), "?")
+	if !strings.Contains(condition, "?") && strings.Contains(eventType, "=") {
+		eventType, query = "", eventType
+	}
+	if eventType == "*" {
+		eventType = ""
+	}
+
+	matcher := stepMatcher{eventType: eventType}
+	if query != "" {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return stepMatcher{}, fmt.Errorf("invalid custom condition %q: %w", condition, err)
+		}
+		matcher.properties = make(map[string]string, len(values))
+		for key := range values {
+			if key == "page" {
+				matcher.page = normalizeFunnelPage(values.Get(key))
+				continue
+			}
+			matcher.properties[key] = values.Get(key)
+		}
+	}
+
+	if matcher.eventType == "" && matcher.page == "" && len(matcher.properties) == 0 {
+		return stepMatcher{}, fmt.Errorf("custom condition %q matches every event", condition)
+	}
+	return matcher, nil
+}
+
+func (m stepMatcher) matches(event *models.Event) bool {
+	if m.eventType != "" && event.EventType != m.eventType {
+		return false
+	}
+	if m.page != "" && !globMatch(m.page, normalizeFunnelPage(event.Page)) {
+		return false
+	}
+	for key, expected := range m.properties {
+		value, ok := event.Properties[key]
+		if !ok || value == nil {
+			return false
+		}
+		if !globMatch(expected, fmt.Sprintf("%v", value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeFunnelPage reduces a page or URL to its path without trailing slash
+func normalizeFunnelPage(page string) string {
+	page = strings.TrimSpace(page)
+	if strings.HasPrefix(page, "http://") || strings.HasPrefix(page, "https://") {
+		if u, err := url.Parse(page); err == nil {
+			page = u.Path
+		}
+	}
+	if idx := strings.IndexAny(page, "?#"); idx != -1 {
+		page = page[:idx]
+	}
+	page = strings.TrimRight(page, "/")
+	if !strings.HasPrefix(page, "/") {
+		page = "/" + page
+	}
+	return page
+}
+
+// globMatch matches value against a pattern where `*` matches any sequence
+func globMatch(pattern, value string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == value
+	}
+	if !strings.HasPrefix(value, parts[0]) {
+		return false
+	}
+	value = value[len(parts[0]):]
+	for i := 1; i < len(parts)-1; i++ {
+		idx := strings.Index(value, parts[i])
+		if idx == -1 {
+			return false
+		}
+		value = value[idx+len(parts[i]):]
+	}
+	return strings.HasSuffix(value, parts[len(parts)-1])
+}
@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+	"github.com/rs/zerolog"
+)
+
+// mmdbRecord covers the fields used from MaxMind GeoLite2/GeoIP2 and DB-IP
+// City and ASN databases, which share the same layout
+type mmdbRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Subdivisions []struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	ASN   uint   `maxminddb:"autonomous_system_number"`
+	ASOrg string `maxminddb:"autonomous_system_organization"`
+}
+
+// mmdbFile is a database file that is reopened when it changes on disk
+type mmdbFile struct {
+	path    string
+	mu      sync.RWMutex
+	reader  *maxminddb.Reader
+	modTime time.Time
+	size    int64
+}
+
+func openMMDBFile(path string) (*mmdbFile, error) {
+	f := &mmdbFile{path: path}
+	if _, err := f.reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// reload reopens the file if it changed and reports whether it did
+func (f *mmdbFile) reload() (bool, error) {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to stat %s: %w", f.path, err)
+	}
+
+	f.mu.RLock()
+	unchanged := f.reader != nil && info.ModTime().Equal(f.modTime) && info.Size() == f.size
+	f.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	reader, err := maxminddb.Open(f.path)
+	if err != nil {
+		return false, fmt.Errorf("failed to open %s: %w", f.path, err)
+	}
+
+	f.mu.Lock()
+	old := f.reader
+	f.reader, f.modTime, f.size = reader, info.ModTime(), info.Size()
+	f.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return true, nil
+}
+
+func (f *mmdbFile) lookup(ip net.IP, record *mmdbRecord) error {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	if f.reader == nil {
+		return fmt.Errorf("geolocation database %s is closed", f.path)
+	}
+	return f.reader.Lookup(ip, record)
+}
+
+func (f *mmdbFile) close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.reader == nil {
+		return nil
+	}
+	err := f.reader.Close()
+	f.reader = nil
+	return err
+}
+
+// MMDBProvider resolves locations from local MaxMind or DB-IP database files.
+// The files are checked periodically and reloaded in place when replaced, so
+// they can be updated by geoipupdate or a cron job without a restart.
+type MMDBProvider struct {
+	city     *mmdbFile
+	asn      *mmdbFile
+	logger   zerolog.Logger
+	onReload atomic.Pointer[func()]
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewMMDBProvider opens a City database and an optional ASN database. A zero
+// reloadInterval disables hot reloading.
+func NewMMDBProvider(cityPath, asnPath string, reloadInterval time.Duration, logger zerolog.Logger) (*MMDBProvider, error) {
+	city, err := openMMDBFile(cityPath)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &MMDBProvider{
+		city:   city,
+		logger: logger,
+		stop:   make(chan struct{}),
+	}
+
+	if asnPath != "" {
+		if p.asn, err = openMMDBFile(asnPath); err != nil {
+			city.close()
+			return nil, err
+		}
+	}
+
+	if reloadInterval > 0 {
+		go p.watch(reloadInterval)
+	}
+
+	return p, nil
+}
+
+// OnReload registers a callback run after a database file was reloaded
+func (p *MMDBProvider) OnReload(fn func()) {
+	p.onReload.Store(&fn)
+}
+
+func (p *MMDBProvider) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+			reloaded := false
+			for _, f := range []*mmdbFile{p.city, p.asn} {
+				if f == nil {
+					continue
+				}
+				changed, err := f.reload()
+				if err != nil {
+					// Keep serving from the previous file
+					p.logger.Error().Err(err).Str("path", f.path).Msg("Failed to reload geolocation database")
+					continue
+				}
+				if changed {
+					p.logger.Info().Str("path", f.path).Msg("Reloaded geolocation database")
+					reloaded = true
+				}
+			}
+			if fn := p.onReload.Load(); reloaded && fn != nil {
+				(*fn)()
+			}
+		}
+	}
+}
+
+// Lookup returns the location of ip
+func (p *MMDBProvider) Lookup(ip string) (*LocationInfo, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, fmt.Errorf("invalid IP address %q", ip)
+	}
+
+	var record mmdbRecord
+	if err := p.city.lookup(parsed, &record); err != nil {
+		return nil, err
+	}
+	if record.Country.ISOCode == "" {
+		return nil, fmt.Errorf("no location found for %s", ip)
+	}
+
+	location := &LocationInfo{
+		Country: record.Country.ISOCode,
+		City:    record.City.Names["en"],
+	}
+	if len(record.Subdivisions) > 0 {
+		location.Region = record.Subdivisions[0].Names["en"]
+		if location.Region == "" {
+			location.Region = record.Subdivisions[0].ISOCode
+		}
+	}
+
+	if p.asn != nil {
+		var asnRecord mmdbRecord
+		if err := p.asn.lookup(parsed, &asnRecord); err == nil {
+			location.ASN = asnRecord.ASN
+			location.ASOrg = asnRecord.ASOrg
+		}
+	}
+
+	return location, nil
+}
+
+// Close stops the reload watcher and releases the database files
+func (p *MMDBProvider) Close() error {
+	p.stopOnce.Do(func() { close(p.stop) })
+
+	err := p.city.close()
+	if p.asn != nil {
+		if asnErr := p.asn.close(); err == nil {
+			err = asnErr
+		}
+	}
+	return err
+}
@@ -17,7 +17,8 @@ type FreeGeoIPResponse struct {
 	IP      string `json:"ip"`
 }
 
-// FreeGeoIPService provides free IP geolocation using public APIs
+// FreeGeoIPService provides free IP geolocation using public APIs. It sends
+// visitor IPs to third parties, so it is only used as an opt-in fallback.
 type FreeGeoIPService struct {
 	client *http.Client
 }
@@ -37,9 +38,9 @@ func (f *FreeGeoIPService) Lookup(ip string) (*LocationInfo, error) {
 		return nil, fmt.Errorf("IP address is required")
 	}
 
-	// Skip private IPs
+	// Private IPs have no location
 	if isPrivateIP(ip) {
-		return &LocationInfo{Country: "Local", City: "Local"}, nil
+		return nil, fmt.Errorf("%s is a private address", ip)
 	}
 
 	// Try multiple free geolocation APIs for redundancy
@@ -55,8 +56,7 @@ func (f *FreeGeoIPService) Lookup(ip string) (*LocationInfo, error) {
 		}
 	}
 
-	// If all APIs fail, return Unknown
-	return &LocationInfo{Country: "Unknown", City: "Unknown"}, nil
+	return nil, fmt.Errorf("all geolocation APIs failed for %s", ip)
 }
 
 // lookupFromAPI performs lookup from a specific API
@@ -99,6 +99,7 @@ func (f *FreeGeoIPService) lookupFromAPI(apiURL, ip string) (*LocationInfo, erro
 
 	return &LocationInfo{
 		Country: geoResp.Country,
+		Region:  geoResp.Region,
 		City:    geoResp.City,
 	}, nil
 }
@@ -3,12 +3,36 @@ package utils
 import (
 	"context"
 	"net"
+	"strings"
 )
 
 // LocationInfo contains geolocation information
 type LocationInfo struct {
 	Country string `json:"country"`
+	Region  string `json:"region,omitempty"`
 	City    string `json:"city"`
+	ASN     uint   `json:"asn,omitempty"`
+	ASOrg   string `json:"as_org,omitempty"`
+}
+
+// GeoProvider resolves an IP address to a location
+type GeoProvider interface {
+	Lookup(ip string) (*LocationInfo, error)
+}
+
+// GeoLocator resolves visitor locations through a chain of providers, caching
+// results in memory so repeat visitors don't hit the providers again
+type GeoLocator struct {
+	providers []GeoProvider
+	cache     *LRUCache[string, LocationInfo]
+}
+
+// NewGeoLocator creates a locator trying providers in order
+func NewGeoLocator(cacheSize int, providers ...GeoProvider) *GeoLocator {
+	return &GeoLocator{
+		providers: providers,
+		cache:     NewLRUCache[string, LocationInfo](cacheSize),
+	}
 }
 
 // GetClientIP extracts the client IP address from the request context
@@ -25,25 +49,46 @@ func GetClientIP(ctx context.Context) string {
 	return ""
 }
 
-// GetLocationFromIP determines location from IP address using free geolocation service
-func GetLocationFromIP(ip string) LocationInfo {
-	if ip == "" {
-		return LocationInfo{Country: "Unknown", City: "Unknown"}
+// Locate returns the location of ip. The location is empty for private
+// addresses and when no provider knows a country for it.
+func (g *GeoLocator) Locate(ip string) LocationInfo {
+	if ip == "" || isPrivateIP(ip) {
+		return LocationInfo{}
+	}
+
+	if location, ok := g.cache.Get(ip); ok {
+		return location
 	}
 
-	// Handle localhost and private IPs
-	if isPrivateIP(ip) {
-		return LocationInfo{Country: "Local", City: "Local"}
+	var location LocationInfo
+	for _, provider := range g.providers {
+		if found, err := provider.Lookup(ip); err == nil && found != nil && IsCountryCode(found.Country) {
+			location = *found
+			location.Country = strings.ToUpper(location.Country)
+			break
+		}
 	}
 
-	// Use free geolocation service
-	geoService := NewFreeGeoIPService()
-	location, err := geoService.Lookup(ip)
-	if err != nil {
-		return LocationInfo{Country: "Unknown", City: "Unknown"}
+	g.cache.Add(ip, location)
+	return location
+}
+
+// IsCountryCode reports whether country is a two-letter ISO 3166 code
+func IsCountryCode(country string) bool {
+	if len(country) != 2 {
+		return false
+	}
+	for _, r := range country {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
 	}
+	return true
+}
 
-	return *location
+// Purge drops cached locations, e.g. after the database was updated
+func (g *GeoLocator) Purge() {
+	g.cache.Purge()
 }
 
 // isPrivateIP checks if an IP address is private/local
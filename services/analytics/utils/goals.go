@@ -0,0 +1,35 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"strings"
+)
+
+// GoalPagePattern turns a goal's page pattern into a SQL LIKE pattern over
+// normalized page paths (no scheme, host, query or trailing slash)
+func GoalPagePattern(pattern string) string {
+	return GlobToLike(normalizeFunnelPage(pattern))
+}
+
+// GlobToLike converts a pattern where `*` matches any sequence into a SQL
+// LIKE pattern, escaping LIKE's own wildcards
+func GlobToLike(pattern string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(pattern)
+	return strings.ReplaceAll(escaped, "*", "%")
+}
+
+// BuildGoalConversions returns a breakdown row's conversions, conversion rate
+// and, when the goal has a value, the value of its conversions
+func BuildGoalConversions(counts models.GoalCounts, goal *models.Goal) models.GoalConversions {
+	conversions := counts.Conversions
+	rate := percentage(counts.Conversions, counts.Sessions)
+	result := models.GoalConversions{
+		Conversions:    &conversions,
+		ConversionRate: &rate,
+	}
+	if goal.Value != nil {
+		value := round2(float64(counts.Conversions) * *goal.Value)
+		result.GoalValue = &value
+	}
+	return result
+}
@@ -0,0 +1,722 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"golang.org/x/text/language"
+	"golang.org/x/text/language/display"
+)
+
+// ErrImportRowSkipped marks rows of an export that hold nothing to import
+var ErrImportRowSkipped = errors.New("row skipped")
+
+// ImportRecord is a row of an export: an event or a day of aggregates
+type ImportRecord struct {
+	Event     *models.Event
+	Aggregate *models.ImportedDailyStat
+}
+
+// Importer reads the records of an export file
+type Importer interface {
+	// Next returns the next record, an error wrapping ErrImportRowSkipped for
+	// rows without one, or io.EOF at the end of the file
+	Next() (*ImportRecord, error)
+}
+
+// NewImporter returns the importer of source's exports read from r, mapping
+// events onto websiteID
+func NewImporter(source string, r io.Reader, websiteID string) (Importer, error) {
+	switch source {
+	case models.ImportSourceGA4:
+		return &ga4Importer{reader: bufio.NewReader(r), websiteID: websiteID}, nil
+	case models.ImportSourceUniversalAnalytics:
+		return newUniversalAnalyticsImporter(r)
+	case models.ImportSourcePlausible:
+		return newPlausibleImporter(r)
+	case models.ImportSourceUmami:
+		return newUmamiImporter(r, websiteID)
+	default:
+		return nil, fmt.Errorf("unsupported import source %q", source)
+	}
+}
+
+func skipRow(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrImportRowSkipped, fmt.Sprintf(format, args...))
+}
+
+// GA4 BigQuery export
+
+// GA4 collects these automatically; sessions and visitors are derived from
+// the imported pageviews instead
+var ga4SkippedEvents = map[string]bool{
+	"first_visit":     true,
+	"session_start":   true,
+	"user_engagement": true,
+}
+
+// Parameters GA4 sets on every event, which are not kept as properties
+var ga4InternalParams = map[string]bool{
+	"page_location":         true,
+	"page_referrer":         true,
+	"page_title":            true,
+	"ga_session_id":         true,
+	"ga_session_number":     true,
+	"engagement_time_msec":  true,
+	"engaged_session_event": true,
+	"session_engaged":       true,
+	"entrances":             true,
+	"ignore_referrer":       true,
+	"batch_event_index":     true,
+	"batch_ordering_id":     true,
+	"batch_page_id":         true,
+}
+
+// ga4Int is an integer BigQuery exports either as a number or a string
+type ga4Int int64
+
+func (i *ga4Int) UnmarshalJSON(data []byte) error {
+	text := strings.Trim(string(data), `"`)
+	if text == "" || text == "null" {
+		return nil
+	}
+	value, err := strconv.ParseInt(text, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = ga4Int(value)
+	return nil
+}
+
+type ga4Param struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue *string  `json:"string_value"`
+		IntValue    *ga4Int  `json:"int_value"`
+		FloatValue  *float64 `json:"float_value"`
+		DoubleValue *float64 `json:"double_value"`
+	} `json:"value"`
+}
+
+// value returns the parameter's value, nil when it has none
+func (p ga4Param) value() interface{} {
+	switch {
+	case p.Value.StringValue != nil:
+		return *p.Value.StringValue
+	case p.Value.IntValue != nil:
+		return int64(*p.Value.IntValue)
+	case p.Value.DoubleValue != nil:
+		return *p.Value.DoubleValue
+	case p.Value.FloatValue != nil:
+		return *p.Value.FloatValue
+	}
+	return nil
+}
+
+type ga4Row struct {
+	EventTimestamp ga4Int     `json:"event_timestamp"`
+	EventName      string     `json:"event_name"`
+	UserPseudoID   string     `json:"user_pseudo_id"`
+	EventParams    []ga4Param `json:"event_params"`
+	Device         struct {
+		Category        string `json:"category"`
+		OperatingSystem string `json:"operating_system"`
+		WebInfo         struct {
+			Browser string `json:"browser"`
+		} `json:"web_info"`
+	} `json:"device"`
+	Geo struct {
+		Country string `json:"country"`
+		City    string `json:"city"`
+	} `json:"geo"`
+	CollectedTrafficSource struct {
+		Source   string `json:"manual_source"`
+		Medium   string `json:"manual_medium"`
+		Campaign string `json:"manual_campaign_name"`
+		Term     string `json:"manual_term"`
+		Content  string `json:"manual_content"`
+	} `json:"collected_traffic_source"`
+	Ecommerce *struct {
+		TransactionID   string   `json:"transaction_id"`
+		PurchaseRevenue *float64 `json:"purchase_revenue"`
+	} `json:"ecommerce"`
+}
+
+type ga4Importer struct {
+	reader    *bufio.Reader
+	websiteID string
+}
+
+func (im *ga4Importer) Next() (*ImportRecord, error) {
+	line, err := im.reader.ReadBytes('\n')
+	if err != nil && (err != io.EOF || len(line) == 0) {
+		return nil, err
+	}
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return nil, skipRow("empty line")
+	}
+
+	var row ga4Row
+	if err := json.Unmarshal(line, &row); err != nil {
+		return nil, skipRow("invalid JSON: %v", err)
+	}
+	if ga4SkippedEvents[row.EventName] {
+		return nil, skipRow("automatically collected %s event", row.EventName)
+	}
+	if row.EventName == "" || row.UserPseudoID == "" || row.EventTimestamp == 0 {
+		return nil, skipRow("event without name, user_pseudo_id or event_timestamp")
+	}
+
+	event := &models.Event{
+		ID:        uuid.New(),
+		WebsiteID: im.websiteID,
+		VisitorID: row.UserPseudoID,
+		EventType: row.EventName,
+		Timestamp: time.UnixMicro(int64(row.EventTimestamp)).UTC(),
+		Country:   importString(importCountry(row.Geo.Country)),
+		City:      importString(notSet(row.Geo.City)),
+		Device:    importString(strings.ToLower(notSet(row.Device.Category))),
+		Browser:   importString(normalizeImportedBrowser(row.Device.WebInfo.Browser)),
+		OS:        importString(normalizeImportedOS(row.Device.OperatingSystem)),
+	}
+
+	properties := models.Properties{}
+	var location string
+	for _, param := range row.EventParams {
+		value := param.value()
+		switch param.Key {
+		case "page_location":
+			location, _ = value.(string)
+		case "page_referrer":
+			if referrer, ok := value.(string); ok {
+				event.Referrer = importString(referrer)
+			}
+		case "ga_session_id":
+			if value != nil {
+				event.SessionID = fmt.Sprintf("%s:%v", row.UserPseudoID, value)
+			}
+		}
+		if !ga4InternalParams[param.Key] && value != nil {
+			properties[param.Key] = value
+		}
+	}
+	event.Page = importPage(event, location)
+	if event.UTMSource == nil {
+		source := row.CollectedTrafficSource
+		event.UTMSource = importString(source.Source)
+		event.UTMMedium = importString(source.Medium)
+		event.UTMCampaign = importString(source.Campaign)
+		event.UTMTerm = importString(source.Term)
+		event.UTMContent = importString(source.Content)
+	}
+
+	switch row.EventName {
+	case "page_view":
+		event.EventType = "pageview"
+	case models.PurchaseEventType:
+		// Purchases with a transaction ID and revenue are recorded as orders
+		orderID, _ := properties["transaction_id"].(string)
+		amount, hasAmount := properties["value"]
+		if row.Ecommerce != nil {
+			if row.Ecommerce.TransactionID != "" {
+				orderID = row.Ecommerce.TransactionID
+			}
+			if row.Ecommerce.PurchaseRevenue != nil {
+				amount, hasAmount = *row.Ecommerce.PurchaseRevenue, true
+			}
+		}
+		if orderID != "" && hasAmount {
+			properties["order_id"] = orderID
+			properties["amount"] = amount
+		}
+		fallthrough
+	default:
+		if len(properties) > 0 {
+			event.Properties = properties
+		}
+	}
+
+	if err := fitImportedEvent(event); err != nil {
+		return nil, err
+	}
+	return &ImportRecord{Event: event}, nil
+}
+
+// csvImporter reads CSV files whose first row names the columns
+type csvImporter struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func newCSVImporter(r io.Reader, comment rune) (*csvImporter, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+	reader.Comment = comment
+
+	for {
+		header, err := reader.Read()
+		if err == io.EOF {
+			return nil, errors.New("export has no header row")
+		}
+		if err != nil {
+			return nil, err
+		}
+		if emptyRecord(header) {
+			continue
+		}
+
+		columns := make(map[string]int, len(header))
+		for i, name := range header {
+			name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+			if _, ok := columns[name]; !ok {
+				columns[name] = i
+			}
+		}
+		return &csvImporter{reader: reader, columns: columns}, nil
+	}
+}
+
+// read returns the next non-empty record
+func (im *csvImporter) read() ([]string, error) {
+	record, err := im.reader.Read()
+	if err != nil {
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			return nil, skipRow("%v", err)
+		}
+		return nil, err
+	}
+	if emptyRecord(record) {
+		return nil, skipRow("empty row")
+	}
+	return record, nil
+}
+
+func (im *csvImporter) has(names ...string) bool {
+	for _, name := range names {
+		if _, ok := im.columns[name]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// get returns the value of the first of the named columns in the header
+func (im *csvImporter) get(record []string, names ...string) string {
+	for _, name := range names {
+		if i, ok := im.columns[name]; ok {
+			if i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+	}
+	return ""
+}
+
+func emptyRecord(record []string) bool {
+	for _, field := range record {
+		if strings.TrimSpace(field) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Universal Analytics CSV reports
+
+type universalAnalyticsImporter struct {
+	*csvImporter
+}
+
+func newUniversalAnalyticsImporter(r io.Reader) (*universalAnalyticsImporter, error) {
+	// Reports start with "#" comment lines describing the report
+	base, err := newCSVImporter(r, '#')
+	if err != nil {
+		return nil, err
+	}
+	if !base.has("date", "day") {
+		return nil, errors.New("universal Analytics report needs a Date column")
+	}
+	return &universalAnalyticsImporter{base}, nil
+}
+
+// Next maps a row of a report by date, optionally also by page or source,
+// onto a day of aggregates. Rows that do not start with a date, such as
+// totals and the sections after the report, are skipped.
+func (im *universalAnalyticsImporter) Next() (*ImportRecord, error) {
+	record, err := im.read()
+	if err != nil {
+		return nil, err
+	}
+	date, err := parseImportDate(im.get(record, "date", "day"))
+	if err != nil {
+		return nil, skipRow("%v", err)
+	}
+
+	sessions := parseImportNumber(im.get(record, "sessions"))
+	stat := &models.ImportedDailyStat{
+		Date:      date,
+		Page:      im.get(record, "page", "page path", "landing page"),
+		Source:    im.get(record, "source"),
+		Pageviews: int64(parseImportNumber(im.get(record, "pageviews"))),
+		Visitors:  int64(parseImportNumber(im.get(record, "users"))),
+		Visits:    int64(sessions),
+		// Rates and averages are per session
+		Bounces:       int64(math.Round(parseImportNumber(im.get(record, "bounce rate")) / 100 * sessions)),
+		VisitDuration: int64(math.Round(parseImportDuration(im.get(record, "avg. session duration")) * sessions)),
+	}
+	if stat.Source == "" {
+		if sourceMedium := im.get(record, "source / medium"); sourceMedium != "" {
+			stat.Source = strings.TrimSpace(strings.SplitN(sourceMedium, " / ", 2)[0])
+		}
+	}
+	return &ImportRecord{Aggregate: stat}, nil
+}
+
+// Plausible CSV exports
+
+type plausibleImporter struct {
+	*csvImporter
+	// Dashboard exports report bounce rates and average durations instead
+	// of totals
+	averages bool
+}
+
+func newPlausibleImporter(r io.Reader) (*plausibleImporter, error) {
+	base, err := newCSVImporter(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !base.has("date") {
+		return nil, errors.New("plausible export needs a date column")
+	}
+	return &plausibleImporter{csvImporter: base, averages: !base.has("bounces") && base.has("bounce_rate")}, nil
+}
+
+func (im *plausibleImporter) Next() (*ImportRecord, error) {
+	record, err := im.read()
+	if err != nil {
+		return nil, err
+	}
+	date, err := parseImportDate(im.get(record, "date"))
+	if err != nil {
+		return nil, skipRow("%v", err)
+	}
+
+	visits := parseImportNumber(im.get(record, "visits"))
+	stat := &models.ImportedDailyStat{
+		Date:          date,
+		Page:          im.get(record, "page"),
+		Source:        im.get(record, "source"),
+		Pageviews:     int64(parseImportNumber(im.get(record, "pageviews"))),
+		Visitors:      int64(parseImportNumber(im.get(record, "visitors"))),
+		Visits:        int64(visits),
+		Bounces:       int64(parseImportNumber(im.get(record, "bounces"))),
+		VisitDuration: int64(parseImportNumber(im.get(record, "visit_duration"))),
+	}
+	if im.averages {
+		stat.Bounces = int64(math.Round(parseImportNumber(im.get(record, "bounce_rate")) / 100 * visits))
+		stat.VisitDuration = int64(math.Round(parseImportNumber(im.get(record, "visit_duration")) * visits))
+	}
+	return &ImportRecord{Aggregate: stat}, nil
+}
+
+// Umami CSV data exports
+
+type umamiImporter struct {
+	*csvImporter
+	websiteID string
+}
+
+func newUmamiImporter(r io.Reader, websiteID string) (*umamiImporter, error) {
+	base, err := newCSVImporter(r, 0)
+	if err != nil {
+		return nil, err
+	}
+	if !base.has("created_at") || !base.has("session_id") {
+		return nil, errors.New("umami export needs created_at and session_id columns")
+	}
+	return &umamiImporter{csvImporter: base, websiteID: websiteID}, nil
+}
+
+// Next maps a website event onto an event. Umami sessions identify a visitor
+// for a while; its visits are the sessions.
+func (im *umamiImporter) Next() (*ImportRecord, error) {
+	record, err := im.read()
+	if err != nil {
+		return nil, err
+	}
+	timestamp, err := parseImportTime(im.get(record, "created_at"))
+	if err != nil {
+		return nil, skipRow("%v", err)
+	}
+	visitorID := im.get(record, "session_id")
+	if visitorID == "" {
+		return nil, skipRow("event without session_id")
+	}
+	sessionID := im.get(record, "visit_id")
+	if sessionID == "" {
+		sessionID = visitorID
+	}
+
+	event := &models.Event{
+		ID:        uuid.New(),
+		WebsiteID: im.websiteID,
+		VisitorID: visitorID,
+		SessionID: sessionID,
+		Timestamp: timestamp,
+		Country:   importString(importCountry(im.get(record, "country"))),
+		City:      importString(im.get(record, "city")),
+		Browser:   importString(normalizeImportedBrowser(im.get(record, "browser"))),
+		OS:        importString(normalizeImportedOS(im.get(record, "os"))),
+		Device:    importString(umamiDevice(im.get(record, "device"))),
+	}
+
+	switch eventType, name := im.get(record, "event_type"), im.get(record, "event_name"); {
+	case eventType == "2" || (eventType == "" && name != ""):
+		if name == "" {
+			return nil, skipRow("custom event without event_name")
+		}
+		event.EventType = name
+	default:
+		event.EventType = "pageview"
+	}
+
+	if domain := im.get(record, "referrer_domain"); domain != "" {
+		referrer := "https://" + domain + im.get(record, "referrer_path")
+		event.Referrer = &referrer
+	}
+	event.UTMSource = importString(im.get(record, "utm_source"))
+	event.UTMMedium = importString(im.get(record, "utm_medium"))
+	event.UTMCampaign = importString(im.get(record, "utm_campaign"))
+	event.UTMTerm = importString(im.get(record, "utm_term"))
+	event.UTMContent = importString(im.get(record, "utm_content"))
+
+	location := im.get(record, "url_path")
+	if query := im.get(record, "url_query"); query != "" {
+		location += "?" + strings.TrimPrefix(query, "?")
+	}
+	event.Page = importPage(event, location)
+
+	if err := fitImportedEvent(event); err != nil {
+		return nil, err
+	}
+	return &ImportRecord{Event: event}, nil
+}
+
+func umamiDevice(device string) string {
+	device = strings.ToLower(device)
+	if device == "laptop" {
+		return "desktop"
+	}
+	return device
+}
+
+// Shared mapping helpers
+
+// importPage returns the path of a page URL and sets the event's missing
+// UTM parameters from its query
+func importPage(event *models.Event, location string) string {
+	parsed, err := url.Parse(location)
+	if err != nil {
+		return location
+	}
+
+	query := parsed.Query()
+	for _, utm := range []struct {
+		name  string
+		value **string
+	}{
+		{"utm_source", &event.UTMSource},
+		{"utm_medium", &event.UTMMedium},
+		{"utm_campaign", &event.UTMCampaign},
+		{"utm_term", &event.UTMTerm},
+		{"utm_content", &event.UTMContent},
+	} {
+		if *utm.value == nil {
+			*utm.value = importString(query.Get(utm.name))
+		}
+	}
+
+	if parsed.Path == "" {
+		return "/"
+	}
+	return parsed.Path
+}
+
+// fitImportedEvent makes an event fit the columns it is stored in, so one
+// long value doesn't fail the batch it is written with. Identifiers that are
+// too long skip the row; descriptive values are cut to the column length.
+func fitImportedEvent(event *models.Event) error {
+	for _, id := range []struct {
+		name  string
+		value string
+		max   int
+	}{
+		{"visitor_id", event.VisitorID, 255},
+		{"session_id", event.SessionID, 255},
+		{"event_type", event.EventType, 100},
+	} {
+		if utf8.RuneCountInString(id.value) > id.max {
+			return skipRow("%s longer than %d characters", id.name, id.max)
+		}
+	}
+
+	for _, field := range []struct {
+		value **string
+		max   int
+	}{
+		{&event.City, 100},
+		{&event.Browser, 100},
+		{&event.Device, 50},
+		{&event.OS, 100},
+		{&event.UTMSource, 255},
+		{&event.UTMMedium, 255},
+		{&event.UTMCampaign, 255},
+		{&event.UTMTerm, 255},
+		{&event.UTMContent, 255},
+	} {
+		if *field.value != nil {
+			value := truncateRunes(**field.value, field.max)
+			*field.value = &value
+		}
+	}
+	if event.Country != nil && len(*event.Country) != 2 {
+		event.Country = nil
+	}
+	return nil
+}
+
+func truncateRunes(value string, max int) string {
+	if utf8.RuneCountInString(value) <= max {
+		return value
+	}
+	return string([]rune(value)[:max])
+}
+
+// importString returns nil for empty values, which are stored as NULL
+func importString(value string) *string {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return nil
+	}
+	return &value
+}
+
+// notSet drops the placeholder GA reports for unknown values
+func notSet(value string) string {
+	if value == "(not set)" {
+		return ""
+	}
+	return value
+}
+
+func normalizeImportedBrowser(browser string) string {
+	if notSet(browser) == "" {
+		return ""
+	}
+	return normalizeBrowserName(browser)
+}
+
+func normalizeImportedOS(os string) string {
+	if notSet(os) == "" {
+		return ""
+	}
+	return normalizeOSName(os)
+}
+
+var (
+	countryCodesOnce sync.Once
+	countryCodes     map[string]string
+)
+
+// importCountry returns the ISO 3166 code of a country given by code or by
+// English name, as GA reports it, or "" when it is unknown
+func importCountry(country string) string {
+	country = strings.TrimSpace(notSet(country))
+	if country == "" {
+		return ""
+	}
+	if len(country) == 2 {
+		return strings.ToUpper(country)
+	}
+
+	countryCodesOnce.Do(func() {
+		countryCodes = make(map[string]string)
+		names := display.English.Regions()
+		for a := 'A'; a <= 'Z'; a++ {
+			for b := 'A'; b <= 'Z'; b++ {
+				region, err := language.ParseRegion(string([]rune{a, b}))
+				if err != nil || !region.IsCountry() {
+					continue
+				}
+				countryCodes[strings.ToLower(names.Name(region))] = region.String()
+			}
+		}
+	})
+	return countryCodes[strings.ToLower(country)]
+}
+
+var importDateLayouts = []string{"2006-01-02", "20060102", "1/2/06", "1/2/2006", "Jan 2, 2006", "January 2, 2006"}
+
+func parseImportDate(value string) (time.Time, error) {
+	for _, layout := range importDateLayouts {
+		if date, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return date, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid date %q", value)
+}
+
+var importTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999Z07:00", "2006-01-02 15:04:05.999999999Z07", "2006-01-02 15:04:05.999999999"}
+
+// parseImportTime parses a timestamp; timestamps without a zone are UTC
+func parseImportTime(value string) (time.Time, error) {
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.UTC); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid timestamp %q", value)
+}
+
+// parseImportNumber reads numbers as reports format them, e.g. "1,234" or
+// "45.50%"; anything else is 0
+func parseImportNumber(value string) float64 {
+	value = strings.NewReplacer(",", "", "%", "", " ", "").Replace(value)
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(number) || math.IsInf(number, 0) {
+		return 0
+	}
+	return number
+}
+
+// parseImportDuration reads durations in seconds or as [HH:]MM:SS
+func parseImportDuration(value string) float64 {
+	value = strings.TrimPrefix(strings.TrimSpace(value), "<")
+	if !strings.Contains(value, ":") {
+		return parseImportNumber(value)
+	}
+	var seconds float64
+	for _, part := range strings.Split(value, ":") {
+		seconds = seconds*60 + parseImportNumber(part)
+	}
+	return seconds
+}
@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LiveReferrer returns the host a visitor came from, without "www.", or
+// "direct" when there is no referrer
+func LiveReferrer(referrer *string) string {
+	if referrer == nil {
+		return "direct"
+	}
+	value := strings.TrimSpace(*referrer)
+	switch strings.ToLower(value) {
+	case "", "direct", "none", "null":
+		return "direct"
+	}
+
+	if !strings.Contains(value, "://") {
+		value = "https://" + value
+	}
+	parsed, err := url.Parse(value)
+	if err != nil || parsed.Hostname() == "" {
+		return strings.ToLower(strings.TrimSpace(*referrer))
+	}
+	return strings.TrimPrefix(strings.ToLower(parsed.Hostname()), "www.")
+}
+
+// CountLive counts values, skipping empty ones, and returns the limit most
+// common, ties by name
+func CountLive(values []string, limit int) []models.LiveCount {
+	counts := make(map[string]int)
+	for _, value := range values {
+		if value != "" {
+			counts[value]++
+		}
+	}
+
+	result := make([]models.LiveCount, 0, len(counts))
+	for name, visitors := range counts {
+		result = append(result, models.LiveCount{Name: name, Visitors: visitors})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Visitors != result[j].Visitors {
+			return result[i].Visitors > result[j].Visitors
+		}
+		return result[i].Name < result[j].Name
+	})
+	if limit > 0 && len(result) > limit {
+		result = result[:limit]
+	}
+	return result
+}
+
+// PageviewSeries returns the pageviews of the minutes before now's, oldest
+// first, from counts keyed by the Unix minute
+func PageviewSeries(counts map[int64]int, now time.Time, minutes int) []models.MinuteCount {
+	current := now.Unix() / 60
+	series := make([]models.MinuteCount, minutes)
+	for i := range series {
+		minute := current - int64(minutes-i)
+		series[i] = models.MinuteCount{
+			Minute:    time.Unix(minute*60, 0).UTC(),
+			Pageviews: counts[minute],
+		}
+	}
+	return series
+}
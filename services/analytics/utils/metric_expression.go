@@ -0,0 +1,450 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const (
+	// MaxMetricExpressionLength caps the length of a custom metric expression
+	MaxMetricExpressionLength = 500
+	// MaxMetricAggregates caps the distinct aggregates of an expression
+	MaxMetricAggregates = 10
+	// maxMetricDepth caps the nesting of an expression
+	maxMetricDepth = 20
+)
+
+// Aggregate functions of custom metric expressions
+const (
+	MetricCount    = "count"
+	MetricVisitors = "visitors"
+	MetricSessions = "sessions"
+	MetricSum      = "sum"
+	MetricAvg      = "avg"
+	MetricMin      = "min"
+	MetricMax      = "max"
+)
+
+// MetricAggregate is an aggregate over the events of one type: their count,
+// unique visitors or sessions, or the sum, average, minimum or maximum of a
+// numeric property
+type MetricAggregate struct {
+	Func     string
+	Event    string
+	Property string
+}
+
+// MetricNode is a node of a parsed custom metric expression: a number, an
+// aggregate, a negation or a binary operation
+type MetricNode struct {
+	Op        string // num, agg, neg, +, -, *, /
+	Value     float64
+	Aggregate MetricAggregate
+	Left      *MetricNode
+	Right     *MetricNode
+}
+
+// MetricExpression is a parsed custom metric expression such as
+// `count("signup") / visitors * 100`
+type MetricExpression struct {
+	Root       *MetricNode
+	aggregates []MetricAggregate
+}
+
+// ParseMetricExpression parses a custom metric expression. Expressions
+// combine numbers and aggregates with + - * / and parentheses. The aggregates
+// are count("event"), visitors("event") and sessions("event"), and
+// sum("event", "property"), avg, min and max over numeric properties;
+// pageviews, visitors and sessions alone stand for those of pageviews.
+func ParseMetricExpression(expression string) (*MetricExpression, error) {
+	if strings.TrimSpace(expression) == "" {
+		return nil, errors.New("expression is required")
+	}
+	if len(expression) > MaxMetricExpressionLength {
+		return nil, fmt.Errorf("expression must be at most %d characters", MaxMetricExpressionLength)
+	}
+
+	tokens, err := tokenizeMetricExpression(expression)
+	if err != nil {
+		return nil, err
+	}
+	p := &metricParser{tokens: tokens, seen: make(map[MetricAggregate]bool)}
+	root, err := p.expression(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != metricTokenEnd {
+		return nil, fmt.Errorf("unexpected %s at position %d", p.peek(), p.peek().pos+1)
+	}
+	if len(p.aggregates) == 0 {
+		return nil, errors.New("expression must use at least one aggregate")
+	}
+	return &MetricExpression{Root: root, aggregates: p.aggregates}, nil
+}
+
+// Aggregates returns the distinct aggregates of the expression in order of
+// appearance
+func (e *MetricExpression) Aggregates() []MetricAggregate {
+	return e.aggregates
+}
+
+// SQL compiles the expression into SQL arithmetic over the columns holding
+// its aggregates, named by column. Division by zero yields NULL. Numbers are
+// reformatted from their parsed value, so no input text reaches the SQL.
+func (e *MetricExpression) SQL(column func(MetricAggregate) string) string {
+	return e.Root.sql(column)
+}
+
+func (n *MetricNode) sql(column func(MetricAggregate) string) string {
+	switch n.Op {
+	case "num":
+		return "(" + strconv.FormatFloat(n.Value, 'g', -1, 64) + ")::double precision"
+	case "agg":
+		return column(n.Aggregate)
+	case "neg":
+		return "(-" + n.Left.sql(column) + ")"
+	case "/":
+		return "(" + n.Left.sql(column) + " / NULLIF(" + n.Right.sql(column) + ", 0))"
+	}
+	return "(" + n.Left.sql(column) + " " + n.Op + " " + n.Right.sql(column) + ")"
+}
+
+// Evaluate computes the expression from the values of its aggregates; nil
+// values, and division by zero, make the result nil
+func (e *MetricExpression) Evaluate(value func(MetricAggregate) *float64) *float64 {
+	return e.Root.evaluate(value)
+}
+
+func (n *MetricNode) evaluate(value func(MetricAggregate) *float64) *float64 {
+	switch n.Op {
+	case "num":
+		v := n.Value
+		return &v
+	case "agg":
+		return value(n.Aggregate)
+	}
+
+	left := n.Left.evaluate(value)
+	if left == nil {
+		return nil
+	}
+	if n.Op == "neg" {
+		v := -*left
+		return &v
+	}
+	right := n.Right.evaluate(value)
+	if right == nil {
+		return nil
+	}
+
+	var v float64
+	switch n.Op {
+	case "+":
+		v = *left + *right
+	case "-":
+		v = *left - *right
+	case "*":
+		v = *left * *right
+	case "/":
+		if *right == 0 {
+			return nil
+		}
+		v = *left / *right
+	}
+	return &v
+}
+
+// BuildCustomMetricValues returns the values of the metrics for a group, as
+// computed by the database. Groups without events of the metrics get the
+// metrics' values over no events: counts and sums are zero, averages,
+// minimums and maximums undefined.
+func BuildCustomMetricValues(values map[string]*float64, metrics []models.CustomMetric) models.CustomMetricValues {
+	if values != nil {
+		return models.CustomMetricValues{CustomMetrics: values}
+	}
+
+	empty := make(map[string]*float64, len(metrics))
+	for _, metric := range metrics {
+		expression, err := ParseMetricExpression(metric.Expression)
+		if err != nil {
+			empty[metric.ID.String()] = nil
+			continue
+		}
+		empty[metric.ID.String()] = expression.Evaluate(func(aggregate MetricAggregate) *float64 {
+			if !aggregate.IsAdditive() {
+				return nil
+			}
+			zero := 0.0
+			return &zero
+		})
+	}
+	return models.CustomMetricValues{CustomMetrics: empty}
+}
+
+// IsAdditive reports whether the aggregate of no events is zero rather than
+// undefined
+func (a MetricAggregate) IsAdditive() bool {
+	switch a.Func {
+	case MetricCount, MetricVisitors, MetricSessions, MetricSum:
+		return true
+	}
+	return false
+}
+
+const (
+	metricTokenEnd = iota
+	metricTokenNumber
+	metricTokenIdent
+	metricTokenString
+	metricTokenSymbol
+)
+
+type metricToken struct {
+	kind int
+	text string
+	pos  int
+}
+
+func (t metricToken) String() string {
+	switch t.kind {
+	case metricTokenEnd:
+		return "end of expression"
+	case metricTokenString:
+		return strconv.Quote(t.text)
+	}
+	return "'" + t.text + "'"
+}
+
+func tokenizeMetricExpression(expression string) ([]metricToken, error) {
+	var tokens []metricToken
+	runes := []rune(expression)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			tokens = append(tokens, metricToken{metricTokenNumber, string(runes[start:i]), start})
+		case r == '_' || unicode.IsLetter(r):
+			start := i
+			for i < len(runes) && (runes[i] == '_' || unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i])) {
+				i++
+			}
+			tokens = append(tokens, metricToken{metricTokenIdent, string(runes[start:i]), start})
+		case r == '"' || r == '\'':
+			start := i
+			var text strings.Builder
+			i++
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				text.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start+1)
+			}
+			i++
+			tokens = append(tokens, metricToken{metricTokenString, text.String(), start})
+		case strings.ContainsRune("+-*/(),", r):
+			tokens = append(tokens, metricToken{metricTokenSymbol, string(r), i})
+			i++
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", r, i+1)
+		}
+	}
+	return append(tokens, metricToken{kind: metricTokenEnd, pos: len(runes)}), nil
+}
+
+type metricParser struct {
+	tokens     []metricToken
+	pos        int
+	aggregates []MetricAggregate
+	seen       map[MetricAggregate]bool
+}
+
+func (p *metricParser) peek() metricToken {
+	return p.tokens[p.pos]
+}
+
+func (p *metricParser) next() metricToken {
+	t := p.tokens[p.pos]
+	if t.kind != metricTokenEnd {
+		p.pos++
+	}
+	return t
+}
+
+func (p *metricParser) isSymbol(symbols ...string) bool {
+	t := p.peek()
+	if t.kind != metricTokenSymbol {
+		return false
+	}
+	for _, s := range symbols {
+		if t.text == s {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *metricParser) expect(symbol string) error {
+	if !p.isSymbol(symbol) {
+		return fmt.Errorf("expected '%s' at position %d, found %s", symbol, p.peek().pos+1, p.peek())
+	}
+	p.next()
+	return nil
+}
+
+// expression := term (("+" | "-") term)*
+func (p *metricParser) expression(depth int) (*MetricNode, error) {
+	if depth > maxMetricDepth {
+		return nil, errors.New("expression is nested too deeply")
+	}
+	left, err := p.term(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.isSymbol("+", "-") {
+		op := p.next().text
+		right, err := p.term(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &MetricNode{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// term := unary (("*" | "/") unary)*
+func (p *metricParser) term(depth int) (*MetricNode, error) {
+	left, err := p.unary(depth)
+	if err != nil {
+		return nil, err
+	}
+	for p.isSymbol("*", "/") {
+		op := p.next().text
+		right, err := p.unary(depth)
+		if err != nil {
+			return nil, err
+		}
+		left = &MetricNode{Op: op, Left: left, Right: right}
+	}
+	return left, nil
+}
+
+// unary := "-" unary | primary
+func (p *metricParser) unary(depth int) (*MetricNode, error) {
+	if p.isSymbol("-") {
+		p.next()
+		if depth+1 > maxMetricDepth {
+			return nil, errors.New("expression is nested too deeply")
+		}
+		operand, err := p.unary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &MetricNode{Op: "neg", Left: operand}, nil
+	}
+	return p.primary(depth)
+}
+
+// primary := number | "(" expression ")" | identifier | identifier "(" arguments ")"
+func (p *metricParser) primary(depth int) (*MetricNode, error) {
+	t := p.next()
+	switch t.kind {
+	case metricTokenNumber:
+		value, err := strconv.ParseFloat(t.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %s at position %d", t, t.pos+1)
+		}
+		return &MetricNode{Op: "num", Value: value}, nil
+
+	case metricTokenSymbol:
+		if t.text != "(" {
+			break
+		}
+		node, err := p.expression(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		return node, nil
+
+	case metricTokenIdent:
+		return p.aggregate(t)
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos+1)
+}
+
+func (p *metricParser) aggregate(name metricToken) (*MetricNode, error) {
+	function := strings.ToLower(name.text)
+
+	var args []string
+	if p.isSymbol("(") {
+		p.next()
+		for !p.isSymbol(")") {
+			if len(args) > 0 {
+				if err := p.expect(","); err != nil {
+					return nil, err
+				}
+			}
+			arg := p.next()
+			if arg.kind != metricTokenString {
+				return nil, fmt.Errorf("expected a quoted string at position %d, found %s", arg.pos+1, arg)
+			}
+			args = append(args, arg.text)
+		}
+		p.next()
+	} else if function != "pageviews" && function != MetricVisitors && function != MetricSessions {
+		return nil, fmt.Errorf("unknown metric %s at position %d", name, name.pos+1)
+	}
+
+	aggregate := MetricAggregate{Func: function, Event: "pageview"}
+	switch function {
+	case "pageviews":
+		aggregate.Func = MetricCount
+		if len(args) > 0 {
+			return nil, fmt.Errorf("pageviews takes no arguments")
+		}
+	case MetricCount, MetricVisitors, MetricSessions:
+		if len(args) > 1 || (function == MetricCount && len(args) == 0) {
+			return nil, fmt.Errorf("%s takes an event name", function)
+		}
+		if len(args) == 1 {
+			aggregate.Event = args[0]
+		}
+	case MetricSum, MetricAvg, MetricMin, MetricMax:
+		if len(args) != 2 {
+			return nil, fmt.Errorf("%s takes an event name and a property", function)
+		}
+		aggregate.Event, aggregate.Property = args[0], args[1]
+		if aggregate.Property == "" || len(aggregate.Property) > 100 {
+			return nil, fmt.Errorf("property of %s must be 1 to 100 characters", function)
+		}
+	default:
+		return nil, fmt.Errorf("unknown function %s at position %d", name, name.pos+1)
+	}
+	if aggregate.Event == "" || len(aggregate.Event) > 255 {
+		return nil, fmt.Errorf("event name of %s must be 1 to 255 characters", function)
+	}
+
+	if !p.seen[aggregate] {
+		if len(p.aggregates) == MaxMetricAggregates {
+			return nil, fmt.Errorf("expression may use at most %d different aggregates", MaxMetricAggregates)
+		}
+		p.seen[aggregate] = true
+		p.aggregates = append(p.aggregates, aggregate)
+	}
+	return &MetricNode{Op: "agg", Aggregate: aggregate}, nil
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"bytes"
+	_ "embed"
+	"fmt"
+	htmltemplate "html/template"
+	"math"
+	"strconv"
+	texttemplate "text/template"
+)
+
+var (
+	//go:embed templates/report.html.tmpl
+	reportHTMLSource string
+	//go:embed templates/report.txt.tmpl
+	reportTextSource string
+)
+
+var reportFuncs = map[string]interface{}{
+	"number":   formatReportNumber,
+	"percent":  func(value float64) string { return fmt.Sprintf("%.1f%%", value) },
+	"duration": formatReportDuration,
+	"change":   formatReportChange,
+}
+
+var (
+	reportHTMLTemplate = htmltemplate.Must(htmltemplate.New("report.html").Funcs(reportFuncs).Parse(reportHTMLSource))
+	reportTextTemplate = texttemplate.Must(texttemplate.New("report.txt").Funcs(reportFuncs).Parse(reportTextSource))
+)
+
+// RenderedReport is a report digest ready to be sent
+type RenderedReport struct {
+	Subject string
+	HTML    string
+	Text    string
+}
+
+// reportView is the data the report templates are executed with
+type reportView struct {
+	Subject string
+	Period  string
+	Digest  *models.ReportDigest
+}
+
+// RenderReport renders the HTML and plain-text versions of a digest
+func RenderReport(digest *models.ReportDigest) (*RenderedReport, error) {
+	view := reportView{Period: ReportPeriod(digest), Digest: digest}
+	view.Subject = fmt.Sprintf("%s: %s", digest.Name, view.Period)
+
+	var html, text bytes.Buffer
+	if err := reportHTMLTemplate.Execute(&html, view); err != nil {
+		return nil, fmt.Errorf("failed to render HTML report: %w", err)
+	}
+	if err := reportTextTemplate.Execute(&text, view); err != nil {
+		return nil, fmt.Errorf("failed to render text report: %w", err)
+	}
+
+	return &RenderedReport{Subject: view.Subject, HTML: html.String(), Text: text.String()}, nil
+}
+
+// ReportPeriod describes the days a digest covers, e.g. "Mar 4 – Mar 10, 2024"
+func ReportPeriod(digest *models.ReportDigest) string {
+	loc := models.DateRange{Timezone: digest.Timezone}.Location()
+	from := digest.From.In(loc)
+	// The range ends at midnight after its last day
+	last := digest.To.In(loc).AddDate(0, 0, -1)
+	if !last.After(from) {
+		return from.Format("Jan 2, 2006")
+	}
+	if from.Year() != last.Year() {
+		return from.Format("Jan 2, 2006") + " – " + last.Format("Jan 2, 2006")
+	}
+	return from.Format("Jan 2") + " – " + last.Format("Jan 2, 2006")
+}
+
+// formatReportNumber groups the digits of a count by thousands
+func formatReportNumber(value int) string {
+	digits := strconv.Itoa(value)
+	sign := ""
+	if value < 0 {
+		sign, digits = "-", digits[1:]
+	}
+	for i := len(digits) - 3; i > 0; i -= 3 {
+		digits = digits[:i] + "," + digits[i:]
+	}
+	return sign + digits
+}
+
+// formatReportDuration formats seconds as e.g. "2m 05s"
+func formatReportDuration(seconds float64) string {
+	total := int(math.Round(seconds))
+	if total < 60 {
+		return fmt.Sprintf("%ds", total)
+	}
+	return fmt.Sprintf("%dm %02ds", total/60, total%60)
+}
+
+// formatReportChange formats a change against the previous period, which is
+// missing when that period had no data
+func formatReportChange(change *float64) string {
+	if change == nil {
+		return "n/a"
+	}
+	return fmt.Sprintf("%+.1f%%", *change)
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// NextReportRun returns when a schedule's cron expression next fires after
+// the given time, evaluated in the schedule's timezone
+func NextReportRun(schedule *models.ReportSchedule, after time.Time) (time.Time, error) {
+	expr, err := cron.ParseStandard(schedule.Cron)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid cron expression: %w", err)
+	}
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timezone: %w", err)
+	}
+
+	next := expr.Next(after.In(loc))
+	if next.IsZero() {
+		return time.Time{}, fmt.Errorf("cron expression %q never fires", schedule.Cron)
+	}
+	return next.UTC(), nil
+}
+
+// ReportRange returns the range a report run at the given time covers: the
+// schedule's last RangeDays whole days before that day, in its timezone
+func ReportRange(schedule *models.ReportSchedule, at time.Time) models.DateRange {
+	loc, err := time.LoadLocation(schedule.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	local := at.In(loc)
+	to := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
+	return models.DateRange{
+		From:     to.AddDate(0, 0, -schedule.RangeDays),
+		To:       to,
+		Timezone: loc.String(),
+	}
+}
@@ -0,0 +1,224 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxOrderIDLength matches the orders table
+const MaxOrderIDLength = 255
+
+// MaxOrderAmount is the exclusive upper bound of NUMERIC(18, 4) amounts
+const MaxOrderAmount = 1e14
+
+// IsOrder reports whether an event is a purchase carrying an order. Purchase
+// events without order_id and amount are kept as plain custom events.
+func IsOrder(event *models.Event) bool {
+	if event.EventType != models.PurchaseEventType {
+		return false
+	}
+	_, hasOrderID := event.Properties["order_id"]
+	_, hasAmount := event.Properties["amount"]
+	return hasOrderID || hasAmount
+}
+
+// ParsePurchase reads the order of a purchase event from its properties:
+// order_id, amount, currency and optional items of id, name, category,
+// price and quantity
+func ParsePurchase(event *models.Event) (*models.Order, error) {
+	orderID, ok := propertyString(event.Properties["order_id"])
+	if !ok || orderID == "" {
+		return nil, errors.New("purchase requires order_id")
+	}
+	if len(orderID) > MaxOrderIDLength {
+		return nil, fmt.Errorf("order_id must be at most %d characters", MaxOrderIDLength)
+	}
+
+	amount, ok := propertyNumber(event.Properties["amount"])
+	if !ok || amount < 0 {
+		return nil, errors.New("purchase requires a non-negative amount")
+	}
+	if amount >= MaxOrderAmount {
+		return nil, fmt.Errorf("amount must be less than %.0f", MaxOrderAmount)
+	}
+
+	currency, _ := propertyString(event.Properties["currency"])
+	currency, err := NormalizeCurrency(currency)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := parseOrderItems(event.Properties["items"])
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.Order{
+		WebsiteID: event.WebsiteID,
+		OrderID:   orderID,
+		EventID:   event.ID,
+		VisitorID: event.VisitorID,
+		SessionID: event.SessionID,
+		Amount:    amount,
+		Currency:  currency,
+		Items:     items,
+		Timestamp: event.Timestamp,
+	}, nil
+}
+
+func parseOrderItems(value interface{}) (models.OrderItems, error) {
+	if value == nil {
+		return nil, nil
+	}
+	list, ok := value.([]interface{})
+	if !ok {
+		return nil, errors.New("items must be a list")
+	}
+
+	items := make(models.OrderItems, 0, len(list))
+	for i, entry := range list {
+		fields, ok := entry.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("item %d must be an object", i)
+		}
+
+		item := models.OrderItem{Quantity: 1}
+		if item.ID, ok = propertyString(fields["id"]); !ok || item.ID == "" {
+			return nil, fmt.Errorf("item %d requires id", i)
+		}
+		item.Name, _ = propertyString(fields["name"])
+		item.Category, _ = propertyString(fields["category"])
+		if price, ok := propertyNumber(fields["price"]); ok {
+			if price < 0 {
+				return nil, fmt.Errorf("item %d price must be non-negative", i)
+			}
+			item.Price = price
+		}
+		if quantity, ok := propertyNumber(fields["quantity"]); ok {
+			if quantity < 1 || quantity != math.Trunc(quantity) {
+				return nil, fmt.Errorf("item %d quantity must be a positive integer", i)
+			}
+			item.Quantity = int(quantity)
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+// NormalizeCurrency returns the upper case ISO 4217 code of a currency
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return "", errors.New("currency must be a three letter ISO 4217 code")
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return "", errors.New("currency must be a three letter ISO 4217 code")
+		}
+	}
+	return code, nil
+}
+
+// CurrencyConverter converts amounts to a website's base currency using
+// rates quoted against models.ReferenceCurrency
+type CurrencyConverter struct {
+	base  string
+	rates map[string]float64
+}
+
+func NewCurrencyConverter(base string, rates map[string]float64) *CurrencyConverter {
+	return &CurrencyConverter{base: base, rates: rates}
+}
+
+// Base returns the currency amounts are converted to
+func (c *CurrencyConverter) Base() string {
+	return c.base
+}
+
+// Convert returns amount in the base currency, or false when a rate is missing
+func (c *CurrencyConverter) Convert(amount float64, currency string) (float64, bool) {
+	if currency == c.base {
+		return amount, true
+	}
+	from, ok := c.rate(currency)
+	if !ok {
+		return 0, false
+	}
+	to, ok := c.rate(c.base)
+	if !ok {
+		return 0, false
+	}
+	return amount / from * to, true
+}
+
+func (c *CurrencyConverter) rate(currency string) (float64, bool) {
+	if currency == models.ReferenceCurrency {
+		return 1, true
+	}
+	rate, ok := c.rates[currency]
+	return rate, ok && rate > 0
+}
+
+// OrdersValue returns the value in the base currency of the orders placed in
+// [from, to)
+func (c *CurrencyConverter) OrdersValue(orders []models.Order, from, to time.Time) float64 {
+	var value float64
+	for _, order := range orders {
+		if order.Timestamp.Before(from) || !order.Timestamp.Before(to) {
+			continue
+		}
+		if amount, ok := c.Convert(order.Amount, order.Currency); ok {
+			value += amount
+		}
+	}
+	return value
+}
+
+// BuildRevenueStats converts a breakdown row's per-currency totals to the
+// base currency
+func BuildRevenueStats(totals map[string]models.CurrencyTotals, converter *CurrencyConverter) models.RevenueStats {
+	stats := models.RevenueStats{Currency: converter.Base()}
+	for currency, total := range totals {
+		amount, ok := converter.Convert(total.Amount, currency)
+		if !ok {
+			continue
+		}
+		stats.Orders += total.Orders
+		stats.Revenue += amount
+	}
+	if stats.Orders > 0 {
+		stats.AverageOrderValue = round2(stats.Revenue / float64(stats.Orders))
+	}
+	stats.Revenue = round2(stats.Revenue)
+	return stats
+}
+
+// propertyString reads an event property sent as a string or a number
+func propertyString(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return strings.TrimSpace(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// propertyNumber reads an event property sent as a number or a numeric string
+func propertyNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, !math.IsNaN(v) && !math.IsInf(v, 0)
+	case string:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return n, err == nil && !math.IsNaN(n) && !math.IsInf(n, 0)
+	default:
+		return 0, false
+	}
+}
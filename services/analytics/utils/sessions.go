@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// DefaultSessionTimeout is the inactivity after which a visitor's next event
+// starts a new session
+const DefaultSessionTimeout = 30 * time.Minute
+
+// SessionRules decide when a visitor's activity starts a new session
+type SessionRules struct {
+	// Timeout is the longest gap between events of one session
+	Timeout time.Duration
+	// Location is where days end; sessions are split at its midnight
+	Location *time.Location
+	// SplitOnCampaign starts a new session when a pageview arrives from a
+	// different UTM campaign
+	SplitOnCampaign bool
+}
+
+func DefaultSessionRules() SessionRules {
+	return SessionRules{
+		Timeout:         DefaultSessionTimeout,
+		Location:        time.UTC,
+		SplitOnCampaign: true,
+	}
+}
+
+// SessionBreak returns why event can not continue the open session, or ""
+// when it does. Events older than the last one seen continue the session as
+// long as they are on the same day.
+func SessionBreak(state *models.SessionState, event *models.Event, rules SessionRules) string {
+	location := rules.Location
+	if location == nil {
+		location = time.UTC
+	}
+
+	if event.Timestamp.Sub(state.LastSeen) > rules.Timeout {
+		return models.SessionEndTimeout
+	}
+
+	ly, lm, ld := state.LastSeen.In(location).Date()
+	ey, em, ed := event.Timestamp.In(location).Date()
+	if ly != ey || lm != em || ld != ed {
+		return models.SessionEndMidnight
+	}
+
+	if rules.SplitOnCampaign && event.EventType == "pageview" {
+		if campaign := SessionCampaign(event); campaign != "" && campaign != state.Campaign {
+			return models.SessionEndCampaign
+		}
+	}
+
+	return ""
+}
+
+// StartSession opens a session with event. The client's session ID is kept
+// unless it is missing or still names the previous session.
+func StartSession(event *models.Event, previous *models.SessionState) *models.SessionState {
+	sessionID := event.SessionID
+	if sessionID == "" || (previous != nil && previous.SessionID == sessionID) {
+		sessionID = uuid.New().String()
+	}
+
+	state := &models.SessionState{
+		SessionID: sessionID,
+		StartedAt: event.Timestamp,
+		LastSeen:  event.Timestamp,
+		Campaign:  SessionCampaign(event),
+		IsBot:     event.IsBot,
+	}
+	if event.EventType == "pageview" {
+		state.Pageviews = 1
+	}
+	return state
+}
+
+// ContinueSession records event in the open session
+func ContinueSession(state *models.SessionState, event *models.Event) {
+	if event.Timestamp.After(state.LastSeen) {
+		state.LastSeen = event.Timestamp
+	}
+	if event.EventType == "pageview" {
+		state.Pageviews++
+	}
+}
+
+// SessionCampaign identifies the UTM campaign of an event, or returns "" for
+// events without a UTM source
+func SessionCampaign(event *models.Event) string {
+	if event.UTMSource == nil || strings.TrimSpace(*event.UTMSource) == "" {
+		return ""
+	}
+	value := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return strings.ToLower(strings.TrimSpace(*s))
+	}
+	return strings.Join([]string{value(event.UTMSource), value(event.UTMMedium), value(event.UTMCampaign)}, "|")
+}
+
+// SessionStartEvent is the synthetic event opening a session, carrying the
+// page, referrer, campaign and device of the event that started it
+func SessionStartEvent(event *models.Event, state *models.SessionState) models.Event {
+	start := *event
+	start.ID = uuid.New()
+	start.SessionID = state.SessionID
+	start.EventType = models.SessionStartEventType
+	start.TimeOnPage = nil
+	start.Properties = nil
+	start.Timestamp = state.StartedAt
+	return start
+}
+
+// SessionEndEvent is the synthetic event closing a session at its last
+// activity
+func SessionEndEvent(websiteID, visitorID string, state *models.SessionState, reason string) models.Event {
+	return models.Event{
+		ID:        uuid.New(),
+		WebsiteID: websiteID,
+		VisitorID: visitorID,
+		SessionID: state.SessionID,
+		EventType: models.SessionEndEventType,
+		IsBot:     state.IsBot,
+		Properties: models.Properties{
+			"duration":  int(state.LastSeen.Sub(state.StartedAt).Seconds()),
+			"pageviews": state.Pageviews,
+			"reason":    reason,
+		},
+		Timestamp: state.LastSeen,
+		CreatedAt: time.Now(),
+	}
+}
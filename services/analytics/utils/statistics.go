@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"math"
+)
+
+// NormalCDF returns P(Z <= z) for a standard normal variable
+func NormalCDF(z float64) float64 {
+	return 0.5 * math.Erfc(-z/math.Sqrt2)
+}
+
+// NormalQuantile returns z such that P(Z <= z) = p, for 0 < p < 1
+func NormalQuantile(p float64) float64 {
+	return math.Sqrt2 * math.Erfinv(2*p-1)
+}
+
+// WilsonInterval returns the Wilson score interval of a proportion at the
+// given confidence level (e.g. 0.95). It stays inside [0, 1] and behaves
+// well for small samples and rates close to 0 or 1.
+func WilsonInterval(successes, trials int, confidence float64) (low, high float64) {
+	if trials == 0 {
+		return 0, 0
+	}
+	n := float64(trials)
+	p := float64(successes) / n
+	z := NormalQuantile(1 - (1-confidence)/2)
+
+	denominator := 1 + z*z/n
+	center := (p + z*z/(2*n)) / denominator
+	margin := z * math.Sqrt(p*(1-p)/n+z*z/(4*n*n)) / denominator
+	return math.Max(0, center-margin), math.Min(1, center+margin)
+}
+
+// TwoProportionZTest compares the success rates of two samples using the
+// pooled two-proportion z-test. z is positive when the second sample has the
+// higher rate; pValue is two-sided. ok is false when either sample is empty
+// or no test is possible because all or none of the trials succeeded.
+func TwoProportionZTest(successesA, trialsA, successesB, trialsB int) (z, pValue float64, ok bool) {
+	if trialsA == 0 || trialsB == 0 {
+		return 0, 0, false
+	}
+	nA, nB := float64(trialsA), float64(trialsB)
+	pA, pB := float64(successesA)/nA, float64(successesB)/nB
+	pooled := float64(successesA+successesB) / (nA + nB)
+
+	standardError := math.Sqrt(pooled * (1 - pooled) * (1/nA + 1/nB))
+	if standardError == 0 {
+		return 0, 0, false
+	}
+	z = (pB - pA) / standardError
+	return z, 2 * (1 - NormalCDF(math.Abs(z))), true
+}
+
+// ChiSquaredTest runs Pearson's chi-squared test of independence on a k x 2
+// table of successes and failures, one row per sample. ok is false when
+// fewer than two samples have trials or every trial had the same outcome.
+func ChiSquaredTest(successes, trials []int) (statistic, pValue float64, degreesOfFreedom int, ok bool) {
+	var totalSuccesses, totalTrials float64
+	rows := 0
+	for i := range trials {
+		if trials[i] == 0 {
+			continue
+		}
+		rows++
+		totalSuccesses += float64(successes[i])
+		totalTrials += float64(trials[i])
+	}
+	if rows < 2 || totalSuccesses == 0 || totalSuccesses == totalTrials {
+		return 0, 0, 0, false
+	}
+
+	rate := totalSuccesses / totalTrials
+	for i := range trials {
+		if trials[i] == 0 {
+			continue
+		}
+		n := float64(trials[i])
+		observed := []float64{float64(successes[i]), n - float64(successes[i])}
+		expected := []float64{n * rate, n * (1 - rate)}
+		for j := range observed {
+			diff := observed[j] - expected[j]
+			statistic += diff * diff / expected[j]
+		}
+	}
+
+	degreesOfFreedom = rows - 1
+	return statistic, ChiSquaredSurvival(statistic, float64(degreesOfFreedom)), degreesOfFreedom, true
+}
+
+// ChiSquaredSurvival returns P(X >= x) for a chi-squared distribution
+func ChiSquaredSurvival(x, degreesOfFreedom float64) float64 {
+	if x <= 0 {
+		return 1
+	}
+	return upperIncompleteGamma(degreesOfFreedom/2, x/2)
+}
+
+// upperIncompleteGamma returns the regularized upper incomplete gamma
+// function Q(a, x), using the series expansion below a+1 and a continued
+// fraction above it
+func upperIncompleteGamma(a, x float64) float64 {
+	const (
+		maxIterations = 500
+		epsilon       = 1e-14
+		tiny          = 1e-300
+	)
+	lgammaA, _ := math.Lgamma(a)
+	prefix := math.Exp(-x + a*math.Log(x) - lgammaA)
+
+	if x < a+1 {
+		sum, term := 1/a, 1/a
+		for n := 1; n < maxIterations; n++ {
+			term *= x / (a + float64(n))
+			sum += term
+			if math.Abs(term) < math.Abs(sum)*epsilon {
+				break
+			}
+		}
+		return math.Max(0, 1-sum*prefix)
+	}
+
+	// Modified Lentz's method
+	b := x + 1 - a
+	c := 1 / tiny
+	d := 1 / b
+	h := d
+	for n := 1; n < maxIterations; n++ {
+		an := -float64(n) * (float64(n) - a)
+		b += 2
+		d = an*d + b
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		c = b + an/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		d = 1 / d
+		delta := d * c
+		h *= delta
+		if math.Abs(delta-1) < epsilon {
+			break
+		}
+	}
+	return prefix * h
+}
+
+// SampleSizePerVariant returns the visitors each variant needs to detect a
+// relative lift of minimumEffect (0.1 = +10%) over baselineRate with a
+// two-sided test at significance alpha and the given power. It returns 0
+// when the baseline or effect make the question meaningless.
+func SampleSizePerVariant(baselineRate, minimumEffect, alpha, power float64) int {
+	p1 := baselineRate
+	p2 := baselineRate * (1 + minimumEffect)
+	if p1 <= 0 || p1 >= 1 || p2 <= 0 || p2 >= 1 || p1 == p2 {
+		return 0
+	}
+
+	zAlpha := NormalQuantile(1 - alpha/2)
+	zPower := NormalQuantile(power)
+	pooled := (p1 + p2) / 2
+
+	numerator := zAlpha*math.Sqrt(2*pooled*(1-pooled)) + zPower*math.Sqrt(p1*(1-p1)+p2*(1-p2))
+	return int(math.Ceil(numerator * numerator / ((p2 - p1) * (p2 - p1))))
+}
+
+// ProbabilityToBeat returns the posterior probability that variant B has a
+// higher conversion rate than A, with uniform Beta(1, 1) priors. The closed
+// form sums over B's successes, so it is exact for any sample size.
+func ProbabilityToBeat(successesA, trialsA, successesB, trialsB int) float64 {
+	alphaA := float64(successesA + 1)
+	betaA := float64(trialsA - successesA + 1)
+	alphaB := successesB + 1
+	betaB := float64(trialsB - successesB + 1)
+
+	total := 0.0
+	for i := 0; i < alphaB; i++ {
+		fi := float64(i)
+		total += math.Exp(logBeta(alphaA+fi, betaA+betaB) - math.Log(betaB+fi) -
+			logBeta(1+fi, betaB) - logBeta(alphaA, betaA))
+	}
+	return math.Max(0, math.Min(1, total))
+}
+
+func logBeta(a, b float64) float64 {
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	return la + lb - lab
+}
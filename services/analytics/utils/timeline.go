@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"sort"
+)
+
+// MergeTimeline merges timeline items from several sources into time order,
+// keeping the latest limit items. Items at the same time keep the order of
+// their sources.
+func MergeTimeline(limit int, sources ...[]models.TimelineItem) []models.TimelineItem {
+	var items []models.TimelineItem
+	for _, source := range sources {
+		items = append(items, source...)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Timestamp.Before(items[j].Timestamp)
+	})
+	if limit > 0 && len(items) > limit {
+		items = items[len(items)-limit:]
+	}
+	if items == nil {
+		items = []models.TimelineItem{}
+	}
+	return items
+}
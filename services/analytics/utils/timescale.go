@@ -3,6 +3,7 @@ package utils
 import (
 	"context"
 	"fmt"
+	"math"
 	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
@@ -17,24 +18,31 @@ func NewTimescaleDBHelper(db *pgxpool.Pool) *TimescaleDBHelper {
 	return &TimescaleDBHelper{db: db}
 }
 
-// GetHypertableStats returns statistics about hypertables
-func (h *TimescaleDBHelper) GetHypertableStats(ctx context.Context) (map[string]interface{}, error) {
+// HypertableSize is the storage used by one hypertable
+type HypertableSize struct {
+	Name                   string
+	CompressionEnabled     bool
+	Chunks                 int64
+	CompressedChunks       int64
+	TotalBytes             int64
+	BeforeCompressionBytes int64
+	AfterCompressionBytes  int64
+}
+
+// HypertableSizes returns chunk counts and sizes of every hypertable
+func (h *TimescaleDBHelper) HypertableSizes(ctx context.Context) ([]HypertableSize, error) {
 	query := `
-		SELECT 
-			hypertable_name,
-			compression_enabled,
-			num_chunks,
-			COALESCE(SUM(c.total_size_bytes), 0) as total_size_bytes,
-			COALESCE(SUM(c.compressed_size_bytes), 0) as compressed_size_bytes,
-			CASE 
-				WHEN COALESCE(SUM(c.total_size_bytes), 0) > 0 
-				THEN ROUND((1 - COALESCE(SUM(c.compressed_size_bytes), 0)::float / SUM(c.total_size_bytes)::float) * 100, 2)
-				ELSE 0 
-			END as compression_ratio
+		SELECT
+			h.hypertable_name,
+			h.compression_enabled,
+			COALESCE(h.num_chunks, 0),
+			COALESCE(cs.number_compressed_chunks, 0),
+			COALESCE(hypertable_size(format('%I.%I', h.hypertable_schema, h.hypertable_name)::regclass), 0),
+			COALESCE(cs.before_compression_total_bytes, 0),
+			COALESCE(cs.after_compression_total_bytes, 0)
 		FROM timescaledb_information.hypertables h
-		LEFT JOIN timescaledb_information.chunks c ON h.hypertable_name = c.hypertable_name
-		WHERE h.hypertable_name = $1
-		GROUP BY hypertable_name, num_chunks, compression_enabled`
+		LEFT JOIN LATERAL hypertable_compression_stats(format('%I.%I', h.hypertable_schema, h.hypertable_name)::regclass) cs ON true
+		ORDER BY h.hypertable_name`
 
 	rows, err := h.db.Query(ctx, query)
 	if err != nil {
@@ -42,27 +50,44 @@ func (h *TimescaleDBHelper) GetHypertableStats(ctx context.Context) (map[string]
 	}
 	defer rows.Close()
 
-	stats := make(map[string]interface{})
+	var sizes []HypertableSize
 	for rows.Next() {
-		var tableName string
-		var numChunks *int
-		var totalSize, compressedSize *int64
-		var compressionRatio *float64
-
-		err := rows.Scan(&tableName, &numChunks, &totalSize, &compressedSize, &compressionRatio)
+		var size HypertableSize
+		err := rows.Scan(&size.Name, &size.CompressionEnabled, &size.Chunks, &size.CompressedChunks,
+			&size.TotalBytes, &size.BeforeCompressionBytes, &size.AfterCompressionBytes)
 		if err != nil {
 			return nil, err
 		}
+		sizes = append(sizes, size)
+	}
+
+	return sizes, rows.Err()
+}
+
+// GetHypertableStats returns statistics about hypertables
+func (h *TimescaleDBHelper) GetHypertableStats(ctx context.Context) (map[string]interface{}, error) {
+	sizes, err := h.HypertableSizes(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-		stats[tableName] = map[string]interface{}{
-			"num_chunks":        numChunks,
-			"total_size_bytes":  totalSize,
-			"compressed_size":   compressedSize,
-			"compression_ratio": compressionRatio,
+	stats := make(map[string]interface{}, len(sizes))
+	for _, size := range sizes {
+		var compressionRatio float64
+		if size.BeforeCompressionBytes > 0 {
+			compressionRatio = math.Round((1-float64(size.AfterCompressionBytes)/float64(size.BeforeCompressionBytes))*10000) / 100
+		}
+		stats[size.Name] = map[string]interface{}{
+			"compression_enabled": size.CompressionEnabled,
+			"num_chunks":          size.Chunks,
+			"compressed_chunks":   size.CompressedChunks,
+			"total_size_bytes":    size.TotalBytes,
+			"compressed_size":     size.AfterCompressionBytes,
+			"compression_ratio":   compressionRatio,
 		}
 	}
 
-	return stats, rows.Err()
+	return stats, nil
 }
 
 // GetChunkInfo returns information about table chunks
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"fmt"
+	"time"
+)
+
+// MaxTrendBuckets bounds the length of an activity trends series
+const MaxTrendBuckets = 1000
+
+// DefaultTrendGranularity picks hourly buckets for ranges of up to two days,
+// daily buckets up to 90 days and weekly buckets beyond that
+func DefaultTrendGranularity(r models.DateRange) string {
+	switch days := r.Days(); {
+	case days <= 2:
+		return models.GranularityHour
+	case days <= 90:
+		return models.GranularityDay
+	default:
+		return models.GranularityWeek
+	}
+}
+
+// TrendBuckets returns the start of every bucket overlapping the range. Buckets
+// follow the wall clock of the range's timezone, matching date_trunc on
+// local time: weeks start on Monday and DST transitions make some hours and
+// days shorter or longer.
+func TrendBuckets(r models.DateRange, granularity string) ([]time.Time, error) {
+	loc := r.Location()
+	from := r.From.In(loc)
+	year, month, day := from.Date()
+
+	var bucket func(k int) time.Time
+	switch granularity {
+	case models.GranularityHour:
+		hour := from.Hour()
+		bucket = func(k int) time.Time { return time.Date(year, month, day, hour+k, 0, 0, 0, loc) }
+	case models.GranularityDay:
+		bucket = func(k int) time.Time { return time.Date(year, month, day+k, 0, 0, 0, 0, loc) }
+	case models.GranularityWeek:
+		monday := day - (int(from.Weekday())+6)%7
+		bucket = func(k int) time.Time { return time.Date(year, month, monday+7*k, 0, 0, 0, 0, loc) }
+	case models.GranularityMonth:
+		bucket = func(k int) time.Time { return time.Date(year, month+time.Month(k), 1, 0, 0, 0, 0, loc) }
+	default:
+		return nil, fmt.Errorf("unsupported granularity %q", granularity)
+	}
+
+	var buckets []time.Time
+	for k := 0; ; k++ {
+		start := bucket(k)
+		if !start.Before(r.To) {
+			break
+		}
+		// Wall-clock hours skipped by DST normalize onto the next hour
+		if len(buckets) > 0 && !start.After(buckets[len(buckets)-1]) {
+			continue
+		}
+		if len(buckets) == MaxTrendBuckets {
+			return nil, fmt.Errorf("range has more than %d %s buckets", MaxTrendBuckets, granularity)
+		}
+		buckets = append(buckets, start)
+	}
+	return buckets, nil
+}
+
+// BuildTrendBuckets lines up the counts of the current and previous range by
+// bucket position. Buckets without activity are reported as zeros.
+func BuildTrendBuckets(currentBuckets, previousBuckets []time.Time, current, previous []models.ActivityCount) []models.TrendBucket {
+	index := func(counts []models.ActivityCount) map[int64]models.ActivityCount {
+		byPeriod := make(map[int64]models.ActivityCount, len(counts))
+		for _, count := range counts {
+			byPeriod[count.Period.Unix()] = count
+		}
+		return byPeriod
+	}
+	currentCounts, previousCounts := index(current), index(previous)
+
+	buckets := make([]models.TrendBucket, 0, len(currentBuckets))
+	for i, period := range currentBuckets {
+		curr := currentCounts[period.Unix()]
+		bucket := models.TrendBucket{
+			Period:    period,
+			PageViews: curr.PageViews,
+			Visitors:  curr.Visitors,
+			Sessions:  curr.Sessions,
+		}
+		if i < len(previousBuckets) {
+			previousPeriod := previousBuckets[i]
+			prev := previousCounts[previousPeriod.Unix()]
+			bucket.PreviousPeriod = &previousPeriod
+			bucket.PreviousPageViews = prev.PageViews
+			bucket.PreviousVisitors = prev.Visitors
+			bucket.PreviousSessions = prev.Sessions
+		}
+		bucket.PageViewsChange = PercentChange(bucket.PageViews, bucket.PreviousPageViews)
+		bucket.VisitorsChange = PercentChange(bucket.Visitors, bucket.PreviousVisitors)
+		bucket.SessionsChange = PercentChange(bucket.Sessions, bucket.PreviousSessions)
+		buckets = append(buckets, bucket)
+	}
+	return buckets
+}
+
+// PercentChange returns the change from previous to current in percent, or
+// nil when there is nothing to compare against
+func PercentChange(current, previous int) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := (float64(current) - float64(previous)) * 100 / float64(previous)
+	return &change
+}
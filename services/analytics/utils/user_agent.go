@@ -39,7 +39,9 @@ func ParseUserAgent(userAgentString string) UserAgentInfo {
 
 	// Detect device type
 	var device string
-	if ua.Mobile() {
+	if ua.Bot() || BotNameFromUserAgent(userAgentString) != "" {
+		device = "bot"
+	} else if ua.Mobile() {
 		device = "mobile"
 	} else {
 		// Check if it's a tablet by looking for tablet-specific keywords in user agent
@@ -3,10 +3,66 @@ package utils
 import (
 	"analytics-app/models"
 	"errors"
+	"fmt"
+	"net"
+	"net/mail"
 	"net/url"
 	"strings"
+	"time"
 )
 
+// MaxEventClockSkew is how far in the future an event timestamp may be
+const MaxEventClockSkew = time.Hour
+
+// ValidateTrackedEvent checks an event received from a tracker against the
+// limits of the events tables, so that it is rejected up front rather than
+// failing when written
+func ValidateTrackedEvent(event *models.Event) error {
+	if event.WebsiteID == "" {
+		return errors.New("website_id is required")
+	}
+	if event.VisitorID == "" {
+		return errors.New("visitor_id is required")
+	}
+
+	limits := []struct {
+		field string
+		value string
+		max   int
+	}{
+		{"website_id", event.WebsiteID, 24},
+		{"visitor_id", event.VisitorID, 255},
+		{"session_id", event.SessionID, 255},
+		{"event_type", event.EventType, 100},
+	}
+	for _, limit := range limits {
+		if len(limit.value) > limit.max {
+			return fmt.Errorf("%s must be at most %d characters", limit.field, limit.max)
+		}
+	}
+
+	// Session events are emitted by the server when stitching sessions
+	if event.EventType == models.SessionStartEventType || event.EventType == models.SessionEndEventType {
+		return fmt.Errorf("event_type %q is reserved", event.EventType)
+	}
+
+	if event.TimeOnPage != nil && *event.TimeOnPage < 0 {
+		return errors.New("time_on_page must be non-negative")
+	}
+	if event.Timestamp.After(time.Now().Add(MaxEventClockSkew)) {
+		return errors.New("timestamp is in the future")
+	}
+
+	// Purchases are recorded as orders when stored
+	if IsOrder(event) {
+		if _, err := ParsePurchase(event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ValidateEvent validates an analytics event before processing
 func ValidateEvent(event *models.Event) error {
 	if event.WebsiteID == "" {
@@ -63,6 +119,14 @@ func ValidateFunnel(funnel *models.Funnel) error {
 		return errors.New("funnel must have at least one step")
 	}
 
+	if funnel.StepOrder != "" && funnel.StepOrder != models.FunnelStepOrderStrict && funnel.StepOrder != models.FunnelStepOrderAny {
+		return errors.New("step_order must be 'strict' or 'any'")
+	}
+
+	if funnel.ConversionWindowHours < 0 {
+		return errors.New("conversion_window_hours must be positive")
+	}
+
 	// Validate each step
 	for _, step := range funnel.Steps {
 		if step.Name == "" {
@@ -92,12 +156,225 @@ func ValidateFunnel(funnel *models.Funnel) error {
 			if step.Condition.Custom == nil || *step.Condition.Custom == "" {
 				return errors.New("step of type 'custom' requires custom condition")
 			}
+			if err := ValidateCustomCondition(*step.Condition.Custom); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateExperiment validates an experiment configuration
+func ValidateExperiment(experiment *models.Experiment) error {
+	if experiment.Name == "" {
+		return errors.New("experiment name is required")
+	}
+
+	if experiment.WebsiteID == "" {
+		return errors.New("website_id is required")
+	}
+
+	if experiment.Property == "" {
+		return errors.New("property is required")
+	}
+
+	if len(experiment.Variants) < 2 {
+		return errors.New("experiment must have at least two variants")
+	}
+
+	keys := make(map[string]bool, len(experiment.Variants))
+	for _, variant := range experiment.Variants {
+		if variant.Key == "" {
+			return errors.New("variant key is required")
+		}
+		if keys[variant.Key] {
+			return fmt.Errorf("duplicate variant key %q", variant.Key)
+		}
+		keys[variant.Key] = true
+	}
+
+	if !keys[experiment.Control] {
+		return errors.New("control must be one of the variant keys")
+	}
+
+	switch experiment.GoalType {
+	case models.ExperimentGoalEvent:
+		if experiment.GoalEvent == nil || *experiment.GoalEvent == "" {
+			return errors.New("goal of type 'event' requires goal_event")
+		}
+		if err := ValidateCustomCondition(*experiment.GoalEvent); err != nil {
+			return err
+		}
+	case models.ExperimentGoalFunnel:
+		if experiment.GoalFunnelID == nil {
+			return errors.New("goal of type 'funnel' requires goal_funnel_id")
 		}
+	default:
+		return errors.New("goal_type must be 'event' or 'funnel'")
+	}
+
+	if experiment.MinimumDetectableEffect <= 0 {
+		return errors.New("minimum_detectable_effect must be positive")
+	}
+
+	validStatuses := []string{models.ExperimentStatusDraft, models.ExperimentStatusRunning, models.ExperimentStatusStopped}
+	if !contains(validStatuses, experiment.Status) {
+		return errors.New("status must be 'draft', 'running' or 'stopped'")
 	}
 
 	return nil
 }
 
+// ValidateGoal validates a goal configuration
+func ValidateGoal(goal *models.Goal) error {
+	if goal.Name == "" {
+		return errors.New("goal name is required")
+	}
+
+	if goal.WebsiteID == "" {
+		return errors.New("website_id is required")
+	}
+
+	switch goal.Type {
+	case models.GoalTypePage:
+		if goal.PagePattern == nil || strings.TrimSpace(*goal.PagePattern) == "" {
+			return errors.New("goal of type 'page' requires page_pattern")
+		}
+	case models.GoalTypeEvent:
+		if goal.EventName == nil || *goal.EventName == "" {
+			return errors.New("goal of type 'event' requires event_name")
+		}
+		if goal.PropertyValue != nil && (goal.PropertyKey == nil || *goal.PropertyKey == "") {
+			return errors.New("property_value requires property_key")
+		}
+	default:
+		return errors.New("goal type must be 'page' or 'event'")
+	}
+
+	if goal.Value != nil && *goal.Value < 0 {
+		return errors.New("goal value must not be negative")
+	}
+
+	return nil
+}
+
+// MaxRecipients caps the email recipients of a report schedule or alert rule
+const MaxRecipients = 50
+
+// ValidateReportSchedule validates a report schedule configuration
+func ValidateReportSchedule(schedule *models.ReportSchedule) error {
+	if schedule.Name == "" {
+		return errors.New("report name is required")
+	}
+
+	if schedule.WebsiteID == "" {
+		return errors.New("website_id is required")
+	}
+
+	if _, err := NextReportRun(schedule, time.Now()); err != nil {
+		return err
+	}
+
+	if schedule.RangeDays < 1 || schedule.RangeDays > 90 {
+		return errors.New("range_days must be between 1 and 90")
+	}
+
+	switch schedule.Channel {
+	case models.ReportChannelEmail:
+		return validateRecipients(schedule.Recipients)
+	case models.ReportChannelWebhook:
+		return validateWebhook(schedule.WebhookURL, schedule.WebhookSecret)
+	default:
+		return errors.New("channel must be 'email' or 'webhook'")
+	}
+}
+
+// ValidateAlertRule validates an alert rule configuration
+func ValidateAlertRule(rule *models.AlertRule) error {
+	if rule.Name == "" {
+		return errors.New("alert name is required")
+	}
+
+	if rule.WebsiteID == "" {
+		return errors.New("website_id is required")
+	}
+
+	if rule.Metric != models.AlertMetricPageviews && rule.Metric != models.AlertMetricVisitors {
+		return errors.New("metric must be 'pageviews' or 'visitors'")
+	}
+
+	switch rule.Type {
+	case models.AlertTypeThreshold:
+		if rule.Direction != models.AlertDirectionBelow && rule.Direction != models.AlertDirectionAbove {
+			return errors.New("direction of a threshold alert must be 'below' or 'above'")
+		}
+		validBaselines := []string{models.AlertBaselineAbsolute, models.AlertBaselinePreviousHour, models.AlertBaselineSameHourLastWeek}
+		if !contains(validBaselines, rule.Baseline) {
+			return errors.New("baseline must be 'absolute', 'previous_hour' or 'same_hour_last_week'")
+		}
+		if rule.Threshold < 0 || (rule.Baseline != models.AlertBaselineAbsolute && rule.Threshold == 0) {
+			return errors.New("threshold must be positive")
+		}
+	case models.AlertTypeAnomaly:
+		validDirections := []string{models.AlertDirectionBelow, models.AlertDirectionAbove, models.AlertDirectionOutside}
+		if !contains(validDirections, rule.Direction) {
+			return errors.New("direction must be 'below', 'above' or 'outside'")
+		}
+		if rule.Sensitivity <= 0 {
+			return errors.New("sensitivity must be positive")
+		}
+		if rule.BaselineWeeks < 2 || rule.BaselineWeeks > MaxAlertBaselineWeeks {
+			return fmt.Errorf("baseline_weeks must be between 2 and %d", MaxAlertBaselineWeeks)
+		}
+	default:
+		return errors.New("alert type must be 'threshold' or 'anomaly'")
+	}
+
+	if rule.CooldownMinutes < 0 || rule.CooldownMinutes > 7*24*60 {
+		return errors.New("cooldown_minutes must be between 0 and 10080")
+	}
+
+	switch rule.Channel {
+	case models.AlertChannelEmail:
+		return validateRecipients(rule.Recipients)
+	case models.AlertChannelWebhook:
+		return validateWebhook(rule.WebhookURL, rule.WebhookSecret)
+	case models.AlertChannelSlack:
+		if rule.WebhookURL == nil || !isWebhookURL(*rule.WebhookURL) {
+			return errors.New("alert of channel 'slack' requires a public http or https webhook_url")
+		}
+		return nil
+	default:
+		return errors.New("channel must be 'email', 'webhook' or 'slack'")
+	}
+}
+
+func validateRecipients(recipients []string) error {
+	if len(recipients) == 0 {
+		return errors.New("channel 'email' requires recipients")
+	}
+	if len(recipients) > MaxRecipients {
+		return fmt.Errorf("at most %d recipients are allowed", MaxRecipients)
+	}
+	for _, recipient := range recipients {
+		if address, err := mail.ParseAddress(recipient); err != nil || address.Name != "" {
+			return fmt.Errorf("invalid recipient %q", recipient)
+		}
+	}
+	return nil
+}
+
+func validateWebhook(webhookURL, secret *string) error {
+	if webhookURL == nil || !isWebhookURL(*webhookURL) {
+		return errors.New("channel 'webhook' requires a public http or https webhook_url")
+	}
+	if secret == nil || len(*secret) < 16 {
+		return errors.New("channel 'webhook' requires a webhook_secret of at least 16 characters")
+	}
+	return nil
+}
+
 // Helper functions
 func isValidURL(urlString string) bool {
 	// Allow relative URLs for pages
@@ -114,6 +391,46 @@ func isValidURL(urlString string) bool {
 	return u.Scheme != "" && u.Host != ""
 }
 
+// ValidateCustomMetric validates a custom metric and its expression
+func ValidateCustomMetric(metric *models.CustomMetric) error {
+	if metric.Name == "" {
+		return errors.New("metric name is required")
+	}
+
+	if metric.WebsiteID == "" {
+		return errors.New("website_id is required")
+	}
+
+	validFormats := []string{models.CustomMetricFormatNumber, models.CustomMetricFormatPercentage, models.CustomMetricFormatCurrency}
+	if !contains(validFormats, metric.Format) {
+		return errors.New("format must be 'number', 'percentage' or 'currency'")
+	}
+
+	if _, err := ParseMetricExpression(metric.Expression); err != nil {
+		return fmt.Errorf("invalid expression: %w", err)
+	}
+
+	return nil
+}
+
+// isWebhookURL accepts http(s) URLs unless their host is localhost or a
+// literal non-public IP. Hosts resolving to such addresses are refused by the
+// WebhookSender when delivering.
+func isWebhookURL(urlString string) bool {
+	u, err := url.Parse(urlString)
+	if err != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Hostname() == "" {
+		return false
+	}
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil && !IsPublicIP(ip) {
+		return false
+	}
+	return true
+}
+
 func contains(slice []string, item string) bool {
 	for _, s := range slice {
 		if s == item {
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"analytics-app/models"
+	"time"
+)
+
+// SeenVisitor is the first and last pageview of a visitor
+type SeenVisitor struct {
+	WebsiteID string
+	VisitorID string
+	FirstSeen time.Time
+	LastSeen  time.Time
+}
+
+// SeenVisitors returns the first and last pageviews of each visitor of
+// events, in order of appearance. Bot traffic is left out, so a visitor
+// whose first hits were flagged is first seen at their first human pageview.
+func SeenVisitors(events []models.Event) []SeenVisitor {
+	type key struct{ websiteID, visitorID string }
+	index := make(map[key]int)
+
+	var visitors []SeenVisitor
+	for _, event := range events {
+		if event.EventType != "pageview" || event.VisitorID == "" || event.IsBot {
+			continue
+		}
+		k := key{event.WebsiteID, event.VisitorID}
+		i, ok := index[k]
+		if !ok {
+			index[k] = len(visitors)
+			visitors = append(visitors, SeenVisitor{
+				WebsiteID: event.WebsiteID,
+				VisitorID: event.VisitorID,
+				FirstSeen: event.Timestamp,
+				LastSeen:  event.Timestamp,
+			})
+			continue
+		}
+		if event.Timestamp.Before(visitors[i].FirstSeen) {
+			visitors[i].FirstSeen = event.Timestamp
+		}
+		if event.Timestamp.After(visitors[i].LastSeen) {
+			visitors[i].LastSeen = event.Timestamp
+		}
+	}
+	return visitors
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// Headers of webhook deliveries
+const (
+	WebhookEventHeader     = "X-Seentics-Event"
+	WebhookTimestampHeader = "X-Seentics-Timestamp"
+	WebhookSignatureHeader = "X-Seentics-Signature"
+)
+
+// ErrWebhookAddress is returned for deliveries to addresses that are not
+// public, such as loopback, private and link-local ones
+var ErrWebhookAddress = errors.New("webhook address is not public")
+
+// WebhookSender posts JSON payloads signed with a shared secret. Webhook URLs
+// are chosen by users, so the sender only connects to public addresses, checked
+// after the host is resolved, and does not follow redirects.
+type WebhookSender struct {
+	client       *http.Client
+	allowPrivate bool
+}
+
+func NewWebhookSender(timeout time.Duration) *WebhookSender {
+	s := &WebhookSender{}
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: s.checkAddress}
+	s.client = &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 10 * time.Second,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	return s
+}
+
+// AllowPrivateAddresses lets the sender connect to any address, for tests and
+// receivers on the local network.
+func (s *WebhookSender) AllowPrivateAddresses() *WebhookSender {
+	s.allowPrivate = true
+	return s
+}
+
+// checkAddress runs before each connection, with the resolved address, so
+// hosts resolving to internal addresses are refused too.
+func (s *WebhookSender) checkAddress(network, address string, _ syscall.RawConn) error {
+	if s.allowPrivate {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrWebhookAddress, host)
+	}
+	return nil
+}
+
+// IsPublicIP reports whether ip is a globally routable unicast address
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	for _, network := range nonPublicNetworks {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// nonPublicNetworks are the reserved ranges net.IP has no method for
+var nonPublicNetworks = func() []*net.IPNet {
+	var networks []*net.IPNet
+	for _, cidr := range []string{
+		"0.0.0.0/8",      // "This" network
+		"100.64.0.0/10",  // Carrier-grade NAT
+		"192.0.0.0/24",   // IETF protocol assignments
+		"198.18.0.0/15",  // Benchmarking
+		"240.0.0.0/4",    // Reserved
+		"64:ff9b::/96",   // IPv4/IPv6 translation
+		"64:ff9b:1::/48", // Local IPv4/IPv6 translation
+	} {
+		_, network, _ := net.ParseCIDR(cidr)
+		networks = append(networks, network)
+	}
+	return networks
+}()
+
+// Send posts payload to url as an event. Responses other than 2xx fail the
+// delivery.
+func (s *WebhookSender) Send(ctx context.Context, url, secret, event string, payload interface{}) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	return s.post(ctx, url, body, map[string]string{
+		WebhookEventHeader:     event,
+		WebhookTimestampHeader: timestamp,
+		WebhookSignatureHeader: SignWebhook(secret, timestamp, body),
+	})
+}
+
+// SendSlack posts text to a Slack-compatible incoming webhook as
+// {"text": ...}. Such webhooks authenticate by their URL and are not signed.
+func (s *WebhookSender) SendSlack(ctx context.Context, url, text string) error {
+	body, err := json.Marshal(map[string]string{"text": text})
+	if err != nil {
+		return err
+	}
+	return s.post(ctx, url, body, nil)
+}
+
+func (s *WebhookSender) post(ctx context.Context, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Seentics-Analytics/1.0")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// SignWebhook returns the signature of a delivery: the hex HMAC-SHA256 of
+// "{timestamp}.{body}" keyed with the secret, prefixed with "sha256=".
+// Receivers recompute it and reject old timestamps to stop replays.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}